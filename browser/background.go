@@ -0,0 +1,145 @@
+package browser
+
+import (
+	"github.com/psilva261/opossum/browser/duitx"
+	"github.com/psilva261/opossum/img"
+	"github.com/psilva261/opossum/logger"
+	"github.com/psilva261/opossum/nodes"
+	"github.com/psilva261/opossum/style"
+	"golang.org/x/net/html"
+	"image"
+	"math"
+)
+
+// duitBackgroundLayers returns the background layers of n with images
+// or gradients. Images which are not loaded yet are set once they
+// arrive. Layers whose image cannot be interpreted or loaded are
+// omitted.
+func duitBackgroundLayers(n *nodes.Node) (ls []duitx.BackgroundLayer) {
+	loading := make(map[int]string)
+	for _, l := range n.BackgroundLayers() {
+		dl := duitx.BackgroundLayer{
+			RepeatX: duitRepeat(l.RepeatX),
+			RepeatY: duitRepeat(l.RepeatY),
+			X:       duitLength(l.X),
+			Y:       duitLength(l.Y),
+			W:       duitLength(l.W),
+			H:       duitLength(l.H),
+			Origin:  duitArea(l.Origin),
+			Clip:    duitArea(l.Clip),
+		}
+		if src, ok := l.Url(); ok {
+			i, cached := cachedBackground(src)
+			if cached && i == nil {
+				continue
+			}
+			dl.Image = i
+			if !cached {
+				loading[len(ls)] = src
+			}
+		} else if g, ok := n.ParseGradient(l.Image); ok {
+			dl.Paint = func(sz image.Point) image.Image { return g.Render(sz) }
+		} else {
+			log.Printf("bg img: cannot interpret %v", l.Image)
+			continue
+		}
+		switch l.Size {
+		case "cover":
+			dl.Size = duitx.SizeCover
+		case "contain":
+			dl.Size = duitx.SizeContain
+		}
+		ls = append(ls, dl)
+	}
+	for i, src := range loading {
+		loadBackground(src, &ls[i])
+	}
+	return
+}
+
+// cachedBackground returns the decoded image src if it was loaded
+// already, nil if that failed.
+func cachedBackground(src string) (i image.Image, ok bool) {
+	imageMu.Lock()
+	defer imageMu.Unlock()
+	i, ok = bgCache[src]
+	return
+}
+
+// loadBackground decodes the image src in the background and sets it
+// as the image of l once it arrives. The page is laid out again then.
+// Like with loadImage every src is loaded only once.
+func loadBackground(src string, l *duitx.BackgroundLayer) {
+	imageMu.Lock()
+	defer imageMu.Unlock()
+	if ls, ok := bgWaiting[src]; ok {
+		bgWaiting[src] = append(ls, l)
+		return
+	}
+	bgWaiting[src] = []*duitx.BackgroundLayer{l}
+	ctx := browser.Ctx()
+	go func() {
+		i, err := img.Decode(imageFetcher{browser}, src)
+		if err != nil {
+			log.Errorf("bg img load %v: %v", src, err)
+			i = nil
+		}
+		dui.Call <- func() {
+			if ctx.Err() != nil {
+				return
+			}
+			imageMu.Lock()
+			ls := bgWaiting[src]
+			delete(bgWaiting, src)
+			bgCache[src] = i
+			imageMu.Unlock()
+			if i == nil {
+				return
+			}
+			for _, l := range ls {
+				l.Image = i
+			}
+			dui.MarkLayout(dui.Top.UI)
+			dui.MarkDraw(dui.Top.UI)
+			dui.Render()
+		}
+	}()
+}
+
+func duitRepeat(r string) duitx.Repeat {
+	switch r {
+	case "no-repeat":
+		return duitx.RepeatNone
+	case "space":
+		return duitx.RepeatSpace
+	case "round":
+		return duitx.RepeatRound
+	}
+	return duitx.RepeatTile
+}
+
+func duitLength(l style.BackgroundLength) duitx.Length {
+	return duitx.Length{Px: int(math.Round(l.Px)), Percent: l.Percent, Auto: l.Auto}
+}
+
+func duitArea(a string) duitx.Area {
+	switch a {
+	case "padding-box":
+		return duitx.PaddingBox
+	case "content-box":
+		return duitx.ContentBox
+	}
+	return duitx.BorderBox
+}
+
+// emptyBox returns an element for n without content if it has a size
+// and a background, e.g. an icon from a sprite.
+func emptyBox(n *nodes.Node) *Element {
+	if n.Type() != html.ElementNode || n.Width() <= 0 || n.Height() <= 0 {
+		return nil
+	}
+	if n.Css("background") == "" && n.Css("background-color") == "" && n.Css("background-image") == "" {
+		return nil
+	}
+	return NewElement(&duitx.Box{}, n)
+}
@@ -0,0 +1,53 @@
+package browser
+
+import (
+	"github.com/psilva261/opossum/browser/duitx"
+	"github.com/psilva261/opossum/nodes"
+	"github.com/psilva261/opossum/style"
+)
+
+// duitBorders returns the borders and radius of n.
+func duitBorders(n *nodes.Node) (bs duitx.Borders) {
+	sbs := n.Borders()
+	bs.Top = duitBorder(sbs.Top)
+	bs.Right = duitBorder(sbs.Right)
+	bs.Bottom = duitBorder(sbs.Bottom)
+	bs.Left = duitBorder(sbs.Left)
+	bs.Radius = n.BorderRadius()
+	return
+}
+
+func duitOutline(n *nodes.Node) (b duitx.Border, offset int) {
+	o, offset := n.Outline()
+	return duitBorder(o), offset
+}
+
+func duitShadow(n *nodes.Node) *duitx.Shadow {
+	s, ok := n.BoxShadow()
+	if !ok {
+		return nil
+	}
+	ds := duitx.Shadow(s)
+	return &ds
+}
+
+// duitBorder maps the 3D styles to solid.
+func duitBorder(b style.Border) duitx.Border {
+	var st duitx.BorderStyle
+	switch b.Style {
+	case "none", "hidden":
+		return duitx.Border{}
+	case "dashed":
+		st = duitx.BorderDashed
+	case "dotted":
+		st = duitx.BorderDotted
+	case "double":
+		st = duitx.BorderDouble
+	default:
+		st = duitx.BorderSolid
+	}
+	if b.Width <= 0 {
+		return duitx.Border{}
+	}
+	return duitx.Border{Width: b.Width, Style: st, Color: b.Color}
+}
@@ -26,6 +26,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 
 	"github.com/mjl-/duit"
@@ -61,6 +63,9 @@ var cursor = [16 * 2]uint8{
 var (
 	ExperimentalJsInsecure bool
 	EnableNoScriptTag      bool
+
+	// DisableAnimations shows only the first frame of animated images.
+	DisableAnimations bool
 )
 
 var (
@@ -75,9 +80,20 @@ var (
 	fromLabel *duitx.Label
 
 	colorCache = make(map[draw.Color]*draw.Image)
-	imageCache = make(map[string]*draw.Image)
+
+	imageMu      sync.Mutex
+	imageCache   = make(map[imageKey]*img.Frames)
+	imageWaiting = make(map[imageKey][]*Image)
+	bgCache      = make(map[string]image.Image)
+	bgWaiting    = make(map[string][]*duitx.BackgroundLayer)
 )
 
+// imageKey identifies images loaded with the same size.
+type imageKey struct {
+	src string
+	o   img.Options
+}
+
 type Label struct {
 	*duitx.Label
 
@@ -85,39 +101,71 @@ type Label struct {
 }
 
 func NewLabel(t string, n *nodes.Node) *Label {
+	l := &duitx.Label{
+		Text: n.TextTransform(t),
+		Font: n.Font(),
+	}
+	styleLabel(l, n)
 	return &Label{
-		Label: &duitx.Label{
-			Text: t + " ",
-			Font: n.Font(),
-		},
-		n: n,
+		Label: l,
+		n:     n,
 	}
 }
 
+// NewText returns labels for the text content with white space
+// already processed. Wrapping text is split into words, preserved
+// newlines force line breaks.
 func NewText(content []string, n *nodes.Node) (el []*Element) {
-	tt := strings.Join(content, " ")
-
-	// '\n' is nowhere visible
-	tt = strings.Replace(tt, "\n", " ", -1)
-
-	ts := strings.Split(tt, " ")
-	ls := make([]*Element, 0, len(ts))
+	tt := strings.Join(content, "")
+	_, newlines, wrap := n.WhiteSpace()
+	breakWord := n.BreakWord()
+
+	lines := []string{tt}
+	if newlines {
+		lines = strings.Split(tt, "\n")
+	}
+	ls := make([]*Element, 0, strings.Count(tt, " ")+len(lines))
+
+	for i, line := range lines {
+		br := i < len(lines)-1
+		var ts []string
+		if wrap {
+			ts = words(line)
+		} else if line != "" {
+			ts = []string{line}
+		}
+		if len(ts) == 0 && br {
+			// empty line
+			ts = []string{" "}
+		}
+		for j, t := range ts {
+			l := NewLabel(t, n)
+			l.LineBreak = br && j == len(ts)-1
+			l.BreakWord = breakWord
+			ls = append(ls, &Element{
+				UI: l,
+				n:  n,
+			})
+		}
+	}
 
-	for _, t := range ts {
-		t = strings.TrimSpace(t)
+	return ls
+}
 
-		if t == "" {
-			continue
+// words splits s after each sequence of spaces.
+func words(s string) (ws []string) {
+	for s != "" {
+		i := strings.IndexByte(s, ' ')
+		if i < 0 {
+			return append(ws, s)
 		}
-
-		l := &Element{
-			UI: NewLabel(t, n),
-			n:  n,
+		for i < len(s) && s[i] == ' ' {
+			i++
 		}
-		ls = append(ls, l)
+		ws = append(ws, s[:i])
+		s = s[i:]
 	}
-
-	return ls
+	return
 }
 
 func (ui *Label) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
@@ -147,12 +195,24 @@ func NewCodeView(s string, n style.Map) (cv *CodeView) {
 	edit := &duit.Edit{
 		Font: Style.Font(),
 	}
-	lines := len(strings.Split(s, "\n"))
+	lines := strings.Split(s, "\n")
 	edit.Append([]byte(s))
-	cv.UI = &duitx.Box{
+	box := &duitx.Box{
 		Kids:   duit.NewKids(edit),
-		Height: int(n.FontHeight()) * (lines + 2),
+		Height: int(n.FontHeight()) * (len(lines) + 2),
+	}
+	// scroll long lines horizontally
+	scroll := duitx.NewNestedScroll(box, 0, true)
+	if f := edit.Font; f != nil && display != nil {
+		w := 0
+		for _, l := range lines {
+			if lw := f.StringWidth(l); lw > w {
+				w = lw
+			}
+		}
+		scroll.ContentWidth = int(float64(w+2*f.Height) * n.FontHeight() / float64(f.Height))
 	}
+	cv.UI = scroll
 	return
 }
 
@@ -168,6 +228,92 @@ type Image struct {
 	*duit.Image
 
 	src string
+
+	// size reserved while the image is loading
+	size image.Point
+
+	// lazy is the image to load once it is about to become visible
+	lazy *imageKey
+
+	// image and rectangle drawn on last
+	drawnImg *draw.Image
+	drawnR   image.Rectangle
+
+	ctx    context.Context
+	frames *img.Frames
+	cur    int
+	timer  *time.Timer
+}
+
+func (ui *Image) setFrames(fr *img.Frames) {
+	ui.frames = fr
+	ui.cur = 0
+	ui.Image.Image = fr.Images[0]
+}
+
+func (ui *Image) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, force bool) {
+	if ui.Image.Image == nil {
+		self.R = image.Rectangle{Max: ui.size}
+		return
+	}
+	ui.Image.Layout(dui, self, sizeAvail, force)
+}
+
+// Draw the current frame. The next frame of animations is only
+// scheduled when the image is within the clipping rectangle, i.e.
+// visible. Lazy images start loading then. Since the scroll draws
+// tiles around the viewport in advance, this happens shortly before
+// they are scrolled into view.
+func (ui *Image) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
+	r := image.Rectangle{Max: self.R.Size()}.Add(orig)
+	ui.drawnImg, ui.drawnR = img, r
+	if ui.lazy != nil && visible(r, img.Clipr) {
+		k := *ui.lazy
+		ui.lazy = nil
+		loadImage(k, ui)
+	}
+	ui.Image.Draw(dui, self, img, orig, m, force)
+	if ui.frames == nil || len(ui.frames.Images) < 2 || ui.timer != nil || DisableAnimations {
+		return
+	}
+	if r.Overlaps(img.Clipr) {
+		ui.timer = time.AfterFunc(ui.frames.Delays[ui.cur], func() {
+			dui.Call <- ui.next
+		})
+	}
+}
+
+func (ui *Image) Drawn() (img *draw.Image, r image.Rectangle) {
+	return ui.drawnImg, ui.drawnR
+}
+
+// visible is true if r overlaps clip. Empty rectangles like the
+// placeholders of images with unknown size are visible if their
+// position is within clip.
+func visible(r, clip image.Rectangle) bool {
+	if r.Empty() {
+		return r.Min.In(clip)
+	}
+	return r.Overlaps(clip)
+}
+
+// next shows the next frame of the animation.
+func (ui *Image) next() {
+	ui.timer = nil
+	if ui.ctx != nil && ui.ctx.Err() != nil {
+		return
+	}
+	ui.cur = (ui.cur + 1) % len(ui.frames.Images)
+	ui.Image.Image = ui.frames.Images[ui.cur]
+	dui.MarkDraw(ui)
+	dui.Render()
+}
+
+func (ui *Image) Mark(self *duit.Kid, o duit.UI, forLayout bool) (marked bool) {
+	if o == duit.UI(ui) {
+		return self.Mark(self.UI, forLayout)
+	}
+	return ui.Image.Mark(self, o, forLayout)
 }
 
 func NewImage(n *nodes.Node) duit.UI {
@@ -180,8 +326,6 @@ func NewImage(n *nodes.Node) duit.UI {
 }
 
 func newImage(n *nodes.Node) (ui duit.UI, err error) {
-	var i *draw.Image
-	var cached bool
 	src := attr(*n.DomSubtree, "src")
 	log.Printf("newImage: src: %v", src)
 
@@ -202,15 +346,27 @@ func newImage(n *nodes.Node) (ui duit.UI, err error) {
 			return nil, fmt.Errorf("serialize: %w", err)
 		}
 		log.Printf("newImage: xml: %v", xml)
-		i, err = img.Svg(dui, xml, dui.Scale(n.Width()), dui.Scale(n.Height()))
+		i, err := img.Svg(dui, xml, dui.Scale(n.Width()), dui.Scale(n.Height()))
 		if err != nil {
 			return nil, fmt.Errorf("img svg %v: %v", xml, err)
 		}
-		goto img_elem
-	} else if n.Data() == "img" {
-		_, s := srcSet(n)
-		if s != "" {
+		return NewElement(
+			&Image{
+				Image: &duit.Image{
+					Image: i,
+				},
+				src: src,
+			},
+			n,
+		), nil
+	}
+	density := 1
+	if n.Data() == "img" {
+		if _, x, s := srcSet(n); s != "" {
 			src = s
+			if x > 0 {
+				density = x
+			}
 		}
 	}
 
@@ -218,28 +374,128 @@ func newImage(n *nodes.Node) (ui duit.UI, err error) {
 		return nil, fmt.Errorf("no src in %+v", n.DomSubtree.Attr)
 	}
 
-	if i, cached = imageCache[src]; !cached {
-		mw, _ := n.CssPx("max-width")
-		mw = dui.Scale(mw)
-		w := dui.Scale(n.Width())
-		h := dui.Scale(n.Height())
-		i, err = img.Load(dui, browser, src, mw, w, h, false)
-		if err != nil {
-			return nil, fmt.Errorf("load image: %w", err)
+	mw, _ := n.CssPx("max-width")
+	fit, x, y := n.ObjectFit()
+	k := imageKey{
+		src: src,
+		o: img.Options{
+			MaxW:  dui.Scale(mw),
+			W:     dui.Scale(n.Width()),
+			H:     dui.Scale(n.Height()),
+			Scale: float64(dui.Scale(1)) / float64(density),
+			Fit: img.Fit{
+				Mode: fit,
+				X:    x.Percent,
+				Y:    y.Percent,
+				OffX: dui.Scale(int(math.Round(x.Px))),
+				OffY: dui.Scale(int(math.Round(y.Px))),
+			},
+		},
+	}
+	im := &Image{
+		Image: &duit.Image{},
+		src:   src,
+		size:  placeholderSize(n, k.o.W, k.o.H),
+		ctx:   browser.Ctx(),
+	}
+	if fr, ok := cachedImage(k); ok {
+		im.setFrames(fr)
+	} else if n.Attr("loading") == "eager" {
+		loadImage(k, im)
+	} else {
+		im.lazy = &k
+	}
+	return NewElement(im, n), nil
+}
+
+// placeholderSize returns the size reserved for an image of w x h
+// device pixels while it is loading. If only one of them is known, the
+// other is derived from aspect-ratio or the width and height
+// attributes.
+func placeholderSize(n *nodes.Node, w, h int) image.Point {
+	if (w == 0) == (h == 0) {
+		return image.Pt(w, h)
+	}
+	r, ok := n.AspectRatio()
+	if !ok {
+		aw, errW := strconv.Atoi(strings.TrimSuffix(n.Attr("width"), "px"))
+		ah, errH := strconv.Atoi(strings.TrimSuffix(n.Attr("height"), "px"))
+		if errW != nil || errH != nil || aw <= 0 || ah <= 0 {
+			return image.Pt(w, h)
 		}
-		imageCache[src] = i
+		r = float64(aw) / float64(ah)
 	}
+	if h == 0 {
+		h = int(math.Round(float64(w) / r))
+	} else {
+		w = int(math.Round(float64(h) * r))
+	}
+	return image.Pt(w, h)
+}
 
-img_elem:
-	return NewElement(
-		&Image{
-			Image: &duit.Image{
-				Image: i,
-			},
-			src: src,
-		},
-		n,
-	), nil
+func cachedImage(k imageKey) (fr *img.Frames, ok bool) {
+	imageMu.Lock()
+	defer imageMu.Unlock()
+	fr, ok = imageCache[k]
+	return
+}
+
+// loadImage in the background and show it in ui once it arrives. The
+// page is laid out again then. Images of the same src and size are
+// loaded only once, later requests use the cache.
+func loadImage(k imageKey, ui *Image) {
+	imageMu.Lock()
+	defer imageMu.Unlock()
+	if uis, ok := imageWaiting[k]; ok {
+		imageWaiting[k] = append(uis, ui)
+		return
+	}
+	imageWaiting[k] = []*Image{ui}
+	ctx := browser.Ctx()
+	go func() {
+		fr, cached := cachedImage(k)
+		var err error
+		if !cached {
+			fr, err = decodeImage(k)
+		}
+		dui.Call <- func() {
+			if ctx.Err() != nil {
+				return
+			}
+			imageMu.Lock()
+			uis := imageWaiting[k]
+			delete(imageWaiting, k)
+			if err == nil {
+				imageCache[k] = fr
+			}
+			imageMu.Unlock()
+			if err != nil {
+				return
+			}
+			for _, ui := range uis {
+				ui.setFrames(fr)
+			}
+			dui.MarkLayout(dui.Top.UI)
+			dui.MarkDraw(dui.Top.UI)
+			dui.Render()
+		}
+	}()
+}
+
+// decodeImage loads all frames of k unless animations are disabled.
+func decodeImage(k imageKey) (fr *img.Frames, err error) {
+	if DisableAnimations {
+		var i *draw.Image
+		if i, err = img.Load(dui, imageFetcher{browser}, k.src, k.o, true); err == nil {
+			fr = &img.Frames{Images: []*draw.Image{i}}
+		}
+	} else {
+		fr, err = img.LoadFrames(dui, imageFetcher{browser}, k.src, k.o)
+	}
+	if err != nil {
+		log.Errorf("load image %v: %v", k.src, err)
+	}
+	return
 }
 
 func newPicture(n *nodes.Node) string {
@@ -247,7 +503,7 @@ func newPicture(n *nodes.Node) string {
 	smallestW := 0
 
 	for _, source := range n.FindAll("source") {
-		w, src := srcSet(source)
+		w, _, src := srcSet(source)
 		if src != "" && (smallestImg == "" || smallestW > w) {
 			smallestImg = src
 			smallestW = w
@@ -257,7 +513,9 @@ func newPicture(n *nodes.Node) string {
 	return smallestImg
 }
 
-func srcSet(n *nodes.Node) (w int, src string) {
+// srcSet returns the candidate of the srcset attribute matching the
+// display density x or the one closest to the width w of n.
+func srcSet(n *nodes.Node) (w, x int, src string) {
 	bestImg := ""
 	bestW := 0
 	idealW := n.Width()
@@ -280,8 +538,11 @@ func srcSet(n *nodes.Node) (w int, src string) {
 		if len(tmp) == 2 {
 			s = tmp[1]
 		}
-		if s == "" || s == fmt.Sprintf("%vx", scale) {
-			return 0, src
+		if s == "" {
+			return 0, 1, src
+		}
+		if s == fmt.Sprintf("%vx", scale) {
+			return 0, scale, src
 		}
 		s = strings.TrimSuffix(s, "w")
 		w, err := strconv.Atoi(s)
@@ -294,7 +555,7 @@ func srcSet(n *nodes.Node) (w int, src string) {
 		}
 	}
 
-	return bestW, bestImg
+	return bestW, 0, bestImg
 }
 
 type Element struct {
@@ -305,8 +566,10 @@ type Element struct {
 	Click   func() duit.Event
 	Changed func(*Element)
 
-	m    draw.Mouse
-	rect image.Rectangle
+	m       draw.Mouse
+	rect    image.Rectangle
+	stack   duitx.StackingContext // if it has a z-index
+	wrapped bool                  // within an outer element of n
 }
 
 func NewElement(ui duit.UI, n *nodes.Node) *Element {
@@ -331,6 +594,7 @@ func NewElement(ui duit.UI, n *nodes.Node) *Element {
 		UI: ui,
 		n:  n,
 	}
+	markWrapped(ui, n)
 	n.Rectangular = el
 	return el
 }
@@ -392,23 +656,50 @@ func newBoxElement(n *nodes.Node, force bool, uis ...duit.UI) (box *duitx.Box, o
 		}
 	}
 
-	if w == 0 && h == 0 && mw == 0 && i == nil && m == zs && p == zs && !force {
+	var bs duitx.Borders
+	var ol duitx.Border
+	var olOff int
+	var sh *duitx.Shadow
+	var tr float64
+	var ls []duitx.BackgroundLayer
+	if n != nil && n.Type() == html.ElementNode {
+		bs = duitBorders(n)
+		ol, olOff = duitOutline(n)
+		sh = duitShadow(n)
+		tr = 1 - n.Opacity()
+		ls = duitBackgroundLayers(n)
+	}
+
+	if w == 0 && h == 0 && mw == 0 && i == nil && m == zs && p == zs && bs == (duitx.Borders{}) && ol.Width == 0 && sh == nil && tr == 0 && len(ls) == 0 && !clips(n) && !force {
 		return nil, false
 	}
 
 	contentBox := n == nil || n.Css("box-sizing") != "border-box"
 	box = &duitx.Box{
-		Kids:       duit.NewKids(uis...),
-		Width:      w,
-		Height:     h,
-		MaxWidth:   mw,
-		ContentBox: contentBox,
-		Background: i,
-		Margin:     m,
-		Padding:    p,
-		Dir:        duitFlexDir(n),
-		Disp:       duitDisplay(n),
-	}
+		Kids:          duit.NewKids(uis...),
+		Width:         w,
+		Height:        h,
+		MaxWidth:      mw,
+		ContentBox:    contentBox,
+		Background:    i,
+		Border:        bs,
+		Outline:       ol,
+		OutlineOffset: olOff,
+		Shadow:        sh,
+		Transparency:  tr,
+		Layers:        ls,
+		Margin:        m,
+		Padding:       p,
+		Dir:           duitFlexDir(n),
+		Disp:          duitDisplay(n),
+		Flex:          duitFlexContainer(n),
+		Floating:      duitFloat(n),
+		Clearing:      duitClear(n),
+		BFC:           isBFC(n),
+		TextAlign:     duitTextAlign(n),
+		NoWrap:        noWrap(n),
+	}
+	overflow(n, box)
 
 	return box, true
 }
@@ -425,6 +716,21 @@ func (el *Element) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig ima
 	if el == nil {
 		return
 	}
+	if isFixed(el.n) && el.outermost() {
+		// drawn by the surrounding Scroll relative to its viewport
+		return
+	}
+	if z, _ := el.ZIndex(); z != 0 {
+		// drawn by the stacking context
+		return
+	}
+	el.draw(dui, self, img, orig, m, force)
+}
+
+func (el *Element) draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
+	orig = orig.Add(el.relOffset(dui))
+	el.stack.DrawBelow(dui, img, orig, m)
+	defer el.stack.DrawAbove(dui, img, orig, m)
 
 	// It would be possible to avoid flickers under certain circumstances
 	// of overlapping elements but the load for this is high:
@@ -439,7 +745,7 @@ func (el *Element) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig ima
 			X: dui.Scale(box.Width),
 			Y: dui.Scale(box.Height),
 		}
-		duit.KidsDraw(dui, self, box.Kids, uiSize, box.Background, img, orig, m, force)
+		box.DrawSize(dui, self, img, orig, m, force, uiSize)
 	} else {
 		el.UI.Draw(dui, self, img, orig, m, force)
 	}
@@ -475,6 +781,9 @@ func (el *Element) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point,
 	}
 
 	el.rect = self.R
+	if _, ok := el.ZIndex(); ok {
+		el.stack.Layout(dui, self)
+	}
 
 	return
 }
@@ -564,6 +873,36 @@ func NewInputField(n *nodes.Node) *Element {
 	return NewElement(f, n)
 }
 
+func NewCheckbox(n *nodes.Node) *Element {
+	cb := &duit.Checkbox{
+		Checked:  n.HasAttr("checked"),
+		Disabled: n.HasAttr("disabled"),
+		Font:     n.Font(),
+	}
+	cb.Changed = func() duit.Event {
+		browser.Website.check(n.DomSubtree, cb.Checked)
+		return duit.Event{
+			Consumed: true,
+		}
+	}
+	return NewElement(cb, n)
+}
+
+func NewRadio(n *nodes.Node) *Element {
+	rb := &duit.Radiobutton{
+		Selected: n.HasAttr("checked"),
+		Disabled: n.HasAttr("disabled"),
+		Font:     n.Font(),
+	}
+	rb.Changed = func(interface{}) duit.Event {
+		browser.Website.check(n.DomSubtree, true)
+		return duit.Event{
+			Consumed: true,
+		}
+	}
+	return NewElement(rb, n)
+}
+
 func NewSelect(n *nodes.Node) *Element {
 	var l *duit.List
 	l = &duit.List{
@@ -648,6 +987,50 @@ func (el *Element) FlexDir() duitx.Dir {
 	return duitFlexDir(n)
 }
 
+func (el *Element) FlexItem() duitx.FlexItem {
+	if el == nil {
+		return duitx.DefaultFlexItem
+	}
+	return duitFlexItem(el.n)
+}
+
+func (el *Element) Float() duitx.Float {
+	if el == nil {
+		return duitx.FloatNone
+	}
+	return duitFloat(el.n)
+}
+
+func (el *Element) Clear() duitx.Clear {
+	if el == nil {
+		return duitx.ClearNone
+	}
+	return duitClear(el.n)
+}
+
+func (el *Element) IsBFC() bool {
+	return el != nil && isBFC(el.n)
+}
+
+func (el *Element) Exclude(ex []duitx.Exclusion) {
+	if el == nil {
+		return
+	}
+	if e, ok := el.UI.(duitx.Excluder); ok {
+		e.Exclude(ex)
+	}
+}
+
+func (el *Element) Floats() []duitx.Exclusion {
+	if el == nil {
+		return nil
+	}
+	if e, ok := el.UI.(duitx.Excluder); ok {
+		return e.Floats()
+	}
+	return nil
+}
+
 func (el *Element) Key(dui *duit.DUI, self *duit.Kid, k rune, m draw.Mouse, orig image.Point) (r duit.Result) {
 	r = el.UI.Key(dui, self, k, m, orig)
 
@@ -659,6 +1042,14 @@ func (el *Element) Key(dui *duit.DUI, self *duit.Kid, k rune, m draw.Mouse, orig
 }
 
 func (el *Element) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) (r duit.Result) {
+	if z, _ := el.ZIndex(); z != 0 {
+		// delivered by the stacking context
+		return
+	}
+	return el.mouse(dui, self, m, origM, orig)
+}
+
+func (el *Element) mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) (r duit.Result) {
 	if m.Buttons == 4 {
 		if el == nil {
 			log.Infof("inspect nil element")
@@ -671,6 +1062,10 @@ func (el *Element) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw
 	if el == nil {
 		return
 	}
+	if off := el.relOffset(dui); off != image.ZP {
+		m.Point = m.Point.Sub(off)
+		origM.Point = origM.Point.Sub(off)
+	}
 
 	x := m.Point.X
 	y := m.Point.Y
@@ -681,34 +1076,39 @@ func (el *Element) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw
 	if l, ok := el.UI.(*Label); ok && l != nil {
 		fromLabel = l.Label
 	}
+	if !border {
+		hovered = el
+	}
 	if el.n.Data() == "body" {
 		if el.mouseSelect(dui, self, m, origM, orig) {
 			return duit.Result{
 				Consumed: true,
 			}
 		}
+		hovered = nil
+		el.m = m
+		r = el.mouseUI(dui, self, m, origM, orig)
+		browser.Website.interact(hovered, m)
+		return
 	} else if el.m.Buttons&1 == 1 && m.Buttons&1 == 0 && el.click() {
 		return duit.Result{
 			Consumed: true,
 		}
 	}
-	/*if !border && el.IsLink {
-		dui.Display.SwitchCursor(&draw.Cursor{
-			Black: cursor,
-		})
-		if m.Buttons == 0 {
-			//r.Consumed = true
-			return r
-		}
-	} else {
-		dui.Display.SwitchCursor(nil)
-	}*/
 	if border {
 		el.m = draw.Mouse{}
 	} else {
 		el.m = m
 	}
-	return el.UI.Mouse(dui, self, m, origM, orig)
+	return el.mouseUI(dui, self, m, origM, orig)
+}
+
+// mouseUI delivers m to the UI of el and to the descendants drawn by
+// its stacking context.
+func (el *Element) mouseUI(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) duit.Result {
+	return el.stack.Mouse(dui, self, m, origM, orig, func() duit.Result {
+		return el.UI.Mouse(dui, self, m, origM, orig)
+	})
 }
 
 func (el *Element) mouseSelect(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) (consumed bool) {
@@ -821,9 +1221,7 @@ func (el *Element) click() (consumed bool) {
 		if err != nil {
 			log.Errorf("trigger click %v: %v", q, err)
 		} else if consumed {
-			offset := scroller.Offset
-			browser.Website.layout(browser, res, ClickRelayout)
-			scroller.Offset = offset
+			browser.Website.update(res)
 			dui.MarkLayout(dui.Top.UI)
 			dui.MarkDraw(dui.Top.UI)
 			dui.Render()
@@ -897,37 +1295,59 @@ func setAttr(n *html.Node, key, val string) {
 	n.Attr = append(n.Attr, newAttr)
 }
 
-func placeFunc(name string, place *duit.Place) func(self *duit.Kid, sizeAvail image.Point) {
+func delAttr(n *html.Node, key string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+			return
+		}
+	}
+}
+
+func placeFunc(flow *Element, place *duit.Place) func(self *duit.Kid, sizeAvail image.Point) {
 	return func(self *duit.Kid, sizeAvail image.Point) {
-		for i, kid := range place.Kids {
-			el := kid.UI.(*Element)
-			if i == 0 {
-				kid.UI.Layout(dui, self, sizeAvail, true)
-				kid.R = self.R
-			} else {
+		cb := sizeAvail
+		for _, kid := range place.Kids {
+			if kid.UI == flow {
 				kid.UI.Layout(dui, kid, sizeAvail, true)
-				if t, err := el.n.CssPx("top"); err == nil && el.n.Css("top") != "auto" {
-					t = dui.Scale(t)
-					kid.R.Min.Y += t
-					kid.R.Max.Y += t
-				} else if b, err := el.n.CssPx("bottom"); err == nil && el.n.Css("bottom") != "auto" {
-					b = dui.Scale(b)
-					h := kid.R.Max.X
-					kid.R.Min.Y = sizeAvail.Y - b
-					kid.R.Max.Y = sizeAvail.Y - h
-				}
-				if l, err := el.n.CssPx("left"); err == nil && el.n.Css("left") != "auto" {
-					l = dui.Scale(l)
-					kid.R.Max.X += l
-					kid.R.Min.X += l
-				} else if r, err := el.n.CssPx("right"); err == nil && el.n.Css("right") != "auto"  {
-					r = dui.Scale(r)
-					w := kid.R.Max.X
-					kid.R.Max.X = sizeAvail.X - r
-					kid.R.Min.X = sizeAvail.X - w
+				kid.R = kid.R.Sub(kid.R.Min)
+				cb = kid.R.Size()
+				if cb.X < sizeAvail.X {
+					cb.X = sizeAvail.X
 				}
 			}
 		}
+		self.R = image.Rectangle{Max: cb}
+		for _, kid := range place.Kids {
+			if kid.UI == flow {
+				continue
+			}
+			el := kid.UI.(*Element)
+			avail := cb
+			l, lok := inset(el.n, "left")
+			r, rok := inset(el.n, "right")
+			l = dui.Scale(l)
+			r = dui.Scale(r)
+			if lok && rok && cb.X-l-r > 0 {
+				avail.X = cb.X - l - r
+			}
+			kid.UI.Layout(dui, kid, avail, true)
+			kid.R = kid.R.Sub(kid.R.Min)
+			w, h := kid.R.Dx(), kid.R.Dy()
+			if t, ok := inset(el.n, "top"); ok {
+				kid.R = kid.R.Add(image.Pt(0, dui.Scale(t)))
+			} else if b, ok := inset(el.n, "bottom"); ok {
+				b = dui.Scale(b)
+				kid.R.Min.Y = cb.Y - b - h
+				kid.R.Max.Y = cb.Y - b
+			}
+			if lok {
+				kid.R = kid.R.Add(image.Pt(l, 0))
+			} else if rok {
+				kid.R.Min.X = cb.X - r - w
+				kid.R.Max.X = cb.X - r
+			}
+		}
 	}
 }
 
@@ -937,8 +1357,7 @@ func arrangeAbsolute(n *nodes.Node, elements ...*Element) (ael *Element, ok bool
 	other := make([]*Element, 0, len(elements))
 
 	for _, el := range elements {
-		if el.n.Css("position") == "absolute" || el.n.Css("position") == "fixed" {
-			// Treating fixed like absolute
+		if el.n.IsOutOfFlow() {
 			absolutes = append(absolutes, el)
 		} else {
 			other = append(other, el)
@@ -953,19 +1372,12 @@ func arrangeAbsolute(n *nodes.Node, elements ...*Element) (ael *Element, ok bool
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
-	uis := make([]duit.UI, 0, len(other)+1)
 	na := Arrange(n, other...)
-	if na != nil {
-		uis = append(uis, na)
-	}
-	for _, a := range absolutes {
-		uis = append(uis, a)
-	}
 	pl := &duit.Place{
-		Kids:       duit.NewKids(uis...),
+		Kids:       duit.NewKids(stackPositioned(na, absolutes)...),
 		Background: bg,
 	}
-	pl.Place = placeFunc(n.QueryRef(), pl)
+	pl.Place = placeFunc(na, pl)
 
 	return NewElement(pl, n), true
 }
@@ -974,6 +1386,9 @@ func Arrange(n *nodes.Node, elements ...*Element) *Element {
 	if ael, ok := arrangeAbsolute(n, elements...); ok {
 		return ael
 	}
+	if isGrid(n) {
+		return arrangeGrid(n, elements...)
+	}
 
 	ui := horizontalSeq(n, true, elements)
 	if ui == nil {
@@ -983,6 +1398,7 @@ func Arrange(n *nodes.Node, elements ...*Element) *Element {
 		n:  n,
 		UI: ui,
 	}
+	markWrapped(ui, n)
 	n.Rectangular = el
 	return el
 }
@@ -994,19 +1410,8 @@ func horizontalSeq(parent *nodes.Node, wrap bool, es []*Element) duit.UI {
 
 	finalUis := make([]duit.UI, 0, len(es))
 	for _, el := range es {
-		label, isLabel := el.UI.(*duit.Label)
-		if isLabel {
-			tts := strings.Split(label.Text, " ")
-			for _, t := range tts {
-				finalUis = append(finalUis, NewElement(&duit.Label{
-					Text: t,
-					Font: label.Font,
-				}, el.n))
-			}
-		} else {
-			if el != nil {
-				finalUis = append(finalUis, el)
-			}
+		if el != nil {
+			finalUis = append(finalUis, el)
 		}
 	}
 
@@ -1021,37 +1426,184 @@ func duitDisplay(n *nodes.Node) duitx.Display {
 	if n == nil {
 		return duitx.InlineBlock
 	}
-	if n.Css("float") == "left" {
+	if duitFloat(n) != duitx.FloatNone {
 		return duitx.InlineBlock
-	} else if cl := n.Css("clear"); cl == "left" || cl == "both" {
+	} else if duitClear(n) != duitx.ClearNone {
 		return duitx.Block
 	}
 	switch n.Css("display") {
 	case "inline":
 		return duitx.Inline
-	case "block":
+	case "block", "flow-root", "list-item", "grid":
 		return duitx.Block
 	case "flex":
 		return duitx.Flex
+	case "inline-flex":
+		return duitx.InlineFlex
 	default:
 		return duitx.InlineBlock
 	}
 }
 
+func duitFloat(n *nodes.Node) duitx.Float {
+	if n == nil {
+		return duitx.FloatNone
+	}
+	switch n.Css("float") {
+	case "left", "inline-start":
+		return duitx.FloatLeft
+	case "right", "inline-end":
+		return duitx.FloatRight
+	default:
+		return duitx.FloatNone
+	}
+}
+
+func duitClear(n *nodes.Node) duitx.Clear {
+	if n == nil {
+		return duitx.ClearNone
+	}
+	switch n.Css("clear") {
+	case "left", "inline-start":
+		return duitx.ClearLeft
+	case "right", "inline-end":
+		return duitx.ClearRight
+	case "both":
+		return duitx.ClearBoth
+	default:
+		return duitx.ClearNone
+	}
+}
+
+// isBFC returns whether n establishes a block formatting context.
+func isBFC(n *nodes.Node) bool {
+	if n == nil {
+		return false
+	}
+	if n.Data() == "body" || duitFloat(n) != duitx.FloatNone {
+		return true
+	}
+	for _, p := range []string{"overflow", "overflow-x", "overflow-y"} {
+		if o := n.Css(p); o != "" && o != "visible" && o != "clip" {
+			return true
+		}
+	}
+	switch n.Css("display") {
+	case "flow-root", "inline-block", "flex", "inline-flex", "grid", "inline-grid", "table-cell", "table-caption":
+		return true
+	}
+	switch n.Css("position") {
+	case "absolute", "fixed":
+		return true
+	}
+	return false
+}
+
 func duitFlexDir(n *nodes.Node) duitx.Dir {
 	if n == nil {
 		return 0
 	}
-	switch n.Css("flex-direction") {
-	case "row":
+	switch flexDirection(n) {
+	case "row", "row-reverse":
 		return duitx.Row
-	case "column":
+	case "column", "column-reverse":
 		return duitx.Column
 	default:
 		return 0
 	}
 }
 
+// flexDirection from flex-direction or the flex-flow shorthand.
+func flexDirection(n *nodes.Node) string {
+	if d := n.Css("flex-direction"); d != "" {
+		return d
+	}
+	for _, f := range strings.Fields(n.Css("flex-flow")) {
+		if strings.HasPrefix(f, "row") || strings.HasPrefix(f, "column") {
+			return f
+		}
+	}
+	return ""
+}
+
+func duitFlexContainer(n *nodes.Node) (c duitx.FlexContainer) {
+	if n == nil {
+		return
+	}
+	c.Reverse = strings.HasSuffix(flexDirection(n), "-reverse")
+	wrap := n.Css("flex-wrap")
+	if wrap == "" {
+		for _, f := range strings.Fields(n.Css("flex-flow")) {
+			if strings.Contains(f, "wrap") {
+				wrap = f
+			}
+		}
+	}
+	c.Wrap = wrap == "wrap" || wrap == "wrap-reverse"
+	c.WrapReverse = wrap == "wrap-reverse"
+	switch lastField(n.Css("justify-content")) {
+	case "flex-end", "end", "right":
+		c.Justify = duitx.JustifyEnd
+	case "center":
+		c.Justify = duitx.JustifyCenter
+	case "space-between":
+		c.Justify = duitx.JustifySpaceBetween
+	case "space-around":
+		c.Justify = duitx.JustifySpaceAround
+	case "space-evenly":
+		c.Justify = duitx.JustifySpaceEvenly
+	}
+	c.AlignItems = duitAlign(n.Css("align-items"))
+	c.AlignContent = duitAlign(n.Css("align-content"))
+	c.RowGap, c.ColumnGap = n.Gap()
+	return
+}
+
+func duitFlexItem(n *nodes.Node) (it duitx.FlexItem) {
+	it = duitx.DefaultFlexItem
+	if n == nil {
+		return
+	}
+	it.Grow, it.Shrink, it.Basis = n.FlexItem()
+	if o, err := strconv.Atoi(n.Css("order")); err == nil {
+		it.Order = o
+	}
+	it.AlignSelf = duitAlign(n.Css("align-self"))
+	return
+}
+
+func duitAlign(a string) duitx.Align {
+	switch lastField(a) {
+	case "stretch", "normal":
+		return duitx.AlignStretch
+	case "flex-start", "start", "self-start":
+		return duitx.AlignStart
+	case "flex-end", "end", "self-end":
+		return duitx.AlignEnd
+	case "center":
+		return duitx.AlignCenter
+	case "baseline":
+		return duitx.AlignBaseline
+	case "space-between":
+		return duitx.AlignSpaceBetween
+	case "space-around":
+		return duitx.AlignSpaceAround
+	case "space-evenly":
+		return duitx.AlignSpaceEvenly
+	default:
+		return duitx.AlignAuto
+	}
+}
+
+// lastField ignores overflow alignment keywords like in "safe center".
+func lastField(v string) string {
+	fs := strings.Fields(v)
+	if len(fs) == 0 {
+		return ""
+	}
+	return fs[len(fs)-1]
+}
+
 func verticalSeq(es []*Element) duit.UI {
 	if len(es) == 0 {
 		return nil
@@ -1082,11 +1634,15 @@ func verticalSeq(es []*Element) duit.UI {
 }
 
 type Table struct {
-	rows []*TableRow
+	n       *nodes.Node
+	caption *nodes.Node
+	cols    []*nodes.Node // col or colgroup per column
+	rows    []*TableRow
 }
 
 func NewTable(n *nodes.Node) (t *Table) {
 	t = &Table{
+		n:    n,
 		rows: make([]*TableRow, 0, 10),
 	}
 
@@ -1095,120 +1651,276 @@ func NewTable(n *nodes.Node) (t *Table) {
 		return nil
 	}
 
-	trContainers := make([]*nodes.Node, 0, 2)
-	for _, c := range n.Children {
-		if c.DomSubtree.Data == "tbody" || c.DomSubtree.Data == "thead" {
-			trContainers = append(trContainers, c)
-		}
-	}
-	if len(trContainers) == 0 {
-		trContainers = []*nodes.Node{n}
-	}
-
-	for _, tc := range trContainers {
+	var foot []*TableRow
+	group := 0
+	addRows := func(tc *nodes.Node, rows []*TableRow) []*TableRow {
+		group++
 		for _, c := range tc.Children {
 			if txt := c.Text; txt != "" && strings.TrimSpace(txt) == "" {
 				continue
 			}
 			if c.DomSubtree.Data == "tr" {
-				row := NewTableRow(c)
-				t.rows = append(t.rows, row)
+				if row := NewTableRow(c); row != nil {
+					row.group = group
+					rows = append(rows, row)
+				}
 			} else {
 				log.Printf("unexpected row element '%v' (%v)", c.DomSubtree.Data, c.DomSubtree.Type)
 			}
 		}
+		return rows
 	}
+	for _, c := range n.Children {
+		switch c.DomSubtree.Data {
+		case "caption":
+			if t.caption == nil {
+				t.caption = c
+			}
+		case "colgroup":
+			l := len(t.cols)
+			for _, col := range c.Children {
+				if col.DomSubtree.Data == "col" {
+					t.addCol(col)
+				}
+			}
+			if len(t.cols) == l {
+				t.addCol(c)
+			}
+		case "col":
+			t.addCol(c)
+		case "thead", "tbody":
+			t.rows = addRows(c, t.rows)
+		case "tfoot":
+			foot = addRows(c, foot)
+		case "tr":
+			if row := NewTableRow(c); row != nil {
+				row.group = group
+				t.rows = append(t.rows, row)
+			}
+		}
+	}
+	t.rows = append(t.rows, foot...)
 	return
 }
 
-func (t *Table) numColsMin() (min int) {
-	min = t.numColsMax()
-	for _, r := range t.rows {
-		if l := len(r.columns); l < min {
-			min = l
+func (t *Table) addCol(c *nodes.Node) {
+	for i := 0; i < span(c, "span", 1000); i++ {
+		t.cols = append(t.cols, c)
+	}
+}
+
+// span returns the value of the attribute k within 1 and max.
+func span(n *nodes.Node, k string, max int) int {
+	s, err := strconv.Atoi(n.Attr(k))
+	if err != nil || s < 1 {
+		return 1
+	}
+	if s > max {
+		return max
+	}
+	return s
+}
+
+// cells places the cells in the slots of the table grid and returns
+// the number of columns.
+func (t *Table) cells() (cells []*nodes.Node, ps []duitx.Placement, numCols int) {
+	occupied := make(map[image.Point]bool)
+	for y, row := range t.rows {
+		x := 0
+		for _, td := range row.columns {
+			for occupied[image.Pt(x, y)] {
+				x++
+			}
+			cs := span(td, "colspan", 1000)
+			rs := span(td, "rowspan", 65534)
+			end := y + 1
+			for end < len(t.rows) && t.rows[end].group == row.group {
+				end++
+			}
+			if td.Attr("rowspan") == "0" || y+rs > end {
+				rs = end - y
+			}
+			for yy := y; yy < y+rs; yy++ {
+				for xx := x; xx < x+cs; xx++ {
+					occupied[image.Pt(xx, yy)] = true
+				}
+			}
+			cells = append(cells, td)
+			ps = append(ps, duitx.Placement{
+				RowStart: y + 1,
+				RowEnd:   y + rs + 1,
+				ColStart: x + 1,
+				ColEnd:   x + cs + 1,
+			})
+			x += cs
+			if x > numCols {
+				numCols = x
+			}
 		}
 	}
 	return
 }
 
-func (t *Table) numColsMax() (max int) {
-	for _, r := range t.rows {
-		if l := len(r.columns); l > max {
-			max = l
+// colTracks returns the column tracks of the auto or fixed table layout.
+func (t *Table) colTracks(cells []*nodes.Node, ps []duitx.Placement, numCols int) (ts []duitx.Track) {
+	fixed := t.n.Css("table-layout") == "fixed" && hasWidth(t.n)
+	ts = make([]duitx.Track, numCols)
+	set := make([]bool, numCols)
+	width := func(i int, n *nodes.Node) {
+		if i >= numCols || set[i] || !hasWidth(n) {
+			return
+		}
+		s := duitx.TrackSize{Kind: duitx.TrackPx, Value: float64(n.Width())}
+		if w := n.Css("width"); strings.HasSuffix(w, "%") {
+			if f, err := strconv.ParseFloat(strings.TrimSuffix(w, "%"), 64); err == nil {
+				s = duitx.TrackSize{Kind: duitx.TrackPercent, Value: f}
+			}
+		}
+		if fixed {
+			ts[i] = duitx.Track{Min: s, Max: s}
+		} else {
+			ts[i] = duitx.Track{Min: s, Max: duitx.TrackSize{Kind: duitx.TrackMaxContent}}
+		}
+		set[i] = true
+	}
+	for i, c := range t.cols {
+		width(i, c)
+	}
+	for i, td := range cells {
+		p := ps[i]
+		if p.ColEnd-p.ColStart == 1 && (!fixed || p.RowStart == 1) {
+			width(p.ColStart-1, td)
+		}
+	}
+	if fixed {
+		// columns without width share the remaining space
+		for i := range ts {
+			if !set[i] {
+				ts[i] = duitx.Track{
+					Min: duitx.TrackSize{Kind: duitx.TrackPx},
+					Max: duitx.TrackSize{Kind: duitx.TrackFr, Value: 1},
+				}
+			}
 		}
 	}
 	return
 }
 
-func (t *Table) Element(r int, b *Browser, n *nodes.Node) *Element {
-	numRows := len(t.rows)
-	numCols := t.numColsMax()
-	useOneGrid := t.numColsMin() == t.numColsMax()
+func hasWidth(n *nodes.Node) bool {
+	w := n.Css("width")
+	return w != "" && w != "auto" && w != "0"
+}
 
+func (t *Table) Element(r int, b *Browser, n *nodes.Node) *Element {
+	if t == nil {
+		return nil
+	}
+	cells, ps, numCols := t.cells()
 	if numCols == 0 {
 		return nil
 	}
 
-	if useOneGrid {
-		uis := make([]duit.UI, 0, numRows*numCols)
-
-		for _, row := range t.rows {
-			for _, td := range row.columns {
-				uis = append(uis, NodeToBox(r+1, b, td))
+	g := &duitx.Grid{
+		Places:    make([]duitx.Placement, 0, len(cells)),
+		ColTracks: duitx.TrackList{Tracks: t.colTracks(cells, ps, numCols)},
+	}
+	if hasWidth(n) {
+		g.Width = -1
+	}
+	if n.Css("border-collapse") == "collapse" {
+		g.Collapse = true
+	} else {
+		g.RowGap, g.ColGap = 2, 2
+		if v := n.Attr("cellspacing"); v != "" {
+			if s, err := strconv.Atoi(v); err == nil {
+				g.RowGap, g.ColGap = s, s
 			}
-		}
-
-		colSpans := make([]int, 0, len(uis))
-		rowSpans := make([]int, 0, len(uis))
-		halign := make([]duit.Halign, 0, len(uis))
-		valign := make([]duit.Valign, 0, len(uis))
-
-		for j := 0; j < numCols; j++ {
-			for i := 0; i < len(t.rows); i++ {
-				colSpans = append(colSpans, 1)
-				rowSpans = append(rowSpans, 1)
+		} else if v := n.Css("border-spacing"); v != "" {
+			if s, err := n.CssPx("border-spacing"); err == nil {
+				g.RowGap, g.ColGap = s, s
 			}
-			halign = append(halign, duit.HalignLeft)
-			valign = append(valign, duit.ValignTop)
-		}
-
-		return NewElement(
-			&duitx.Grid{
-				Columns:  numCols,
-				Rows:     len(t.rows),
-				ColSpans: colSpans,
-				RowSpans: rowSpans,
-				Padding:  duit.NSpace(numCols, duit.SpaceXY(0, 3)),
-				Halign:   halign,
-				Valign:   valign,
-				Kids:     duit.NewKids(uis...),
-			},
-			n,
-		)
-	} else {
-		seqs := make([]*Element, 0, len(t.rows))
-
-		for _, row := range t.rows {
-			rowEls := make([]*Element, 0, len(row.columns))
-			for _, col := range row.columns {
-				ui := NodeToBox(r+1, b, col)
-				if ui != nil {
-					el := NewElement(ui, col)
-					rowEls = append(rowEls, el)
+			if fs := strings.Fields(v); len(fs) == 2 {
+				// horizontal and vertical spacing
+				if h, err := strconv.Atoi(strings.TrimSuffix(fs[0], "px")); err == nil {
+					g.ColGap = h
+				}
+				if v, err := strconv.Atoi(strings.TrimSuffix(fs[1], "px")); err == nil {
+					g.RowGap = v
 				}
 			}
+		}
+	}
+	if v := n.Attr("border"); v != "" {
+		// the table border itself is a presentational hint
+		if bw, err := strconv.Atoi(v); err != nil || bw > 0 {
+			g.Border = 1
+		}
+	}
 
-			if len(rowEls) > 0 {
-				seq := horizontalSeq(nil, false, rowEls)
-				seqs = append(seqs, NewElement(seq, row.n))
-			}
+	uis := make([]duit.UI, 0, len(cells))
+	for i, td := range cells {
+		ui := NodeToBox(r+1, b, td)
+		if ui == nil {
+			continue
 		}
-		return NewElement(verticalSeq(seqs), n)
+		uis = append(uis, ui)
+		g.Places = append(g.Places, ps[i])
+		g.KidHalign = append(g.KidHalign, cellHalign(td))
+		g.KidValign = append(g.KidValign, cellValign(td))
+	}
+	if len(uis) == 0 {
+		return nil
+	}
+	g.Kids = duit.NewKids(uis...)
+
+	if t.caption == nil {
+		return NewElement(g, n)
+	}
+	c := NodeToBox(r+1, b, t.caption)
+	if c == nil {
+		return NewElement(g, n)
+	}
+	els := []*Element{c, {UI: g, n: n}}
+	if t.caption.Css("caption-side") == "bottom" {
+		els[0], els[1] = els[1], els[0]
+	}
+	return NewElement(verticalSeq(els), n)
+}
+
+func cellHalign(td *nodes.Node) duit.Halign {
+	a := td.Css("text-align")
+	if v := td.Attr("align"); v != "" {
+		a = v
+	}
+	switch a {
+	case "center", "middle":
+		return duit.HalignMiddle
+	case "right", "end":
+		return duit.HalignRight
 	}
+	return duit.HalignLeft
+}
+
+func cellValign(td *nodes.Node) duit.Valign {
+	a := td.Css("vertical-align")
+	if a == "" {
+		a = td.Attr("valign")
+	}
+	if tr := td.Ancestor("tr"); a == "" && tr != nil {
+		a = tr.Attr("valign")
+	}
+	switch a {
+	case "top", "text-top":
+		return duit.ValignTop
+	case "bottom", "text-bottom":
+		return duit.ValignBottom
+	}
+	return duit.ValignMiddle
 }
 
 type TableRow struct {
 	n       *nodes.Node
+	group   int
 	columns []*nodes.Node
 }
 
@@ -1275,6 +1987,10 @@ func NodeToBox(r int, b *Browser, n *nodes.Node) (el *Element) {
 				return NewInputField(n)
 			} else if t == "submit" {
 				return NewSubmitButton(b, n)
+			} else if t == "checkbox" {
+				return NewCheckbox(n)
+			} else if t == "radio" {
+				return NewRadio(n)
 			}
 		case "select":
 			return NewSelect(n)
@@ -1293,6 +2009,10 @@ func NodeToBox(r int, b *Browser, n *nodes.Node) (el *Element) {
 			return NewElement(btn, n)
 		case "table":
 			return NewTable(n).Element(r+1, b, n)
+		case "br":
+			l := NewLabel("", n)
+			l.LineBreak = true
+			return NewElement(l, n)
 		case "picture", "img", "svg":
 			return NewElement(NewImage(n), n)
 		case "pre":
@@ -1301,28 +2021,13 @@ func NodeToBox(r int, b *Browser, n *nodes.Node) (el *Element) {
 				n,
 			)
 		case "li":
-			var innerContent duit.UI
-
-			if nodes.IsPureTextContent(*n) {
-				t := n.ContentString(false)
-
-				if ul := n.Ancestor("ul"); ul != nil {
-					if ul.Css("list-style") != "none" && n.Css("list-style-type") != "none" {
-						t = "• " + t
-					}
-				}
-				innerContent = NewLabel(t, n)
-			} else {
-				return InnerNodesToBox(r+1, b, n)
-			}
-
-			return NewElement(innerContent, n)
+			return newListItem(r+1, b, n)
 		case "a":
 			var href = n.Attr("href")
 			var innerContent duit.UI
 			if nodes.IsPureTextContent(*n) {
 				innerContent = NewLabel(
-					n.ContentString(false),
+					n.ContentString(true),
 					n,
 				)
 			} else {
@@ -1340,6 +2045,9 @@ func NodeToBox(r int, b *Browser, n *nodes.Node) (el *Element) {
 			}
 			fallthrough
 		default:
+			if n.Css("display") == "list-item" {
+				return newListItem(r+1, b, n)
+			}
 			return InnerNodesToBox(r+1, b, n)
 		}
 	} else if n.Type() == html.TextNode {
@@ -1358,7 +2066,7 @@ func NodeToBox(r int, b *Browser, n *nodes.Node) (el *Element) {
 func isWrapped(n *nodes.Node) bool {
 	isText := nodes.IsPureTextContent(*n)
 	isCTag := false
-	for _, t := range []string{"span", "i", "b", "tt"} {
+	for _, t := range []string{"span", "i", "b", "tt", "code", "kbd", "samp"} {
 		if n.Data() == t {
 			isCTag = true
 		}
@@ -1371,15 +2079,15 @@ func InnerNodesToBox(r int, b *Browser, n *nodes.Node) *Element {
 	els := make([]*Element, 0, len(items))
 
 	for _, c := range items {
-		if c.IsDisplayNone() {
+		if c.IsDisplayNone() || isOutsideMarker(c) {
 			continue
 		}
 		if isWrapped(c) {
-			ls := NewText(c.Content(false), c)
+			ls := NewText(c.Content(true), c)
 			els = append(els, ls...)
 		} else if nodes.IsPureTextContent(*n) && n.IsInline() {
 			// Handle text wrapped in unwrappable tags like p, div, ...
-			ls := NewText(c.Content(false), items[0])
+			ls := NewText(c.Content(true), items[0])
 			if len(ls) == 0 {
 				continue
 			}
@@ -1394,7 +2102,7 @@ func InnerNodesToBox(r int, b *Browser, n *nodes.Node) *Element {
 	}
 
 	if len(els) == 0 {
-		return nil
+		return emptyBox(n)
 	}
 
 	return Arrange(n, els...)
@@ -1438,6 +2146,7 @@ func traverseTree(r int, ui duit.UI, f func(ui duit.UI)) {
 	case *duit.Field:
 	case *duit.Edit:
 	case *duit.Button:
+	case *duit.Checkbox, *duit.Radiobutton:
 	case *duit.List:
 	case *duit.Place:
 		for _, kid := range v.Kids {
@@ -1450,6 +2159,16 @@ func traverseTree(r int, ui duit.UI, f func(ui duit.UI)) {
 	}
 }
 
+// freeImages releases the images of the boxes in the tree of ui when it
+// is discarded.
+func freeImages(ui duit.UI) {
+	TraverseTree(ui, func(ui duit.UI) {
+		if b, ok := ui.(*duitx.Box); ok {
+			b.Free()
+		}
+	})
+}
+
 func PrintTree(ui duit.UI) {
 	if log.Debug && debugPrintHtml {
 		printTree(0, ui)
@@ -1548,6 +2267,7 @@ func NewBrowser(_dui *duit.DUI, initUrl string) (b *Browser) {
 	browser = b
 	b.Website.UI = &duit.Label{}
 	style.SetFetcher(b)
+	style.FontLoaded = webFontLoaded
 	dui = _dui
 	dui.Background, err = dui.Display.AllocImage(image.Rect(0, 0, 10, 10), draw.ARGB32, true, 0x00000000)
 	if err != nil {
@@ -1608,6 +2328,7 @@ func (b *Browser) SetAndLoadUrl(u *url.URL) func() duit.Event {
 		// Stop updating existing widgets
 		if scroller != nil {
 			scroller.Free()
+			freeImages(scroller)
 			scroller = nil
 		}
 		b.showBodyMessage("")
@@ -1660,7 +2381,7 @@ func (b *Browser) LoadUrl(url *url.URL) (e duit.Event) {
 
 func (b *Browser) loadUrl(url *url.URL) {
 	b.StatusCh <- fmt.Sprintf("Load %v...", url)
-	buf, contentType, err := b.get(url, true)
+	buf, contentType, err := b.get(url, true, "")
 	if err != nil {
 		log.Errorf("error loading %v: %v", url, err)
 		if er := errors.Unwrap(err); er != nil {
@@ -1695,11 +2416,16 @@ func (b *Browser) loadUrl(url *url.URL) {
 func (b *Browser) render(ct opossum.ContentType, buf []byte) {
 	log.Printf("Empty some cache...")
 	cache.Tidy()
-	imageCache = make(map[string]*draw.Image)
+	imageMu.Lock()
+	imageCache = make(map[imageKey]*img.Frames)
+	imageWaiting = make(map[imageKey][]*Image)
+	bgCache = make(map[string]image.Image)
+	bgWaiting = make(map[string][]*duitx.BackgroundLayer)
+	imageMu.Unlock()
 
 	b.Website.ContentType = ct
 	htm := ct.Utf8(buf)
-	b.Website.layout(b, htm, InitialLayout)
+	b.Website.layout(b, htm)
 
 	log.Printf("Render...")
 	dui.Call <- func() {
@@ -1712,9 +2438,6 @@ func (b *Browser) render(ct opossum.ContentType, buf []byte) {
 			}
 		})
 		PrintTree(b.Website.UI)
-		if scroller != nil {
-			scroller.Offset = b.History.Scroll()
-		}
 		dui.MarkLayout(dui.Top.UI)
 		dui.MarkDraw(dui.Top.UI)
 		dui.Render()
@@ -1724,12 +2447,16 @@ func (b *Browser) render(ct opossum.ContentType, buf []byte) {
 }
 
 func (b *Browser) Get(uri *url.URL) (buf []byte, contentType opossum.ContentType, err error) {
+	return b.getCached(uri, "")
+}
+
+func (b *Browser) getCached(uri *url.URL, accept string) (buf []byte, contentType opossum.ContentType, err error) {
 	c, ok := cache.Get(uri.String())
 	if ok {
 		log.Printf("use %v from cache", uri)
 	} else {
 		c.Addr = uri.String()
-		c.Buf, c.ContentType, err = b.get(uri, false)
+		c.Buf, c.ContentType, err = b.get(uri, false, accept)
 		if err == nil {
 			cache.Set(c)
 		}
@@ -1738,13 +2465,26 @@ func (b *Browser) Get(uri *url.URL) (buf []byte, contentType opossum.ContentType
 	return c.Buf, c.ContentType, err
 }
 
-func (b *Browser) get(uri *url.URL, isNewOrigin bool) (buf []byte, contentType opossum.ContentType, err error) {
+// imageFetcher requests images with an Accept header listing the
+// formats that can be decoded.
+type imageFetcher struct {
+	*Browser
+}
+
+func (f imageFetcher) Get(uri *url.URL) (buf []byte, contentType opossum.ContentType, err error) {
+	return f.getCached(uri, img.Accept)
+}
+
+func (b *Browser) get(uri *url.URL, isNewOrigin bool, accept string) (buf []byte, contentType opossum.ContentType, err error) {
 	log.Infof("Get %v", uri.String())
 	req, err := http.NewRequestWithContext(b.ctx, "GET", uri.String(), nil)
 	if err != nil {
 		return
 	}
 	req.Header.Add("User-Agent", UserAgent)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
 	resp, err := b.client.Do(req)
 	if err != nil {
 		return nil, opossum.ContentType{}, fmt.Errorf("error loading %v: %w", uri, err)
@@ -485,13 +485,56 @@ func TestSrcSet(t *testing.T) {
 		t.Fatalf("digest: %v", err)
 	}
 	img := nt.Find("img")
-	w, src := srcSet(img)
+	w, _, src := srcSet(img)
 	t.Logf("%v, %v, %v", nt.Data(), w, src)
 	if w != 608 || src != "/t-608x334.jpg" {
 		t.Error()
 	}
 }
 
+func TestPlaceholderSize(t *testing.T) {
+	htm := `
+		<img id="a" width="800" height="400" src="/a.jpg">
+		<img id="b" width="800" height="400" style="width: 200px; height: auto" src="/b.jpg">
+		<img id="c" style="height: 90px; aspect-ratio: 4 / 3" src="/c.jpg">
+		<img id="d" style="width: 100px" src="/d.jpg">
+	`
+	nt, _, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	exps := map[string]image.Point{
+		"a": {800, 400},
+		"b": {200, 100},
+		"c": {120, 90},
+		"d": {100, 0},
+	}
+	for _, n := range nt.FindAll("img") {
+		exp := exps[n.Attr("id")]
+		if p := placeholderSize(n, n.Width(), n.Height()); p != exp {
+			t.Errorf("%v: %v != %v", n.Attr("id"), p, exp)
+		}
+	}
+}
+
+func TestVisible(t *testing.T) {
+	clip := image.Rect(0, 100, 200, 200)
+	tests := []struct {
+		r   image.Rectangle
+		exp bool
+	}{
+		{image.Rect(0, 50, 10, 150), true},
+		{image.Rect(0, 0, 10, 100), false},
+		{image.Rect(5, 150, 5, 150), true},
+		{image.Rect(5, 250, 5, 250), false},
+	}
+	for _, tt := range tests {
+		if v := visible(tt.r, clip); v != tt.exp {
+			t.Errorf("%v: %v", tt.r, v)
+		}
+	}
+}
+
 func TestWidths(t *testing.T) {
 	htm := `
 <html>
@@ -528,3 +571,492 @@ func TestWidths(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFlexProperties(t *testing.T) {
+	htm := `
+		<body>
+			<div style="display: flex; flex-flow: row-reverse wrap; justify-content: space-between; align-items: safe center; gap: 4px 8px">
+				<p style="flex: 2 1 10px; order: -1; align-self: flex-end">a</p>
+				<p style="flex: 1">b</p>
+			</div>
+		</body>
+	`
+	nt, _, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	div := nt.Find("div")
+	if d := duitFlexDir(div); d != duitx.Row {
+		t.Errorf("%v", d)
+	}
+	c := duitFlexContainer(div)
+	exp := duitx.FlexContainer{
+		Wrap:       true,
+		Reverse:    true,
+		Justify:    duitx.JustifySpaceBetween,
+		AlignItems: duitx.AlignCenter,
+		RowGap:     4,
+		ColumnGap:  8,
+	}
+	if c != exp {
+		t.Errorf("%+v", c)
+	}
+	ps := div.FindAll("p")
+	it := duitFlexItem(ps[0])
+	if it.Grow != 2 || it.Shrink != 1 || it.Basis != 10 || it.Order != -1 || it.AlignSelf != duitx.AlignEnd {
+		t.Errorf("%+v", it)
+	}
+	it = duitFlexItem(ps[1])
+	if it.Grow != 1 || it.Shrink != 1 || it.Basis != 0 {
+		t.Errorf("%+v", it)
+	}
+}
+
+func TestGrid(t *testing.T) {
+	htm := `
+		<body>
+			<div style="display: grid; grid-template-columns: 100px 1fr; grid-template-areas: 'head head' 'nav main'; gap: 4px 8px">
+				<header style="grid-area: head">head</header>
+				<nav>nav</nav>
+				<main style="grid-column: 2; justify-self: center">main</main>
+				text
+			</div>
+		</body>
+	`
+	_, boxed, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	var g *duitx.Grid
+	TraverseTree(boxed, func(ui duit.UI) {
+		if gg, ok := ui.(*duitx.Grid); ok {
+			g = gg
+		}
+	})
+	if g == nil {
+		t.Fatalf("no grid")
+	}
+	if len(g.Kids) != 4 || len(g.ColTracks.Tracks) != 2 || len(g.RowTracks.Tracks) != 2 || g.RowGap != 4 || g.ColGap != 8 {
+		t.Fatalf("%+v", g)
+	}
+	if g.ColTracks.Tracks[0].Min != (duitx.TrackSize{Kind: duitx.TrackPx, Value: 100}) || g.ColTracks.Tracks[1].Max.Kind != duitx.TrackFr {
+		t.Errorf("%+v", g.ColTracks)
+	}
+	exp := []duitx.Placement{
+		{RowStart: 1, RowEnd: 2, RowSpan: 1, ColStart: 1, ColEnd: 3, ColSpan: 1},
+		{RowSpan: 1, ColSpan: 1},
+		{RowSpan: 1, ColStart: 2, ColSpan: 1},
+		{},
+	}
+	for i, p := range g.Places {
+		if p != exp[i] {
+			t.Errorf("%v: %+v", i, p)
+		}
+	}
+	if g.KidHalign[2] != duit.HalignMiddle {
+		t.Errorf("%v", g.KidHalign)
+	}
+}
+
+func TestTable(t *testing.T) {
+	htm := `
+		<body>
+			<table border="1" cellpadding="3">
+				<caption>Caption</caption>
+				<colgroup><col width="50"><col span="2"></colgroup>
+				<tfoot><tr><td colspan="3">foot</td></tr></tfoot>
+				<thead><tr><th>a</th><th>b</th><th>c</th></tr></thead>
+				<tbody>
+					<tr><td rowspan="0">1</td><td colspan="2">2</td></tr>
+					<tr><td>3</td></tr>
+				</tbody>
+			</table>
+		</body>
+	`
+	nt, _, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	tbl := NewTable(nt.Find("table"))
+	if tbl.caption == nil || len(tbl.cols) != 3 || len(tbl.rows) != 4 {
+		t.Fatalf("%+v", tbl)
+	}
+	cells, ps, numCols := tbl.cells()
+	if numCols != 3 || len(cells) != 7 {
+		t.Fatalf("%v %v", numCols, len(cells))
+	}
+	exp := []duitx.Placement{
+		{RowStart: 1, RowEnd: 2, ColStart: 1, ColEnd: 2},
+		{RowStart: 1, RowEnd: 2, ColStart: 2, ColEnd: 3},
+		{RowStart: 1, RowEnd: 2, ColStart: 3, ColEnd: 4},
+		{RowStart: 2, RowEnd: 4, ColStart: 1, ColEnd: 2},
+		{RowStart: 2, RowEnd: 3, ColStart: 2, ColEnd: 4},
+		{RowStart: 3, RowEnd: 4, ColStart: 2, ColEnd: 3},
+		{RowStart: 4, RowEnd: 5, ColStart: 1, ColEnd: 4},
+	}
+	for i, p := range ps {
+		if p != exp[i] {
+			t.Errorf("%v: %+v", i, p)
+		}
+	}
+	if cells[6].ContentString(false) != "foot" {
+		t.Errorf("tfoot must be last")
+	}
+	ts := tbl.colTracks(cells, ps, numCols)
+	if ts[0].Min != (duitx.TrackSize{Kind: duitx.TrackPx, Value: 50}) || ts[1] != (duitx.Track{}) {
+		t.Errorf("%+v", ts)
+	}
+	if cellHalign(cells[0]) != duit.HalignMiddle || cellHalign(cells[3]) != duit.HalignLeft || cellValign(cells[3]) != duit.ValignMiddle {
+		t.Errorf("alignment")
+	}
+
+	el := tbl.Element(0, browser, nt.Find("table"))
+	var g *duitx.Grid
+	TraverseTree(el, func(ui duit.UI) {
+		if gg, ok := ui.(*duitx.Grid); ok && gg.Places != nil {
+			g = gg
+		}
+	})
+	if g == nil || len(g.Kids) != 7 || g.Border != 1 || g.RowGap != 2 || g.Width != 0 {
+		t.Fatalf("%+v", g)
+	}
+	if p := cells[0].Css("padding"); p != "3px" {
+		t.Errorf("cellpadding: %v", p)
+	}
+	if b, ok := el.UI.(*duitx.Box); !ok || b.Border.Top.Width != 1 {
+		t.Errorf("table border: %+v", el.UI)
+	}
+}
+
+func TestTableFixed(t *testing.T) {
+	htm := `
+		<body>
+			<table style="table-layout: fixed; width: 400px; border-collapse: collapse">
+				<tr><td style="width: 100px">a</td><td>b</td><td>c</td></tr>
+				<tr><td style="width: 300px">d</td><td>e</td><td>f</td></tr>
+			</table>
+		</body>
+	`
+	nt, _, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	tbl := NewTable(nt.Find("table"))
+	cells, ps, numCols := tbl.cells()
+	ts := tbl.colTracks(cells, ps, numCols)
+	px := duitx.TrackSize{Kind: duitx.TrackPx, Value: 100}
+	if ts[0] != (duitx.Track{Min: px, Max: px}) || ts[1].Max.Kind != duitx.TrackFr || ts[2].Max.Kind != duitx.TrackFr {
+		t.Errorf("%+v", ts)
+	}
+	el := tbl.Element(0, browser, nt.Find("table"))
+	g := el.UI.(*duitx.Box).Kids[0].UI.(*duitx.Grid)
+	if !g.Collapse || g.RowGap != 0 || g.Width != -1 {
+		t.Errorf("%+v", g)
+	}
+}
+
+func TestPositioning(t *testing.T) {
+	htm := `
+		<body>
+			<div id="rel" style="position: relative; top: 5px; right: 3px; z-index: 2">a</div>
+			<div id="sticky" style="position: sticky; top: 0">b</div>
+			<div id="static" style="z-index: 4">c</div>
+			<p style="position: fixed; bottom: 10px; z-index: -1">d</p>
+		</body>
+	`
+	doc, err := html.Parse(strings.NewReader(htm))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	nm, err := style.FetchNodeMap(doc, style.AddOnCSS)
+	if err != nil {
+		t.Fatalf("FetchNodeMap: %v", err)
+	}
+	nt := nodes.NewNodeTree(grep(doc, "body"), style.Map{}, nm, nil)
+	divs := nt.FindAll("div")
+	if len(divs) != 3 {
+		t.Fatalf("%v", divs)
+	}
+	rel := &Element{n: divs[0]}
+	sticky := &Element{n: divs[1]}
+	static := &Element{n: divs[2]}
+	fixed := &Element{n: nt.Find("p")}
+	inner := &Element{n: rel.n}
+	innerFixed := &Element{n: fixed.n}
+	markWrapped(duitx.NewBox(inner, innerFixed), rel.n)
+	if !rel.outermost() || inner.outermost() || !innerFixed.outermost() {
+		t.Fatalf("outermost")
+	}
+	markWrapped(duitx.NewBox(innerFixed), fixed.n)
+	d := &duit.DUI{Display: &draw.Display{DPI: draw.DefaultDPI}}
+
+	if o := rel.relOffset(d); o != image.Pt(-3, 5) {
+		t.Fatalf("%v", o)
+	}
+	if o := sticky.relOffset(d); o != image.ZP {
+		t.Fatalf("%v", o)
+	}
+	if _, ok := rel.Pin(); ok {
+		t.Fatalf("relative pinned")
+	}
+	if p, ok := sticky.Pin(); !ok || p.Fixed || p.Top != 0 || p.Bottom != -1 {
+		t.Fatalf("%+v %v", p, ok)
+	}
+	if p, ok := fixed.Pin(); !ok || !p.Fixed || p.Top != -1 || p.Bottom != 10 {
+		t.Fatalf("%+v %v", p, ok)
+	}
+	for _, tt := range []struct {
+		el *Element
+		z  int
+		ok bool
+	}{
+		{rel, 2, true},
+		{sticky, 0, true},
+		{static, 0, false},
+		{fixed, -1, true},
+		{inner, 0, false},
+	} {
+		if z, ok := tt.el.ZIndex(); z != tt.z || ok != tt.ok {
+			t.Errorf("%v: %v %v", tt.el.n.Attr("id"), z, ok)
+		}
+	}
+	if _, ok := innerFixed.Pin(); ok {
+		t.Fatalf("inner element pinned")
+	}
+
+	flow := &Element{n: nt}
+	uis := stackPositioned(flow, []*Element{rel, fixed})
+	if len(uis) != 3 || uis[0] != fixed || uis[1] != flow || uis[2] != rel {
+		t.Fatalf("%v", uis)
+	}
+}
+
+func TestBorders(t *testing.T) {
+	htm := `
+		<body>
+			<div id="card" style="border: 2px dotted red; border-radius: 4px; outline: 1px solid; box-shadow: 1px 1px">x</div>
+		</body>
+	`
+	nt, _, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	n := nt.Find("div")
+	box, ok := n.Rectangular.(*Element).UI.(*duitx.Box)
+	if !ok {
+		t.Fatalf("%T", n.Rectangular.(*Element).UI)
+	}
+	exp := duitx.Border{Width: 2, Style: duitx.BorderDotted, Color: 0xff0000ff}
+	if box.Border.Top != exp || box.Border.Left != exp || box.Border.Radius != [4]int{4, 4, 4, 4} {
+		t.Errorf("%+v", box.Border)
+	}
+	if box.Outline != (duitx.Border{Width: 1, Style: duitx.BorderSolid, Color: draw.Black}) {
+		t.Errorf("%+v", box.Outline)
+	}
+	if box.Shadow == nil || box.Shadow.X != 1 || box.Shadow.Y != 1 {
+		t.Errorf("%+v", box.Shadow)
+	}
+}
+
+func TestTextStyle(t *testing.T) {
+	htm := `
+		<body>
+			<p style="text-align: center; text-transform: uppercase">
+				<a href="/">link</a> <b>bold</b> <span style="letter-spacing: 2px; line-height: 20px">spaced</span>
+			</p>
+		</body>
+	`
+	_, boxed, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	labels := make(map[string]*duitx.Label)
+	centered := false
+	TraverseTree(boxed, func(ui duit.UI) {
+		switch v := ui.(type) {
+		case *Label:
+			labels[strings.TrimSpace(v.Text)] = v.Label
+		case *duitx.Label:
+			labels[strings.TrimSpace(v.Text)] = v
+		case *duitx.Box:
+			if v.TextAlign == duitx.TextCenter {
+				centered = true
+			}
+		}
+	})
+	if !centered {
+		t.Errorf("no centered box")
+	}
+	if l := labels["LINK"]; l == nil || l.Decoration != duitx.Underline {
+		t.Errorf("%+v", l)
+	}
+	if l := labels["BOLD"]; l == nil || l.Decoration != 0 {
+		t.Errorf("%+v", l)
+	}
+	if l := labels["SPACED"]; l == nil || l.LetterSpacing != 2 || l.LineHeight != 20 {
+		t.Errorf("%+v", l)
+	}
+}
+
+func TestWhiteSpace(t *testing.T) {
+	htm := `
+		<body>
+			<p>foo <code>x = 1</code>, bar<br>baz</p>
+			<p style="white-space: pre-wrap">a  b
+c</p>
+			<table><tr><td nowrap>no wrap here</td></tr></table>
+			<p style="overflow-wrap: anywhere">long</p>
+		</body>
+	`
+	_, boxed, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	var ts []string
+	noWrap := false
+	var long *Label
+	TraverseTree(boxed, func(ui duit.UI) {
+		switch v := ui.(type) {
+		case *Label:
+			t := v.Text
+			if v.LineBreak {
+				t += "⏎"
+			}
+			ts = append(ts, t)
+			if v.Text == "long" {
+				long = v
+			}
+		case *duitx.Box:
+			if v.NoWrap {
+				noWrap = true
+			}
+		}
+	})
+	exp := []string{"foo ", "x ", "= ", "1", ", ", "bar", "⏎", "baz", "a  ", "b⏎", "c", "no wrap here", "long"}
+	if strings.Join(ts, "|") != strings.Join(exp, "|") {
+		t.Errorf("%q", ts)
+	}
+	if !noWrap {
+		t.Errorf("no nowrap box")
+	}
+	if long == nil || !long.BreakWord {
+		t.Errorf("%+v", long)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	htm := `
+		<body>
+			<div id="hidden" style="overflow: hidden; height: 20px">a</div>
+			<div id="scroll" style="overflow: auto; height: 40px; padding: 5px; box-sizing: border-box">b</div>
+			<div id="ellipsis" style="overflow: hidden; white-space: nowrap; text-overflow: ellipsis">long text</div>
+			<div id="max" style="overflow: auto; max-height: 50px">c</div>
+			<div id="clamped" style="overflow-y: scroll; height: 80px; max-height: 60px">d</div>
+		</body>
+	`
+	nt, _, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	divs := nt.FindAll("div")
+	box := func(id string) *duitx.Box {
+		var n *nodes.Node
+		for _, d := range divs {
+			if d.Attr("id") == id {
+				n = d
+			}
+		}
+		if n == nil {
+			t.Fatalf("%v", id)
+		}
+		b, ok := n.Rectangular.(*Element).UI.(*duitx.Box)
+		if !ok {
+			t.Fatalf("%v: %T", id, n.Rectangular.(*Element).UI)
+		}
+		return b
+	}
+	if b := box("hidden"); !b.ClipX || !b.ClipY || len(b.Kids) != 1 {
+		t.Errorf("hidden: %+v", b)
+	}
+	b := box("scroll")
+	s, ok := b.Kids[0].UI.(*duitx.Scroll)
+	if !ok || len(b.Kids) != 1 || !b.ClipY || s.Height != 30 || !s.X {
+		t.Errorf("scroll: %+v %+v", b, s)
+	}
+	for id, exp := range map[string][2]int{"max": {0, 50}, "clamped": {60, 60}} {
+		s, ok := box(id).Kids[0].UI.(*duitx.Scroll)
+		if !ok || s.Height != exp[0] || s.MaxHeight != exp[1] {
+			t.Errorf("%v: %+v", id, s)
+		}
+	}
+	var ellipsis bool
+	TraverseTree(box("ellipsis"), func(ui duit.UI) {
+		if l, ok := ui.(*Label); ok && l.Text == "long text" {
+			ellipsis = l.Ellipsis
+		}
+	})
+	if !ellipsis {
+		t.Errorf("no ellipsis")
+	}
+}
+
+func TestListItems(t *testing.T) {
+	htm := `
+		<body>
+			<ol><li>one</li><li><p>two</p></li></ol>
+			<ul style="list-style-position: inside"><li>in</li></ul>
+		</body>
+	`
+	_, boxed, err := digestHtm(htm)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	var markers, ts []string
+	TraverseTree(boxed, func(ui duit.UI) {
+		switch v := ui.(type) {
+		case *duitx.Box:
+			if l, ok := v.Marker.(*Label); ok {
+				markers = append(markers, l.Text)
+			}
+		case *Label:
+			ts = append(ts, v.Text)
+		}
+	})
+	if strings.Join(markers, "|") != "1. |2. " {
+		t.Errorf("markers %q", markers)
+	}
+	if strings.Join(ts, "|") != "one|two|• |in" {
+		t.Errorf("texts %q", ts)
+	}
+}
+
+func TestAddMarker(t *testing.T) {
+	nt, _, err := digestHtm(`<body><ul><li>x</li></ul></body>`)
+	if err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	li := nt.Find("li")
+	var marker *nodes.Node
+	for _, c := range li.Children {
+		if isOutsideMarker(c) {
+			marker = c
+		}
+	}
+	if marker == nil {
+		t.Fatalf("no marker")
+	}
+
+	// content that isn't a box gets the marker inside
+	content := &Element{n: li, UI: &duit.Place{}}
+	el := addMarker(li, content, marker)
+	box, ok := el.UI.(*duitx.Box)
+	if !ok || len(box.Kids) != 2 || box.Marker != nil {
+		t.Fatalf("%+v", el.UI)
+	}
+	if l, ok := box.Kids[0].UI.(*Element).UI.(*Label); !ok || l.Text != "• " {
+		t.Fatalf("%+v", box.Kids[0].UI)
+	}
+	if box.Kids[1].UI != content || content.outermost() || !el.outermost() {
+		t.Fatalf("content %+v", box.Kids[1].UI)
+	}
+}
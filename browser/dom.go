@@ -0,0 +1,69 @@
+package browser
+
+import (
+	"golang.org/x/net/html"
+)
+
+// patch updates the DOM tree dst in place to match src and returns the
+// nodes whose attributes or children changed. Nodes of src without an
+// equivalent in dst are moved over, so src must not be used afterwards.
+func patch(dst, src *html.Node) (changed []*html.Node) {
+	if !sameAttrs(dst.Attr, src.Attr) {
+		dst.Attr = src.Attr
+		changed = append(changed, dst)
+	}
+	modified := false
+	d := dst.FirstChild
+	for s := src.FirstChild; s != nil; {
+		next := s.NextSibling
+		switch {
+		case d != nil && d.Type == s.Type && d.Data == s.Data && d.Namespace == s.Namespace:
+			changed = append(changed, patch(d, s)...)
+			d = d.NextSibling
+		case d != nil && d.Type == html.TextNode && s.Type == html.TextNode:
+			d.Data = s.Data
+			modified = true
+			d = d.NextSibling
+		default:
+			src.RemoveChild(s)
+			dst.InsertBefore(s, d)
+			modified = true
+		}
+		s = next
+	}
+	for d != nil {
+		next := d.NextSibling
+		dst.RemoveChild(d)
+		modified = true
+		d = next
+	}
+	if modified {
+		changed = append(changed, dst)
+	}
+	return
+}
+
+func sameAttrs(a, b []html.Attribute) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// within returns the nodes of ns that are root or its descendants.
+func within(root *html.Node, ns []*html.Node) (res []*html.Node) {
+	for _, n := range ns {
+		for a := n; a != nil; a = a.Parent {
+			if a == root {
+				res = append(res, n)
+				break
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package browser
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"strings"
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	parse := func(htm string) *html.Node {
+		doc, err := html.Parse(strings.NewReader(htm))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+	render := func(n *html.Node) string {
+		var b bytes.Buffer
+		html.Render(&b, n)
+		return b.String()
+	}
+	tests := []struct {
+		a, b    string
+		changed []string
+	}{
+		{`<p>x</p>`, `<p>x</p>`, nil},
+		{`<p>x</p>`, `<p>y</p>`, []string{"p"}},
+		{`<p>x</p>`, `<p class="a">x</p>`, []string{"p"}},
+		{`<ul><li>1</li></ul>`, `<ul><li>1</li><li>2</li></ul>`, []string{"ul"}},
+		{`<ul><li>1</li><li>2</li></ul>`, `<ul><li>2</li></ul>`, []string{"li", "ul"}},
+		{`<div><p>x</p></div>`, `<div><span>x</span></div>`, []string{"div"}},
+	}
+	for _, tt := range tests {
+		doc := parse(tt.a)
+		p := grep(doc, "body").FirstChild
+		changed := patch(doc, parse(tt.b))
+		if res, exp := render(doc), render(parse(tt.b)); res != exp {
+			t.Errorf("%v: %v != %v", tt.b, res, exp)
+		}
+		if p.Data != "div" && grep(doc, "body").FirstChild != p {
+			t.Errorf("%v: node not kept", tt.b)
+		}
+		var names []string
+		for _, n := range changed {
+			names = append(names, n.Data)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.changed, ",") {
+			t.Errorf("%v: changed %v", tt.b, names)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<title>x</title><p>y</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := grep(doc, "body")
+	ns := within(body, []*html.Node{grep(doc, "title"), grep(doc, "p"), body, {}})
+	if len(ns) != 2 || ns[0].Data != "p" {
+		t.Errorf("%+v", ns)
+	}
+}
+
+func TestChainDiff(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div><p><b>x</b></p><i>y</i></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(ns []*html.Node) (l []string) {
+		for _, n := range ns {
+			l = append(l, n.Data)
+		}
+		return
+	}
+	if ns := names(chainDiff(grep(doc, "b"), grep(doc, "i"))); strings.Join(ns, ",") != "i,b,p" {
+		t.Errorf("%v", ns)
+	}
+	if ns := names(chainDiff(nil, grep(doc, "p"))); strings.Join(ns, ",") != "p,div,body,html" {
+		t.Errorf("%v", ns)
+	}
+	if ns := chainDiff(grep(doc, "b"), grep(doc, "b")); len(ns) != 0 {
+		t.Errorf("%v", ns)
+	}
+}
@@ -0,0 +1,316 @@
+package duitx
+
+import (
+	"image"
+	imagedraw "image/draw"
+	"math"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+	xdraw "golang.org/x/image/draw"
+)
+
+// Repeat of a background image along an axis.
+type Repeat int
+
+const (
+	RepeatTile  Repeat = iota // repeat
+	RepeatNone                // no-repeat
+	RepeatSpace               // repeat without clipping, distributing the space between
+	RepeatRound               // repeat, scaled to fit a whole number of times
+)
+
+// Area of a Box.
+type Area int
+
+const (
+	BorderBox Area = iota
+	PaddingBox
+	ContentBox
+)
+
+// Sizing of a background image.
+type Sizing int
+
+const (
+	SizeExplicit Sizing = iota // W and H, auto keeps the aspect ratio
+	SizeCover
+	SizeContain
+)
+
+// Length is Px lowDPI pixels plus Percent of a reference size or Auto.
+type Length struct {
+	Px      int
+	Percent float64
+	Auto    bool
+}
+
+func (l Length) resolve(dui *duit.DUI, ref int) int {
+	return dui.Scale(l.Px) + int(math.Round(l.Percent*float64(ref)/100))
+}
+
+// BackgroundLayer is an image drawn in the background of a Box.
+// Images without intrinsic size like gradients are painted by Paint
+// for the size of a tile instead.
+type BackgroundLayer struct {
+	Image            image.Image
+	Paint            func(size image.Point) image.Image
+	RepeatX, RepeatY Repeat
+	X, Y             Length // Position within the Origin area relative to the remaining space.
+	Size             Sizing
+	W, H             Length // Size if Size is SizeExplicit.
+	Origin, Clip     Area
+}
+
+// area returns the rectangle of a within the box of size.
+func (ui *Box) area(dui *duit.DUI, a Area, size image.Point) image.Rectangle {
+	var s duit.Space
+	switch a {
+	case PaddingBox:
+		s = dui.ScaleSpace(ui.Border.Space())
+	case ContentBox:
+		s = ui.padding(dui)
+	}
+	return image.Rect(s.Left, s.Top, size.X-s.Right, size.Y-s.Bottom)
+}
+
+// fillBackground draws the background color and the layers of the box
+// with origin orig within r.
+func (ui *Box) fillBackground(dui *duit.DUI, img *draw.Image, orig image.Point, size image.Point, r image.Rectangle) {
+	var d *decorations
+	if ui.decorated() {
+		d = ui.decorations(dui, size)
+	}
+	if ui.Background != nil {
+		cr := r
+		if n := len(ui.Layers); n > 0 {
+			cr = r.Intersect(ui.area(dui, ui.Layers[n-1].Clip, size).Add(orig))
+		}
+		d.genDraw(img, cr, orig, ui.Background, image.ZP)
+	}
+	for i := len(ui.Layers) - 1; i >= 0; i-- {
+		l := ui.Layers[i]
+		if l.Image == nil && l.Paint == nil {
+			continue
+		}
+		area := ui.area(dui, l.Origin, size)
+		sz := l.tileSize(dui, area.Size())
+		if sz.X <= 0 || sz.Y <= 0 {
+			continue
+		}
+		clip := ui.area(dui, l.Clip, size).Intersect(r.Sub(orig))
+		ds := l.draws(dui, area, clip, sz)
+		if len(ds) == 0 {
+			continue
+		}
+		tile := ui.layerTile(dui, i, sz)
+		if tile == nil {
+			continue
+		}
+		for _, ld := range ds {
+			d.genDraw(img, ld.r.Add(orig), orig, tile, ld.sp)
+		}
+	}
+}
+
+// layerTile is the replicated tile of a background layer on the
+// display.
+type layerTile struct {
+	size image.Point
+	i    *draw.Image
+}
+
+// layerTile returns the tile of size sz of layer i.
+func (ui *Box) layerTile(dui *duit.DUI, i int, sz image.Point) *draw.Image {
+	if len(ui.layers) != len(ui.Layers) {
+		ui.freeLayers()
+		ui.layers = make([]layerTile, len(ui.Layers))
+	}
+	lt := &ui.layers[i]
+	if lt.i != nil && lt.size == sz {
+		return lt.i
+	}
+	if lt.i != nil {
+		lt.i.Free()
+	}
+	lt.size = sz
+	lt.i = nil
+	if rgba := ui.Layers[i].tile(sz); rgba != nil {
+		lt.i = loadRGBA(dui, rgba, true)
+	}
+	return lt.i
+}
+
+func (ui *Box) freeLayers() {
+	for _, lt := range ui.layers {
+		if lt.i != nil {
+			lt.i.Free()
+		}
+	}
+	ui.layers = nil
+}
+
+// tile returns the image of the layer scaled to or painted for sz.
+func (l BackgroundLayer) tile(sz image.Point) *image.RGBA {
+	src := l.Image
+	if src == nil {
+		src = l.Paint(sz)
+	}
+	if src == nil {
+		return nil
+	}
+	rgba := image.NewRGBA(rect(sz))
+	if b := src.Bounds(); l.Image != nil && b.Size() != sz {
+		xdraw.ApproxBiLinear.Scale(rgba, rgba.Rect, src, b, xdraw.Src, nil)
+	} else {
+		imagedraw.Draw(rgba, rgba.Rect, src, b.Min, imagedraw.Src)
+	}
+	return rgba
+}
+
+// layerDraw is a rectangle covered by tiles of a layer, drawn from the
+// replicated tile at sp.
+type layerDraw struct {
+	r  image.Rectangle
+	sp image.Point
+}
+
+// draws returns the rectangles covered by the tiles of size sz of the
+// layer positioned in area, clipped to clip. Adjacent tiles are drawn
+// at once.
+func (l BackgroundLayer) draws(dui *duit.DUI, area, clip image.Rectangle, sz image.Point) (ds []layerDraw) {
+	if clip.Empty() {
+		return
+	}
+	xs := spans(tiles(dui, l.RepeatX, l.X, area.Min.X, area.Dx(), sz.X, clip.Min.X, clip.Max.X), sz.X)
+	ys := spans(tiles(dui, l.RepeatY, l.Y, area.Min.Y, area.Dy(), sz.Y, clip.Min.Y, clip.Max.Y), sz.Y)
+	for _, y := range ys {
+		for _, x := range xs {
+			r := image.Rect(x[0], y[0], x[1], y[1])
+			if cr := r.Intersect(clip); !cr.Empty() {
+				ds = append(ds, layerDraw{cr, cr.Min.Sub(r.Min)})
+			}
+		}
+	}
+	return
+}
+
+// spans merges the adjacent tiles of length n at ps.
+func spans(ps []int, n int) (ss [][2]int) {
+	for _, p := range ps {
+		if k := len(ss); k > 0 && ss[k-1][1] == p {
+			ss[k-1][1] = p + n
+		} else {
+			ss = append(ss, [2]int{p, p + n})
+		}
+	}
+	return
+}
+
+// tileSize returns the size of the image in the positioning area of
+// size a.
+func (l BackgroundLayer) tileSize(dui *duit.DUI, a image.Point) (sz image.Point) {
+	var in image.Point
+	if l.Image != nil {
+		b := l.Image.Bounds().Size()
+		in = image.Pt(dui.Scale(b.X), dui.Scale(b.Y))
+	}
+	switch {
+	case in.X <= 0 || in.Y <= 0:
+		sz = a
+		if !l.W.Auto && l.Size == SizeExplicit {
+			sz.X = l.W.resolve(dui, a.X)
+		}
+		if !l.H.Auto && l.Size == SizeExplicit {
+			sz.Y = l.H.resolve(dui, a.Y)
+		}
+	case l.Size == SizeCover || l.Size == SizeContain:
+		sx := float64(a.X) / float64(in.X)
+		sy := float64(a.Y) / float64(in.Y)
+		s := math.Min(sx, sy)
+		if l.Size == SizeCover {
+			s = math.Max(sx, sy)
+		}
+		sz = image.Pt(int(math.Round(float64(in.X)*s)), int(math.Round(float64(in.Y)*s)))
+	case l.W.Auto && l.H.Auto:
+		sz = in
+	case l.W.Auto:
+		sz.Y = l.H.resolve(dui, a.Y)
+		sz.X = sz.Y * in.X / in.Y
+	case l.H.Auto:
+		sz.X = l.W.resolve(dui, a.X)
+		sz.Y = sz.X * in.Y / in.X
+	default:
+		sz = image.Pt(l.W.resolve(dui, a.X), l.H.resolve(dui, a.Y))
+	}
+	if l.RepeatX == RepeatRound && sz.X > 0 && a.X > 0 {
+		w := a.X / maximum(1, int(math.Round(float64(a.X)/float64(sz.X))))
+		if l.RepeatY != RepeatRound && l.H.Auto {
+			sz.Y = sz.Y * w / sz.X
+		}
+		sz.X = w
+	}
+	if l.RepeatY == RepeatRound && sz.Y > 0 && a.Y > 0 {
+		h := a.Y / maximum(1, int(math.Round(float64(a.Y)/float64(sz.Y))))
+		if l.RepeatX != RepeatRound && l.W.Auto {
+			sz.X = sz.X * h / sz.Y
+		}
+		sz.Y = h
+	}
+	return
+}
+
+// tiles returns the offsets of the tiles of length n along an axis
+// where the positioning area starts at a with length an and is
+// clipped to c0 and c1.
+func tiles(dui *duit.DUI, rep Repeat, pos Length, a, an, n, c0, c1 int) (ps []int) {
+	if rep == RepeatSpace {
+		if k := an / n; k > 1 {
+			gap := (an - k*n) / (k - 1)
+			for i := 0; i < k; i++ {
+				ps = append(ps, a+i*(n+gap))
+			}
+			return
+		}
+		rep = RepeatNone
+	}
+	p := a + pos.resolve(dui, an-n)
+	if rep == RepeatNone {
+		return []int{p}
+	}
+	if p > c0 {
+		p -= (p - c0 + n - 1) / n * n
+	}
+	for ; p < c1; p += n {
+		if p+n > c0 {
+			ps = append(ps, p)
+		}
+	}
+	return
+}
+
+// kidsDraw draws kids like duit.KidsDraw but calls fill to draw the
+// background of dirty kids instead of drawing a background image
+// aligned to each kid.
+func kidsDraw(dui *duit.DUI, self *duit.Kid, kids []*duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool, fill func(r image.Rectangle)) {
+	force = force || self.Draw == duit.Dirty
+	if force {
+		self.Draw = duit.Dirty
+	}
+	for _, k := range kids {
+		if !force && k.Draw == duit.Clean {
+			continue
+		}
+		if !force && k.Draw == duit.Dirty {
+			fill(k.R.Add(orig))
+		}
+		mm := m
+		mm.Point = mm.Point.Sub(k.R.Min)
+		if force {
+			k.Draw = duit.Dirty
+		}
+		k.UI.Draw(dui, k, img, orig.Add(k.R.Min), mm, force)
+		k.Draw = duit.Clean
+	}
+	self.Draw = duit.Clean
+}
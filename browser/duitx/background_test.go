@@ -0,0 +1,144 @@
+package duitx
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mjl-/duit"
+)
+
+func TestTiles(t *testing.T) {
+	dui := testDUI()
+	tests := []struct {
+		rep Repeat
+		pos Length
+		exp string
+	}{
+		{RepeatNone, Length{Px: -20}, "[-20]"},
+		{RepeatNone, Length{Percent: 100}, "[70]"},
+		{RepeatNone, Length{Percent: 50, Px: 3}, "[38]"},
+		{RepeatTile, Length{}, "[0 30 60 90]"},
+		{RepeatTile, Length{Percent: 50}, "[-25 5 35 65 95]"},
+		{RepeatSpace, Length{}, "[0 35 70]"},
+		{RepeatRound, Length{Percent: 100}, "[-20 10 40 70]"},
+	}
+	for _, tt := range tests {
+		// positioning area and clip 0-100 with tiles of 30
+		ps := tiles(dui, tt.rep, tt.pos, 0, 100, 30, 0, 100)
+		if s := fmt.Sprintf("%v", ps); s != tt.exp {
+			t.Errorf("%+v: %v", tt, s)
+		}
+	}
+	if ps := tiles(dui, RepeatSpace, Length{Percent: 50}, 0, 50, 30, 0, 50); fmt.Sprintf("%v", ps) != "[10]" {
+		t.Errorf("%v", ps)
+	}
+}
+
+func TestTileSize(t *testing.T) {
+	dui := testDUI()
+	in := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	a := image.Pt(100, 100)
+	auto := Length{Auto: true}
+	tests := []struct {
+		l   BackgroundLayer
+		exp image.Point
+	}{
+		{BackgroundLayer{Image: in, W: auto, H: auto}, image.Pt(40, 20)},
+		{BackgroundLayer{Image: in, Size: SizeCover}, image.Pt(200, 100)},
+		{BackgroundLayer{Image: in, Size: SizeContain}, image.Pt(100, 50)},
+		{BackgroundLayer{Image: in, W: Length{Percent: 50}, H: auto}, image.Pt(50, 25)},
+		{BackgroundLayer{Image: in, W: auto, H: Length{Px: 10}}, image.Pt(20, 10)},
+		{BackgroundLayer{Image: in, W: Length{Px: 10}, H: Length{Px: 10}}, image.Pt(10, 10)},
+		{BackgroundLayer{Image: in, W: auto, H: auto, RepeatX: RepeatRound}, image.Pt(33, 16)},
+		{BackgroundLayer{Image: image.NewRGBA(image.Rectangle{}), W: Length{Px: 10}, H: auto}, image.Pt(10, 100)},
+		{BackgroundLayer{Paint: func(image.Point) image.Image { return nil }, W: auto, H: Length{Percent: 50}}, image.Pt(100, 50)},
+	}
+	for _, tt := range tests {
+		if sz := tt.l.tileSize(dui, a); sz != tt.exp {
+			t.Errorf("%+v: %v", tt.l, sz)
+		}
+	}
+}
+
+func TestRenderLayers(t *testing.T) {
+	dui := testDUI()
+	sprite := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	red := color.RGBA{R: 0xff, A: 0xff}
+	blue := color.RGBA{B: 0xff, A: 0xff}
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			c := red
+			if x >= 10 {
+				c = blue
+			}
+			sprite.Set(x, y, c)
+		}
+	}
+	ui := &Box{
+		Padding: duit.SpaceXY(2, 2),
+		Border:  Borders{Top: Border{Width: 1, Style: BorderSolid}},
+		Layers: []BackgroundLayer{
+			{Image: sprite, RepeatX: RepeatNone, RepeatY: RepeatNone, X: Length{Px: -10}, W: Length{Auto: true}, H: Length{Auto: true}, Origin: ContentBox, Clip: ContentBox},
+		},
+	}
+	l := ui.Layers[0]
+	size := image.Pt(14, 15)
+	area := ui.area(dui, l.Origin, size)
+	sz := l.tileSize(dui, area.Size())
+	ds := l.draws(dui, area, ui.area(dui, l.Clip, size), sz)
+	// the blue half of the sprite, clipped to the content box
+	if len(ds) != 1 || ds[0].r != image.Rect(2, 3, 12, 13) || ds[0].sp != image.Pt(10, 0) {
+		t.Fatalf("%+v", ds)
+	}
+	if c := l.tile(sz).RGBAAt(ds[0].sp.X, ds[0].sp.Y); c != blue {
+		t.Errorf("sprite offset: %v", c)
+	}
+	if ds := l.draws(dui, area, ui.area(dui, l.Clip, size).Intersect(image.Rect(0, 0, 2, 15)), sz); len(ds) != 0 {
+		t.Errorf("clip: %+v", ds)
+	}
+}
+
+func TestLayerSpans(t *testing.T) {
+	dui := testDUI()
+	l := BackgroundLayer{RepeatX: RepeatTile, RepeatY: RepeatSpace}
+	ds := l.draws(dui, image.Rect(0, 0, 100, 50), image.Rect(5, 0, 100, 50), image.Pt(30, 20))
+	// adjacent tiles along x are drawn at once, spaced ones separately
+	if len(ds) != 2 || ds[0].r != image.Rect(5, 0, 100, 20) || ds[0].sp != image.Pt(5, 0) || ds[1].r != image.Rect(5, 30, 100, 50) {
+		t.Errorf("%+v", ds)
+	}
+}
+
+func TestRenderPaintedLayer(t *testing.T) {
+	dui := testDUI()
+	var painted image.Point
+	red := color.RGBA{R: 0xff, A: 0xff}
+	ui := &Box{
+		Layers: []BackgroundLayer{
+			{
+				Paint: func(sz image.Point) image.Image {
+					painted = sz
+					return image.NewUniform(red)
+				},
+				RepeatX: RepeatNone,
+				RepeatY: RepeatNone,
+				W:       Length{Percent: 50},
+				H:       Length{Auto: true},
+			},
+		},
+	}
+	l := ui.Layers[0]
+	sz := l.tileSize(dui, image.Pt(20, 10))
+	rgba := l.tile(sz)
+	if painted != image.Pt(10, 10) {
+		t.Errorf("%v", painted)
+	}
+	if c := rgba.RGBAAt(9, 9); c != red {
+		t.Errorf("%v", c)
+	}
+	ds := l.draws(dui, image.Rect(0, 0, 20, 10), image.Rect(0, 0, 20, 10), sz)
+	if len(ds) != 1 || ds[0].r != image.Rect(0, 0, 10, 10) {
+		t.Errorf("%+v", ds)
+	}
+}
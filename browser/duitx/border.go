@@ -0,0 +1,747 @@
+package duitx
+
+import (
+	"image"
+	"math"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/opossum/logger"
+)
+
+type BorderStyle int
+
+const (
+	BorderNone BorderStyle = iota
+	BorderSolid
+	BorderDashed
+	BorderDotted
+	BorderDouble
+)
+
+// Border of one side of a box. Width is in lowDPI pixels.
+type Border struct {
+	Width int
+	Style BorderStyle
+	Color draw.Color
+}
+
+// Borders around the padding of a box. Radius is in lowDPI pixels for
+// the top-left, top-right, bottom-right and bottom-left corner.
+type Borders struct {
+	Top, Right, Bottom, Left Border
+	Radius                   [4]int
+}
+
+// Shadow below a box in lowDPI pixels.
+type Shadow struct {
+	X, Y, Blur, Spread int
+	Color              draw.Color
+}
+
+func (bs Borders) sides() [4]Border {
+	return [4]Border{bs.Top, bs.Right, bs.Bottom, bs.Left}
+}
+
+// Space returns the border widths.
+func (bs Borders) Space() duit.Space {
+	var s duit.Space
+	if bs.Top.Style != BorderNone {
+		s.Top = bs.Top.Width
+	}
+	if bs.Right.Style != BorderNone {
+		s.Right = bs.Right.Width
+	}
+	if bs.Bottom.Style != BorderNone {
+		s.Bottom = bs.Bottom.Width
+	}
+	if bs.Left.Style != BorderNone {
+		s.Left = bs.Left.Width
+	}
+	return s
+}
+
+func (bs Borders) rounded() bool {
+	return bs.Radius != [4]int{}
+}
+
+// frame is a border scaled to the pixels of a box of size.
+type frame struct {
+	size   image.Point
+	widths [4]int // top, right, bottom, left
+	sides  [4]Border
+	radii  [4]float64 // top-left, top-right, bottom-right, bottom-left
+}
+
+func newFrame(dui *duit.DUI, size image.Point, bs Borders) (f frame) {
+	f.size = size
+	f.sides = bs.sides()
+	sp := dui.ScaleSpace(bs.Space())
+	f.widths = [4]int{sp.Top, sp.Right, sp.Bottom, sp.Left}
+	for i, r := range bs.Radius {
+		f.radii[i] = float64(dui.Scale(r))
+	}
+	f.radii = fitRadii(size, f.radii)
+	return
+}
+
+// fitRadii reduces radii so that adjacent corners don't overlap.
+func fitRadii(size image.Point, radii [4]float64) [4]float64 {
+	w, h := float64(size.X), float64(size.Y)
+	scale := 1.0
+	for _, s := range []float64{
+		w / (radii[0] + radii[1]),
+		h / (radii[1] + radii[2]),
+		w / (radii[2] + radii[3]),
+		h / (radii[3] + radii[0]),
+	} {
+		if s < scale {
+			scale = s
+		}
+	}
+	for i := range radii {
+		radii[i] *= scale
+	}
+	return radii
+}
+
+// roundedDist returns the signed distance of p to the rounded
+// rectangle r, negative inside.
+func roundedDist(p [2]float64, r [4]float64, radii [4]float64) float64 {
+	x, y := p[0], p[1]
+	x0, y0, x1, y1 := r[0], r[1], r[2], r[3]
+	var cx, cy, rad float64
+	switch {
+	case x < x0+radii[0] && y < y0+radii[0]:
+		rad = radii[0]
+		cx, cy = x0+rad, y0+rad
+	case x > x1-radii[1] && y < y0+radii[1]:
+		rad = radii[1]
+		cx, cy = x1-rad, y0+rad
+	case x > x1-radii[2] && y > y1-radii[2]:
+		rad = radii[2]
+		cx, cy = x1-rad, y1-rad
+	case x < x0+radii[3] && y > y1-radii[3]:
+		rad = radii[3]
+		cx, cy = x0+rad, y1-rad
+	default:
+		return math.Max(math.Max(x0-x, x-x1), math.Max(y0-y, y-y1))
+	}
+	return math.Hypot(x-cx, y-cy) - rad
+}
+
+// coverage of a pixel with signed distance d to an edge.
+func coverage(d float64) float64 {
+	return math.Max(0, math.Min(1, 0.5-d))
+}
+
+// outer returns the rectangle and radii of the border edge.
+func (f frame) outer() (r [4]float64, radii [4]float64) {
+	return [4]float64{0, 0, float64(f.size.X), float64(f.size.Y)}, f.radii
+}
+
+// inner returns the rectangle and radii of the padding edge.
+func (f frame) inner() (r [4]float64, radii [4]float64) {
+	t, ri, b, l := float64(f.widths[0]), float64(f.widths[1]), float64(f.widths[2]), float64(f.widths[3])
+	r = [4]float64{l, t, float64(f.size.X) - ri, float64(f.size.Y) - b}
+	adj := [4][2]float64{{t, l}, {t, ri}, {b, ri}, {b, l}}
+	for i, rad := range f.radii {
+		radii[i] = math.Max(0, rad-math.Max(adj[i][0], adj[i][1]))
+	}
+	return
+}
+
+// side returns the index of the border drawn at p and the position
+// along and the depth into that side.
+func (f frame) side(p [2]float64) (i int, along, depth float64) {
+	i = -1
+	best := math.Inf(1)
+	ds := [4]float64{p[1], float64(f.size.X) - p[0], float64(f.size.Y) - p[1], p[0]}
+	for j, w := range f.widths {
+		if w <= 0 {
+			continue
+		}
+		if d := ds[j] / float64(w); d < best {
+			best = d
+			i = j
+		}
+	}
+	if i == 0 || i == 2 {
+		along = p[0]
+	} else {
+		along = p[1]
+	}
+	if i >= 0 {
+		depth = ds[i]
+	}
+	return
+}
+
+// pattern returns the coverage of a dashed, dotted or double border of
+// width w at the position along and depth.
+func pattern(st BorderStyle, w, along, depth float64) float64 {
+	switch st {
+	case BorderDashed:
+		dash := 3 * w
+		if dash < 3 {
+			dash = 3
+		}
+		if math.Mod(along, 2*dash) >= dash {
+			return 0
+		}
+	case BorderDotted:
+		period := 2 * w
+		if period < 2 {
+			period = 2
+		}
+		c := math.Floor(along/period)*period + w/2
+		return coverage(math.Hypot(along-c, depth-w/2) - w/2)
+	case BorderDouble:
+		if w < 3 {
+			return 1
+		}
+		line := math.Ceil(w / 3)
+		if depth > line && depth < w-line {
+			return 0
+		}
+	}
+	return 1
+}
+
+// corners returns the rectangles at the corners of f containing the
+// rounded parts of the border and the joins of adjacent sides.
+func (f frame) corners() [4]image.Rectangle {
+	t, r, b, l := f.widths[0], f.widths[1], f.widths[2], f.widths[3]
+	var ks [4]image.Point
+	for i, w := range [4][2]int{{l, t}, {r, t}, {r, b}, {l, b}} {
+		k := int(math.Ceil(f.radii[i]))
+		ks[i] = image.Pt(maximum(k, w[0]), maximum(k, w[1]))
+	}
+	return cornerRects(f.size, ks)
+}
+
+// cornerRects returns the rectangles of the sizes ks at the top-left,
+// top-right, bottom-right and bottom-left corner within size.
+func cornerRects(size image.Point, ks [4]image.Point) [4]image.Rectangle {
+	return [4]image.Rectangle{
+		image.Rect(0, 0, ks[0].X, ks[0].Y),
+		image.Rect(size.X-ks[1].X, 0, size.X, ks[1].Y),
+		image.Rect(size.X-ks[2].X, size.Y-ks[2].Y, size.X, size.Y),
+		image.Rect(0, size.Y-ks[3].Y, ks[3].X, size.Y),
+	}
+}
+
+// overlap reports whether any of the corners cs overlap, which is the
+// case for small boxes.
+func overlap(cs [4]image.Rectangle) bool {
+	for i := range cs {
+		for j := i + 1; j < len(cs); j++ {
+			if cs[i].Overlaps(cs[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// edges returns the rectangles of the straight parts of the top,
+// right, bottom and left border between the corners cs.
+func (f frame) edges(cs [4]image.Rectangle) [4]image.Rectangle {
+	t, r, b, l := f.widths[0], f.widths[1], f.widths[2], f.widths[3]
+	return [4]image.Rectangle{
+		image.Rect(cs[0].Max.X, 0, cs[1].Min.X, t),
+		image.Rect(f.size.X-r, cs[1].Max.Y, f.size.X, cs[2].Min.Y),
+		image.Rect(cs[3].Max.X, f.size.Y-b, cs[2].Min.X, f.size.Y),
+		image.Rect(0, cs[0].Max.Y, l, cs[3].Min.Y),
+	}
+}
+
+// plain returns the color of corner i if it is filled by solid borders
+// of one color, i.e. it needs no raster.
+func (f frame) plain(i int) (c draw.Color, ok bool) {
+	if f.radii[i] > 0 {
+		return 0, false
+	}
+	found := false
+	for _, j := range []int{i, (i + 3) % 4} {
+		b := f.sides[j]
+		if f.widths[j] == 0 {
+			continue
+		}
+		if b.Style != BorderSolid || found && b.Color != c {
+			return 0, false
+		}
+		c = b.Color
+		found = true
+	}
+	return c, true
+}
+
+// raster returns the border of f within r.
+func (f frame) raster(r image.Rectangle) *image.RGBA {
+	or, orad := f.outer()
+	ir, irad := f.inner()
+	rgba := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := [2]float64{float64(x) + 0.5, float64(y) + 0.5}
+			a := coverage(roundedDist(p, or, orad)) * (1 - coverage(roundedDist(p, ir, irad)))
+			if a <= 0 {
+				continue
+			}
+			i, along, depth := f.side(p)
+			if i < 0 {
+				continue
+			}
+			b := f.sides[i]
+			a *= pattern(b.Style, float64(f.widths[i]), along, depth)
+			setPixel(rgba, x, y, b.Color, a)
+		}
+	}
+	return rgba
+}
+
+// pieces returns the border of f as rasterized corners and straight
+// edges drawn with replicated images, nil if there is no border.
+func (f frame) pieces(dui *duit.DUI) (ps pieces) {
+	if f.widths == [4]int{} || f.size.X <= 0 || f.size.Y <= 0 {
+		return nil
+	}
+	cs := f.corners()
+	if overlap(cs) {
+		return pieces{rasterPiece(dui, f.raster(rect(f.size)), false)}
+	}
+	for i, c := range cs {
+		if c.Empty() {
+			continue
+		}
+		if col, ok := f.plain(i); ok {
+			ps = append(ps, piece{r: c, i: colorImage(dui, col)})
+		} else {
+			ps = append(ps, rasterPiece(dui, f.raster(c), false))
+		}
+	}
+	for i, e := range f.edges(cs) {
+		if e.Empty() {
+			continue
+		}
+		vertical := i%2 == 1
+		sp := image.Pt(e.Min.X, 0)
+		if vertical {
+			sp = image.Pt(0, e.Min.Y)
+		}
+		ps = append(ps, piece{r: e, i: patternImage(dui, f.sides[i], f.widths[i], vertical), sp: sp})
+	}
+	return
+}
+
+// mask returns the coverage of the border edge within r as alpha of a
+// white image.
+func (f frame) mask(r image.Rectangle) *image.RGBA {
+	or, orad := f.outer()
+	rgba := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := [2]float64{float64(x) + 0.5, float64(y) + 0.5}
+			setPixel(rgba, x, y, draw.White, coverage(roundedDist(p, or, orad)))
+		}
+	}
+	return rgba
+}
+
+// maskCorners returns the rectangles of the rounded corners of the
+// border edge, the whole box if they overlap.
+func (f frame) maskCorners() (rs []image.Rectangle) {
+	var ks [4]image.Point
+	for i, rad := range f.radii {
+		k := int(math.Ceil(rad))
+		ks[i] = image.Pt(k, k)
+	}
+	cs := cornerRects(f.size, ks)
+	if overlap(cs) {
+		return []image.Rectangle{rect(f.size)}
+	}
+	for _, c := range cs {
+		if !c.Empty() {
+			rs = append(rs, c)
+		}
+	}
+	return
+}
+
+// shade is the shadow of a box: a rounded rectangle r with radii
+// within size, blurred by blur pixels and drawn at off relative to the
+// box.
+type shade struct {
+	size  image.Point
+	r     [4]float64
+	radii [4]float64
+	blur  int
+	color draw.Color
+	off   image.Point
+}
+
+func newShade(dui *duit.DUI, size image.Point, radius [4]int, s Shadow) (sh shade) {
+	spread := dui.Scale(s.Spread)
+	blur := dui.Scale(s.Blur)
+	ext := spread + blur
+	if ext < 0 {
+		ext = 0
+	}
+	sh.size = size.Add(image.Pt(2*ext, 2*ext))
+	for i, r := range radius {
+		if r > 0 {
+			sh.radii[i] = float64(dui.Scale(r) + spread)
+		}
+	}
+	d := float64(ext - spread)
+	sh.r = [4]float64{d, d, float64(sh.size.X) - d, float64(sh.size.Y) - d}
+	sh.radii = fitRadii(image.Pt(int(sh.r[2]-sh.r[0]), int(sh.r[3]-sh.r[1])), sh.radii)
+	sh.blur = blur
+	sh.color = s.Color
+	sh.off = image.Pt(dui.Scale(s.X)-ext, dui.Scale(s.Y)-ext)
+	return
+}
+
+// raster returns the shadow within r.
+func (sh shade) raster(r image.Rectangle) *image.RGBA {
+	rgba := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := [2]float64{float64(x) + 0.5, float64(y) + 0.5}
+			dist := roundedDist(p, sh.r, sh.radii)
+			if sh.blur > 0 {
+				dist /= float64(sh.blur)
+			}
+			setPixel(rgba, x, y, sh.color, coverage(dist))
+		}
+	}
+	return rgba
+}
+
+// band returns the depth from the edges of the shadow within which it
+// is not opaque.
+func (sh shade) band() int {
+	return int(math.Ceil(sh.r[0]+float64(sh.blur)/2)) + 1
+}
+
+// corners returns the rectangles at the corners of the shadow which
+// contain the rounded parts.
+func (sh shade) corners() [4]image.Rectangle {
+	var ks [4]image.Point
+	for i, rad := range sh.radii {
+		k := sh.band() + int(math.Ceil(rad))
+		ks[i] = image.Pt(k, k)
+	}
+	return cornerRects(sh.size, ks)
+}
+
+// pieces returns the rasterized corners of the shadow, its edges drawn
+// with replicated rows or columns of pixels and the opaque inside.
+func (sh shade) pieces(dui *duit.DUI) (ps pieces) {
+	if sh.size.X <= 0 || sh.size.Y <= 0 {
+		return nil
+	}
+	cs := sh.corners()
+	if overlap(cs) {
+		return pieces{rasterPiece(dui, sh.raster(rect(sh.size)), false).add(sh.off)}
+	}
+	for _, c := range cs {
+		ps = append(ps, rasterPiece(dui, sh.raster(c), false).add(sh.off))
+	}
+	e, X, Y := sh.band(), sh.size.X, sh.size.Y
+	for _, s := range [][2]image.Rectangle{
+		{image.Rect(cs[0].Max.X, 0, cs[1].Min.X, e), image.Rect(cs[0].Max.X, 0, cs[0].Max.X+1, e)},
+		{image.Rect(X-e, cs[1].Max.Y, X, cs[2].Min.Y), image.Rect(X-e, cs[1].Max.Y, X, cs[1].Max.Y+1)},
+		{image.Rect(cs[3].Max.X, Y-e, cs[2].Min.X, Y), image.Rect(cs[3].Max.X, Y-e, cs[3].Max.X+1, Y)},
+		{image.Rect(0, cs[0].Max.Y, e, cs[3].Min.Y), image.Rect(0, cs[0].Max.Y, e, cs[0].Max.Y+1)},
+	} {
+		if !s[0].Empty() {
+			p := rasterPiece(dui, sh.raster(s[1]), true)
+			p.r = s[0]
+			ps = append(ps, p.add(sh.off))
+		}
+	}
+	for _, r := range subtract(rect(sh.size).Inset(e), cs[:]) {
+		ps = append(ps, piece{r: r.Add(sh.off), i: colorImage(dui, sh.color)})
+	}
+	return
+}
+
+// subtract returns rectangles covering r except for holes.
+func subtract(r image.Rectangle, holes []image.Rectangle) []image.Rectangle {
+	for i, h := range holes {
+		h = h.Intersect(r)
+		if h.Empty() {
+			continue
+		}
+		var rs []image.Rectangle
+		for _, p := range []image.Rectangle{
+			image.Rect(r.Min.X, r.Min.Y, r.Max.X, h.Min.Y),
+			image.Rect(r.Min.X, h.Max.Y, r.Max.X, r.Max.Y),
+			image.Rect(r.Min.X, h.Min.Y, h.Min.X, h.Max.Y),
+			image.Rect(h.Max.X, h.Min.Y, r.Max.X, h.Max.Y),
+		} {
+			if !p.Empty() {
+				rs = append(rs, subtract(p, holes[i+1:])...)
+			}
+		}
+		return rs
+	}
+	if r.Empty() {
+		return nil
+	}
+	return []image.Rectangle{r}
+}
+
+// setPixel sets the premultiplied color c with coverage a.
+func setPixel(rgba *image.RGBA, x, y int, c draw.Color, a float64) {
+	if a <= 0 {
+		return
+	}
+	i := rgba.PixOffset(x, y)
+	rgba.Pix[i+0] = uint8(float64(c>>24&0xff) * a)
+	rgba.Pix[i+1] = uint8(float64(c>>16&0xff) * a)
+	rgba.Pix[i+2] = uint8(float64(c>>8&0xff) * a)
+	rgba.Pix[i+3] = uint8(float64(c&0xff) * a)
+}
+
+// piece of a decoration drawn at r relative to the box from i at sp.
+// Images which are not owned are shared between boxes.
+type piece struct {
+	r   image.Rectangle
+	i   *draw.Image
+	sp  image.Point
+	own bool
+}
+
+// rasterPiece returns a piece drawing rgba, whose bounds are relative
+// to the box.
+func rasterPiece(dui *duit.DUI, rgba *image.RGBA, repl bool) piece {
+	r := rgba.Bounds()
+	return piece{r: r, i: loadRGBA(dui, rgba, repl), sp: r.Min, own: true}
+}
+
+func (p piece) add(pt image.Point) piece {
+	p.r = p.r.Add(pt)
+	return p
+}
+
+type pieces []piece
+
+func (ps pieces) draw(img *draw.Image, orig image.Point) {
+	for _, p := range ps {
+		if p.i != nil {
+			img.Draw(p.r.Add(orig), p.i, nil, p.sp)
+		}
+	}
+}
+
+func (ps pieces) free() {
+	for _, p := range ps {
+		if p.own && p.i != nil {
+			p.i.Free()
+		}
+	}
+}
+
+// decorations are the images of borders, outline and shadow of a Box
+// for a size. Only rounded or patterned corners and the blurred
+// corners and edges of shadows are rasterized.
+type decorations struct {
+	size    image.Point
+	mask    pieces // rounded corners of the border edge
+	border  pieces
+	outline pieces
+	shadow  pieces
+}
+
+func (d *decorations) free() {
+	for _, ps := range []pieces{d.mask, d.border, d.outline, d.shadow} {
+		ps.free()
+	}
+}
+
+// genDraw draws src at dst like img.Draw, but clipped to the rounded
+// border edge of the box at orig.
+func (d *decorations) genDraw(img *draw.Image, dst image.Rectangle, orig image.Point, src *draw.Image, sp image.Point) {
+	if d == nil || len(d.mask) == 0 {
+		img.Draw(dst, src, nil, sp)
+		return
+	}
+	holes := make([]image.Rectangle, 0, len(d.mask))
+	for _, p := range d.mask {
+		pr := p.r.Add(orig)
+		holes = append(holes, pr)
+		r := pr.Intersect(dst)
+		if r.Empty() || p.i == nil {
+			continue
+		}
+		img.GenDraw(r, src, sp.Add(r.Min.Sub(dst.Min)), p.i, p.sp.Add(r.Min.Sub(pr.Min)))
+	}
+	for _, r := range subtract(dst, holes) {
+		img.Draw(r, src, nil, sp.Add(r.Min.Sub(dst.Min)))
+	}
+}
+
+func loadRGBA(dui *duit.DUI, rgba *image.RGBA, repl bool) *draw.Image {
+	if rgba == nil {
+		return nil
+	}
+	i, err := dui.Display.AllocImage(rgba.Bounds(), draw.ABGR32, repl, draw.Transparent)
+	if err != nil {
+		log.Errorf("alloc image: %v", err)
+		return nil
+	}
+	if _, err := i.Load(rgba.Bounds(), rgba.Pix); err != nil {
+		log.Errorf("load image: %v", err)
+		i.Free()
+		return nil
+	}
+	return i
+}
+
+// colorImages are replicated images by color to draw solid areas.
+var colorImages = make(map[draw.Color]*draw.Image)
+
+func colorImage(dui *duit.DUI, c draw.Color) *draw.Image {
+	if i, ok := colorImages[c]; ok {
+		return i
+	}
+	i, err := dui.Display.AllocImage(image.Rect(0, 0, 1, 1), draw.ARGB32, true, c)
+	if err != nil {
+		log.Errorf("alloc image: %v", err)
+		return nil
+	}
+	colorImages[c] = i
+	return i
+}
+
+type patternKey struct {
+	b        Border
+	w        int
+	vertical bool
+}
+
+// patterns are replicated images of one period of straight edges by
+// style, color and scaled width.
+var patterns = make(map[patternKey]*draw.Image)
+
+// patternImage returns the replicated straight edge of the border b of
+// width w along x, or along y if vertical.
+func patternImage(dui *duit.DUI, b Border, w int, vertical bool) *draw.Image {
+	if b.Style == BorderSolid {
+		return colorImage(dui, b.Color)
+	}
+	k := patternKey{b, w, vertical}
+	k.b.Width = 0
+	if i, ok := patterns[k]; ok {
+		return i
+	}
+	i := loadRGBA(dui, patternCell(b, w, vertical), true)
+	if i != nil {
+		patterns[k] = i
+	}
+	return i
+}
+
+// patternCell rasterizes one period of the edge of border b of width w.
+func patternCell(b Border, w int, vertical bool) *image.RGBA {
+	period := 1
+	switch b.Style {
+	case BorderDashed:
+		period = 2 * maximum(3*w, 3)
+	case BorderDotted:
+		period = maximum(2*w, 2)
+	}
+	sz := image.Pt(period, w)
+	if vertical {
+		sz = image.Pt(w, period)
+	}
+	rgba := image.NewRGBA(rect(sz))
+	for a := 0; a < period; a++ {
+		for d := 0; d < w; d++ {
+			x, y := a, d
+			if vertical {
+				x, y = d, a
+			}
+			setPixel(rgba, x, y, b.Color, pattern(b.Style, float64(w), float64(a)+0.5, float64(d)+0.5))
+		}
+	}
+	return rgba
+}
+
+var transparent *draw.Image
+
+// clearImage returns a transparent image, e.g. to draw a Box without
+// background.
+func clearImage(dui *duit.DUI) *draw.Image {
+	if transparent == nil {
+		var err error
+		transparent, err = dui.Display.AllocImage(image.Rect(0, 0, 1, 1), draw.ARGB32, true, draw.Transparent)
+		if err != nil {
+			log.Errorf("alloc image: %v", err)
+		}
+	}
+	return transparent
+}
+
+func (ui *Box) decorated() bool {
+	return ui.Border.Space() != duit.Space{} || ui.Border.rounded() || ui.Outline.Style != BorderNone && ui.Outline.Width > 0 || ui.Shadow != nil
+}
+
+// decorations returns the images for the box of size.
+func (ui *Box) decorations(dui *duit.DUI, size image.Point) *decorations {
+	if ui.deco != nil && ui.deco.size == size {
+		return ui.deco
+	}
+	if ui.deco != nil {
+		ui.deco.free()
+	}
+	d := &decorations{size: size}
+	f := newFrame(dui, size, ui.Border)
+	if ui.Border.rounded() {
+		for _, r := range f.maskCorners() {
+			d.mask = append(d.mask, rasterPiece(dui, f.mask(r), false))
+		}
+	}
+	d.border = f.pieces(dui)
+	if o := ui.Outline; o.Style != BorderNone && o.Width > 0 {
+		ext := dui.Scale(o.Width + ui.OutlineOffset)
+		bs := Borders{Top: o, Right: o, Bottom: o, Left: o}
+		for _, p := range newFrame(dui, size.Add(image.Pt(2*ext, 2*ext)), bs).pieces(dui) {
+			d.outline = append(d.outline, p.add(image.Pt(-ext, -ext)))
+		}
+	}
+	if ui.Shadow != nil {
+		d.shadow = newShade(dui, size, ui.Border.Radius, *ui.Shadow).pieces(dui)
+	}
+	ui.deco = d
+	return d
+}
+
+// Free releases the images of the box on the display, e.g. when it is
+// discarded. They are allocated again when the box is drawn.
+func (ui *Box) Free() {
+	if ui.deco != nil {
+		ui.deco.free()
+		ui.deco = nil
+	}
+	ui.freeLayers()
+	ui.freeLayer()
+}
+
+// drawBackground draws the shadow and the rounded background.
+func (ui *Box) drawBackground(dui *duit.DUI, img *draw.Image, orig image.Point, size image.Point) {
+	if ui.decorated() {
+		ui.decorations(dui, size).shadow.draw(img, orig)
+	}
+	ui.fillBackground(dui, img, orig, size, rect(size).Add(orig))
+}
+
+// drawBorders draws the borders and the outline.
+func (ui *Box) drawBorders(dui *duit.DUI, img *draw.Image, orig image.Point, size image.Point) {
+	d := ui.decorations(dui, size)
+	d.border.draw(img, orig)
+	d.outline.draw(img, orig)
+}
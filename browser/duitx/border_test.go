@@ -0,0 +1,215 @@
+package duitx
+
+import (
+	"image"
+	"testing"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+)
+
+func solid(w int, c draw.Color) Border {
+	return Border{Width: w, Style: BorderSolid, Color: c}
+}
+
+func TestBorderLayout(t *testing.T) {
+	b := &Box{
+		Kids:    duit.NewKids(&fixed{image.Pt(50, 20)}),
+		Padding: duit.SpaceXY(2, 1),
+		Border: Borders{
+			Top:  solid(3, draw.Red),
+			Left: solid(4, draw.Red),
+			// no style means no border
+			Right: Border{Width: 5},
+		},
+	}
+	k := layout(b, 100)
+	if k.R.Size() != image.Pt(4+2+50+2, 3+1+20+1) {
+		t.Fatalf("%v", k.R)
+	}
+	if b.Kids[0].R.Min != image.Pt(6, 4) {
+		t.Fatalf("%v", b.Kids[0].R)
+	}
+}
+
+func alpha(rgba *image.RGBA, x, y int) uint8 {
+	return rgba.Pix[rgba.PixOffset(x, y)+3]
+}
+
+func TestBorderRaster(t *testing.T) {
+	dui := testDUI()
+	bs := Borders{
+		Top:    solid(2, draw.Red),
+		Right:  solid(2, draw.Green),
+		Bottom: Border{Width: 2, Style: BorderDashed, Color: draw.Blue},
+		Left:   Border{Width: 6, Style: BorderDouble, Color: draw.Black},
+	}
+	rgba := newFrame(dui, image.Pt(40, 20), bs).raster(rect(image.Pt(40, 20)))
+	if c := rgba.RGBAAt(20, 0); c.R != 0xff || c.A != 0xff || c.G != 0 {
+		t.Errorf("top %+v", c)
+	}
+	if c := rgba.RGBAAt(39, 10); c.G != 0xff || c.A != 0xff {
+		t.Errorf("right %+v", c)
+	}
+	if alpha(rgba, 20, 10) != 0 || alpha(rgba, 20, 2) != 0 {
+		t.Errorf("padding not transparent")
+	}
+	// dashes of 6px
+	if alpha(rgba, 14, 19) != 0xff || alpha(rgba, 8, 19) != 0 {
+		t.Errorf("dashes %v %v", alpha(rgba, 14, 19), alpha(rgba, 8, 19))
+	}
+	// two lines of 2px
+	if alpha(rgba, 0, 10) != 0xff || alpha(rgba, 2, 10) != 0 || alpha(rgba, 5, 10) != 0xff {
+		t.Errorf("double %v %v %v", alpha(rgba, 0, 10), alpha(rgba, 2, 10), alpha(rgba, 5, 10))
+	}
+	if newFrame(dui, image.Pt(40, 20), Borders{}).pieces(dui) != nil {
+		t.Errorf("pieces without borders")
+	}
+}
+
+func TestBorderRadius(t *testing.T) {
+	dui := testDUI()
+	bs := Borders{
+		Top:    solid(1, draw.Black),
+		Right:  solid(1, draw.Black),
+		Bottom: solid(1, draw.Black),
+		Left:   solid(1, draw.Black),
+		Radius: [4]int{10, 0, 1000, 0},
+	}
+	f := newFrame(dui, image.Pt(40, 20), bs)
+	if f.radii != [4]float64{0.2, 0, 20, 0} {
+		// 1000 is reduced to fit the height
+		t.Fatalf("%v", f.radii)
+	}
+	bs.Radius = [4]int{10, 0, 0, 0}
+	f = newFrame(dui, image.Pt(40, 20), bs)
+	rgba := f.raster(rect(f.size))
+	if alpha(rgba, 0, 0) != 0 || alpha(rgba, 39, 0) != 0xff || alpha(rgba, 0, 19) != 0xff {
+		t.Errorf("corners %v %v %v", alpha(rgba, 0, 0), alpha(rgba, 39, 0), alpha(rgba, 0, 19))
+	}
+	// anti-aliased arc
+	if a := alpha(rgba, 3, 2); a == 0 || a == 0xff {
+		t.Errorf("arc %v", a)
+	}
+	mask := f.mask(rect(f.size))
+	if alpha(mask, 0, 0) != 0 || alpha(mask, 20, 10) != 0xff || alpha(mask, 1, 10) != 0xff {
+		t.Errorf("mask")
+	}
+}
+
+func TestShadow(t *testing.T) {
+	dui := testDUI()
+	sh := newShade(dui, image.Pt(20, 10), [4]int{}, Shadow{X: 3, Y: 4, Blur: 4, Spread: 1, Color: draw.Black})
+	rgba := sh.raster(rect(sh.size))
+	if sh.off != image.Pt(3-5, 4-5) || rgba.Bounds().Size() != image.Pt(30, 20) {
+		t.Fatalf("%v %v", sh.off, rgba.Bounds())
+	}
+	if alpha(rgba, 15, 10) != 0xff || alpha(rgba, 0, 0) != 0 {
+		t.Errorf("%v %v", alpha(rgba, 15, 10), alpha(rgba, 0, 0))
+	}
+	if a := alpha(rgba, 4, 10); a == 0 || a == 0xff {
+		t.Errorf("blur %v", a)
+	}
+}
+
+func TestBorderCorners(t *testing.T) {
+	dui := testDUI()
+	b := solid(1, draw.Black)
+	bs := Borders{Top: b, Right: b, Bottom: b, Left: solid(2, draw.Black)}
+	f := newFrame(dui, image.Pt(40, 20), bs)
+	cs := f.corners()
+	if cs[0] != image.Rect(0, 0, 2, 1) || cs[2] != image.Rect(39, 19, 40, 20) {
+		t.Fatalf("%v", cs)
+	}
+	for i := range cs {
+		if _, ok := f.plain(i); !ok {
+			t.Errorf("corner %v rasterized", i)
+		}
+	}
+	if es := f.edges(cs); es[0] != image.Rect(2, 0, 39, 1) || es[3] != image.Rect(0, 1, 2, 19) {
+		t.Errorf("%v", es)
+	}
+
+	bs.Top.Color = draw.Red
+	bs.Radius = [4]int{0, 0, 5, 0}
+	f = newFrame(dui, image.Pt(40, 20), bs)
+	cs = f.corners()
+	if _, ok := f.plain(0); ok {
+		t.Errorf("corner of two colors not rasterized")
+	}
+	if _, ok := f.plain(2); ok || cs[2] != image.Rect(35, 15, 40, 20) {
+		t.Errorf("rounded corner %v", cs[2])
+	}
+	if _, ok := f.plain(3); !ok {
+		t.Errorf("plain corner rasterized")
+	}
+	if rs := f.maskCorners(); len(rs) != 1 || rs[0] != cs[2] {
+		t.Errorf("%v", rs)
+	}
+}
+
+func TestShadowEdges(t *testing.T) {
+	dui := testDUI()
+	sh := newShade(dui, image.Pt(100, 50), [4]int{8, 0, 0, 0}, Shadow{Blur: 6, Color: draw.Black})
+	cs := sh.corners()
+	if overlap(cs) {
+		t.Fatalf("%v", cs)
+	}
+	// the edges outside the corners are the same along them
+	e := sh.band()
+	a := sh.raster(image.Rect(cs[0].Max.X, 0, cs[0].Max.X+1, e))
+	b := sh.raster(image.Rect(50, 0, 51, e))
+	for y := 0; y < e; y++ {
+		if a.RGBAAt(cs[0].Max.X, y) != b.RGBAAt(50, y) {
+			t.Errorf("%v: %v %v", y, a.RGBAAt(cs[0].Max.X, y), b.RGBAAt(50, y))
+		}
+	}
+	if alpha(b, 50, e-1) != 0xff || alpha(sh.raster(image.Rect(50, e, 51, e+1)), 50, e) != 0xff {
+		t.Errorf("not opaque inside the band")
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	r := image.Rect(0, 0, 10, 10)
+	rs := subtract(r, []image.Rectangle{image.Rect(0, 0, 3, 3), image.Rect(8, 8, 12, 12)})
+	area := 0
+	for i, a := range rs {
+		area += a.Dx() * a.Dy()
+		for _, b := range rs[i+1:] {
+			if a.Overlaps(b) {
+				t.Errorf("%v %v", a, b)
+			}
+		}
+	}
+	if area != 100-9-4 {
+		t.Errorf("%v %v", area, rs)
+	}
+}
+
+func TestPatternCell(t *testing.T) {
+	dui := testDUI()
+	for _, st := range []BorderStyle{BorderDashed, BorderDotted, BorderDouble} {
+		b := Border{Width: 4, Style: st, Color: draw.Blue}
+		f := newFrame(dui, image.Pt(60, 30), Borders{Top: b, Right: b, Bottom: b, Left: b})
+		rgba := f.raster(rect(f.size))
+		h, v := patternCell(b, 4, false), patternCell(b, 4, true)
+		// bottom and right edges drawn from the cells like in pieces
+		e := f.edges(f.corners())
+		for x := e[2].Min.X; x < e[2].Max.X; x++ {
+			for y := e[2].Min.Y; y < e[2].Max.Y; y++ {
+				c := h.RGBAAt(x%h.Bounds().Dx(), y-e[2].Min.Y)
+				if c != rgba.RGBAAt(x, y) {
+					t.Fatalf("%v bottom %v,%v: %v %v", st, x, y, c, rgba.RGBAAt(x, y))
+				}
+			}
+		}
+		for y := e[1].Min.Y; y < e[1].Max.Y; y++ {
+			for x := e[1].Min.X; x < e[1].Max.X; x++ {
+				c := v.RGBAAt(x-e[1].Min.X, y%v.Bounds().Dy())
+				if c != rgba.RGBAAt(x, y) {
+					t.Fatalf("%v right %v,%v: %v %v", st, x, y, c, rgba.RGBAAt(x, y))
+				}
+			}
+		}
+	}
+}
@@ -52,6 +52,17 @@ const (
 	Block              // always start a new line
 	Inline             // flow inline but ignore margin, width and height
 	Flex
+	InlineFlex
+)
+
+// TextAlign of the lines of a box.
+type TextAlign int
+
+const (
+	TextLeft TextAlign = iota
+	TextCenter
+	TextRight
+	TextJustify
 )
 
 type Dir int
@@ -66,26 +77,72 @@ type Boxable interface {
 	FlexDir() Dir
 }
 
+// Breaker is implemented by UIs that can force a line break after
+// them, like labels ending with a preserved newline.
+type Breaker interface {
+	BreaksLine() bool
+}
+
+// breaksLine returns whether a line break is forced after k, also
+// when its UI is wrapped.
+func breaksLine(k *duit.Kid) bool {
+	for ui := k.UI; ui != nil; {
+		if b, ok := ui.(Breaker); ok {
+			return b.BreaksLine()
+		}
+		w, ok := ui.(Wrapper)
+		if !ok {
+			break
+		}
+		ui = w.Unwrap()
+	}
+	return false
+}
+
 // Box keeps elements on a line as long as they fit, then moves on to the next line.
 type Box struct {
-	Kids       []*duit.Kid // Kids and UIs in this box.
-	Reverse    bool        // Lay out children from bottom to top. First kid will be at the bottom.
-	Margin     duit.Space  // In lowDPI pixels, will be adjusted for highDPI screens.
-	Padding    duit.Space  // Padding inside box, so children don't touch the sides; in lowDPI pixels, also adjusted for highDPI screens.
-	Valign     duit.Valign // How to align children on a line.
-	Width      int         // 0 means dynamic (as much as needed), -1 means full width, >0 means that exact amount of lowDPI pixels.
-	Height     int         // 0 means dynamic (as much as needed), -1 means full height, >0 means that exact amount of lowDPI pixels.
-	MaxWidth   int         // if >0, the max number of lowDPI pixels that will be used.
-	ContentBox bool        // Use ContentBox (BorderBox by default)
-	Disp       Display
-	Dir        Dir
-	Background *draw.Image `json:"-"` // Background for this box, instead of default duit background.
-
-	size image.Point // of entire box, including padding but excluding margin
+	Kids          []*duit.Kid // Kids and UIs in this box.
+	Reverse       bool        // Lay out children from bottom to top. First kid will be at the bottom.
+	Margin        duit.Space  // In lowDPI pixels, will be adjusted for highDPI screens.
+	Padding       duit.Space  // Padding inside box, so children don't touch the sides; in lowDPI pixels, also adjusted for highDPI screens.
+	Valign        duit.Valign // How to align children on a line.
+	Width         int         // 0 means dynamic (as much as needed), -1 means full width, >0 means that exact amount of lowDPI pixels.
+	Height        int         // 0 means dynamic (as much as needed), -1 means full height, >0 means that exact amount of lowDPI pixels.
+	MaxWidth      int         // if >0, the max number of lowDPI pixels that will be used.
+	ContentBox    bool        // Use ContentBox (BorderBox by default)
+	Disp          Display
+	Dir           Dir
+	Flex          FlexContainer     // Flex container properties if Disp is Flex or InlineFlex.
+	Floating      Float             // Float to the left or right within the parent box.
+	Clearing      Clear             // Start below floats of the parent box.
+	BFC           bool              // Establishes a block formatting context, i.e. is placed next to floats instead of flowing around them.
+	TextAlign     TextAlign         // Alignment of lines of inline content, ignored for Inline boxes.
+	NoWrap        bool              // Keep inline content on one line unless a line break is forced.
+	Marker        duit.UI           // List marker drawn left of the first line, nil means none.
+	Background    *draw.Image       `json:"-"` // Background for this box, instead of default duit background.
+	Border        Borders           // Borders between margin and padding.
+	Outline       Border            // Outline drawn around the border.
+	OutlineOffset int               // Distance of the outline to the border in lowDPI pixels.
+	Shadow        *Shadow           // Box shadow, nil means none.
+	Layers        []BackgroundLayer // Background images drawn over Background, the first on top.
+	Transparency  float64           // Of the box and its kids from 0 (opaque) to 1 (invisible), i.e. 1 - opacity.
+	ClipX, ClipY  bool              // Clip the kids to the padding box horizontally or vertically, a fixed Width or Height is kept.
+
+	size       image.Point // of entire box, including padding and border but excluding margin
+	exclusions []Exclusion // floats of preceding siblings
+	inFlow     bool        // laid out by a parent flow
+	overhang   []Exclusion // floats extending below the box, passed to the parent flow
+	deco       *decorations
+	marker     duit.Kid
+	layers     []layerTile // tiles of the background layers
+	layer      *draw.Image // offscreen image of a translucent box
+	backdrop   *draw.Image // what is behind a translucent box
 }
 
 var _ duit.UI = &Box{}
 var _ Boxable = &Box{}
+var _ Floater = &Box{}
+var _ Excluder = &Box{}
 
 func (ui *Box) Display() Display {
 	return ui.Disp
@@ -97,6 +154,11 @@ func (ui *Box) FlexDir() Dir {
 
 func (ui *Box) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, force bool) {
 	debugLayout(dui, self)
+	// the flow context passed by Exclude applies to this layout only
+	defer func() {
+		ui.exclusions = nil
+		ui.inFlow = false
+	}()
 	if duit.KidsLayout(dui, self, ui.Kids, force) {
 		return
 	}
@@ -105,7 +167,7 @@ func (ui *Box) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, forc
 		panic("combination ui.Width < 0 and ui.MaxWidth > 0 invalid")
 	}
 
-	padding := dui.ScaleSpace(ui.Padding)
+	padding := ui.padding(dui)
 	margin := dui.ScaleSpace(ui.Margin)
 
 	// widths and heights
@@ -136,8 +198,37 @@ func (ui *Box) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, forc
 		sizeAvail.Y = bbh
 	}
 	sizeAvail = sizeAvail.Sub(padding.Size()).Sub(margin.Size())
-	nx := 0 // number on current line
 
+	var content image.Point
+	if ui.Disp == Flex || ui.Disp == InlineFlex {
+		content = ui.layoutFlex(dui, sizeAvail, padding)
+	} else {
+		content = ui.layoutFlow(dui, sizeAvail, padding, margin)
+	}
+
+	if ui.Marker != nil {
+		ui.layoutMarker(dui, sizeAvail, padding)
+	}
+
+	ui.size = content.Add(padding.Size())
+	if ui.Width < 0 {
+		ui.size.X = osize.X
+	}
+	if ui.Height < 0 && ui.size.Y < osize.Y {
+		ui.size.Y = osize.Y
+	}
+	if ui.ClipX && ui.Width > 0 {
+		ui.size.X = minimum(bbw, osize.X) - margin.Dx()
+	}
+	if ui.ClipY && ui.Height > 0 {
+		ui.size.Y = bbh - margin.Dy()
+	}
+	self.R = rect(ui.size.Add(margin.Size()))
+}
+
+// layoutFlow lays out the kids in lines and returns the size of the
+// contents.
+func (ui *Box) layoutFlow(dui *duit.DUI, sizeAvail image.Point, padding, margin duit.Space) image.Point {
 	// variables below are about box contents excluding offsets for padding and margin
 	cur := image.ZP
 	xmax := 0  // max x seen so far
@@ -158,42 +249,128 @@ func (ui *Box) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, forc
 		}
 	}
 
-	for i, k := range ui.Kids {
+	// floats in content coordinates, starting with those of preceding
+	// siblings in the parent box
+	fs := make(floats, 0, len(ui.exclusions))
+	for _, ex := range ui.exclusions {
+		fs = append(fs, Exclusion{
+			R:    ex.R.Sub(margin.Topleft()).Sub(padding.Topleft()),
+			Side: ex.Side,
+		})
+	}
+	inherited := len(fs)
+
+	line := make([]*duit.Kid, 0, len(ui.Kids)) // kids on current line
+	lineX1 := sizeAvail.X                      // end of current line
+	fixAlign := func(kids []*duit.Kid, wrapped bool) {
+		if ui.alignLine(kids, lineX1+padding.Left, wrapped) {
+			xmax = maximum(xmax, lineX1)
+		}
+	}
+	newLine := func(wrapped bool) {
+		if len(line) > 0 {
+			fixValign(line)
+			fixAlign(line, wrapped)
+			cur.X = 0
+			cur.Y += lineY + margin.Topleft().Y
+			line = line[:0]
+			lineY = 0
+		}
+	}
+
+	for _, k := range ui.Kids {
+		fl, cl, bfc := floatOf(k)
+		d := display(k)
+		shouldCol := d == Block || d == Flex
+		if cl != ClearNone && len(fs) > 0 {
+			newLine(false)
+			cur.Y = fs.clear(cur.Y, cl)
+		}
+		if shouldCol && fl == FloatNone {
+			newLine(false)
+		}
+		if ex, ok := k.UI.(Excluder); ok && fl == FloatNone {
+			var exs []Exclusion
+			if shouldCol && !bfc {
+				exs = fs.within(cur.Y, cur)
+			}
+			ex.Exclude(exs)
+		}
 		k.UI.Layout(dui, k, sizeAvail.Sub(image.Pt(0, cur.Y+lineY)), true)
 		childSize := k.R.Size()
-		var kr image.Rectangle
-		var shouldCol bool
-		if ui.Disp == Flex {
-			shouldCol = ui.Dir == Column
-		} else if display(k) == Block {
-			shouldCol = true
+
+		if fl != FloatNone {
+			// Take out of the flow
+			y := cur.Y
+			if len(line) > 0 {
+				y += lineY
+			}
+			y, x0, x1 := fs.fit(y, childSize.X, childSize.Y, sizeAvail.X)
+			x := x0
+			if fl == FloatRight {
+				x = maximum(x0, x1-childSize.X)
+			}
+			r := rect(childSize).Add(image.Pt(x, y))
+			fs = append(fs, Exclusion{R: r, Side: fl})
+			k.R = r.Add(padding.Topleft())
+			xmax = maximum(xmax, r.Max.X)
+			continue
 		}
-		if (nx == 0 || cur.X+childSize.X <= sizeAvail.X) && !shouldCol {
-			// Put on same line
-			kr = rect(childSize).Add(cur).Add(padding.Topleft())
-			cur.X += childSize.X
-			lineY = maximum(lineY, childSize.Y)
-			nx += 1
+
+		x0, x1 := fs.bounds(cur.Y, maximum(lineY, childSize.Y), sizeAvail.X)
+		if shouldCol {
+			if bfc && len(fs) > 0 {
+				// Place next to the floats
+				cur.Y, x0, x1 = fs.fit(cur.Y, 0, childSize.Y, sizeAvail.X)
+				if x1-x0 < childSize.X {
+					k.UI.Layout(dui, k, image.Pt(x1-x0, sizeAvail.Y-cur.Y), true)
+					childSize = k.R.Size()
+				}
+				cur.X = x0
+			}
 		} else {
-			// Put on new line
-			if nx > 0 {
-				fixValign(ui.Kids[i-nx : i])
-				cur.X = 0
-				cur.Y += lineY + margin.Topleft().Y
+			if len(line) > 0 && cur.X+childSize.X > x1 && !ui.NoWrap {
+				newLine(true)
+			}
+			if len(line) == 0 {
+				if len(fs) > 0 {
+					cur.Y, x0, x1 = fs.fit(cur.Y, childSize.X, childSize.Y, sizeAvail.X)
+				}
+				cur.X = x0
+			}
+		}
+		lineX1 = x1
+		// Add padding translation, so the child UI can be drawn right there
+		k.R = rect(childSize).Add(cur).Add(padding.Topleft())
+		if ex, ok := k.UI.(Excluder); ok {
+			for _, f := range ex.Floats() {
+				fs = append(fs, Exclusion{R: f.R.Add(cur), Side: f.Side})
 			}
-			// Add padding translation, so the child UI can be drawn right there
-			kr = rect(childSize).Add(cur).Add(padding.Topleft())
-			nx = 1
-			cur.X = childSize.X
-			lineY = childSize.Y
 		}
-		k.R = kr
+		cur.X += childSize.X
+		lineY = maximum(lineY, childSize.Y)
+		line = append(line, k)
 		if xmax < cur.X {
 			xmax = cur.X
 		}
+		if breaksLine(k) && !shouldCol {
+			newLine(false)
+		}
 	}
-	fixValign(ui.Kids[len(ui.Kids)-nx : len(ui.Kids)])
+	fixValign(line)
+	fixAlign(line, false)
 	cur.Y += lineY
+	ui.overhang = nil
+	for _, f := range fs[inherited:] {
+		if ui.containsFloats() {
+			cur.Y = maximum(cur.Y, f.R.Max.Y)
+		} else if f.R.Max.Y > cur.Y {
+			ui.overhang = append(ui.overhang, Exclusion{
+				R:    f.R.Add(padding.Topleft()).Add(margin.Topleft()),
+				Side: f.Side,
+			})
+		}
+	}
 
 	if ui.Reverse {
 		bottomY := cur.Y + padding.Dy()
@@ -204,14 +381,42 @@ func (ui *Box) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, forc
 		}
 	}
 
-	ui.size = image.Pt(xmax, cur.Y).Add(padding.Size())
-	if ui.Width < 0 {
-		ui.size.X = osize.X
+	return image.Pt(xmax, cur.Y)
+}
+
+// alignLine moves the kids of a line ending at x1 according to
+// TextAlign. Only lines that wrapped are justified.
+func (ui *Box) alignLine(kids []*duit.Kid, x1 int, wrapped bool) (aligned bool) {
+	if ui.TextAlign == TextLeft || ui.Disp == Inline || len(kids) == 0 {
+		return false
 	}
-	if ui.Height < 0 && ui.size.Y < osize.Y {
-		ui.size.Y = osize.Y
+	for _, k := range kids {
+		if d := display(k); d == Block || d == Flex {
+			return false
+		}
 	}
-	self.R = rect(ui.size.Add(margin.Size()))
+	extra := x1 - kids[len(kids)-1].R.Max.X
+	if extra <= 0 {
+		return true
+	}
+	switch ui.TextAlign {
+	case TextCenter:
+		for _, k := range kids {
+			k.R = k.R.Add(image.Pt(extra/2, 0))
+		}
+	case TextRight:
+		for _, k := range kids {
+			k.R = k.R.Add(image.Pt(extra, 0))
+		}
+	case TextJustify:
+		if !wrapped || len(kids) < 2 {
+			return true
+		}
+		for i, k := range kids {
+			k.R = k.R.Add(image.Pt(extra*i/(len(kids)-1), 0))
+		}
+	}
+	return true
 }
 
 func display(k *duit.Kid) (d Display) {
@@ -221,17 +426,79 @@ func display(k *duit.Kid) (d Display) {
 	return InlineBlock
 }
 
+// padding returns the scaled padding including the borders.
+func (ui *Box) padding(dui *duit.DUI) duit.Space {
+	p := dui.ScaleSpace(ui.Padding)
+	b := dui.ScaleSpace(ui.Border.Space())
+	return duit.Space{
+		Top:    p.Top + b.Top,
+		Right:  p.Right + b.Right,
+		Bottom: p.Bottom + b.Bottom,
+		Left:   p.Left + b.Left,
+	}
+}
+
 func (ui *Box) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
+	if ui.Transparency > 0 {
+		ui.drawTranslucent(dui, self, img, orig, m, force)
+		return
+	}
 	margin := dui.ScaleSpace(ui.Margin)
 	orig = orig.Add(margin.Topleft())
-	duit.KidsDraw(dui, self, ui.Kids, ui.size, ui.Background, img, orig, m, force)
+	ui.DrawSize(dui, self, img, orig, m, force, ui.size)
+}
+
+// DrawSize draws the box without margin with its background, borders
+// and decorations covering size.
+func (ui *Box) DrawSize(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool, size image.Point) {
+	if ui.Marker != nil && (force || self.Draw == duit.Dirty) {
+		defer ui.drawMarker(dui, img, orig, m)
+	}
+	if !ui.decorated() && len(ui.Layers) == 0 {
+		defer ui.clip(dui, img, orig, size)()
+		duit.KidsDraw(dui, self, ui.Kids, size, ui.Background, img, orig, m, force)
+		return
+	}
+	force = force || self.Draw == duit.Dirty
+	if force {
+		ui.drawBackground(dui, img, orig, size)
+	}
+	unclip := ui.clip(dui, img, orig, size)
+	kidsDraw(dui, self, ui.Kids, img, orig, m, force, func(r image.Rectangle) {
+		ui.fillBackground(dui, img, orig, size, r)
+	})
+	unclip()
+	if force && ui.decorated() {
+		ui.drawBorders(dui, img, orig, size)
+	}
+}
+
+// clip restricts drawing on img to the padding box along the clipped
+// axes and returns a function restoring the previous clip rectangle.
+func (ui *Box) clip(dui *duit.DUI, img *draw.Image, orig image.Point, size image.Point) (unclip func()) {
+	if !ui.ClipX && !ui.ClipY {
+		return func() {}
+	}
+	tmp := img.Clipr
+	r := ui.area(dui, PaddingBox, size).Add(orig)
+	cr := tmp
+	if ui.ClipX {
+		cr.Min.X, cr.Max.X = r.Min.X, r.Max.X
+	}
+	if ui.ClipY {
+		cr.Min.Y, cr.Max.Y = r.Min.Y, r.Max.Y
+	}
+	img.ReplClipr(false, cr.Intersect(tmp))
+	return func() {
+		img.ReplClipr(false, tmp)
+	}
 }
 
 func (ui *Box) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) (r duit.Result) {
 	margin := dui.ScaleSpace(ui.Margin)
 	origM.Point = origM.Point.Sub(margin.Topleft())
 	m.Point = m.Point.Sub(margin.Topleft())
-	return duit.KidsMouse(dui, self, ui.Kids, m, origM, orig)
+	return duit.KidsMouse(dui, self, flowKids(ui.Kids), m, origM, orig)
 }
 
 func (ui *Box) Key(dui *duit.DUI, self *duit.Kid, k rune, m draw.Mouse, orig image.Point) (r duit.Result) {
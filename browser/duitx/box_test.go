@@ -0,0 +1,282 @@
+package duitx
+
+import (
+	"image"
+	"testing"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+)
+
+// testDUI can be used for layouting without display.
+func testDUI() *duit.DUI {
+	return &duit.DUI{
+		Display: &draw.Display{
+			DPI: draw.DefaultDPI,
+		},
+	}
+}
+
+// fixed is a UI of fixed size.
+type fixed struct {
+	image.Point
+}
+
+func (ui *fixed) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, force bool) {
+	self.R = rect(ui.Point)
+}
+
+func (ui *fixed) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
+}
+
+func (ui *fixed) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) (r duit.Result) {
+	return
+}
+
+func (ui *fixed) Key(dui *duit.DUI, self *duit.Kid, k rune, m draw.Mouse, orig image.Point) (r duit.Result) {
+	return
+}
+
+func (ui *fixed) FirstFocus(dui *duit.DUI, self *duit.Kid) *image.Point {
+	return nil
+}
+
+func (ui *fixed) Focus(dui *duit.DUI, self *duit.Kid, o duit.UI) *image.Point {
+	return nil
+}
+
+func (ui *fixed) Mark(self *duit.Kid, o duit.UI, forLayout bool) (marked bool) {
+	return self.Mark(o, forLayout)
+}
+
+func (ui *fixed) Print(self *duit.Kid, indent int) {
+}
+
+func words(n, w, h int) (uis []duit.UI) {
+	for i := 0; i < n; i++ {
+		uis = append(uis, &fixed{image.Pt(w, h)})
+	}
+	return
+}
+
+func layout(ui duit.UI, width int) *duit.Kid {
+	k := &duit.Kid{UI: ui}
+	ui.Layout(testDUI(), k, image.Pt(width, 1000), true)
+	return k
+}
+
+func TestBoxFloat(t *testing.T) {
+	left := &Box{Kids: duit.NewKids(&fixed{image.Pt(40, 50)}), Floating: FloatLeft}
+	right := &Box{Kids: duit.NewKids(&fixed{image.Pt(20, 15)}), Floating: FloatRight}
+	b := &Box{Kids: duit.NewKids(append([]duit.UI{left, right}, words(10, 10, 10)...)...)}
+	k := layout(b, 100)
+
+	if r := b.Kids[0].R; r != image.Rect(0, 0, 40, 50) {
+		t.Fatalf("left %v", r)
+	}
+	if r := b.Kids[1].R; r != image.Rect(80, 0, 100, 15) {
+		t.Fatalf("right %v", r)
+	}
+	// 4 words fit between the floats, 4 next to both floats again
+	// because the line is 10px high and then 2 next to the left float
+	if r := b.Kids[2].R; r.Min != image.Pt(40, 0) {
+		t.Fatalf("first word %v", r)
+	}
+	if r := b.Kids[6].R; r.Min != image.Pt(40, 10) {
+		t.Fatalf("5th word %v", r)
+	}
+	if r := b.Kids[11].R; r.Min != image.Pt(50, 20) {
+		t.Fatalf("last word %v", r)
+	}
+	// floats are contained
+	if k.R.Dy() != 50 {
+		t.Fatalf("height %v", k.R)
+	}
+}
+
+func TestBoxClear(t *testing.T) {
+	left := &Box{Kids: duit.NewKids(&fixed{image.Pt(40, 50)}), Floating: FloatLeft}
+	cleared := &Box{Kids: duit.NewKids(&fixed{image.Pt(10, 10)}), Clearing: ClearLeft}
+	b := &Box{Kids: duit.NewKids(left, &fixed{image.Pt(10, 10)}, cleared)}
+	layout(b, 100)
+
+	if r := b.Kids[1].R; r.Min != image.Pt(40, 0) {
+		t.Fatalf("%v", r)
+	}
+	if r := b.Kids[2].R; r.Min != image.Pt(0, 50) {
+		t.Fatalf("%v", r)
+	}
+}
+
+func TestBoxBFC(t *testing.T) {
+	left := &Box{Kids: duit.NewKids(&fixed{image.Pt(40, 15)}), Floating: FloatLeft}
+	para := &Box{Kids: duit.NewKids(words(8, 20, 10)...), Disp: Block}
+	bfc := &Box{Kids: duit.NewKids(words(8, 20, 10)...), Disp: Block, BFC: true}
+
+	// text of a block flows around the float
+	b := &Box{Kids: duit.NewKids(left, para)}
+	layout(b, 100)
+	if r := para.Kids[0].R; r.Min != image.Pt(40, 0) {
+		t.Fatalf("%v", r)
+	}
+	if r := para.Kids[6].R; r.Min != image.Pt(0, 20) {
+		t.Fatalf("%v", r)
+	}
+
+	// a BFC is placed next to the float as a whole
+	b = &Box{Kids: duit.NewKids(left, bfc)}
+	layout(b, 100)
+	if r := b.Kids[1].R; r.Min.X != 40 || r.Dx() > 60 {
+		t.Fatalf("%v", r)
+	}
+	if r := bfc.Kids[3].R; r.Min != image.Pt(0, 10) {
+		t.Fatalf("%v", r)
+	}
+}
+
+func TestBoxFloatOverhang(t *testing.T) {
+	left := &Box{Kids: duit.NewKids(&fixed{image.Pt(40, 50)}), Floating: FloatLeft}
+	para := &Box{Kids: duit.NewKids(left, &fixed{image.Pt(10, 10)}), Disp: Block}
+	next := &Box{Kids: duit.NewKids(words(2, 10, 10)...), Disp: Block}
+	b := &Box{Kids: duit.NewKids(para, next)}
+	k := layout(b, 100)
+
+	// the float extends below the block and the next one flows around it
+	if r := b.Kids[0].R; r.Dy() != 10 {
+		t.Fatalf("block %v", r)
+	}
+	if r := b.Kids[1].R; r.Min != image.Pt(0, 10) {
+		t.Fatalf("next block %v", r)
+	}
+	if r := next.Kids[0].R; r.Min != image.Pt(40, 0) {
+		t.Fatalf("next word %v", r)
+	}
+	// and is contained by the outermost box
+	if k.R.Dy() != 50 {
+		t.Fatalf("height %v", k.R)
+	}
+
+	// a BFC contains its floats
+	para.BFC = true
+	k = layout(b, 100)
+	if r := b.Kids[0].R; r.Dy() != 50 || len(para.Floats()) != 0 {
+		t.Fatalf("bfc %v", r)
+	}
+	if r := next.Kids[0].R; r.Min != image.Pt(0, 0) {
+		t.Fatalf("next word %v", r)
+	}
+	if k.R.Dy() != 60 {
+		t.Fatalf("height %v", k.R)
+	}
+
+	// laid out on its own the block contains its floats again
+	para.BFC = false
+	layout(b, 100)
+	if len(para.Floats()) != 1 {
+		t.Fatalf("%v", para.Floats())
+	}
+	if k = layout(para, 100); k.R.Dy() != 50 || len(para.Floats()) != 0 {
+		t.Fatalf("own layout %v %v", k.R, para.Floats())
+	}
+}
+
+func TestBoxTextAlign(t *testing.T) {
+	xs := func(b *Box) (l []int) {
+		for _, k := range b.Kids {
+			l = append(l, k.R.Min.X)
+		}
+		return
+	}
+	tests := []struct {
+		align TextAlign
+		exp   []int
+	}{
+		{TextLeft, []int{0, 30, 60, 0}},
+		{TextCenter, []int{5, 35, 65, 35}},
+		{TextRight, []int{10, 40, 70, 70}},
+		// the last line is not justified
+		{TextJustify, []int{0, 35, 70, 0}},
+	}
+	for _, tt := range tests {
+		b := &Box{Kids: duit.NewKids(words(4, 30, 10)...), TextAlign: tt.align}
+		k := layout(b, 100)
+		got := xs(b)
+		for i, x := range tt.exp {
+			if got[i] != x {
+				t.Errorf("%v: %v", tt.align, got)
+				break
+			}
+		}
+		if tt.align != TextLeft && k.R.Dx() != 100 {
+			t.Errorf("%v: %v", tt.align, k.R)
+		}
+	}
+	b := &Box{Kids: duit.NewKids(words(2, 30, 10)...), TextAlign: TextCenter, Disp: Inline}
+	layout(b, 100)
+	if xs(b)[0] != 0 {
+		t.Errorf("inline box aligned")
+	}
+}
+
+// breaking is a fixed UI forcing a line break after it.
+type breaking struct {
+	fixed
+}
+
+func (ui *breaking) BreaksLine() bool {
+	return true
+}
+
+func TestBoxLineBreak(t *testing.T) {
+	uis := words(2, 10, 10)
+	uis = append(uis, &breaking{fixed{image.Pt(10, 10)}})
+	uis = append(uis, words(2, 10, 10)...)
+	b := &Box{Kids: duit.NewKids(uis...)}
+	layout(b, 100)
+	if r := b.Kids[2].R; r != image.Rect(20, 0, 30, 10) {
+		t.Fatalf("break %v", r)
+	}
+	if r := b.Kids[3].R; r != image.Rect(0, 10, 10, 20) {
+		t.Fatalf("after break %v", r)
+	}
+}
+
+func TestBoxNoWrap(t *testing.T) {
+	b := &Box{Kids: duit.NewKids(words(10, 10, 10)...), NoWrap: true}
+	k := layout(b, 50)
+	if r := b.Kids[9].R; r != image.Rect(90, 0, 100, 10) {
+		t.Fatalf("last %v", r)
+	}
+	if s := k.R.Size(); s != image.Pt(100, 10) {
+		t.Fatalf("size %v", s)
+	}
+}
+
+func TestBoxMarker(t *testing.T) {
+	b := &Box{
+		Kids:    duit.NewKids(words(3, 10, 10)...),
+		Padding: duit.Space{Top: 5, Left: 40},
+		Marker:  &fixed{image.Pt(15, 10)},
+	}
+	layout(b, 100)
+	if r := b.marker.R; r != image.Rect(25, 5, 40, 15) {
+		t.Fatalf("marker %v", r)
+	}
+}
+
+func TestBoxClip(t *testing.T) {
+	b := &Box{Kids: duit.NewKids(words(10, 10, 10)...), NoWrap: true, Width: 50, Height: 5}
+	if s := layout(b, 100).R.Size(); s != image.Pt(100, 10) {
+		t.Fatalf("visible %v", s)
+	}
+	b.ClipX = true
+	b.ClipY = true
+	if s := layout(b, 100).R.Size(); s != image.Pt(50, 5) {
+		t.Fatalf("clipped %v", s)
+	}
+	b.Padding = duit.SpaceXY(2, 2)
+	b.ContentBox = true
+	if s := layout(b, 100).R.Size(); s != image.Pt(54, 9) {
+		t.Fatalf("content box %v", s)
+	}
+}
@@ -0,0 +1,409 @@
+package duitx
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/mjl-/duit"
+)
+
+type Justify int
+
+const (
+	JustifyStart Justify = iota
+	JustifyEnd
+	JustifyCenter
+	JustifySpaceBetween
+	JustifySpaceAround
+	JustifySpaceEvenly
+)
+
+type Align int
+
+const (
+	AlignAuto Align = iota // only for AlignSelf, use AlignItems of the container
+	AlignStretch
+	AlignStart
+	AlignEnd
+	AlignCenter
+	AlignBaseline // treated like AlignStart
+	AlignSpaceBetween
+	AlignSpaceAround
+	AlignSpaceEvenly
+)
+
+// FlexContainer holds the properties of a box with Disp Flex or InlineFlex.
+type FlexContainer struct {
+	Wrap         bool
+	WrapReverse  bool
+	Reverse      bool // row-reverse or column-reverse
+	Justify      Justify
+	AlignItems   Align // AlignAuto is the same as AlignStretch
+	AlignContent Align // AlignAuto is the same as AlignStretch
+	RowGap       int   // In lowDPI pixels.
+	ColumnGap    int   // In lowDPI pixels.
+}
+
+// FlexItem holds the properties of a flex item.
+type FlexItem struct {
+	Grow      float64
+	Shrink    float64
+	Basis     int // In lowDPI pixels, -1 means auto.
+	Order     int
+	AlignSelf Align
+}
+
+// DefaultFlexItem has the initial values of the flex item properties.
+var DefaultFlexItem = FlexItem{
+	Shrink: 1,
+	Basis:  -1,
+}
+
+// Flexible is implemented by UIs with flex item properties.
+type Flexible interface {
+	FlexItem() FlexItem
+}
+
+func flexItemOf(k *duit.Kid) FlexItem {
+	if f, ok := k.UI.(Flexible); ok {
+		return f.FlexItem()
+	}
+	return DefaultFlexItem
+}
+
+// flexMeasure returns the size of item i when its main size is
+// constrained to main. Main is the x axis for rows.
+type flexMeasure func(i, main int) (size image.Point)
+
+// flexLayout implements the CSS Flexbox layout algorithm for items
+// with the given properties (already scaled). availMain is the
+// available space of the main axis, availCross the definite cross size
+// of the container or 0. Rects are returned in main/cross coordinates,
+// i.e. X is the main axis, in the order of the items. size is the size
+// of the contents.
+func flexLayout(c FlexContainer, items []FlexItem, availMain, availCross, mainGap, crossGap int, measure flexMeasure, row bool) (rects []image.Rectangle, size image.Point) {
+	n := len(items)
+	rects = make([]image.Rectangle, n)
+	if n == 0 {
+		return
+	}
+	mc := func(p image.Point) (main, cross int) {
+		if row {
+			return p.X, p.Y
+		}
+		return p.Y, p.X
+	}
+
+	// order-modified document order
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return items[idx[a]].Order < items[idx[b]].Order
+	})
+
+	// flex base sizes and min sizes
+	base := make([]int, n)
+	min := make([]int, n)
+	for i, it := range items {
+		if it.Basis >= 0 {
+			base[i] = it.Basis
+		} else {
+			base[i], _ = mc(measure(i, availMain))
+		}
+		if it.Shrink > 0 {
+			min[i], _ = mc(measure(i, 0))
+			if min[i] > base[i] && it.Basis < 0 {
+				min[i] = base[i]
+			}
+		} else {
+			min[i] = base[i]
+		}
+	}
+
+	// collect lines
+	var lines [][]int
+	var line []int
+	used := 0
+	for _, i := range idx {
+		gap := 0
+		if len(line) > 0 {
+			gap = mainGap
+		}
+		if c.Wrap && len(line) > 0 && used+gap+base[i] > availMain {
+			lines = append(lines, line)
+			line = nil
+			used = 0
+			gap = 0
+		}
+		line = append(line, i)
+		used += gap + base[i]
+	}
+	lines = append(lines, line)
+
+	// resolve flexible lengths
+	main := make([]int, n)
+	cross := make([]int, n)
+	lineCross := make([]int, len(lines))
+	for l, line := range lines {
+		resolveFlexible(items, line, base, min, main, availMain-mainGap*(len(line)-1))
+		for _, i := range line {
+			_, cross[i] = mc(measure(i, main[i]))
+			lineCross[l] = maximum(lineCross[l], cross[i])
+		}
+	}
+	if len(lines) == 1 && availCross > 0 {
+		lineCross[0] = availCross
+	}
+
+	// align-content
+	crossPos := make([]int, len(lines))
+	totalCross := crossGap * (len(lines) - 1)
+	for _, lc := range lineCross {
+		totalCross += lc
+	}
+	free := 0
+	if availCross > 0 && len(lines) > 1 {
+		free = availCross - totalCross
+	}
+	ac := c.AlignContent
+	if free < 0 && ac != AlignStart {
+		free = 0
+	}
+	if (ac == AlignAuto || ac == AlignStretch) && free > 0 {
+		for l := range lineCross {
+			lineCross[l] += free / len(lines)
+		}
+		free = 0
+	}
+	offsets := distribute(justifyOf(ac), free, len(lines))
+	y := 0
+	for l := range lines {
+		y += offsets[l]
+		crossPos[l] = y
+		y += lineCross[l] + crossGap
+	}
+	size.Y = maximum(totalCross, availCross)
+
+	for l, line := range lines {
+		used := mainGap * (len(line) - 1)
+		for _, i := range line {
+			used += main[i]
+		}
+		offsets := distribute(c.Justify, availMain-used, len(line))
+		x := 0
+		for j, i := range line {
+			x += offsets[j]
+			a := items[i].AlignSelf
+			if a == AlignAuto {
+				a = c.AlignItems
+			}
+			y := crossPos[l]
+			h := cross[i]
+			switch a {
+			case AlignAuto, AlignStretch:
+				h = lineCross[l]
+			case AlignEnd:
+				y += lineCross[l] - h
+			case AlignCenter:
+				y += (lineCross[l] - h) / 2
+			}
+			rects[i] = image.Rect(x, y, x+main[i], y+h)
+			x += main[i] + mainGap
+		}
+		size.X = maximum(size.X, x-mainGap)
+	}
+
+	if c.Reverse {
+		for i, r := range rects {
+			rects[i] = image.Rect(availMain-r.Max.X, r.Min.Y, availMain-r.Min.X, r.Max.Y)
+		}
+	}
+	if c.WrapReverse {
+		for i, r := range rects {
+			rects[i] = image.Rect(r.Min.X, size.Y-r.Max.Y, r.Max.X, size.Y-r.Min.Y)
+		}
+	}
+	return
+}
+
+// resolveFlexible lengths of a line according to flex-grow and
+// flex-shrink.
+func resolveFlexible(items []FlexItem, line []int, base, min, main []int, avail int) {
+	frozen := make(map[int]bool)
+	sum := 0
+	for _, i := range line {
+		main[i] = base[i]
+		sum += base[i]
+	}
+	grow := sum < avail
+	for _, i := range line {
+		if (grow && items[i].Grow == 0) || (!grow && (items[i].Shrink == 0 || sum == avail)) {
+			frozen[i] = true
+		}
+	}
+	for len(frozen) < len(line) {
+		free := avail
+		var factors float64
+		for _, i := range line {
+			if frozen[i] {
+				free -= main[i]
+			} else {
+				free -= base[i]
+				if grow {
+					factors += items[i].Grow
+				} else {
+					factors += items[i].Shrink * float64(base[i])
+				}
+			}
+		}
+		if factors == 0 {
+			break
+		}
+		violation := false
+		for _, i := range line {
+			if frozen[i] {
+				continue
+			}
+			var f float64
+			if grow {
+				f = items[i].Grow / factors
+			} else {
+				f = items[i].Shrink * float64(base[i]) / factors
+			}
+			main[i] = base[i] + int(math.Round(f*float64(free)))
+			if main[i] < min[i] {
+				main[i] = min[i]
+				frozen[i] = true
+				violation = true
+			}
+		}
+		if !violation {
+			break
+		}
+	}
+}
+
+func justifyOf(a Align) Justify {
+	switch a {
+	case AlignEnd:
+		return JustifyEnd
+	case AlignCenter:
+		return JustifyCenter
+	case AlignSpaceBetween:
+		return JustifySpaceBetween
+	case AlignSpaceAround:
+		return JustifySpaceAround
+	case AlignSpaceEvenly:
+		return JustifySpaceEvenly
+	}
+	return JustifyStart
+}
+
+// distribute free space in front of n items.
+func distribute(j Justify, free, n int) (offsets []int) {
+	offsets = make([]int, n)
+	if free <= 0 || n == 0 {
+		return
+	}
+	switch j {
+	case JustifyEnd:
+		offsets[0] = free
+	case JustifyCenter:
+		offsets[0] = free / 2
+	case JustifySpaceBetween:
+		for i := 1; i < n; i++ {
+			offsets[i] = free*i/(n-1) - free*(i-1)/(n-1)
+		}
+	case JustifySpaceAround:
+		offsets[0] = free / (2 * n)
+		for i := 1; i < n; i++ {
+			offsets[i] = free / n
+		}
+	case JustifySpaceEvenly:
+		for i := range offsets {
+			offsets[i] = free / (n + 1)
+		}
+	}
+	return
+}
+
+// layoutFlex lays out the kids as flex items and returns the size of
+// the contents.
+func (ui *Box) layoutFlex(dui *duit.DUI, sizeAvail image.Point, padding duit.Space) (size image.Point) {
+	row := ui.Dir != Column
+	items := make([]FlexItem, len(ui.Kids))
+	for i, k := range ui.Kids {
+		it := flexItemOf(k)
+		if it.Basis > 0 {
+			it.Basis = dui.Scale(it.Basis)
+		}
+		items[i] = it
+	}
+	availMain, availCross := sizeAvail.X, 0
+	mainGap, crossGap := dui.Scale(ui.Flex.ColumnGap), dui.Scale(ui.Flex.RowGap)
+	if !row {
+		availMain, availCross = 0, sizeAvail.X
+		mainGap, crossGap = crossGap, mainGap
+		if ui.Height > 0 {
+			availMain = sizeAvail.Y
+		}
+	} else if ui.Height > 0 {
+		availCross = sizeAvail.Y
+	}
+	availOf := func(main int) image.Point {
+		avail := sizeAvail
+		if row {
+			avail.X = maximum(main, 1)
+		} else if main > 0 {
+			avail.Y = main
+		}
+		return avail
+	}
+	measured.begin()
+	defer measured.end()
+	measure := func(i, main int) image.Point {
+		return measured.measure(dui, ui.Kids[i], availOf(main))
+	}
+	if row && ui.Disp == InlineFlex {
+		// shrink to fit the contents
+		w := mainGap * (len(items) - 1)
+		for i, it := range items {
+			if it.Basis >= 0 {
+				w += it.Basis
+			} else {
+				w += measure(i, sizeAvail.X).X
+			}
+		}
+		availMain = minimum(w, availMain)
+	}
+	if !row && availMain == 0 {
+		// the main size of columns is the sum of the items
+		for i := range items {
+			availMain += measure(i, 0).Y
+		}
+		availMain += mainGap * (len(items) - 1)
+	}
+	rects, size := flexLayout(ui.Flex, items, availMain, availCross, mainGap, crossGap, measure, row)
+	for i, k := range ui.Kids {
+		r := rects[i]
+		avail := availOf(r.Dx())
+		sz := measured.measure(dui, k, avail)
+		measured.layout(dui, k, avail)
+		if !row {
+			r = image.Rect(r.Min.Y, r.Min.X, r.Max.Y, r.Max.X)
+		}
+		// Keep the laid out size if the item could not be stretched
+		// (e.g. labels) but at least the measured one.
+		if (row && sz.Y > r.Dy()) || (!row && sz.X > r.Dx()) {
+			r.Max = r.Min.Add(image.Pt(maximum(sz.X, r.Dx()), maximum(sz.Y, r.Dy())))
+		}
+		k.R = r.Add(padding.Topleft())
+	}
+	if row {
+		return image.Pt(availMain, size.Y)
+	}
+	return image.Pt(size.Y, availMain)
+}
@@ -0,0 +1,187 @@
+package duitx
+
+import (
+	"image"
+	"testing"
+
+	"github.com/mjl-/duit"
+)
+
+// measureFixed returns measure funcs for items of fixed sizes.
+func measureFixed(sizes ...image.Point) flexMeasure {
+	return func(i, main int) image.Point {
+		return sizes[i]
+	}
+}
+
+func TestFlexGrow(t *testing.T) {
+	items := []FlexItem{DefaultFlexItem, DefaultFlexItem, DefaultFlexItem}
+	items[1].Grow = 1
+	items[2].Grow = 3
+	m := measureFixed(image.Pt(20, 10), image.Pt(20, 30), image.Pt(20, 10))
+	rects, size := flexLayout(FlexContainer{}, items, 100, 0, 0, 0, m, true)
+	exp := []image.Rectangle{
+		image.Rect(0, 0, 20, 30),
+		image.Rect(20, 0, 50, 30),
+		image.Rect(50, 0, 100, 30),
+	}
+	for i, r := range rects {
+		if r != exp[i] {
+			t.Errorf("%v: %v != %v", i, r, exp[i])
+		}
+	}
+	if size != image.Pt(100, 30) {
+		t.Errorf("%v", size)
+	}
+}
+
+func TestFlexShrink(t *testing.T) {
+	items := []FlexItem{DefaultFlexItem, DefaultFlexItem}
+	items[0].Basis = 100
+	items[1].Basis = 50
+	items[1].Shrink = 0
+	m := measureFixed(image.Pt(10, 10), image.Pt(10, 10))
+	rects, _ := flexLayout(FlexContainer{}, items, 100, 0, 0, 0, m, true)
+	if rects[0].Dx() != 50 || rects[1] != image.Rect(50, 0, 100, 10) {
+		t.Fatalf("%v", rects)
+	}
+}
+
+func TestFlexJustifyAlign(t *testing.T) {
+	items := []FlexItem{DefaultFlexItem, DefaultFlexItem, DefaultFlexItem}
+	items[2].AlignSelf = AlignEnd
+	m := measureFixed(image.Pt(10, 10), image.Pt(10, 20), image.Pt(10, 5))
+	c := FlexContainer{
+		Justify:    JustifySpaceBetween,
+		AlignItems: AlignCenter,
+	}
+	rects, _ := flexLayout(c, items, 100, 0, 0, 0, m, true)
+	exp := []image.Rectangle{
+		image.Rect(0, 5, 10, 15),
+		image.Rect(45, 0, 55, 20),
+		image.Rect(90, 15, 100, 20),
+	}
+	for i, r := range rects {
+		if r != exp[i] {
+			t.Errorf("%v: %v != %v", i, r, exp[i])
+		}
+	}
+
+	c.Justify = JustifyCenter
+	c.Reverse = true
+	rects, _ = flexLayout(c, items, 100, 0, 0, 0, m, true)
+	if rects[0].Min.X != 55 || rects[2].Min.X != 35 {
+		t.Errorf("%v", rects)
+	}
+}
+
+func TestFlexWrapGapOrder(t *testing.T) {
+	items := make([]FlexItem, 5)
+	sizes := make([]image.Point, 5)
+	for i := range items {
+		items[i] = DefaultFlexItem
+		sizes[i] = image.Pt(30, 10)
+	}
+	items[0].Order = 1
+	c := FlexContainer{
+		Wrap:       true,
+		AlignItems: AlignStart,
+	}
+	rects, size := flexLayout(c, items, 100, 0, 5, 2, measureFixed(sizes...), true)
+	// order: 1 2 3 | 4 0
+	if rects[1].Min != image.Pt(0, 0) || rects[3].Min != image.Pt(70, 0) {
+		t.Errorf("%v", rects)
+	}
+	if rects[4].Min != image.Pt(0, 12) || rects[0].Min != image.Pt(35, 12) {
+		t.Errorf("%v", rects)
+	}
+	if size.Y != 22 {
+		t.Errorf("%v", size)
+	}
+}
+
+func TestFlexColumn(t *testing.T) {
+	items := []FlexItem{DefaultFlexItem, DefaultFlexItem}
+	m := measureFixed(image.Pt(40, 10), image.Pt(20, 30))
+	rects, size := flexLayout(FlexContainer{}, items, 60, 50, 0, 0, m, false)
+	// main axis is X of the result
+	if rects[0] != image.Rect(0, 0, 10, 50) || rects[1] != image.Rect(10, 0, 40, 50) {
+		t.Fatalf("%v", rects)
+	}
+	if size != image.Pt(40, 50) {
+		t.Fatalf("%v", size)
+	}
+}
+
+// flexBox is a box with flex item properties.
+type flexBox struct {
+	*Box
+	item FlexItem
+}
+
+func (ui *flexBox) FlexItem() FlexItem {
+	return ui.item
+}
+
+func TestBoxFlex(t *testing.T) {
+	grow := DefaultFlexItem
+	grow.Grow = 1
+	b := &Box{
+		Kids: duit.NewKids(
+			&fixed{image.Pt(20, 10)},
+			&flexBox{Box: &Box{Kids: duit.NewKids(&fixed{image.Pt(10, 10)})}, item: grow},
+			&fixed{image.Pt(20, 10)},
+		),
+		Disp: Flex,
+		Flex: FlexContainer{
+			ColumnGap: 5,
+		},
+	}
+	k := layout(b, 200)
+	if r := b.Kids[1].R; r != image.Rect(25, 0, 175, 10) {
+		t.Fatalf("%v", r)
+	}
+	if r := b.Kids[2].R; r.Min.X != 180 {
+		t.Fatalf("%v", r)
+	}
+	if k.R.Dx() != 200 {
+		t.Fatalf("%v", k.R)
+	}
+
+	b.Dir = Column
+	layout(b, 200)
+	if r := b.Kids[2].R; r.Min != image.Pt(0, 20) || r.Dx() != 200 {
+		t.Fatalf("%v", r)
+	}
+}
+
+// counted is a UI of fixed size counting its layouts.
+type counted struct {
+	fixed
+	n int
+}
+
+func (ui *counted) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, force bool) {
+	ui.n++
+	ui.fixed.Layout(dui, self, sizeAvail, force)
+}
+
+func TestFlexNestedLayouts(t *testing.T) {
+	leaf := &counted{fixed: fixed{image.Pt(10, 10)}}
+	var ui duit.UI = leaf
+	for i := 0; i < 10; i++ {
+		b := &Box{Disp: Flex, Kids: duit.NewKids(ui, &fixed{image.Pt(5, 5)})}
+		if i%2 == 1 {
+			b.Dir = Column
+		}
+		ui = b
+	}
+	k := layout(ui, 100)
+	// the siblings make the available sizes differ on each level
+	if leaf.n > 100 {
+		t.Errorf("%v layouts", leaf.n)
+	}
+	if k.R.Dy() < 10 {
+		t.Errorf("%v", k.R)
+	}
+}
@@ -0,0 +1,159 @@
+package duitx
+
+import (
+	"image"
+
+	"github.com/mjl-/duit"
+)
+
+type Float int
+
+const (
+	FloatNone Float = iota
+	FloatLeft
+	FloatRight
+)
+
+type Clear int
+
+const (
+	ClearNone Clear = iota
+	ClearLeft
+	ClearRight
+	ClearBoth
+)
+
+// Floater is implemented by UIs that can be floated, that clear
+// floats or that establish a block formatting context (BFC).
+type Floater interface {
+	Float() Float
+	Clear() Clear
+	IsBFC() bool
+}
+
+// Exclusion is the area of a float that content flows around.
+type Exclusion struct {
+	R    image.Rectangle
+	Side Float
+}
+
+// Excluder is implemented by UIs that flow their content around the
+// floats of preceding siblings. Floats returns the floats of the content
+// that extend below the UI, in its coordinates, so following siblings
+// flow around them too.
+type Excluder interface {
+	Exclude(ex []Exclusion)
+	Floats() []Exclusion
+}
+
+func floatOf(k *duit.Kid) (f Float, c Clear, bfc bool) {
+	if fl, ok := k.UI.(Floater); ok {
+		return fl.Float(), fl.Clear(), fl.IsBFC()
+	}
+	return
+}
+
+// floats placed within a box in content coordinates.
+type floats []Exclusion
+
+// bounds returns the horizontal space between the floats that overlap
+// the band of height h at y.
+func (fs floats) bounds(y, h, width int) (x0, x1 int) {
+	x1 = width
+	band := image.Rect(0, y, width, y+maximum(h, 1))
+	for _, f := range fs {
+		if f.R.Min.Y >= band.Max.Y || f.R.Max.Y <= band.Min.Y {
+			continue
+		}
+		if f.Side == FloatLeft {
+			x0 = maximum(x0, f.R.Max.X)
+		} else {
+			x1 = minimum(x1, f.R.Min.X)
+		}
+	}
+	return
+}
+
+// next returns the smallest bottom edge of the floats overlapping y,
+// or ok=false if there are none.
+func (fs floats) next(y int) (ny int, ok bool) {
+	for _, f := range fs {
+		if f.R.Min.Y <= y && y < f.R.Max.Y && (!ok || f.R.Max.Y < ny) {
+			ny = f.R.Max.Y
+			ok = true
+		}
+	}
+	return
+}
+
+// fit returns the first y at or below y where a band of size w×h fits
+// between the floats.
+func (fs floats) fit(y, w, h, width int) (ny, x0, x1 int) {
+	for {
+		x0, x1 = fs.bounds(y, h, width)
+		if x1-x0 >= w {
+			return y, x0, x1
+		}
+		ny, ok := fs.next(y)
+		if !ok {
+			return y, x0, x1
+		}
+		y = ny
+	}
+}
+
+// clear returns the y below the floats of side c.
+func (fs floats) clear(y int, c Clear) int {
+	for _, f := range fs {
+		if c == ClearBoth || (c == ClearLeft && f.Side == FloatLeft) || (c == ClearRight && f.Side == FloatRight) {
+			y = maximum(y, f.R.Max.Y)
+		}
+	}
+	return y
+}
+
+// within returns the floats overlapping the area below y, translated
+// by -o.
+func (fs floats) within(y int, o image.Point) (ex []Exclusion) {
+	for _, f := range fs {
+		if f.R.Max.Y > y {
+			ex = append(ex, Exclusion{R: f.R.Sub(o), Side: f.Side})
+		}
+	}
+	return
+}
+
+func (ui *Box) Float() Float {
+	return ui.Floating
+}
+
+func (ui *Box) Clear() Clear {
+	return ui.Clearing
+}
+
+func (ui *Box) IsBFC() bool {
+	return ui.BFC
+}
+
+// Exclude sets floats of preceding siblings in the coordinates of the
+// box. They are ignored if the box establishes a BFC. Exclude is called
+// by the parent flow before each Layout, the box passes its floats on to
+// it then. Without it the box contains its floats.
+func (ui *Box) Exclude(ex []Exclusion) {
+	if ui.BFC || ui.Disp == Flex || ui.Disp == InlineFlex {
+		ex = nil
+	}
+	ui.exclusions = ex
+	ui.inFlow = true
+}
+
+// Floats of the content extending below the box. They are contained
+// instead if the box establishes a BFC.
+func (ui *Box) Floats() []Exclusion {
+	return ui.overhang
+}
+
+// containsFloats returns whether the box grows to contain its floats.
+func (ui *Box) containsFloats() bool {
+	return ui.BFC || ui.Disp == Flex || ui.Disp == InlineFlex || !ui.inFlow
+}
@@ -42,10 +42,28 @@ type Grid struct {
 	Width      int           // -1 means full width, 0 means automatic width, >0 means exactly that many lowDPI pixels.
 	Background *draw.Image   `json:"-"` // Background color.
 
+	// If Places is not nil, kids are placed in the tracks with the
+	// CSS grid layout algorithm instead of filling Columns per row.
+	Places    []Placement
+	ColTracks TrackList
+	RowTracks TrackList
+	AutoCols  []Track // Implicit column tracks, auto by default.
+	AutoRows  []Track // Implicit row tracks, auto by default.
+	Dense     bool
+	ColGap    int           // In lowDPI pixels.
+	RowGap    int           // In lowDPI pixels.
+	KidHalign []duit.Halign // Horizontal alignment per kid.
+	KidValign []duit.Valign // Vertical alignment per kid.
+
+	Border      int         // Width of cell borders in lowDPI pixels.
+	Collapse    bool        // Adjacent cells share their borders.
+	BorderColor *draw.Image `json:"-"` // Black by default.
+
 	widths  []int
 	heights []int
 	pos     [][]int
 	size    image.Point
+	cells   []image.Rectangle
 }
 
 var _ duit.UI = &Grid{}
@@ -116,6 +134,12 @@ func (ui *Grid) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, for
 		return
 	}
 
+	if ui.Places != nil {
+		ui.layoutTracks(dui, sizeAvail)
+		self.R = rect(ui.size)
+		return
+	}
+
 	if ui.pos == nil {
 		ui.initPos()
 	}
@@ -249,10 +273,24 @@ func (ui *Grid) spaces(dui *duit.DUI) (s []duit.Space) {
 
 func (ui *Grid) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
 	duit.KidsDraw(dui, self, ui.Kids, ui.size, ui.Background, img, orig, m, force)
+	if ui.Border <= 0 || img == nil {
+		return
+	}
+	col := ui.BorderColor
+	if col == nil {
+		col = dui.Display.Black
+	}
+	w := dui.Scale(ui.Border)
+	for _, c := range ui.cells {
+		if ui.Collapse {
+			c.Max = c.Max.Add(image.Pt(w, w))
+		}
+		img.Border(c.Add(orig), w, col, image.ZP)
+	}
 }
 
 func (ui *Grid) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) (r duit.Result) {
-	return duit.KidsMouse(dui, self, ui.Kids, m, origM, orig)
+	return duit.KidsMouse(dui, self, flowKids(ui.Kids), m, origM, orig)
 }
 
 func (ui *Grid) Key(dui *duit.DUI, self *duit.Kid, k rune, m draw.Mouse, orig image.Point) (r duit.Result) {
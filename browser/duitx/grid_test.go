@@ -73,3 +73,137 @@ func TestMaxWidths(t *testing.T) {
 		t.Fatalf("%+v, %v, %+v", maxW, w, xs)
 	}
 }
+
+func TestPlaceGrid(t *testing.T) {
+	ps := []Placement{
+		{},
+		{ColSpan: 2},
+		{RowStart: 1, ColStart: 3},
+		{},
+	}
+	areas, cols, rows := placeGrid(ps, 3, 1, false)
+	exp := []image.Rectangle{
+		image.Rect(0, 0, 1, 1),
+		image.Rect(0, 1, 2, 2),
+		image.Rect(2, 0, 3, 1),
+		image.Rect(2, 1, 3, 2),
+	}
+	if cols != 3 || rows != 2 {
+		t.Fatalf("%v %v", cols, rows)
+	}
+	for i, a := range areas {
+		if a != exp[i] {
+			t.Errorf("%v: %v != %v", i, a, exp[i])
+		}
+	}
+
+	areas, _, _ = placeGrid([]Placement{{}, {RowStart: -2, ColStart: 1, ColEnd: -1}}, 3, 2, false)
+	if areas[1] != image.Rect(0, 1, 3, 2) || areas[0] != image.Rect(0, 0, 1, 1) {
+		t.Errorf("negative lines: %v", areas)
+	}
+
+	areas, _, _ = placeGrid([]Placement{{ColSpan: 2}, {ColSpan: 2}, {}}, 3, 0, true)
+	if areas[2] != image.Rect(2, 0, 3, 1) {
+		t.Errorf("dense: %v", areas)
+	}
+}
+
+func TestSizeTracks(t *testing.T) {
+	px := TrackSize{Kind: TrackPx, Value: 100}
+	fr := TrackSize{Kind: TrackFr, Value: 1}
+	fr2 := TrackSize{Kind: TrackFr, Value: 2}
+	sizes := sizeTracks([]Track{NewTrack(px), NewTrack(fr), NewTrack(fr2)}, nil, 400, 10)
+	if sizes[0] != 100 || sizes[1] != 93 || sizes[2] != 186 {
+		t.Errorf("%v", sizes)
+	}
+
+	auto := NewTrack(TrackSize{})
+	items := []trackItem{{0, 1, 20, 50}, {1, 2, 20, 100}}
+	if sizes = sizeTracks([]Track{auto, auto}, items, -1, 0); sizes[0] != 50 || sizes[1] != 100 {
+		t.Errorf("%v", sizes)
+	}
+	if sizes = sizeTracks([]Track{auto, auto}, items, 400, 0); sizes[0] != 175 || sizes[1] != 225 {
+		t.Errorf("%v", sizes)
+	}
+
+	// items larger than the fr share keep their min-content size
+	items = []trackItem{{0, 1, 300, 300}}
+	if sizes = sizeTracks([]Track{NewTrack(fr), NewTrack(fr)}, items, 400, 0); sizes[0] != 300 || sizes[1] != 100 {
+		t.Errorf("%v", sizes)
+	}
+}
+
+func TestGridTracks(t *testing.T) {
+	fr := NewTrack(TrackSize{Kind: TrackFr, Value: 1})
+	g := &Grid{
+		Kids:      duit.NewKids(words(3, 50, 20)...),
+		Places:    make([]Placement, 3),
+		ColTracks: TrackList{Tracks: []Track{fr, fr}},
+		ColGap:    10,
+		RowGap:    10,
+		Width:     -1,
+	}
+	k := layout(g, 210)
+	if k.R.Size() != image.Pt(210, 50) {
+		t.Errorf("%v", k.R)
+	}
+	if r := g.Kids[1].R; r.Min != image.Pt(110, 0) {
+		t.Errorf("%v", r)
+	}
+	if r := g.Kids[2].R; r.Min != image.Pt(0, 30) {
+		t.Errorf("%v", r)
+	}
+
+	g = &Grid{
+		Kids:   duit.NewKids(words(4, 50, 20)...),
+		Places: make([]Placement, 4),
+		ColTracks: TrackList{
+			Repeat: []Track{NewTrack(TrackSize{Kind: TrackPx, Value: 100})},
+		},
+		ColGap: 10,
+		Width:  -1,
+	}
+	layout(g, 350)
+	if len(g.widths) != 3 || g.Kids[3].R.Min != image.Pt(0, 20) {
+		t.Errorf("%v %v", g.widths, g.Kids[3].R)
+	}
+}
+
+func TestGridBorder(t *testing.T) {
+	g := &Grid{
+		Kids:   duit.NewKids(words(2, 50, 20)...),
+		Places: make([]Placement, 2),
+		ColTracks: TrackList{
+			Tracks: []Track{{}, {}},
+		},
+		Border: 1,
+	}
+	k := layout(g, 400)
+	if k.R.Size() != image.Pt(104, 22) || g.Kids[1].R.Min != image.Pt(53, 1) {
+		t.Errorf("%v %v", k.R, g.Kids[1].R)
+	}
+	g.Collapse = true
+	k = layout(g, 400)
+	if k.R.Size() != image.Pt(103, 22) || g.Kids[1].R.Min != image.Pt(52, 1) {
+		t.Errorf("collapse: %v %v", k.R, g.Kids[1].R)
+	}
+}
+
+func TestGridNestedLayouts(t *testing.T) {
+	leaf := &counted{fixed: fixed{image.Pt(10, 10)}}
+	var ui duit.UI = leaf
+	for i := 0; i < 7; i++ {
+		ui = &Grid{
+			Kids:      duit.NewKids(ui),
+			Places:    make([]Placement, 1),
+			ColTracks: TrackList{Tracks: []Track{NewTrack(TrackSize{Kind: TrackFr, Value: 1})}},
+		}
+		if i%2 == 1 {
+			ui = &Box{Disp: Flex, Kids: duit.NewKids(ui)}
+		}
+	}
+	layout(ui, 100)
+	if leaf.n > 20 {
+		t.Errorf("%v layouts", leaf.n)
+	}
+}
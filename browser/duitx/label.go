@@ -24,6 +24,8 @@ import (
 	"fmt"
 	"image"
 	"math"
+	"strings"
+	"unicode/utf8"
 
 	"9fans.net/go/draw"
 	"github.com/mjl-/duit"
@@ -33,6 +35,15 @@ var (
 	selectedBg *draw.Image
 )
 
+// Decoration lines of text.
+type Decoration int
+
+const (
+	Underline Decoration = 1 << iota
+	Overline
+	LineThrough
+)
+
 // Label draws multiline text in a single font.:
 //
 // Keys:
@@ -44,8 +55,17 @@ type Label struct {
 	Click    func() (e duit.Event) `json:"-"` // Called on button1 click.
 	Selected bool
 
+	Decoration    Decoration // Lines drawn along the text.
+	LetterSpacing int        // Extra space after each character in lowDPI pixels.
+	WordSpacing   int        // Extra space after each space in lowDPI pixels.
+	LineHeight    int        // Height of the line in lowDPI pixels, 0 means 1.2 times the font height.
+	LineBreak     bool       // Force a line break after the label.
+	BreakWord     bool       // Break the text at any glyph if it is wider than the available space.
+	Ellipsis      bool       // Truncate the text with an ellipsis if it is wider than the available space.
+
 	orig  image.Point
 	size  image.Point
+	lines []string // text broken into lines, nil if not broken
 	m     draw.Mouse
 }
 
@@ -57,19 +77,134 @@ func (ui *Label) font(dui *duit.DUI) *draw.Font {
 
 func (ui *Label) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, force bool) {
 	debugLayout(dui, self)
-	if ui.Text == "" || ui.size != image.ZP {
+	if ui.Text == "" || (ui.size != image.ZP && !ui.BreakWord && !ui.Ellipsis) {
 		return
 	}
 
 	font := ui.font(dui)
-	ui.size = image.Pt(font.StringWidth(ui.Text), ui.lineHeight(font))
+	w := ui.textWidth(dui, font, ui.Text)
+	lh := ui.lineHeight(dui, font)
+	ui.lines = nil
+	if ui.BreakWord && sizeAvail.X > 0 && w > sizeAvail.X {
+		ui.lines = ui.breakLines(dui, font, sizeAvail.X)
+		ui.size = image.Pt(sizeAvail.X, len(ui.lines)*lh)
+	} else if ui.Ellipsis && sizeAvail.X > 0 && w > sizeAvail.X {
+		ui.lines = []string{ui.truncate(dui, font, sizeAvail.X)}
+		ui.size = image.Pt(sizeAvail.X, lh)
+	} else {
+		ui.size = image.Pt(w, lh)
+	}
 	self.R = rect(ui.size)
 }
 
-func (ui *Label) lineHeight(font *draw.Font) int {
+// BreaksLine returns whether a line break is forced after the label.
+func (ui *Label) BreaksLine() bool {
+	return ui.LineBreak
+}
+
+// breakLines breaks the text at glyphs into lines of at most width
+// pixels. Every line has at least one glyph.
+func (ui *Label) breakLines(dui *duit.DUI, font *draw.Font, width int) (lines []string) {
+	start := 0
+	w := 0
+	for i, r := range ui.Text {
+		rw := ui.textWidth(dui, font, string(r))
+		if i > start && w+rw > width {
+			lines = append(lines, ui.Text[start:i])
+			start = i
+			w = 0
+		}
+		w += rw
+	}
+	return append(lines, ui.Text[start:])
+}
+
+// truncate returns the longest prefix of the text that fits into width
+// pixels together with an ellipsis.
+func (ui *Label) truncate(dui *duit.DUI, font *draw.Font, width int) string {
+	const ellipsis = "…"
+	w := ui.textWidth(dui, font, ellipsis)
+	end := 0
+	for i, r := range ui.Text {
+		rw := ui.textWidth(dui, font, string(r))
+		if w+rw > width {
+			break
+		}
+		w += rw
+		end = i + utf8.RuneLen(r)
+	}
+	return ui.Text[:end] + ellipsis
+}
+
+func (ui *Label) lineHeight(dui *duit.DUI, font *draw.Font) int {
+	if ui.LineHeight > 0 {
+		return dui.Scale(ui.LineHeight)
+	}
 	return int(math.Ceil(float64(font.Height) * 1.2))
 }
 
+// textWidth of s includes letter and word spacing.
+func (ui *Label) textWidth(dui *duit.DUI, font *draw.Font, s string) int {
+	w := font.StringWidth(s)
+	if ui.LetterSpacing != 0 {
+		w += utf8.RuneCountInString(s) * dui.Scale(ui.LetterSpacing)
+	}
+	if ui.WordSpacing != 0 {
+		w += strings.Count(s, " ") * dui.Scale(ui.WordSpacing)
+	}
+	return w
+}
+
+// drawText draws the text t at p with letter and word spacing.
+func (ui *Label) drawText(dui *duit.DUI, img *draw.Image, p image.Point, font *draw.Font, t string) {
+	if ui.LetterSpacing == 0 && ui.WordSpacing == 0 {
+		if ui.Selected {
+			img.StringBg(p, dui.Regular.Normal.Text, image.ZP, font, t, selectedBg, image.ZP)
+		} else {
+			img.String(p, dui.Regular.Normal.Text, image.ZP, font, t)
+		}
+		return
+	}
+	ls := dui.Scale(ui.LetterSpacing)
+	ws := dui.Scale(ui.WordSpacing)
+	for _, r := range t {
+		s := string(r)
+		if ui.Selected {
+			w := font.StringWidth(s) + ls
+			if r == ' ' {
+				w += ws
+			}
+			img.Draw(image.Rect(p.X, p.Y, p.X+w, p.Y+font.Height), selectedBg, nil, image.ZP)
+		}
+		p = img.String(p, dui.Regular.Normal.Text, image.ZP, font, s)
+		p.X += ls
+		if r == ' ' {
+			p.X += ws
+		}
+	}
+}
+
+// drawDecoration draws underline, overline and line-through of a line
+// of width w at p.
+func (ui *Label) drawDecoration(dui *duit.DUI, img *draw.Image, p image.Point, font *draw.Font, w int) {
+	th := font.Height / 14
+	if th < 1 {
+		th = 1
+	}
+	line := func(y int) {
+		img.Draw(image.Rect(p.X, p.Y+y, p.X+w, p.Y+y+th), dui.Regular.Normal.Text, nil, image.ZP)
+	}
+	if ui.Decoration&Underline != 0 {
+		line(font.Ascent + th)
+	}
+	if ui.Decoration&Overline != 0 {
+		line(0)
+	}
+	if ui.Decoration&LineThrough != 0 {
+		line(font.Ascent * 2 / 3)
+	}
+}
+
 func (ui *Label) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
 	debugDraw(dui, self)
 
@@ -82,10 +217,26 @@ func (ui *Label) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image
 	}
 
 	font := ui.font(dui)
-	if ui.Selected {
-		img.StringBg(orig, dui.Regular.Normal.Text, image.ZP, font, ui.Text, selectedBg, image.ZP)
-	} else {
-		img.String(orig, dui.Regular.Normal.Text, image.ZP, font, ui.Text)
+	p := orig
+	lh := ui.lineHeight(dui, font)
+	if ui.LineHeight > 0 {
+		// center the text in the line
+		p.Y += (lh - font.Height) / 2
+	}
+	lines := ui.lines
+	if lines == nil {
+		lines = []string{ui.Text}
+	}
+	for _, t := range lines {
+		ui.drawText(dui, img, p, font, t)
+		if ui.Decoration != 0 {
+			w := ui.size.X
+			if len(lines) > 1 {
+				w = ui.textWidth(dui, font, t)
+			}
+			ui.drawDecoration(dui, img, p, font, w)
+		}
+		p.Y += lh
 	}
 	ui.orig = orig
 }
@@ -0,0 +1,27 @@
+package duitx
+
+import (
+	"image"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+)
+
+// layoutMarker places the marker in front of the first line of the
+// content, i.e. in the padding or margin left of it.
+func (ui *Box) layoutMarker(dui *duit.DUI, sizeAvail image.Point, padding duit.Space) {
+	k := &ui.marker
+	k.UI = ui.Marker
+	ui.Marker.Layout(dui, k, sizeAvail, true)
+	size := k.R.Size()
+	y := padding.Top
+	if len(ui.Kids) > 0 {
+		y = ui.Kids[0].R.Min.Y
+	}
+	k.R = rect(size).Add(image.Pt(padding.Left-size.X, y))
+}
+
+func (ui *Box) drawMarker(dui *duit.DUI, img *draw.Image, orig image.Point, m draw.Mouse) {
+	k := &ui.marker
+	ui.Marker.Draw(dui, k, img, orig.Add(k.R.Min), m, true)
+}
@@ -0,0 +1,76 @@
+package duitx
+
+import (
+	"image"
+
+	"github.com/mjl-/duit"
+)
+
+// measureKey identifies the layout of a kid with an available size.
+type measureKey struct {
+	k     *duit.Kid
+	avail image.Point
+}
+
+// measureCache holds the sizes of flex and grid items laid out during
+// the outermost flex or grid layout. Items are laid out several times
+// to measure them, without the cache nested containers would take
+// exponential time. Layouts only run on the duit loop.
+type measureCache struct {
+	depth int
+	sizes map[measureKey]image.Point
+
+	// measuring is > 0 while kids are laid out only to get their
+	// size. Their descendants are not laid out for good then.
+	measuring int
+
+	// last is the available size of the latest final layout of each
+	// kid
+	last map[*duit.Kid]image.Point
+}
+
+var measured measureCache
+
+func (c *measureCache) begin() {
+	if c.depth == 0 {
+		c.sizes = make(map[measureKey]image.Point)
+		c.last = make(map[*duit.Kid]image.Point)
+	}
+	c.depth++
+}
+
+func (c *measureCache) end() {
+	c.depth--
+	if c.depth == 0 {
+		c.sizes = nil
+		c.last = nil
+	}
+}
+
+// measure returns the size of k laid out with avail.
+func (c *measureCache) measure(dui *duit.DUI, k *duit.Kid, avail image.Point) image.Point {
+	if sz, ok := c.sizes[measureKey{k, avail}]; ok {
+		return sz
+	}
+	c.measuring++
+	k.UI.Layout(dui, k, avail, true)
+	c.measuring--
+	delete(c.last, k)
+	c.sizes[measureKey{k, avail}] = k.R.Size()
+	return k.R.Size()
+}
+
+// layout k with avail for good unless its latest final layout was with
+// avail. Its rectangle might have been moved or stretched by the
+// parent since. Nothing is done while measuring.
+func (c *measureCache) layout(dui *duit.DUI, k *duit.Kid, avail image.Point) {
+	if c.measuring > 0 {
+		return
+	}
+	if last, ok := c.last[k]; ok && last == avail {
+		return
+	}
+	k.UI.Layout(dui, k, avail, true)
+	c.last[k] = avail
+	c.sizes[measureKey{k, avail}] = k.R.Size()
+}
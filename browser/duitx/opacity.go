@@ -0,0 +1,77 @@
+package duitx
+
+import (
+	"image"
+	"math"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/opossum/logger"
+)
+
+// alphaMasks are replicated images by alpha to draw through.
+var alphaMasks = make(map[uint8]*draw.Image)
+
+func alphaMask(dui *duit.DUI, a uint8) *draw.Image {
+	if i, ok := alphaMasks[a]; ok {
+		return i
+	}
+	i, err := dui.Display.AllocImage(image.Rect(0, 0, 1, 1), draw.ARGB32, true, draw.White.WithAlpha(a))
+	if err != nil {
+		log.Errorf("alloc image: %v", err)
+		return nil
+	}
+	alphaMasks[a] = i
+	return i
+}
+
+// drawTranslucent draws the box including its kids onto an offscreen
+// layer which is composited over img. Kids are redrawn incrementally
+// into the layer, so what is behind the box is saved at forced draws
+// and restored before compositing again.
+func (ui *Box) drawTranslucent(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
+	if ui.Transparency >= 1 {
+		self.Draw = duit.Clean
+		return
+	}
+	r := rect(self.R.Size()).Add(orig)
+	force = force || self.Draw == duit.Dirty || ui.layer == nil || ui.layer.R != r
+	if force {
+		if ui.layer == nil || ui.layer.R != r {
+			ui.freeLayer()
+			var err error
+			if ui.layer, err = dui.Display.AllocImage(r, draw.ARGB32, false, draw.Transparent); err != nil {
+				log.Errorf("alloc image: %v", err)
+				ui.layer = nil
+			} else if ui.backdrop, err = dui.Display.AllocImage(r, draw.ARGB32, false, draw.Transparent); err != nil {
+				log.Errorf("alloc image: %v", err)
+				ui.freeLayer()
+			}
+		} else {
+			ui.layer.DrawOp(r, clearImage(dui), nil, image.ZP, draw.S)
+		}
+		if ui.backdrop != nil {
+			ui.backdrop.DrawOp(r, img, nil, r.Min, draw.S)
+		}
+	} else {
+		img.DrawOp(r, ui.backdrop, nil, r.Min, draw.S)
+	}
+	mask := alphaMask(dui, uint8(math.Round(0xff*(1-ui.Transparency))))
+	margin := dui.ScaleSpace(ui.Margin)
+	if ui.layer == nil || mask == nil {
+		ui.DrawSize(dui, self, img, orig.Add(margin.Topleft()), m, force, ui.size)
+		return
+	}
+	ui.DrawSize(dui, self, ui.layer, orig.Add(margin.Topleft()), m, force, ui.size)
+	img.Draw(r, ui.layer, mask, r.Min)
+}
+
+func (ui *Box) freeLayer() {
+	for _, i := range []*draw.Image{ui.layer, ui.backdrop} {
+		if i != nil {
+			i.Free()
+		}
+	}
+	ui.layer = nil
+	ui.backdrop = nil
+}
@@ -0,0 +1,322 @@
+package duitx
+
+import (
+	"image"
+	"sort"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+)
+
+// Pin of a UI with position fixed or sticky. Insets are in lowDPI
+// pixels, -1 means auto.
+type Pin struct {
+	Fixed  bool
+	Top    int
+	Bottom int
+}
+
+// Pinned is implemented by UIs that a Scroll draws relative to its
+// viewport instead of with the scrolled content.
+type Pinned interface {
+	Pin() (p Pin, ok bool)
+
+	// DrawPinned draws the UI on top of the scrolled content.
+	DrawPinned(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse)
+}
+
+// Wrapper is implemented by UIs that lay out another UI within the
+// same rectangle.
+type Wrapper interface {
+	Unwrap() duit.UI
+}
+
+// Stacked is implemented by positioned UIs with a z-index. ok is false
+// for z-index auto, otherwise the UI establishes a stacking context.
+// UIs with a z-index other than 0 draw nothing in Draw, the enclosing
+// stacking context draws them in z-index order with DrawStacked.
+type Stacked interface {
+	ZIndex() (z int, ok bool)
+
+	// DrawStacked draws the UI at its place in the stacking order.
+	DrawStacked(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse)
+
+	// MouseStacked delivers mouse events routed by the stacking
+	// context, Mouse ignores them like Draw.
+	MouseStacked(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) duit.Result
+}
+
+// StackingContext holds the descendants with a z-index other than 0 of
+// a stacking context, i.e. a Scroll or a UI with a z-index. They are
+// drawn below or above the other content depending on the sign of their
+// z-index. Descendants establishing a stacking context themselves draw
+// their descendants.
+type StackingContext struct {
+	items []*stackItem // sorted by z-index
+}
+
+type stackItem struct {
+	kid  *duit.Kid
+	ui   Stacked
+	z    int
+	r    image.Rectangle // in coordinates of the stacking context
+	clip image.Rectangle // of the boxes in between
+}
+
+// noClip is the clip rectangle of items not within clipping boxes.
+var noClip = image.Rect(-1<<30, -1<<30, 1<<30, 1<<30)
+
+// Layout collects the stacked descendants of k, the root of the
+// stacking context.
+func (sc *StackingContext) Layout(dui *duit.DUI, k *duit.Kid) {
+	sc.items = nil
+	kids, off, clip := descend(dui, k.UI, rect(k.R.Size()), noClip)
+	for _, kid := range kids {
+		sc.collect(dui, kid, off, clip)
+	}
+	sort.SliceStable(sc.items, func(i, j int) bool {
+		return sc.items[i].z < sc.items[j].z
+	})
+}
+
+// collect the stacked UIs of k and its descendants. o is the origin of
+// k.R in coordinates of the stacking context.
+func (sc *StackingContext) collect(dui *duit.DUI, k *duit.Kid, o image.Point, clip image.Rectangle) {
+	r := k.R.Add(o)
+	for ui := k.UI; ui != nil; {
+		if p, ok := ui.(Pinned); ok {
+			if pin, ok := p.Pin(); ok && pin.Fixed {
+				// drawn by the Scroll
+				return
+			}
+		}
+		if s, ok := ui.(Stacked); ok {
+			if z, ok := s.ZIndex(); ok {
+				if z != 0 {
+					sc.items = append(sc.items, &stackItem{kid: k, ui: s, z: z, r: r, clip: clip})
+				}
+				return
+			}
+		}
+		w, ok := ui.(Wrapper)
+		if !ok {
+			break
+		}
+		ui = w.Unwrap()
+	}
+	kids, off, clip := descend(dui, k.UI, r, clip)
+	for _, kid := range kids {
+		sc.collect(dui, kid, off, clip)
+	}
+}
+
+// DrawBelow draws the descendants with negative z-index, orig is that
+// of the root of the stacking context.
+func (sc *StackingContext) DrawBelow(dui *duit.DUI, img *draw.Image, orig image.Point, m draw.Mouse) {
+	for _, it := range sc.items {
+		if it.z < 0 {
+			it.draw(dui, img, orig, m)
+		}
+	}
+}
+
+// DrawAbove draws the descendants with positive z-index, orig is that
+// of the root of the stacking context.
+func (sc *StackingContext) DrawAbove(dui *duit.DUI, img *draw.Image, orig image.Point, m draw.Mouse) {
+	for _, it := range sc.items {
+		if it.z > 0 {
+			it.draw(dui, img, orig, m)
+		}
+	}
+}
+
+// Mouse delivers m to the topmost descendant with a positive z-index
+// at m. Otherwise mouse delivers it to the content, and if that hits
+// nothing, it goes to the topmost descendant with a negative z-index.
+// self is the root of the stacking context.
+func (sc *StackingContext) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point, mouse func() duit.Result) (r duit.Result) {
+	if r, ok := sc.mouse(dui, self, m, origM, orig, false); ok {
+		return r
+	}
+	r = mouse()
+	if r.Hit == nil {
+		if rr, ok := sc.mouse(dui, self, m, origM, orig, true); ok {
+			return rr
+		}
+	}
+	return
+}
+
+func (sc *StackingContext) mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point, below bool) (r duit.Result, ok bool) {
+	for i := len(sc.items) - 1; i >= 0; i-- {
+		it := sc.items[i]
+		if (it.z < 0) != below || !origM.Point.In(it.r) || !origM.Point.In(it.clip) {
+			continue
+		}
+		m.Point = m.Point.Sub(it.r.Min)
+		origM.Point = origM.Point.Sub(it.r.Min)
+		r = it.ui.MouseStacked(dui, it.kid, m, origM, orig.Add(it.r.Min))
+		if r.Hit == nil {
+			r.Hit = it.kid.UI
+		}
+		// the stacking context draws its items
+		if it.kid.Layout != duit.Clean {
+			self.Layout = duit.Dirty
+		} else if it.kid.Draw != duit.Clean {
+			self.Draw = duit.Dirty
+		}
+		return r, true
+	}
+	return
+}
+
+func (it *stackItem) draw(dui *duit.DUI, img *draw.Image, orig image.Point, m draw.Mouse) {
+	tmp := img.Clipr
+	cr := tmp
+	if it.clip != noClip {
+		cr = it.clip.Add(orig).Intersect(tmp)
+	}
+	if cr.Empty() || !it.r.Add(orig).Overlaps(cr) {
+		return
+	}
+	img.ReplClipr(false, cr)
+	k := *it.kid
+	k.R = rect(it.r.Size())
+	k.Draw = duit.Dirty
+	it.ui.DrawStacked(dui, &k, img, orig.Add(it.r.Min), m)
+	img.ReplClipr(false, tmp)
+}
+
+// pinned is a Pinned descendant of the content of a Scroll.
+type pinned struct {
+	kid   *duit.Kid
+	ui    Pinned
+	pin   Pin
+	r     image.Rectangle // in content coordinates
+	limit image.Rectangle // containing box in content coordinates
+	at    image.Point     // viewport position of the last draw
+	shown bool
+}
+
+// findPinned returns the outermost pinned UIs of k and its descendants
+// and the fixed UIs within them. o is the origin of k.R in content
+// coordinates and limit the box of the parent.
+func findPinned(dui *duit.DUI, k *duit.Kid, o image.Point, limit image.Rectangle) (ps []*pinned) {
+	r := k.R.Add(o)
+	for ui := k.UI; ui != nil; {
+		if p, ok := ui.(Pinned); ok {
+			if pin, ok := p.Pin(); ok {
+				ps = append(ps, &pinned{kid: k, ui: p, pin: pin, r: r, limit: limit})
+				break
+			}
+		}
+		w, ok := ui.(Wrapper)
+		if !ok {
+			break
+		}
+		ui = w.Unwrap()
+	}
+	isPinned := len(ps) > 0
+	kids, off, _ := descend(dui, k.UI, r, noClip)
+	for _, kid := range kids {
+		for _, p := range findPinned(dui, kid, off, r) {
+			if !isPinned || p.pin.Fixed {
+				// sticky descendants of pinned UIs move along
+				ps = append(ps, p)
+			}
+		}
+	}
+	return
+}
+
+// descend returns the kids of ui at r in content coordinates, off is
+// the origin of their rectangles and kidClip their clip rectangle when
+// drawn. Nested scrolls are not descended into.
+func descend(dui *duit.DUI, ui duit.UI, r, clip image.Rectangle) (kids []*duit.Kid, off image.Point, kidClip image.Rectangle) {
+	for {
+		w, ok := ui.(Wrapper)
+		if !ok {
+			break
+		}
+		ui = w.Unwrap()
+	}
+	off, kidClip = r.Min, clip
+	switch v := ui.(type) {
+	case *Box:
+		kids = v.Kids
+		off = off.Add(dui.ScaleSpace(v.Margin).Topleft())
+		if v.ClipX || v.ClipY {
+			pr := v.area(dui, PaddingBox, v.size).Add(off)
+			cr := noClip
+			if v.ClipX {
+				cr.Min.X, cr.Max.X = pr.Min.X, pr.Max.X
+			}
+			if v.ClipY {
+				cr.Min.Y, cr.Max.Y = pr.Min.Y, pr.Max.Y
+			}
+			kidClip = clip.Intersect(cr)
+		}
+	case *Grid:
+		kids = v.Kids
+	case *duit.Place:
+		kids = v.Kids
+	}
+	return kids, off, kidClip
+}
+
+// zIndex returns the z-index of the UI of k.
+func zIndex(k *duit.Kid) int {
+	if s, ok := k.UI.(Stacked); ok {
+		z, _ := s.ZIndex()
+		return z
+	}
+	return 0
+}
+
+// flowKids returns the kids without those with a z-index other than 0,
+// which their stacking context draws and delivers mouse events to.
+func flowKids(kids []*duit.Kid) []*duit.Kid {
+	for i, k := range kids {
+		if zIndex(k) == 0 {
+			continue
+		}
+		fk := append([]*duit.Kid(nil), kids[:i]...)
+		for _, k := range kids[i+1:] {
+			if zIndex(k) == 0 {
+				fk = append(fk, k)
+			}
+		}
+		return fk
+	}
+	return kids
+}
+
+// viewport returns the position of the pinned UI within the viewport
+// of size vp when the content is scrolled by offset, or ok=false if
+// it is not stuck.
+func (p *pinned) viewport(dui *duit.DUI, offset int, vp image.Point) (at image.Point, ok bool) {
+	h := p.r.Dy()
+	if p.pin.Fixed {
+		at = p.r.Min
+		if p.pin.Top >= 0 {
+			at.Y = dui.Scale(p.pin.Top)
+		} else if p.pin.Bottom >= 0 {
+			at.Y = vp.Y - dui.Scale(p.pin.Bottom) - h
+		}
+		return at, true
+	}
+	if p.pin.Top < 0 {
+		return
+	}
+	y := offset + dui.Scale(p.pin.Top)
+	if y <= p.r.Min.Y {
+		return
+	}
+	if max := p.limit.Max.Y - h; y > max {
+		y = max
+	}
+	if y <= p.r.Min.Y {
+		return
+	}
+	return image.Pt(p.r.Min.X, y-offset), true
+}
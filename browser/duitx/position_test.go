@@ -0,0 +1,195 @@
+package duitx
+
+import (
+	"image"
+	"testing"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+)
+
+type stacked struct {
+	duit.UI
+	z    int
+	ok   bool
+	mice int
+}
+
+func (s *stacked) ZIndex() (int, bool) {
+	return s.z, s.ok
+}
+
+func (s *stacked) DrawStacked(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse) {
+}
+
+func (s *stacked) MouseStacked(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) duit.Result {
+	s.mice++
+	return duit.Result{}
+}
+
+func (s *stacked) Unwrap() duit.UI {
+	return s.UI
+}
+
+type pinnedUI struct {
+	fixed
+	pin Pin
+}
+
+func (p *pinnedUI) Pin() (Pin, bool) {
+	return p.pin, true
+}
+
+func (p *pinnedUI) DrawPinned(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse) {
+}
+
+func TestFlowKids(t *testing.T) {
+	a := &duit.Kid{UI: &stacked{UI: &fixed{}, z: 2, ok: true}}
+	b := &duit.Kid{UI: &stacked{UI: &fixed{}, z: -1, ok: true}}
+	c := &duit.Kid{UI: &fixed{}}
+	d := &duit.Kid{UI: &stacked{UI: &fixed{}, ok: true}}
+	kids := []*duit.Kid{a, b, c, d}
+	fk := flowKids(kids)
+	if len(fk) != 2 || fk[0] != c || fk[1] != d {
+		t.Fatalf("%+v", fk)
+	}
+	if kids[0] != a {
+		t.Fatalf("kids modified")
+	}
+	plain := []*duit.Kid{c, d}
+	if fk := flowKids(plain); len(fk) != 2 || &fk[0] != &plain[0] {
+		t.Fatalf("plain")
+	}
+}
+
+func TestStackingContext(t *testing.T) {
+	dui := testDUI()
+	above := &stacked{UI: &fixed{image.Pt(50, 10)}, z: 2, ok: true}
+	below := &stacked{UI: &fixed{image.Pt(50, 10)}, z: -1, ok: true}
+	clipped := &Box{Kids: duit.NewKids(&fixed{image.Pt(100, 5)}, below), Disp: Block, ClipY: true}
+	nested := &stacked{UI: &fixed{image.Pt(10, 10)}, z: 5, ok: true}
+	ctx := &stacked{UI: NewBox(nested), ok: true}
+	root := NewBox(&fixed{image.Pt(100, 30)}, above, clipped, ctx)
+	k := layout(root, 100)
+
+	var sc StackingContext
+	sc.Layout(dui, k)
+	if len(sc.items) != 2 || sc.items[0].ui != below || sc.items[1].ui != above {
+		t.Fatalf("%+v", sc.items)
+	}
+	if it := sc.items[1]; it.r != root.Kids[1].R || it.clip != noClip {
+		t.Errorf("above: %v %v", it.r, it.clip)
+	}
+	cr := root.Kids[2].R
+	if it := sc.items[0]; it.r != clipped.Kids[1].R.Add(cr.Min) || it.clip.Min.Y != cr.Min.Y || it.clip.Max.Y != cr.Max.Y || it.clip.Min.X != noClip.Min.X {
+		t.Errorf("below: %v %v", it.r, it.clip)
+	}
+
+	// positive z-indexes get mouse events before the content, negative
+	// ones only when the content doesn't hit anything
+	self := &duit.Kid{}
+	var content int
+	mouse := func(hit duit.UI) func() duit.Result {
+		return func() duit.Result {
+			content++
+			return duit.Result{Hit: hit}
+		}
+	}
+	at := func(p image.Point) draw.Mouse {
+		return draw.Mouse{Point: p}
+	}
+	m := at(sc.items[1].r.Min)
+	if r := sc.Mouse(dui, self, m, m, image.ZP, mouse(root)); r.Hit != root.Kids[1].UI || above.mice != 1 || content != 0 {
+		t.Fatalf("above: %+v %v %v", r, above.mice, content)
+	}
+	m = at(sc.items[0].r.Min)
+	if r := sc.Mouse(dui, self, m, m, image.ZP, mouse(root)); r.Hit != root || below.mice != 0 || content != 1 {
+		t.Fatalf("below hidden: %+v %v %v", r, below.mice, content)
+	}
+	if r := sc.Mouse(dui, self, m, m, image.ZP, mouse(nil)); r.Hit != clipped.Kids[1].UI || below.mice != 1 || content != 2 {
+		t.Fatalf("below: %+v %v %v", r, below.mice, content)
+	}
+
+	// descendants of nested stacking contexts are theirs
+	sc.Layout(dui, root.Kids[3])
+	if len(sc.items) != 1 || sc.items[0].ui != nested {
+		t.Fatalf("%+v", sc.items)
+	}
+}
+
+// pinnedBox is a pinned box with kids.
+type pinnedBox struct {
+	*Box
+	pin Pin
+}
+
+func (p *pinnedBox) Pin() (Pin, bool) {
+	return p.pin, true
+}
+
+func (p *pinnedBox) DrawPinned(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse) {
+}
+
+func (p *pinnedBox) Unwrap() duit.UI {
+	return p.Box
+}
+
+func TestFindPinnedNested(t *testing.T) {
+	dui := testDUI()
+	fixedUI := &pinnedUI{fixed: fixed{image.Pt(10, 10)}, pin: Pin{Fixed: true, Top: 0, Bottom: -1}}
+	stickyUI := &pinnedUI{fixed: fixed{image.Pt(10, 10)}, pin: Pin{Top: 0, Bottom: -1}}
+	p := &pinnedBox{Box: NewBox(stickyUI, fixedUI), pin: Pin{Top: 0, Bottom: -1}}
+	k := layout(NewBox(p), 100)
+	ps := findPinned(dui, k, image.ZP, k.R)
+	if len(ps) != 2 || ps[0].ui != p || ps[1].ui != fixedUI {
+		t.Fatalf("%+v", ps)
+	}
+}
+
+func TestFindPinned(t *testing.T) {
+	dui := testDUI()
+	p := &pinnedUI{fixed: fixed{image.Pt(100, 20)}, pin: Pin{Top: 0, Bottom: -1}}
+	b := NewBox(&fixed{image.Pt(100, 100)}, p, &fixed{image.Pt(100, 500)})
+	k := layout(b, 100)
+	ps := findPinned(dui, k, image.ZP, k.R)
+	if len(ps) != 1 || ps[0].ui != p {
+		t.Fatalf("%+v", ps)
+	}
+	if ps[0].r.Min.Y != 100 || ps[0].r.Dy() != 20 {
+		t.Fatalf("%v", ps[0].r)
+	}
+}
+
+func TestViewport(t *testing.T) {
+	dui := testDUI()
+	vp := image.Pt(100, 200)
+	p := &pinned{
+		r:     image.Rect(0, 100, 50, 120),
+		limit: image.Rect(0, 0, 100, 400),
+	}
+
+	p.pin = Pin{Fixed: true, Top: 10, Bottom: -1}
+	if at, ok := p.viewport(dui, 300, vp); !ok || at != image.Pt(0, 10) {
+		t.Fatalf("%v %v", at, ok)
+	}
+	p.pin = Pin{Fixed: true, Top: -1, Bottom: 0}
+	if at, ok := p.viewport(dui, 300, vp); !ok || at != image.Pt(0, 180) {
+		t.Fatalf("%v %v", at, ok)
+	}
+
+	p.pin = Pin{Top: 0, Bottom: -1}
+	if _, ok := p.viewport(dui, 50, vp); ok {
+		t.Fatalf("stuck before reaching top")
+	}
+	if at, ok := p.viewport(dui, 150, vp); !ok || at != image.Pt(0, 0) {
+		t.Fatalf("%v %v", at, ok)
+	}
+	// stays within the containing box
+	if at, ok := p.viewport(dui, 390, vp); !ok || at != image.Pt(0, -10) {
+		t.Fatalf("%v %v", at, ok)
+	}
+	p.pin = Pin{Top: -1, Bottom: 0}
+	if _, ok := p.viewport(dui, 150, vp); ok {
+		t.Fatalf("sticky without top")
+	}
+}
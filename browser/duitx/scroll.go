@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"image"
 	"math"
+	"sort"
 	"time"
 
 	"9fans.net/go/draw"
@@ -38,11 +39,18 @@ type Scroll struct {
 	Kid    duit.Kid
 	Height int // < 0 means full height, 0 means as much as necessary, >0 means exactly that many lowdpi pixels
 
+	X            bool // Scroll horizontally too, only for nested scrolls.
+	ContentWidth int  // Minimum width in lowDPI pixels to lay out the kid of horizontally scrolling nested scrolls with.
+	MaxHeight    int  // If >0, nested scrolls with a dynamic Height are at most that many lowDPI pixels high.
+
 	r             image.Rectangle // entire ui
 	barR          image.Rectangle
 	barActiveR    image.Rectangle
+	barXR         image.Rectangle // horizontal scroll bar of nested scrolls
 	childR        image.Rectangle
 	Offset        int         // current scroll offset in pixels
+	OffsetX       int         // current horizontal scroll offset in pixels
+	nested        bool        // within the content of another scroll
 	img           *draw.Image // for child to draw on
 	scrollbarSize int
 	lastMouseUI   duit.UI
@@ -51,10 +59,22 @@ type Scroll struct {
 	tiles        map[int]*draw.Image
 	last         map[int]time.Time
 	tilesChanged bool
+	marked       time.Time       // a descendant was last marked for drawing
+	damaged      image.Rectangle // area of the tiles to redraw
+
+	pinned []*pinned       // fixed and sticky descendants
+	stack  StackingContext // of the content
 }
 
 var _ duit.UI = &Scroll{}
 
+// Drawn is implemented by UIs that know the image and rectangle they
+// were drawn on last. When they are marked for drawing, only their
+// rectangle of the tiles is redrawn instead of the tiles altogether.
+type Drawn interface {
+	Drawn() (img *draw.Image, r image.Rectangle)
+}
+
 // NewScroll returns a full-height scroll bar containing ui.
 func NewScroll(dui *duit.DUI, ui duit.UI) *Scroll {
 	s := &Scroll{
@@ -66,6 +86,19 @@ func NewScroll(dui *duit.DUI, ui duit.UI) *Scroll {
 	return s
 }
 
+// NewNestedScroll returns a scroll within the content of another
+// scroll. It scrolls vertically if height is >0 and horizontally if x
+// is set. Scroll bars are only shown when the content overflows.
+// Nested scrolls are drawn into the tiles of the outer scroll and
+// draw their kid directly.
+func NewNestedScroll(ui duit.UI, height int, x bool) *Scroll {
+	s := NewScroll(nil, ui)
+	s.Height = height
+	s.X = x
+	s.nested = true
+	return s
+}
+
 func (ui *Scroll) Free() {
 	ui.tiles = make(map[int]*draw.Image)
 	ui.last = make(map[int]time.Time)
@@ -101,7 +134,7 @@ func (ui *Scroll) ensure(dui *duit.DUI, i int) {
 	log.Printf("ensure(dui, %v)", i)
 	last, ok := ui.last[i]
 	tl, _ := ui.tiles[i]
-	if ok && time.Since(last) < maxAge && ui.sizeOk(tl) {
+	if ok && time.Since(last) < maxAge && ui.sizeOk(tl) && last.After(ui.marked) {
 		return
 	}
 
@@ -111,7 +144,7 @@ func (ui *Scroll) ensure(dui *duit.DUI, i int) {
 	if duitError(dui, err, "allocimage") {
 		return
 	}
-	ui.Kid.UI.Draw(dui, &ui.Kid, img, image.ZP, draw.Mouse{}, true)
+	ui.drawKid(dui, img, image.ZP)
 
 	if ui.tiles[i] != nil {
 		ui.tiles[i].Free()
@@ -130,6 +163,50 @@ func (ui *Scroll) ensure(dui *duit.DUI, i int) {
 	}
 }
 
+// drawnRect returns the rectangle o was drawn at if that was on a
+// tile. Tiles have content coordinates.
+func (ui *Scroll) drawnRect(o duit.UI) (r image.Rectangle, ok bool) {
+	d, ok := o.(Drawn)
+	if !ok {
+		return
+	}
+	img, r := d.Drawn()
+	for _, tl := range ui.tiles {
+		if tl != nil && tl == img {
+			return r, true
+		}
+	}
+	return r, false
+}
+
+// repair redraws the damaged area of the tiles.
+func (ui *Scroll) repair(dui *duit.DUI) {
+	if ui.damaged.Empty() {
+		return
+	}
+	for _, tl := range ui.tiles {
+		if tl == nil {
+			continue
+		}
+		r := ui.damaged.Intersect(tl.R)
+		if r.Empty() {
+			continue
+		}
+		tmp := tl.Clipr
+		tl.ReplClipr(false, r)
+		ui.drawKid(dui, tl, image.ZP)
+		tl.ReplClipr(false, tmp)
+	}
+	ui.damaged = image.ZR
+}
+
+// stale is true if tile i is missing or drawn before a descendant was
+// marked for drawing.
+func (ui *Scroll) stale(i int) bool {
+	last, ok := ui.last[i]
+	return !ok || !last.After(ui.marked)
+}
+
 func (ui *Scroll) pos() (t, of int) {
 	t = ui.Offset / ui.r.Dy()
 	of = ui.Offset % ui.r.Dy()
@@ -155,6 +232,12 @@ func (ui *Scroll) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, f
 	// todo: be smarter about DirtyKid
 
 	ui.scrollbarSize = dui.Scale(duit.ScrollbarSize)
+	if ui.nested {
+		ui.layoutNested(dui, sizeAvail)
+		ui.stack.Layout(dui, &ui.Kid)
+		self.R = rect(ui.r.Size())
+		return
+	}
 	scaledHeight := dui.Scale(ui.Height)
 	if scaledHeight > 0 && scaledHeight < sizeAvail.Y {
 		sizeAvail.Y = scaledHeight
@@ -177,9 +260,70 @@ func (ui *Scroll) Layout(dui *duit.DUI, self *duit.Kid, sizeAvail image.Point, f
 		ui.childR.Max.Y = kY
 	}
 	self.R = rect(ui.r.Size())
+	ui.pinned = findPinned(dui, &ui.Kid, image.ZP, ui.Kid.R)
+	sort.SliceStable(ui.pinned, func(i, j int) bool {
+		return zIndex(ui.pinned[i].kid) < zIndex(ui.pinned[j].kid)
+	})
+	ui.stack.Layout(dui, &ui.Kid)
 	ui.Free()
 }
 
+// layoutNested lays out the kid within the viewport and adds scroll
+// bars along the axes where it overflows.
+func (ui *Scroll) layoutNested(dui *duit.DUI, sizeAvail image.Point) {
+	bar := ui.scrollbarSize
+	h := dui.Scale(ui.Height)
+	vp := image.Pt(sizeAvail.X, h)
+	if h <= 0 {
+		vp.Y = sizeAvail.Y
+	}
+	layout := func() {
+		avail := vp
+		if ui.X {
+			avail.X = maximum(avail.X, dui.Scale(ui.ContentWidth))
+		}
+		ui.Kid.UI.Layout(dui, &ui.Kid, avail, true)
+		ui.Kid.Layout = duit.Clean
+		ui.Kid.Draw = duit.Dirty
+	}
+	layout()
+	if mh := dui.Scale(ui.MaxHeight); h <= 0 && mh > 0 && ui.Kid.R.Dy() > mh {
+		h = mh
+		vp.Y = h
+	}
+	barY, barX := false, false
+	for i := 0; i < 2; i++ {
+		if !barY && h > 0 && ui.Kid.R.Dy() > vp.Y {
+			barY = true
+			vp.X -= bar
+			layout()
+		}
+		if !barX && ui.X && ui.Kid.R.Dx() > vp.X {
+			barX = true
+			if h > 0 {
+				vp.Y -= bar
+			}
+		}
+	}
+	if h <= 0 {
+		vp.Y = ui.Kid.R.Dy()
+	}
+	ui.childR = rect(vp)
+	ui.barR = image.Rectangle{}
+	if barY {
+		ui.barR = rect(image.Pt(bar, vp.Y))
+		ui.childR = ui.childR.Add(image.Pt(bar, 0))
+	}
+	ui.barXR = image.Rectangle{}
+	if barX {
+		ui.barXR = image.Rect(ui.childR.Min.X, vp.Y, ui.childR.Max.X, vp.Y+bar)
+	}
+	ui.r = ui.childR.Union(ui.barR).Union(ui.barXR)
+	ui.r.Min = image.ZP
+	ui.scroll(0)
+	ui.scrollX(0)
+}
+
 func (ui *Scroll) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
 	debugDraw(dui, self)
 
@@ -195,10 +339,95 @@ func (ui *Scroll) Draw(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig imag
 	}
 
 	ui.drawBar(dui, self, img, orig, m, force)
-	ui.drawChild(dui, self, img, orig, m, force)
+	if ui.nested {
+		ui.drawBarX(dui, img, orig, m)
+		ui.drawNested(dui, img, orig)
+	} else {
+		ui.drawChild(dui, self, img, orig, m, force)
+		ui.drawPinned(dui, img, orig, m)
+	}
 	self.Draw = duit.Clean
 }
 
+// drawKid draws the content at orig with its stacking context.
+func (ui *Scroll) drawKid(dui *duit.DUI, img *draw.Image, orig image.Point) {
+	ui.stack.DrawBelow(dui, img, orig, draw.Mouse{})
+	ui.Kid.UI.Draw(dui, &ui.Kid, img, orig, draw.Mouse{}, true)
+	ui.stack.DrawAbove(dui, img, orig, draw.Mouse{})
+}
+
+// drawNested draws the kid at the scroll offsets clipped to the
+// viewport.
+func (ui *Scroll) drawNested(dui *duit.DUI, img *draw.Image, orig image.Point) {
+	tmp := img.Clipr
+	cr := ui.childR.Add(orig).Intersect(tmp)
+	if cr.Empty() {
+		return
+	}
+	img.ReplClipr(false, cr)
+	o := orig.Add(ui.childR.Min).Sub(image.Pt(ui.OffsetX, ui.Offset))
+	ui.drawKid(dui, img, o)
+	ui.Kid.Draw = duit.Clean
+	img.ReplClipr(false, tmp)
+}
+
+// drawBarX draws the horizontal scroll bar of nested scrolls.
+func (ui *Scroll) drawBarX(dui *duit.DUI, img *draw.Image, orig image.Point, m draw.Mouse) {
+	if ui.barXR.Empty() {
+		return
+	}
+	bg := dui.ScrollBGNormal
+	vis := dui.ScrollVisibleNormal
+	if m.In(ui.barXR) {
+		bg = dui.ScrollBGHover
+		vis = dui.ScrollVisibleHover
+	}
+	w := ui.barXR.Dx()
+	uiw := ui.Kid.R.Dx()
+	img.Draw(ui.barXR.Add(orig), bg, nil, image.ZP)
+	r := ui.barXR
+	r.Min.X += ui.OffsetX * w / uiw
+	r.Max.X = r.Min.X + w*w/uiw
+	r.Min.Y += 1 // unscaled
+	img.Draw(r.Add(orig), vis, nil, image.ZP)
+}
+
+// drawPinned draws fixed and stuck sticky UIs over the content.
+func (ui *Scroll) drawPinned(dui *duit.DUI, img *draw.Image, orig image.Point, m draw.Mouse) {
+	if len(ui.pinned) == 0 || ui.childR.Empty() {
+		return
+	}
+	tmp := img.Clipr
+	img.ReplClipr(false, ui.childR.Add(orig))
+	for _, p := range ui.pinned {
+		p.at, p.shown = p.viewport(dui, ui.Offset, ui.childR.Size())
+		if !p.shown {
+			continue
+		}
+		k := *p.kid
+		k.R = rect(p.r.Size())
+		k.Draw = duit.Dirty
+		p.ui.DrawPinned(dui, &k, img, orig.Add(ui.childR.Min).Add(p.at), m)
+	}
+	img.ReplClipr(false, tmp)
+}
+
+// contentPoint returns the point in content coordinates of the point
+// p within the viewport, which might be on a pinned UI.
+func (ui *Scroll) contentPoint(p image.Point) image.Point {
+	v := p.Sub(ui.childR.Min)
+	if ui.nested {
+		return v.Add(image.Pt(ui.OffsetX, ui.Offset))
+	}
+	for i := len(ui.pinned) - 1; i >= 0; i-- {
+		pp := ui.pinned[i]
+		if pp.shown && v.In(rect(pp.r.Size()).Add(pp.at)) {
+			return v.Sub(pp.at).Add(pp.r.Min)
+		}
+	}
+	return v.Add(image.Pt(0, ui.Offset))
+}
+
 func (ui *Scroll) drawBar(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse, force bool) {
 	// ui.scroll(0)
 	barHover := m.In(ui.barR)
@@ -210,9 +439,9 @@ func (ui *Scroll) drawBar(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig i
 		vis = dui.ScrollVisibleHover
 	}
 
-	h := ui.r.Dy()
+	h := ui.childR.Dy()
 	uih := ui.Kid.R.Dy()
-	if uih > h {
+	if uih > h && !ui.barR.Empty() {
 		barR := ui.barR.Add(orig)
 		img.Draw(barR, bg, nil, image.ZP)
 		barH := h * h / uih
@@ -243,6 +472,8 @@ func (ui *Scroll) drawChild(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig
 		i, of = ui.pos()
 		tl, ok = ui.tiles[i]
 		tl1, ok1 = ui.tiles[i+1]
+		ok = ok && !ui.stale(i)
+		ok1 = ok1 && !ui.stale(i+1)
 		if !ok {
 			ui.ensure(dui, i)
 		}
@@ -295,12 +526,13 @@ func (ui *Scroll) drawChild(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig
 		ui.freeCur()
 		tmp := img.Clipr
 		img.ReplClipr(false, ui.childR.Add(orig))
-		ui.Kid.UI.Draw(dui, &ui.Kid, img, orig.Add(ui.childR.Min).Add(n), draw.Mouse{}, true)
+		ui.drawKid(dui, img, orig.Add(ui.childR.Min).Add(n))
 		img.ReplClipr(false, tmp)
 		ui.Kid.Draw = duit.Clean
 		return
 	}
 
+	ui.repair(dui)
 	predrawCur()
 
 	rTop := draw.Rectangle{
@@ -334,6 +566,25 @@ func (ui *Scroll) scroll(delta int) (changed bool) {
 	return o != ui.Offset
 }
 
+func (ui *Scroll) scrollX(delta int) (changed bool) {
+	o := ui.OffsetX
+	ui.OffsetX += delta
+	ui.OffsetX = maximum(0, ui.OffsetX)
+	ui.OffsetX = minimum(ui.OffsetX, maximum(0, ui.Kid.R.Dx()-ui.childR.Dx()))
+	return o != ui.OffsetX
+}
+
+// wheel scrolls nested scrolls by a step up or down for negative or
+// positive dir, horizontally if the content only overflows
+// horizontally.
+func (ui *Scroll) wheel(dir int) (changed bool) {
+	step := dir * maximum(ui.childR.Dy()/4, ui.scrollbarSize)
+	if ui.Kid.R.Dy() > ui.childR.Dy() {
+		return ui.scroll(step)
+	}
+	return ui.X && ui.scrollX(step)
+}
+
 func (ui *Scroll) scrollKey(k rune) (consumed bool) {
 	switch k {
 	case draw.KeyUp:
@@ -351,8 +602,14 @@ func (ui *Scroll) scrollKey(k rune) (consumed bool) {
 func (ui *Scroll) scrollMouse(m draw.Mouse, scrollOnly bool) (consumed bool) {
 	switch m.Buttons {
 	case duit.Button4:
+		if ui.nested {
+			return ui.wheel(-1)
+		}
 		return ui.scroll(-m.Y / 4)
 	case duit.Button5:
+		if ui.nested {
+			return ui.wheel(1)
+		}
 		return ui.scroll(m.Y / 4)
 	}
 
@@ -375,6 +632,25 @@ func (ui *Scroll) scrollMouse(m draw.Mouse, scrollOnly bool) (consumed bool) {
 	return false
 }
 
+// scrollMouseX handles clicks on the horizontal scroll bar like
+// scrollMouse on the vertical one.
+func (ui *Scroll) scrollMouseX(m draw.Mouse) (consumed bool) {
+	x := m.X - ui.barXR.Min.X
+	switch m.Buttons {
+	case duit.Button4:
+		return ui.scrollX(-ui.childR.Dx() / 4)
+	case duit.Button5:
+		return ui.scrollX(ui.childR.Dx() / 4)
+	case duit.Button1:
+		return ui.scrollX(-x)
+	case duit.Button2:
+		return ui.scrollX(x*ui.Kid.R.Dx()/ui.barXR.Dx() - ui.OffsetX)
+	case duit.Button3:
+		return ui.scrollX(x)
+	}
+	return false
+}
+
 func (ui *Scroll) result(dui *duit.DUI, self *duit.Kid, r *duit.Result, scrolled bool) {
 	if ui.Kid.Layout != duit.Clean {
 		ui.Kid.UI.Layout(dui, &ui.Kid, ui.childR.Size(), false)
@@ -393,13 +669,19 @@ func (ui *Scroll) result(dui *duit.DUI, self *duit.Kid, r *duit.Result, scrolled
 }
 
 func (ui *Scroll) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) (r duit.Result) {
+	d := ui.contentPoint(m.Point).Sub(m.Point)
 	nOrigM := origM
-	nOrigM.Point = nOrigM.Point.Add(image.Pt(-ui.scrollbarSize, ui.Offset))
+	nOrigM.Point = nOrigM.Point.Add(d)
 	nm := m
-	nm.Point = nm.Point.Add(image.Pt(-ui.scrollbarSize, ui.Offset))
+	nm.Point = nm.Point.Add(d)
 
+	mouse := func() duit.Result {
+		return ui.stack.Mouse(dui, &ui.Kid, nm, nOrigM, image.ZP, func() duit.Result {
+			return ui.Kid.UI.Mouse(dui, &ui.Kid, nm, nOrigM, image.ZP)
+		})
+	}
 	if m.Buttons == 0 {
-		ui.Kid.UI.Mouse(dui, &ui.Kid, nm, nOrigM, image.ZP) // comment this to have no flicker after mouse move and then scroll
+		mouse() // comment this to have no flicker after mouse move and then scroll
 		return
 	}
 	if m.Point.In(ui.barR) {
@@ -407,17 +689,23 @@ func (ui *Scroll) Mouse(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.
 		r.Consumed = ui.scrollMouse(m, false)
 		self.Draw = duit.Dirty
 		return
+	} else if m.Point.In(ui.barXR) {
+		r.Hit = ui
+		r.Consumed = ui.scrollMouseX(m)
+		self.Draw = duit.Dirty
+		return
 	} else if m.Point.In(ui.childR) {
-		r.Consumed = ui.scrollMouse(m, true)
+		// nested scrolls get the wheel events first
+		r = mouse()
 		if r.Consumed {
 			self.Draw = duit.Dirty
+			ui.tilesChanged = true
+			log.Printf("Mouse: set ui.tilesChanged = true")
 			return
 		}
-		r = ui.Kid.UI.Mouse(dui, &ui.Kid, nm, nOrigM, image.ZP)
-		if r.Consumed {
+		if ui.scrollMouse(m, true) {
+			r.Consumed = true
 			self.Draw = duit.Dirty
-			ui.tilesChanged = true
-			log.Printf("Mouse: set ui.tilesChanged = true")
 		}
 	}
 	return
@@ -433,7 +721,7 @@ func (ui *Scroll) Key(dui *duit.DUI, self *duit.Kid, k rune, m draw.Mouse, orig
 	}
 	if m.Point.In(ui.childR) {
 		log.Printf("Key: in ui.childR (self.Draw=%v)", self.Draw)
-		m.Point = m.Point.Add(image.Pt(-ui.scrollbarSize, ui.Offset))
+		m.Point = m.Point.Sub(ui.childR.Min).Add(image.Pt(ui.OffsetX, ui.Offset))
 		scrolled := ui.scrollKey(k)
 		if scrolled {
 			self.Draw = duit.Dirty
@@ -506,6 +794,11 @@ func (ui *Scroll) Mark(self *duit.Kid, o duit.UI, forLayout bool) (marked bool)
 				self.Layout = duit.DirtyKid
 			}
 		} else {
+			if r, ok := ui.drawnRect(o); ok {
+				ui.damaged = ui.damaged.Union(r)
+			} else {
+				ui.marked = time.Now()
+			}
 			if self.Layout == duit.Clean {
 				self.Draw = duit.DirtyKid
 			}
@@ -515,7 +808,7 @@ func (ui *Scroll) Mark(self *duit.Kid, o duit.UI, forLayout bool) (marked bool)
 }
 
 func (ui *Scroll) Print(self *duit.Kid, indent int) {
-	what := fmt.Sprintf("Scroll Offset=%d childR=%v", ui.Offset, ui.childR)
+	what := fmt.Sprintf("Scroll Offset=%d OffsetX=%d childR=%v", ui.Offset, ui.OffsetX, ui.childR)
 	duit.PrintUI(what, self, indent)
 	ui.Kid.UI.Print(&ui.Kid, indent+1)
 }
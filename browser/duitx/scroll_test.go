@@ -69,3 +69,119 @@ func TestTlR(t *testing.T) {
 		t.Fatalf("%v", r)
 	}
 }
+
+func TestNestedLayout(t *testing.T) {
+	dui := testDUI()
+	bar := dui.Scale(duit.ScrollbarSize)
+	tests := []struct {
+		height int
+		x      bool
+		kid    image.Point
+		r      image.Rectangle
+		childR image.Rectangle
+		barXR  image.Rectangle
+	}{
+		{100, false, image.Pt(50, 50), image.Rect(0, 0, 200, 100), image.Rect(0, 0, 200, 100), image.Rectangle{}},
+		{100, false, image.Pt(50, 500), image.Rect(0, 0, 200, 100), image.Rect(bar, 0, 200, 100), image.Rectangle{}},
+		{0, true, image.Pt(500, 50), image.Rect(0, 0, 200, 50+bar), image.Rect(0, 0, 200, 50), image.Rect(0, 50, 200, 50+bar)},
+		{100, true, image.Pt(500, 95), image.Rect(0, 0, 200, 100), image.Rect(bar, 0, 200, 100-bar), image.Rect(bar, 100-bar, 200, 100)},
+	}
+	for _, tt := range tests {
+		s := NewNestedScroll(&fixed{tt.kid}, tt.height, tt.x)
+		k := &duit.Kid{UI: s}
+		s.Layout(dui, k, image.Pt(200, 1000), true)
+		if s.r != tt.r || k.R != tt.r || s.childR != tt.childR || s.barXR != tt.barXR {
+			t.Errorf("%+v: r=%v childR=%v barXR=%v", tt, s.r, s.childR, s.barXR)
+		}
+	}
+}
+
+func TestNestedMaxHeight(t *testing.T) {
+	dui := testDUI()
+	bar := dui.Scale(duit.ScrollbarSize)
+	for _, tt := range []struct {
+		kid    int
+		r      image.Rectangle
+		childR image.Rectangle
+	}{
+		{50, image.Rect(0, 0, 200, 50), image.Rect(0, 0, 200, 50)},
+		{500, image.Rect(0, 0, 200, 100), image.Rect(bar, 0, 200, 100)},
+	} {
+		s := NewNestedScroll(&fixed{image.Pt(50, tt.kid)}, 0, false)
+		s.MaxHeight = 100
+		k := &duit.Kid{UI: s}
+		s.Layout(dui, k, image.Pt(200, 1000), true)
+		if s.r != tt.r || k.R != tt.r || s.childR != tt.childR {
+			t.Errorf("%v: r=%v childR=%v", tt.kid, s.r, s.childR)
+		}
+	}
+}
+
+func TestNestedWheel(t *testing.T) {
+	dui := testDUI()
+	inner := NewNestedScroll(&fixed{image.Pt(50, 150)}, 100, false)
+	outer := NewNestedScroll(NewBox(inner, &fixed{image.Pt(50, 500)}), 200, false)
+	k := &duit.Kid{UI: outer}
+	outer.Layout(dui, k, image.Pt(200, 1000), true)
+
+	m := draw.Mouse{Point: image.Pt(30, 10), Buttons: duit.Button5}
+	wheel := func() bool {
+		return outer.Mouse(dui, k, m, m, image.ZP).Consumed
+	}
+	for _, exp := range []int{25, 50} {
+		if !wheel() || inner.Offset != exp || outer.Offset != 0 {
+			t.Fatalf("inner first: %v %v", inner.Offset, outer.Offset)
+		}
+	}
+	if !wheel() || inner.Offset != 50 || outer.Offset != 50 {
+		t.Fatalf("outer at inner end: %v %v", inner.Offset, outer.Offset)
+	}
+}
+
+func TestStaleTiles(t *testing.T) {
+	kid := &fixed{image.Pt(10, 10)}
+	s := NewScroll(nil, kid)
+	self := &duit.Kid{UI: s}
+	s.last[0] = time.Now()
+	if s.stale(0) || !s.stale(1) {
+		t.Fatalf("%v %v", s.stale(0), s.stale(1))
+	}
+	if !s.Mark(self, kid, true) || s.stale(0) {
+		t.Errorf("marked for layout")
+	}
+	self.Layout = duit.Clean
+	if !s.Mark(self, kid, false) || !s.stale(0) || self.Draw != duit.DirtyKid {
+		t.Errorf("marked for drawing")
+	}
+	s.last[0] = time.Now()
+	if s.stale(0) {
+		t.Errorf("redrawn")
+	}
+}
+
+// drawn is a fixed UI drawn at a known rectangle.
+type drawn struct {
+	fixed
+	img *draw.Image
+	r   image.Rectangle
+}
+
+func (ui *drawn) Drawn() (*draw.Image, image.Rectangle) {
+	return ui.img, ui.r
+}
+
+func TestDamagedTiles(t *testing.T) {
+	tl := &draw.Image{}
+	kid := &drawn{fixed: fixed{image.Pt(10, 10)}, img: tl, r: image.Rect(0, 5, 10, 15)}
+	s := NewScroll(nil, kid)
+	self := &duit.Kid{UI: s}
+	s.tiles[0] = tl
+	s.last[0] = time.Now()
+	if !s.Mark(self, kid, false) || s.stale(0) || s.damaged != kid.r {
+		t.Errorf("marked: %v %v", s.stale(0), s.damaged)
+	}
+	kid.img = &draw.Image{}
+	if !s.Mark(self, kid, false) || !s.stale(0) {
+		t.Errorf("not drawn on a tile")
+	}
+}
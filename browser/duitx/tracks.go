@@ -0,0 +1,609 @@
+package duitx
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/mjl-/duit"
+)
+
+type TrackKind int
+
+const (
+	TrackAuto TrackKind = iota
+	TrackPx
+	TrackPercent
+	TrackFr
+	TrackMinContent
+	TrackMaxContent
+)
+
+// TrackSize is a track sizing function like 100px, 20%, 1fr or auto.
+type TrackSize struct {
+	Kind  TrackKind
+	Value float64 // lowDPI pixels, percent or fraction
+}
+
+// Track is sized between Min and Max like minmax(Min, Max).
+type Track struct {
+	Min TrackSize
+	Max TrackSize
+}
+
+// NewTrack returns the track of a single sizing function. Flexible
+// sizes have an automatic minimum.
+func NewTrack(s TrackSize) Track {
+	if s.Kind == TrackFr {
+		return Track{Max: s}
+	}
+	return Track{Min: s, Max: s}
+}
+
+// TrackList is the explicit grid of one axis.
+type TrackList struct {
+	Tracks []Track
+
+	// Repeat is inserted at RepeatAt as often as it fits, like
+	// repeat(auto-fill, ...) or with AutoFit repeat(auto-fit, ...).
+	Repeat   []Track
+	RepeatAt int
+	AutoFit  bool
+}
+
+// expand the automatic repetitions for the available space avail
+// (scaled, < 0 if indefinite). The indices of repeated tracks are
+// returned as well.
+func (tl TrackList) expand(avail, gap int, scale func(int) int) (ts []Track, repeated []int) {
+	if len(tl.Repeat) == 0 {
+		return tl.Tracks, nil
+	}
+	fixed := func(t Track) int {
+		for _, s := range []TrackSize{t.Max, t.Min} {
+			switch s.Kind {
+			case TrackPx:
+				return scale(int(s.Value))
+			case TrackPercent:
+				if avail >= 0 {
+					return int(s.Value * float64(avail) / 100)
+				}
+			}
+		}
+		return 0
+	}
+	count := 1
+	if avail >= 0 {
+		used := 0
+		for _, t := range tl.Tracks {
+			used += fixed(t) + gap
+		}
+		step := 0
+		for _, t := range tl.Repeat {
+			step += fixed(t) + gap
+		}
+		if step > 0 {
+			count = maximum(1, (avail-used+gap)/step)
+		}
+	}
+	at := minimum(maximum(tl.RepeatAt, 0), len(tl.Tracks))
+	ts = append(ts, tl.Tracks[:at]...)
+	for i := 0; i < count; i++ {
+		for range tl.Repeat {
+			repeated = append(repeated, len(ts)+len(repeated))
+		}
+	}
+	for i := 0; i < count; i++ {
+		ts = append(ts, tl.Repeat...)
+	}
+	ts = append(ts, tl.Tracks[at:]...)
+	return
+}
+
+// Placement of a grid item in 1-based lines like grid-row and
+// grid-column. 0 means auto and negative lines count from the end of
+// the explicit grid. Spans are used if start or end is auto.
+type Placement struct {
+	RowStart, RowEnd, RowSpan int
+	ColStart, ColEnd, ColSpan int
+}
+
+// resolveLines returns the 0-based start track and span of an axis
+// with n explicit tracks. ok is false if the start is auto.
+func resolveLines(s, e, span, n int) (start, sp int, ok bool) {
+	line := func(l int) int {
+		if l < 0 {
+			return maximum(n+1+l, 0)
+		}
+		return l - 1
+	}
+	sp = maximum(span, 1)
+	switch {
+	case s != 0 && e != 0:
+		start, end := line(s), line(e)
+		if end < start {
+			start, end = end, start
+		}
+		return start, maximum(end-start, 1), true
+	case s != 0:
+		return line(s), sp, true
+	case e != 0:
+		return maximum(line(e)-sp, 0), sp, true
+	}
+	return 0, sp, false
+}
+
+// placeGrid implements the grid item placement algorithm with row
+// auto flow. Areas are in track coordinates, i.e. X are columns.
+func placeGrid(ps []Placement, cols, rows int, dense bool) (areas []image.Rectangle, ncols, nrows int) {
+	areas = make([]image.Rectangle, len(ps))
+	type axes struct {
+		r, rs, c, cs int
+		rok, cok     bool
+	}
+	as := make([]axes, len(ps))
+	ncols = cols
+	for i, p := range ps {
+		a := &as[i]
+		a.r, a.rs, a.rok = resolveLines(p.RowStart, p.RowEnd, p.RowSpan, rows)
+		a.c, a.cs, a.cok = resolveLines(p.ColStart, p.ColEnd, p.ColSpan, cols)
+		if a.cok {
+			ncols = maximum(ncols, a.c+a.cs)
+		} else {
+			ncols = maximum(ncols, a.cs)
+		}
+	}
+	occupied := make(map[image.Point]bool)
+	free := func(r image.Rectangle) bool {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if occupied[image.Pt(x, y)] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	place := func(i int, r image.Rectangle) {
+		areas[i] = r
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				occupied[image.Pt(x, y)] = true
+			}
+		}
+		nrows = maximum(nrows, r.Max.Y)
+	}
+	nrows = rows
+
+	// items locked to a row and column
+	for i, a := range as {
+		if a.rok && a.cok {
+			place(i, image.Rect(a.c, a.r, a.c+a.cs, a.r+a.rs))
+		}
+	}
+	// items locked to a row
+	cursors := make(map[int]int)
+	for i, a := range as {
+		if !a.rok || a.cok {
+			continue
+		}
+		c := cursors[a.r]
+		if dense {
+			c = 0
+		}
+		for ; ; c++ {
+			r := image.Rect(c, a.r, c+a.cs, a.r+a.rs)
+			if free(r) {
+				place(i, r)
+				cursors[a.r] = r.Max.X
+				ncols = maximum(ncols, r.Max.X)
+				break
+			}
+		}
+	}
+	// auto-placed items
+	cur := image.Point{}
+	for i, a := range as {
+		if a.rok {
+			continue
+		}
+		if dense {
+			cur = image.Point{}
+		}
+		if a.cok {
+			if a.c < cur.X {
+				cur.Y++
+			}
+			for y := cur.Y; ; y++ {
+				r := image.Rect(a.c, y, a.c+a.cs, y+a.rs)
+				if free(r) {
+					place(i, r)
+					cur = image.Pt(a.c+a.cs, y)
+					break
+				}
+			}
+			continue
+		}
+		for {
+			if cur.X+a.cs > ncols {
+				cur = image.Pt(0, cur.Y+1)
+			}
+			r := image.Rect(cur.X, cur.Y, cur.X+a.cs, cur.Y+a.rs)
+			if free(r) {
+				place(i, r)
+				cur.X = r.Max.X
+				break
+			}
+			cur.X++
+		}
+	}
+	return
+}
+
+// trackItem is a grid item spanning tracks [start, end) with its min
+// and max content contributions.
+type trackItem struct {
+	start, end int
+	min, max   int
+}
+
+// sizeTracks implements the grid track sizing algorithm for one axis.
+// Tracks have scaled sizes, avail < 0 means indefinite free space.
+func sizeTracks(tracks []Track, items []trackItem, avail, gap int) (sizes []int) {
+	n := len(tracks)
+	sizes = make([]int, n)
+	if n == 0 {
+		return
+	}
+	limit := make([]int, n)
+	pct := func(s TrackSize) TrackSize {
+		if s.Kind == TrackPercent {
+			if avail < 0 {
+				return TrackSize{}
+			}
+			return TrackSize{Kind: TrackPx, Value: s.Value * float64(avail) / 100}
+		}
+		return s
+	}
+	ts := make([]Track, n)
+	for i, t := range tracks {
+		ts[i] = Track{Min: pct(t.Min), Max: pct(t.Max)}
+		if ts[i].Min.Kind == TrackFr {
+			ts[i].Min = TrackSize{}
+		}
+		if ts[i].Min.Kind == TrackPx {
+			sizes[i] = int(ts[i].Min.Value)
+		}
+		limit[i] = -1
+		if ts[i].Max.Kind == TrackPx {
+			limit[i] = maximum(int(ts[i].Max.Value), sizes[i])
+		}
+	}
+	flex := func(i int) bool { return ts[i].Max.Kind == TrackFr }
+	gaps := gap * (n - 1)
+
+	// intrinsic sizes, items with smaller spans first
+	sorted := append([]trackItem(nil), items...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].end-sorted[a].start < sorted[b].end-sorted[b].start
+	})
+	for _, it := range sorted {
+		span := it.end - it.start
+		spansFlex := false
+		for i := it.start; i < it.end; i++ {
+			spansFlex = spansFlex || flex(i)
+		}
+		if span > 1 && spansFlex {
+			continue
+		}
+		// base sizes of tracks with intrinsic minimum
+		var cands []int
+		sum := gap * (span - 1)
+		for i := it.start; i < it.end; i++ {
+			sum += sizes[i]
+			if k := ts[i].Min.Kind; k == TrackAuto || k == TrackMinContent || k == TrackMaxContent {
+				cands = append(cands, i)
+			}
+		}
+		contrib := it.min
+		if len(cands) == 1 && ts[cands[0]].Min.Kind == TrackMaxContent {
+			contrib = it.max
+		}
+		if extra := contrib - sum; extra > 0 && len(cands) > 0 {
+			for j, i := range cands {
+				sizes[i] += extra*(j+1)/len(cands) - extra*j/len(cands)
+			}
+		}
+		// growth limits of tracks with intrinsic maximum
+		cands = cands[:0]
+		sum = gap * (span - 1)
+		for i := it.start; i < it.end; i++ {
+			if limit[i] >= 0 {
+				sum += limit[i]
+			} else {
+				sum += sizes[i]
+			}
+			if k := ts[i].Max.Kind; k == TrackAuto || k == TrackMinContent || k == TrackMaxContent {
+				cands = append(cands, i)
+			}
+		}
+		contrib = it.max
+		if len(cands) == 1 && ts[cands[0]].Max.Kind == TrackMinContent {
+			contrib = it.min
+		}
+		if extra := contrib - sum; extra > 0 && len(cands) > 0 {
+			for j, i := range cands {
+				if limit[i] < 0 {
+					limit[i] = sizes[i]
+				}
+				limit[i] += extra*(j+1)/len(cands) - extra*j/len(cands)
+			}
+		}
+	}
+	for i := range limit {
+		if !flex(i) && limit[i] < sizes[i] {
+			limit[i] = sizes[i]
+		}
+	}
+
+	total := func() (t int) {
+		for _, s := range sizes {
+			t += s
+		}
+		return t + gaps
+	}
+
+	// maximize tracks
+	for {
+		var grow []int
+		for i := range sizes {
+			if !flex(i) && sizes[i] < limit[i] {
+				grow = append(grow, i)
+			}
+		}
+		free := math.MaxInt32
+		if avail >= 0 {
+			free = avail - total()
+		}
+		if len(grow) == 0 || free <= 0 {
+			break
+		}
+		share := maximum(free/len(grow), 1)
+		for _, i := range grow {
+			d := minimum(share, limit[i]-sizes[i])
+			if avail >= 0 {
+				d = minimum(d, free)
+			}
+			sizes[i] += d
+			free -= d
+		}
+	}
+
+	// expand flexible tracks
+	hasFlex := false
+	for i := range ts {
+		hasFlex = hasFlex || flex(i)
+	}
+	if hasFlex {
+		var frSize float64
+		if avail >= 0 {
+			inflexible := make(map[int]bool)
+			for {
+				leftover := avail - gaps
+				var frs float64
+				for i := range ts {
+					if flex(i) && !inflexible[i] {
+						frs += ts[i].Max.Value
+					} else {
+						leftover -= sizes[i]
+					}
+				}
+				frSize = float64(leftover) / math.Max(frs, 1)
+				again := false
+				for i := range ts {
+					if flex(i) && !inflexible[i] && ts[i].Max.Value*frSize < float64(sizes[i]) {
+						inflexible[i] = true
+						again = true
+					}
+				}
+				if !again {
+					break
+				}
+			}
+		} else {
+			for i := range ts {
+				if flex(i) && ts[i].Max.Value > 0 {
+					frSize = math.Max(frSize, float64(sizes[i])/math.Max(ts[i].Max.Value, 1))
+				}
+			}
+			for _, it := range items {
+				if it.end-it.start == 1 && flex(it.start) {
+					frSize = math.Max(frSize, float64(it.max)/math.Max(ts[it.start].Max.Value, 1))
+				}
+			}
+		}
+		for i := range ts {
+			if flex(i) {
+				sizes[i] = maximum(sizes[i], int(ts[i].Max.Value*frSize))
+			}
+		}
+	} else if avail >= 0 {
+		// stretch auto tracks
+		var autos []int
+		for i := range ts {
+			if ts[i].Max.Kind == TrackAuto {
+				autos = append(autos, i)
+			}
+		}
+		if free := avail - total(); free > 0 && len(autos) > 0 {
+			for j, i := range autos {
+				sizes[i] += free*(j+1)/len(autos) - free*j/len(autos)
+			}
+		}
+	}
+	return
+}
+
+// scaleTracks returns tracks with lengths in scaled pixels.
+func scaleTracks(dui *duit.DUI, ts []Track) []Track {
+	sc := make([]Track, len(ts))
+	for i, t := range ts {
+		sc[i] = t
+		if t.Min.Kind == TrackPx {
+			sc[i].Min.Value = float64(dui.Scale(int(t.Min.Value)))
+		}
+		if t.Max.Kind == TrackPx {
+			sc[i].Max.Value = float64(dui.Scale(int(t.Max.Value)))
+		}
+	}
+	return sc
+}
+
+// implicitTracks extends ts to n tracks with the cycled auto tracks.
+func implicitTracks(ts []Track, n int, auto []Track) []Track {
+	if len(auto) == 0 {
+		auto = []Track{{}}
+	}
+	for i := 0; len(ts) < n; i++ {
+		ts = append(ts, auto[i%len(auto)])
+	}
+	return ts
+}
+
+// layoutTracks lays out the kids with placements and track sizing
+// like CSS grid layout.
+func (ui *Grid) layoutTracks(dui *duit.DUI, sizeAvail image.Point) {
+	colGap, rowGap := dui.Scale(ui.ColGap), dui.Scale(ui.RowGap)
+	availX := sizeAvail.X
+	if w := dui.Scale(ui.Width); w > 0 {
+		availX = minimum(w, availX)
+	}
+	cols, repeated := ui.ColTracks.expand(availX, colGap, dui.Scale)
+	rows, _ := ui.RowTracks.expand(-1, rowGap, dui.Scale)
+	places := make([]Placement, len(ui.Kids))
+	copy(places, ui.Places)
+	areas, ncols, nrows := placeGrid(places, len(cols), len(rows), ui.Dense)
+	cols = scaleTracks(dui, implicitTracks(cols, ncols, ui.AutoCols))
+	rows = scaleTracks(dui, implicitTracks(rows, nrows, ui.AutoRows))
+	if ui.ColTracks.AutoFit {
+		used := make(map[int]bool)
+		for _, a := range areas {
+			for x := a.Min.X; x < a.Max.X; x++ {
+				used[x] = true
+			}
+		}
+		for _, i := range repeated {
+			if !used[i] {
+				cols[i] = Track{Min: TrackSize{Kind: TrackPx}, Max: TrackSize{Kind: TrackPx}}
+			}
+		}
+	}
+
+	// cell borders, collapsed borders are shared with the next cell
+	bw := 0
+	if ui.Border > 0 {
+		bw = dui.Scale(ui.Border)
+	}
+	extra := 2 * bw
+	if ui.Collapse {
+		extra = bw
+		availX -= bw
+	}
+
+	measured.begin()
+	defer measured.end()
+
+	// column sizes
+	items := make([]trackItem, len(ui.Kids))
+	for i, k := range ui.Kids {
+		a := areas[i]
+		min := measured.measure(dui, k, image.Pt(1, sizeAvail.Y)).X
+		max := measured.measure(dui, k, image.Pt(availX, sizeAvail.Y)).X
+		items[i] = trackItem{start: a.Min.X, end: a.Max.X, min: min + extra, max: max + extra}
+	}
+	var widths []int
+	if ui.Width == 0 {
+		widths = sizeTracks(cols, items, -1, colGap)
+		if sum(widths)+colGap*(len(widths)-1) > availX {
+			widths = sizeTracks(cols, items, availX, colGap)
+		}
+	} else {
+		widths = sizeTracks(cols, items, availX, colGap)
+	}
+	xs := offsets(widths, colGap)
+
+	// row sizes
+	span := func(os, ss []int, r0, r1 int) int {
+		if r1 <= r0 {
+			return 0
+		}
+		return os[r1-1] + ss[r1-1] - os[r0]
+	}
+	sizes := make([]image.Point, len(ui.Kids))
+	for i, k := range ui.Kids {
+		a := areas[i]
+		w := span(xs, widths, a.Min.X, a.Max.X) - extra
+		avail := image.Pt(maximum(w, 1), sizeAvail.Y)
+		sizes[i] = measured.measure(dui, k, avail)
+		measured.layout(dui, k, avail)
+		items[i] = trackItem{start: a.Min.Y, end: a.Max.Y, min: sizes[i].Y + extra, max: sizes[i].Y + extra}
+	}
+	ui.heights = sizeTracks(rows, items, -1, rowGap)
+	ui.widths = widths
+	ys := offsets(ui.heights, rowGap)
+
+	ui.cells = make([]image.Rectangle, len(ui.Kids))
+	for i, k := range ui.Kids {
+		a := areas[i]
+		cell := image.Rect(xs[a.Min.X], ys[a.Min.Y], xs[a.Min.X], ys[a.Min.Y])
+		cell.Max.X += span(xs, widths, a.Min.X, a.Max.X)
+		cell.Max.Y += span(ys, ui.heights, a.Min.Y, a.Max.Y)
+		ui.cells[i] = cell
+		cell.Min = cell.Min.Add(image.Pt(bw, bw))
+		cell.Max = cell.Max.Sub(image.Pt(extra-bw, extra-bw))
+		halign, valign := duit.HalignLeft, duit.ValignTop
+		if i < len(ui.KidHalign) {
+			halign = ui.KidHalign[i]
+		}
+		if i < len(ui.KidValign) {
+			valign = ui.KidValign[i]
+		}
+		sz := sizes[i]
+		p := cell.Min
+		switch halign {
+		case duit.HalignMiddle:
+			p.X += (cell.Dx() - sz.X) / 2
+		case duit.HalignRight:
+			p.X += cell.Dx() - sz.X
+		}
+		switch valign {
+		case duit.ValignMiddle:
+			p.Y += (cell.Dy() - sz.Y) / 2
+		case duit.ValignBottom:
+			p.Y += cell.Dy() - sz.Y
+		}
+		k.R = rect(sz).Add(p)
+	}
+	ui.size = image.Pt(sum(widths)+colGap*maximum(len(widths)-1, 0), sum(ui.heights)+rowGap*maximum(len(ui.heights)-1, 0))
+	if ui.Collapse {
+		ui.size = ui.size.Add(image.Pt(bw, bw))
+	}
+	if ui.Width < 0 && ui.size.X < sizeAvail.X {
+		ui.size.X = sizeAvail.X
+	}
+}
+
+func sum(xs []int) (s int) {
+	for _, x := range xs {
+		s += x
+	}
+	return
+}
+
+// offsets of tracks with sizes ss separated by gap.
+func offsets(ss []int, gap int) (os []int) {
+	os = make([]int, len(ss))
+	for i := 1; i < len(ss); i++ {
+		os[i] = os[i-1] + ss[i-1] + gap
+	}
+	return
+}
@@ -0,0 +1,184 @@
+package browser
+
+import (
+	"strings"
+
+	"github.com/mjl-/duit"
+	"github.com/psilva261/opossum/browser/duitx"
+	"github.com/psilva261/opossum/nodes"
+	"github.com/psilva261/opossum/style"
+	"golang.org/x/net/html"
+)
+
+func isGrid(n *nodes.Node) bool {
+	d := n.Css("display")
+	return d == "grid" || d == "inline-grid"
+}
+
+// arrangeGrid lays out the elements as the items of the grid
+// container n.
+func arrangeGrid(n *nodes.Node, elements ...*Element) *Element {
+	items := gridItems(elements)
+	if len(items) == 0 {
+		return nil
+	}
+	g := duitGrid(n, items)
+	ui, ok := newBoxElement(n, true, g)
+	if !ok {
+		return nil
+	}
+	el := &Element{
+		n:  n,
+		UI: ui,
+	}
+	markWrapped(ui, n)
+	n.Rectangular = el
+	return el
+}
+
+// gridItems wraps consecutive elements of the same node or of text
+// into anonymous grid items.
+func gridItems(elements []*Element) (items []*Element) {
+	var run []*Element
+	flush := func() {
+		if len(run) == 1 {
+			items = append(items, run[0])
+		} else if len(run) > 1 {
+			if ui := horizontalSeq(nil, true, run); ui != nil {
+				items = append(items, NewElement(ui, run[0].n))
+			}
+		}
+		run = nil
+	}
+	for _, el := range elements {
+		if el == nil {
+			continue
+		}
+		if len(run) > 0 {
+			prev := run[len(run)-1].n
+			if prev != el.n && (prev == nil || el.n == nil || prev.Type() != html.TextNode || el.n.Type() != html.TextNode) {
+				flush()
+			}
+		}
+		run = append(run, el)
+	}
+	flush()
+	return
+}
+
+// duitGrid creates the grid of container n with its items.
+func duitGrid(n *nodes.Node, items []*Element) *duitx.Grid {
+	areas := n.GridAreas()
+	g := &duitx.Grid{
+		Kids:      make([]*duit.Kid, 0, len(items)),
+		Places:    make([]duitx.Placement, 0, len(items)),
+		ColTracks: duitTrackList(n.GridTemplate("grid-template-columns")),
+		RowTracks: duitTrackList(n.GridTemplate("grid-template-rows")),
+		AutoCols:  duitTracks(n.GridAutoTracks("grid-auto-columns")),
+		AutoRows:  duitTracks(n.GridAutoTracks("grid-auto-rows")),
+		Dense:     hasField(n.Css("grid-auto-flow"), "dense"),
+		Width:     -1,
+	}
+	if n.Css("display") == "inline-grid" {
+		g.Width = 0
+	}
+	g.RowGap, g.ColGap = n.Gap()
+	for _, a := range areas {
+		for len(g.ColTracks.Tracks) < a.Max.X && len(g.ColTracks.Repeat) == 0 {
+			g.ColTracks.Tracks = append(g.ColTracks.Tracks, duitx.Track{})
+		}
+		for len(g.RowTracks.Tracks) < a.Max.Y {
+			g.RowTracks.Tracks = append(g.RowTracks.Tracks, duitx.Track{})
+		}
+	}
+	for _, it := range items {
+		var p style.GridPlacement
+		halign, valign := duit.HalignLeft, duit.ValignTop
+		if it.n != nil && it.n.Type() == html.ElementNode {
+			p = it.n.GridPlacement(areas)
+			halign = gridHalign(it.n.Css("justify-self"), n.Css("justify-items"))
+			valign = gridValign(it.n.Css("align-self"), n.Css("align-items"))
+		}
+		g.Kids = append(g.Kids, &duit.Kid{UI: it})
+		g.Places = append(g.Places, duitx.Placement(p))
+		g.KidHalign = append(g.KidHalign, halign)
+		g.KidValign = append(g.KidValign, valign)
+	}
+	return g
+}
+
+func duitTrackList(tl style.TrackList) duitx.TrackList {
+	return duitx.TrackList{
+		Tracks:   duitTracks(tl.Tracks),
+		Repeat:   duitTracks(tl.Repeat),
+		RepeatAt: tl.RepeatAt,
+		AutoFit:  tl.AutoFit,
+	}
+}
+
+func duitTracks(ts []style.Track) (dts []duitx.Track) {
+	for _, t := range ts {
+		dts = append(dts, duitx.Track{
+			Min: duitTrackSize(t.Min),
+			Max: duitTrackSize(t.Max),
+		})
+	}
+	return
+}
+
+func duitTrackSize(s style.TrackSize) duitx.TrackSize {
+	k := duitx.TrackAuto
+	switch s.Unit {
+	case "px":
+		k = duitx.TrackPx
+	case "%":
+		k = duitx.TrackPercent
+	case "fr":
+		k = duitx.TrackFr
+	case "min-content":
+		k = duitx.TrackMinContent
+	case "max-content":
+		k = duitx.TrackMaxContent
+	}
+	return duitx.TrackSize{Kind: k, Value: s.Value}
+}
+
+// gridHalign returns the alignment of justify-self or else
+// justify-items.
+func gridHalign(self, items string) duit.Halign {
+	v := lastField(self)
+	if v == "" || v == "auto" {
+		v = lastField(items)
+	}
+	switch v {
+	case "center":
+		return duit.HalignMiddle
+	case "end", "self-end", "flex-end", "right":
+		return duit.HalignRight
+	}
+	return duit.HalignLeft
+}
+
+// gridValign returns the alignment of align-self or else align-items.
+func gridValign(self, items string) duit.Valign {
+	v := lastField(self)
+	if v == "" || v == "auto" {
+		v = lastField(items)
+	}
+	switch v {
+	case "center":
+		return duit.ValignMiddle
+	case "end", "self-end", "flex-end":
+		return duit.ValignBottom
+	}
+	return duit.ValignTop
+}
+
+func hasField(v, f string) bool {
+	for _, ff := range strings.Fields(v) {
+		if ff == f {
+			return true
+		}
+	}
+	return false
+}
@@ -6,7 +6,8 @@ import (
 )
 
 type History struct {
-	items []Item
+	items   []Item
+	visited map[string]bool
 }
 
 func (h History) URL() *url.URL {
@@ -22,6 +23,21 @@ func (h *History) Push(u *url.URL, oldScroll int) {
 	}
 	it := Item{u, 0}
 	h.items = append(h.items, it)
+	if h.visited == nil {
+		h.visited = make(map[string]bool)
+	}
+	h.visited[visitKey(u)] = true
+}
+
+// Visited reports whether u has been loaded before.
+func (h *History) Visited(u *url.URL) bool {
+	return h.visited[visitKey(u)]
+}
+
+func visitKey(u *url.URL) string {
+	uu := *u
+	uu.Fragment = ""
+	return uu.String()
 }
 
 func (h *History) Back() {
@@ -25,3 +25,17 @@ func TestNoDup(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestVisited(t *testing.T) {
+	h := History{}
+	a, _ := url.Parse("https://example.com/a#top")
+	b, _ := url.Parse("https://example.com/a#bottom")
+	c, _ := url.Parse("https://example.com/c")
+	h.Push(a, 0)
+	if !h.Visited(b) {
+		t.Errorf("fragment should be ignored")
+	}
+	if h.Visited(c) {
+		t.Errorf("%v not visited", c)
+	}
+}
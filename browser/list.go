@@ -0,0 +1,44 @@
+package browser
+
+import (
+	"github.com/psilva261/opossum/browser/duitx"
+	"github.com/psilva261/opossum/nodes"
+)
+
+// newListItem boxes the list item n. Its marker is drawn left of the
+// content unless list-style-position is inside.
+func newListItem(r int, b *Browser, n *nodes.Node) *Element {
+	el := InnerNodesToBox(r, b, n)
+	var marker *nodes.Node
+	for _, c := range n.Children {
+		if isOutsideMarker(c) {
+			marker = c
+		}
+	}
+	if marker == nil {
+		return el
+	}
+	return addMarker(n, el, marker)
+}
+
+// addMarker adds the outside marker to el, the content of the list
+// item n. Without a box to draw it left of, e.g. for absolutely
+// positioned content, it's placed inside in front of the content.
+func addMarker(n *nodes.Node, el *Element, marker *nodes.Node) *Element {
+	l := NewLabel(marker.Text, marker)
+	if el == nil {
+		// nothing but the marker
+		return NewElement(l, n)
+	}
+	if box, ok := el.UI.(*duitx.Box); ok {
+		box.Marker = l
+		return el
+	}
+	return NewElement(horizontalSeq(n, true, []*Element{NewElement(l, marker), el}), n)
+}
+
+// isOutsideMarker returns whether n is a list marker placed outside of
+// the content of its list item.
+func isOutsideMarker(n *nodes.Node) bool {
+	return n.PseudoElement == "marker" && n.ListStylePosition() == "outside"
+}
@@ -0,0 +1,80 @@
+package browser
+
+import (
+	"github.com/mjl-/duit"
+	"github.com/psilva261/opossum/browser/duitx"
+	"github.com/psilva261/opossum/nodes"
+	"github.com/psilva261/opossum/style"
+	"golang.org/x/net/html"
+)
+
+// clips returns whether n clips its overflowing content.
+func clips(n *nodes.Node) bool {
+	if n == nil || n.Type() != html.ElementNode {
+		return false
+	}
+	x, y := n.Overflow()
+	return x != "visible" || y != "visible"
+}
+
+// overflow clips the kids of box to its padding box according to the
+// overflow of n. If they can be scrolled, they are moved into a nested
+// scroll which is the only kid of box then.
+func overflow(n *nodes.Node, box *duitx.Box) {
+	if !clips(n) || n.Data() == "body" || n.Data() == "html" {
+		return
+	}
+	x, y := n.Overflow()
+	box.ClipX = x != "visible"
+	box.ClipY = y != "visible"
+	mh := 0
+	if v := n.Css("max-height"); v != "" && v != "none" {
+		mh, _ = n.CssPx("max-height")
+	}
+	sx := style.Scrolls(x)
+	sy := style.Scrolls(y) && (box.Height > 0 || mh > 0)
+	if !sx && !sy {
+		return
+	}
+	h := 0
+	if sy {
+		// of the content box
+		pb := 0
+		if !box.ContentBox {
+			pb = box.Padding.Dy() + box.Border.Space().Dy()
+		}
+		if box.Height > 0 {
+			h = box.Height - pb
+		}
+		if mh > 0 {
+			mh -= pb
+			if h > mh {
+				h = mh
+			}
+		}
+	}
+	inner := &duitx.Box{
+		Kids:       box.Kids,
+		Background: box.Background,
+		Dir:        box.Dir,
+		Disp:       duitx.Block,
+		Flex:       box.Flex,
+		BFC:        true,
+		TextAlign:  box.TextAlign,
+		NoWrap:     box.NoWrap,
+	}
+	switch box.Disp {
+	case duitx.Flex:
+		inner.Disp = duitx.Flex
+		box.Disp = duitx.Block
+	case duitx.InlineFlex:
+		inner.Disp = duitx.Flex
+		box.Disp = duitx.InlineBlock
+	}
+	box.Flex = duitx.FlexContainer{}
+	s := duitx.NewNestedScroll(inner, h, sx)
+	if sy {
+		s.MaxHeight = mh
+	}
+	box.Kids = duit.NewKids(s)
+}
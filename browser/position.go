@@ -0,0 +1,171 @@
+package browser
+
+import (
+	"image"
+	"sort"
+	"strconv"
+
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/opossum/browser/duitx"
+	"github.com/psilva261/opossum/nodes"
+)
+
+func (el *Element) Unwrap() duit.UI {
+	if el == nil {
+		return nil
+	}
+	return el.UI
+}
+
+func (el *Element) Pin() (p duitx.Pin, ok bool) {
+	if el == nil || el.n == nil || !el.outermost() {
+		return
+	}
+	switch el.n.Css("position") {
+	case "fixed":
+		p.Fixed = true
+	case "sticky":
+	default:
+		return
+	}
+	p.Top = pinInset(el.n, "top")
+	p.Bottom = pinInset(el.n, "bottom")
+	return p, true
+}
+
+func (el *Element) DrawPinned(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse) {
+	el.draw(dui, self, img, orig, m, true)
+}
+
+// ZIndex of el, ok is true if it establishes a stacking context. Only
+// the outermost element of a node does.
+func (el *Element) ZIndex() (z int, ok bool) {
+	if el == nil || el.n == nil || !el.outermost() {
+		return 0, false
+	}
+	return zIndex(el.n)
+}
+
+func (el *Element) DrawStacked(dui *duit.DUI, self *duit.Kid, img *draw.Image, orig image.Point, m draw.Mouse) {
+	el.draw(dui, self, img, orig, m, true)
+}
+
+func (el *Element) MouseStacked(dui *duit.DUI, self *duit.Kid, m draw.Mouse, origM draw.Mouse, orig image.Point) duit.Result {
+	return el.mouse(dui, self, m, origM, orig)
+}
+
+// outermost returns whether el is the outermost of the elements that
+// wrap each other for its node.
+func (el *Element) outermost() bool {
+	return !el.wrapped
+}
+
+// markWrapped marks the elements of n within ui, i.e. those wrapped by
+// an outer element of n. Elements of other nodes are not descended
+// into.
+func markWrapped(ui duit.UI, n *nodes.Node) {
+	var kids []*duit.Kid
+	switch v := ui.(type) {
+	case *Element:
+		if v == nil || v.n != n {
+			return
+		}
+		v.wrapped = true
+		markWrapped(v.UI, n)
+		return
+	case *duitx.Box:
+		kids = v.Kids
+	case *duitx.Grid:
+		kids = v.Kids
+	case *duit.Place:
+		kids = v.Kids
+	}
+	for _, k := range kids {
+		markWrapped(k.UI, n)
+	}
+}
+
+func isFixed(n *nodes.Node) bool {
+	return n != nil && n.Css("position") == "fixed"
+}
+
+// zIndex returns the z-index of n, ok is true if n establishes a
+// stacking context, i.e. is positioned with a z-index other than auto
+// or is fixed or sticky.
+func zIndex(n *nodes.Node) (z int, ok bool) {
+	switch n.Css("position") {
+	case "", "static":
+		return 0, false
+	case "fixed", "sticky":
+		ok = true
+	}
+	z, err := strconv.Atoi(n.Css("z-index"))
+	return z, ok || err == nil
+}
+
+// inset returns top, right, bottom or left in px, ok is false if the
+// value is auto.
+func inset(n *nodes.Node, k string) (l int, ok bool) {
+	if v := n.Css(k); v == "" || v == "auto" {
+		return 0, false
+	}
+	l, err := n.CssPx(k)
+	if err != nil {
+		return 0, false
+	}
+	return l, true
+}
+
+// pinInset returns the inset for a Pin, i.e. -1 if it is auto.
+func pinInset(n *nodes.Node, k string) int {
+	if l, ok := inset(n, k); ok && l >= 0 {
+		return l
+	}
+	return -1
+}
+
+// relOffset returns the scaled offset of relatively positioned
+// elements.
+func (el *Element) relOffset(dui *duit.DUI) (o image.Point) {
+	if el.n == nil || el.n.Css("position") != "relative" || dui == nil {
+		return
+	}
+	if t, ok := inset(el.n, "top"); ok {
+		o.Y = t
+	} else if b, ok := inset(el.n, "bottom"); ok {
+		o.Y = -b
+	}
+	if l, ok := inset(el.n, "left"); ok {
+		o.X = l
+	} else if r, ok := inset(el.n, "right"); ok {
+		o.X = -r
+	}
+	return image.Pt(dui.Scale(o.X), dui.Scale(o.Y))
+}
+
+// stackPositioned sorts absolutely positioned elements by z-index.
+// Elements with negative z-index are painted below the flow content.
+func stackPositioned(flow *Element, positioned []*Element) (uis []duit.UI) {
+	z := func(el *Element) int {
+		z, _ := zIndex(el.n)
+		return z
+	}
+	sort.SliceStable(positioned, func(i, j int) bool {
+		return z(positioned[i]) < z(positioned[j])
+	})
+	flowAt := 0
+	for flowAt < len(positioned) && z(positioned[flowAt]) < 0 {
+		flowAt++
+	}
+	for i, p := range positioned {
+		if i == flowAt && flow != nil {
+			uis = append(uis, flow)
+		}
+		uis = append(uis, p)
+	}
+	if flowAt == len(positioned) && flow != nil {
+		uis = append(uis, flow)
+	}
+	return
+}
@@ -0,0 +1,310 @@
+package browser
+
+import (
+	"9fans.net/go/draw"
+	"github.com/mjl-/duit"
+	"github.com/psilva261/opossum/browser/duitx"
+	"github.com/psilva261/opossum/logger"
+	"github.com/psilva261/opossum/nodes"
+	"github.com/psilva261/opossum/style"
+	"golang.org/x/net/html"
+)
+
+// hovered is the innermost Element that received the current mouse
+// event. Element.Mouse is called from the outside in.
+var hovered *Element
+
+// interact updates hover, active and focus state after a mouse event
+// and restyles the elements affected by that.
+func (w *Website) interact(el *Element, m draw.Mouse) {
+	w.setCursor(el)
+
+	var n *html.Node
+	if el != nil && el.n != nil && el.n.DomSubtree != nil {
+		n = el.n.DomSubtree
+		if n.Type == html.TextNode {
+			n = n.Parent
+		}
+	}
+	pressed := m.Buttons&1 == 1
+	if n == w.hovered && pressed == (w.active != nil) {
+		return
+	}
+
+	// elements whose state changes
+	var cs []*html.Node
+	if n != w.hovered {
+		cs = append(cs, chainDiff(w.hovered, n)...)
+	}
+	if pressed && w.active == nil && n != nil {
+		cs = append(cs, chainDiff(nil, n)...)
+		cs = append(cs, chainDiff(w.focused, focusable(n))...)
+	} else if !pressed && w.active != nil {
+		cs = append(cs, chainDiff(w.active, nil)...)
+	}
+	ns := w.dynamic.Changes(cs, func() {
+		if n != w.hovered {
+			setStates(w.hovered, style.Hover, false)
+			setStates(n, style.Hover, true)
+			w.hovered = n
+		}
+		if pressed && w.active == nil && n != nil {
+			setStates(n, style.Active, true)
+			w.active = n
+			w.focus(focusable(n))
+		} else if !pressed && w.active != nil {
+			setStates(w.active, style.Active, false)
+			w.active = nil
+		}
+	})
+	w.restyle(ns)
+}
+
+func (w *Website) focus(n *html.Node) {
+	if n == w.focused {
+		return
+	}
+	if w.focused != nil {
+		style.SetState(w.focused, style.Focus, false)
+		setStates(w.focused, style.FocusWithin, false)
+	}
+	if n != nil {
+		style.SetState(n, style.Focus, true)
+		setStates(n, style.FocusWithin, true)
+	}
+	w.focused = n
+}
+
+// setStates of n and its ancestors.
+func setStates(n *html.Node, s style.State, on bool) {
+	for ; n != nil; n = n.Parent {
+		if n.Type == html.ElementNode {
+			style.SetState(n, s, on)
+		}
+	}
+}
+
+// chainDiff returns the elements that are a or its ancestors, or b or
+// its ancestors, but not both.
+func chainDiff(a, b *html.Node) (ns []*html.Node) {
+	inA := make(map[*html.Node]bool)
+	for n := a; n != nil; n = n.Parent {
+		inA[n] = true
+	}
+	inB := make(map[*html.Node]bool)
+	for n := b; n != nil; n = n.Parent {
+		inB[n] = true
+		if !inA[n] && n.Type == html.ElementNode {
+			ns = append(ns, n)
+		}
+	}
+	for n := a; n != nil; n = n.Parent {
+		if !inB[n] && n.Type == html.ElementNode {
+			ns = append(ns, n)
+		}
+	}
+	return
+}
+
+// focusable returns n or its closest ancestor that can be focused.
+func focusable(n *html.Node) *html.Node {
+	for ; n != nil; n = n.Parent {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		switch n.Data {
+		case "input", "textarea", "select", "button":
+			if !hasAttr(*n, "disabled") {
+				return n
+			}
+		case "a":
+			if hasAttr(*n, "href") {
+				return n
+			}
+		}
+		if hasAttr(*n, "tabindex") {
+			return n
+		}
+	}
+	return nil
+}
+
+func (w *Website) setCursor(el *Element) {
+	pointer := false
+	if el != nil && el.n != nil {
+		switch el.n.Css("cursor") {
+		case "pointer":
+			pointer = true
+		case "", "auto":
+			pointer = el.IsLink
+		}
+	}
+	if pointer == w.pointer || dui == nil || dui.Display == nil {
+		return
+	}
+	w.pointer = pointer
+	if pointer {
+		dui.Display.SwitchCursor(&draw.Cursor{
+			Black: cursor,
+		})
+	} else {
+		dui.Display.SwitchCursor(nil)
+	}
+}
+
+// check sets the checked attribute of a checkbox or radio button.
+func (w *Website) check(n *html.Node, on bool) {
+	cs := []*html.Node{n}
+	if attr(*n, "type") == "radio" {
+		cs = radioGroup(n)
+	}
+	ns := w.dynamic.Changes(cs, func() {
+		if on && attr(*n, "type") == "radio" {
+			for _, o := range radioGroup(n) {
+				delAttr(o, "checked")
+			}
+		}
+		if on {
+			setAttr(n, "checked", "")
+		} else {
+			delAttr(n, "checked")
+		}
+	})
+	if attr(*n, "type") == "radio" && w.UI != nil {
+		TraverseTree(w.UI, func(ui duit.UI) {
+			el, ok := ui.(*Element)
+			if !ok || el == nil {
+				return
+			}
+			if rb, ok := el.UI.(*duit.Radiobutton); ok {
+				if sel := hasAttr(*el.n.DomSubtree, "checked"); sel != rb.Selected {
+					rb.Selected = sel
+					dui.MarkDraw(rb)
+				}
+			}
+		})
+	}
+	w.restyle(ns)
+}
+
+// radioGroup returns the radio buttons with the same name in the same
+// form as n.
+func radioGroup(n *html.Node) (g []*html.Node) {
+	name := attr(*n, "name")
+	if name == "" {
+		return []*html.Node{n}
+	}
+	root := n
+	for root.Parent != nil && root.Data != "form" {
+		root = root.Parent
+	}
+	var find func(c *html.Node)
+	find = func(c *html.Node) {
+		if c.Type == html.ElementNode && c.Data == "input" && attr(*c, "type") == "radio" && attr(*c, "name") == name {
+			g = append(g, c)
+		}
+		for cc := c.FirstChild; cc != nil; cc = cc.NextSibling {
+			find(cc)
+		}
+	}
+	find(root)
+	return
+}
+
+// restyle re-computes the styles of the block-level subtrees containing
+// the changed nodes and replaces their UI.
+func (w *Website) restyle(changed []*html.Node) {
+	if len(changed) == 0 || w.nt == nil || w.UI == nil {
+		return
+	}
+	inTree := make(map[*Element]bool)
+	TraverseTree(w.UI, func(ui duit.UI) {
+		if el, ok := ui.(*Element); ok && el != nil {
+			inTree[el] = true
+		}
+	})
+	byDom := make(map[*html.Node]*nodes.Node)
+	w.nt.Traverse(func(r int, n *nodes.Node) {
+		if _, ok := byDom[n.DomSubtree]; !ok {
+			byDom[n.DomSubtree] = n
+		}
+	})
+
+	var ns []*nodes.Node
+	els := make(map[*nodes.Node]*Element)
+	for _, dn := range changed {
+		var n *nodes.Node
+		for ; dn != nil && n == nil; dn = dn.Parent {
+			n = byDom[dn]
+		}
+		if n == nil {
+			n = w.nt
+		}
+		for ; n != nil; n, _ = parentNode(n) {
+			e, ok := n.Rectangular.(*Element)
+			if ok && inTree[e] && (!n.IsInline() || n == w.nt) {
+				if _, ok := els[n]; !ok {
+					ns = append(ns, n)
+					els[n] = e
+				}
+				break
+			}
+			if n == w.nt {
+				break
+			}
+		}
+	}
+	for _, n := range ns {
+		nested := false
+		for p, ok := parentNode(n); ok && p != nil && !nested; p, ok = parentNode(p) {
+			_, nested = els[p]
+		}
+		if !nested {
+			w.restyleNode(n, els[n])
+		}
+	}
+	if len(ns) == 0 {
+		log.Printf("restyle: no element found")
+	}
+}
+
+// restyleNode re-computes the styles of the subtree of n and replaces
+// the UI of its element el.
+func (w *Website) restyleNode(n *nodes.Node, el *Element) {
+	dn := n.DomSubtree
+	nodeMap := make(map[*html.Node]style.Map)
+	if w.rules != nil {
+		nodeMap = w.rules.NodeMap(dn)
+	}
+	nn := n.Restyle(nodeMap)
+	if n == w.nt {
+		w.nt = nn
+	}
+	nel := NodeToBox(0, browser, nn)
+	if nel == nil {
+		nel = &Element{
+			UI: &duitx.Box{},
+			n:  nn,
+		}
+	}
+	for a := dn.Parent; a != nil; a = a.Parent {
+		if a.Type == html.ElementNode && a.Data == "a" && hasAttr(*a, "href") {
+			nel.makeLink(attr(*a, "href"))
+			break
+		}
+	}
+	freeImages(el)
+	*el = *nel
+	nn.Rectangular = el
+	dui.MarkLayout(el)
+	dui.MarkDraw(el)
+}
+
+func parentNode(n *nodes.Node) (p *nodes.Node, ok bool) {
+	dt, ok := n.Parent()
+	if !ok {
+		return nil, false
+	}
+	p, ok = dt.(*nodes.Node)
+	return
+}
@@ -0,0 +1,72 @@
+package browser
+
+import (
+	"github.com/psilva261/opossum/browser/duitx"
+	"github.com/psilva261/opossum/nodes"
+	"golang.org/x/net/html"
+)
+
+// styleLabel applies text-decoration, letter-spacing, word-spacing,
+// line-height and text-overflow of n to l.
+func styleLabel(l *duitx.Label, n *nodes.Node) {
+	if n == nil {
+		return
+	}
+	u, o, lt := n.TextDecoration()
+	if u {
+		l.Decoration |= duitx.Underline
+	}
+	if o {
+		l.Decoration |= duitx.Overline
+	}
+	if lt {
+		l.Decoration |= duitx.LineThrough
+	}
+	l.LetterSpacing = n.LetterSpacing()
+	l.WordSpacing = n.WordSpacing()
+	if f, px, ok := n.LineHeight(); ok {
+		if px > 0 {
+			l.LineHeight = px
+		} else if dui != nil {
+			l.LineHeight = int(f * n.FontHeight())
+		}
+	}
+	l.Ellipsis = textOverflow(n)
+}
+
+// textOverflow returns whether text of n is truncated with an ellipsis
+// by its block container.
+func textOverflow(n *nodes.Node) bool {
+	for n != nil && (n.Type() != html.ElementNode || n.IsInline()) {
+		p, ok := n.Parent()
+		if !ok {
+			return false
+		}
+		n, _ = p.(*nodes.Node)
+	}
+	return n != nil && n.TextOverflow()
+}
+
+func duitTextAlign(n *nodes.Node) duitx.TextAlign {
+	if n == nil {
+		return duitx.TextLeft
+	}
+	switch n.Css("text-align") {
+	case "center", "-webkit-center", "-moz-center":
+		return duitx.TextCenter
+	case "right", "end", "-webkit-right":
+		return duitx.TextRight
+	case "justify":
+		return duitx.TextJustify
+	}
+	return duitx.TextLeft
+}
+
+// noWrap returns whether the inline content of n stays on one line.
+func noWrap(n *nodes.Node) bool {
+	if n == nil {
+		return false
+	}
+	_, _, wrap := n.WhiteSpace()
+	return !wrap
+}
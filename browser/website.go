@@ -13,75 +13,94 @@ import (
 	"golang.org/x/text/encoding"
 	"net/url"
 	"strings"
-)
-
-const (
-	InitialLayout = iota
-	ClickRelayout
+	"sync/atomic"
+	"time"
 )
 
 type Website struct {
 	duit.UI
 	opossum.ContentType
+
+	doc     *html.Node
+	nt      *nodes.Node
+	csss    []string
+	scripts []string
+	rules   *style.RuleIndex
+	dynamic style.Dynamic
+
+	hovered *html.Node
+	active  *html.Node
+	focused *html.Node
+	pointer bool
 }
 
-func (w *Website) layout(f opossum.Fetcher, htm string, layouting int) {
+// paintInterval is the minimum time between two paints while
+// stylesheets arrive.
+const paintInterval = 500 * time.Millisecond
+
+// layout parses htm once and paints it with user agent styles right
+// away. It is painted again as stylesheets arrive and finally after
+// its scripts have run.
+func (w *Website) layout(f opossum.Fetcher, htm string) {
 	defer func() {
 		browser.StatusCh <- ""
 	}()
-	pass := func(htm string, csss ...string) (*html.Node, map[*html.Node]style.Map) {
-		if f.Ctx().Err() != nil {
-			return nil, nil
-		}
-
-		if debugPrintHtml {
-			log.Printf("%v\n", htm)
-		}
-
-		var doc *html.Node
-		var err error
-		doc, err = html.ParseWithOptions(
-			strings.NewReader(htm),
-			html.ParseOptionEnableScripting(ExperimentalJsInsecure),
-		)
-		if err != nil {
-			panic(err.Error())
-		}
-
-		log.Printf("Retrieving CSS Rules...")
-		var cssSize int
-		nodeMap := make(map[*html.Node]style.Map)
-		for i, css := range csss {
+	if f.Ctx().Err() != nil {
+		return
+	}
+	if debugPrintHtml {
+		log.Printf("%v\n", htm)
+	}
+	doc, err := parseHtml(htm)
+	if err != nil {
+		panic(err.Error())
+	}
+	style.ResetStates()
+	style.ResetFontFaces()
+	markVisited(f, doc)
 
-			log.Printf("CSS size %v kB", cssSize/1024)
+	rules := style.NewRuleIndex()
+	var ms style.Matches
+	added := 0
+	addRules := func(csss []string) {
+		for ; added < len(csss); added++ {
+			css := csss[added]
+			log.Printf("CSS size %v kB", len(css)/1024)
 
-			nm, err := style.FetchNodeMap(doc, css)
-			if err == nil {
-				if debugPrintHtml {
-					log.Printf("%v", nm)
-				}
-				style.MergeNodeMaps(nodeMap, nm)
-			} else {
-				log.Errorf("%v/css/%v.css: Fetch CSS Rules failed: %v", opossum.PathPrefix, i, err)
+			if err := rules.Add(css); err != nil {
+				log.Errorf("%v/css/%v.css: Fetch CSS Rules failed: %v", opossum.PathPrefix, added, err)
 			}
 		}
-
-		return doc, nodeMap
 	}
+	log.Printf("First paint...")
+	addRules([]string{style.AddOnCSS})
+	w.paint(f, doc, rules, ms.Update(rules, doc), true, false)
+	painted := time.Now()
+	paintedRules := added
 
-	log.Printf("1st pass")
-	doc, _ := pass(htm)
-
-	log.Printf("2nd pass")
 	log.Printf("Download style...")
-	csss := cssSrcs(f, doc)
-	doc, nodeMap := pass(htm, csss...)
+	csss := cssSrcs(f, doc, func(csss []string) {
+		if time.Since(painted) < paintInterval || f.Ctx().Err() != nil {
+			return
+		}
+		addRules(csss)
+		w.paint(f, doc, rules, ms.Update(rules, doc), false, false)
+		painted = time.Now()
+		paintedRules = added
+	})
+	if f.Ctx().Err() != nil {
+		return
+	}
+	log.Printf("Retrieving CSS Rules...")
+	addRules(csss)
 
-	// 3rd pass is only needed initially to load the scripts and set the js VM
-	// state. During subsequent calls from click handlers that state is kept.
 	var scripts []string
-	if ExperimentalJsInsecure && layouting != ClickRelayout {
-		log.Printf("3rd pass")
+	if ExperimentalJsInsecure {
+		nodeMap := ms.Update(rules, doc)
+		if added > paintedRules {
+			// scripts might take a while, show all stylesheets meanwhile
+			w.paint(f, doc, rules, nodeMap, false, false)
+		}
 		nt := nodes.NewNodeTree(doc, style.Map{}, nodeMap, nil)
 		jsSrcs := js.Srcs(nt)
 		downloads := make(map[string]string)
@@ -111,7 +130,19 @@ func (w *Website) layout(f opossum.Fetcher, htm string, layouting int) {
 			if debugPrintHtml {
 				log.Printf("%v\n", jsProcessed)
 			}
-			doc, nodeMap = pass(htm, csss...)
+			if jsDoc, err := parseHtml(htm); err == nil {
+				// the painted website still references doc
+				done := make(chan struct{})
+				dui.Call <- func() {
+					patch(doc, jsDoc)
+					markVisited(f, doc)
+					close(done)
+				}
+				<-done
+				ms = style.Matches{}
+			} else {
+				log.Errorf("parse js result: %v", err)
+			}
 		} else if err != nil {
 			log.Errorf("JS error: %v", err)
 		}
@@ -130,6 +161,22 @@ func (w *Website) layout(f opossum.Fetcher, htm string, layouting int) {
 	}
 	log.Printf("%v html nodes found...", countHtmlNodes(doc))
 
+	nt := w.paint(f, doc, rules, ms.Update(rules, doc), false, true)
+	if nt == nil {
+		return
+	}
+	w.csss = csss
+	w.scripts = scripts
+
+	fs.Update(f.Origin().String(), htm, csss, scripts)
+	fs.SetDOM(nt)
+}
+
+// paint lays out the body of doc styled with nodeMap and shows it. The
+// scroll position is restored from history on the first paint and kept
+// on later ones. Only after the final paint the website is restyled on
+// interaction.
+func (w *Website) paint(f opossum.Fetcher, doc *html.Node, rules *style.RuleIndex, nodeMap map[*html.Node]style.Map, first, final bool) (nt *nodes.Node) {
 	body := grep(doc, "body")
 	if body == nil {
 		// TODO: handle frameset without noframes
@@ -138,30 +185,132 @@ func (w *Website) layout(f opossum.Fetcher, htm string, layouting int) {
 	}
 
 	log.Printf("Layout website...")
-	nt := nodes.NewNodeTree(body, style.Map{}, nodeMap, &nodes.Node{})
-	if scroller != nil {
-		scroller.Free()
-		scroller = nil
+	if debugPrintHtml {
+		log.Printf("%v", nodeMap)
 	}
-	scroller = duitx.NewScroll(dui, NodeToBox(0, browser, nt))
+	nt = nodes.NewNodeTree(body, style.Map{}, nodeMap, &nodes.Node{})
+	s := duitx.NewScroll(dui, NodeToBox(0, browser, nt))
 	numElements := 0
-	TraverseTree(scroller, func(ui duit.UI) {
+	TraverseTree(s, func(ui duit.UI) {
 		numElements++
 	})
-	w.UI = scroller
 	log.Printf("Layouting done (%v elements created)", numElements)
 	if numElements < 10 {
 		log.Errorf("Less than 10 elements layouted, seems css processing failed. Will layout without css")
 		nt = nodes.NewNodeTree(body, style.Map{}, make(map[*html.Node]style.Map), nil)
-		scroller = duitx.NewScroll(dui, NodeToBox(0, browser, nt))
-		w.UI = scroller
+		s = duitx.NewScroll(dui, NodeToBox(0, browser, nt))
 	}
 
-	fs.Update(f.Origin().String(), htm, csss, scripts)
-	fs.SetDOM(nt)
+	dui.Call <- func() {
+		if f.Ctx().Err() != nil {
+			s.Free()
+			freeImages(s)
+			return
+		}
+		if first {
+			w.hovered = nil
+			w.active = nil
+			w.focused = nil
+			s.Offset = browser.History.Scroll()
+		}
+		if scroller != nil {
+			if !first {
+				s.Offset = scroller.Offset
+			}
+			scroller.Free()
+			freeImages(scroller)
+		}
+		scroller = s
+		w.UI = s
+		if final {
+			w.doc = doc
+			w.nt = nt
+			w.rules = rules
+			w.dynamic = rules.Dynamic()
+		} else {
+			w.doc = nil
+			w.nt = nil
+			w.rules = nil
+			w.dynamic = style.Dynamic{}
+		}
+		dui.MarkLayout(dui.Top.UI)
+		dui.MarkDraw(dui.Top.UI)
+		dui.Render()
+	}
+	return
 }
 
-func cssSrcs(f opossum.Fetcher, doc *html.Node) (srcs []string) {
+// fontsLoaded is set while a restyle for web fonts loaded in the
+// background is queued.
+var fontsLoaded int32
+
+// webFontLoaded restyles the page once web fonts were loaded, fallback
+// fonts were used until then. Fonts loaded before the restyle runs are
+// picked up at once.
+func webFontLoaded() {
+	if !atomic.CompareAndSwapInt32(&fontsLoaded, 0, 1) {
+		return
+	}
+	dui.Call <- func() {
+		atomic.StoreInt32(&fontsLoaded, 0)
+		if w := browser.Website; w.nt != nil {
+			w.restyle([]*html.Node{w.nt.DomSubtree})
+		}
+	}
+}
+
+// update the DOM to htm, e.g. after a script changed it, and restyle
+// only the changed subtrees. Stylesheets are not loaded again.
+func (w *Website) update(htm string) {
+	if w.doc == nil || w.nt == nil {
+		return
+	}
+	if debugPrintHtml {
+		log.Printf("%v\n", htm)
+	}
+	doc, err := parseHtml(htm)
+	if err != nil {
+		log.Errorf("update: %v", err)
+		return
+	}
+	changed := patch(w.doc, doc)
+	changed = within(w.nt.DomSubtree, append(changed, w.dynamic.Siblings(changed)...))
+	log.Printf("update: %v nodes changed", len(changed))
+	w.restyle(changed)
+
+	fs.Update(browser.Origin().String(), htm, w.csss, w.scripts)
+	fs.SetDOM(w.nt)
+}
+
+func parseHtml(htm string) (*html.Node, error) {
+	return html.ParseWithOptions(
+		strings.NewReader(htm),
+		html.ParseOptionEnableScripting(ExperimentalJsInsecure),
+	)
+}
+
+// markVisited sets the visited state of links found in the history.
+func markVisited(f opossum.Fetcher, doc *html.Node) {
+	if browser == nil {
+		return
+	}
+	var mark func(n *html.Node)
+	mark = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" && hasAttr(*n, "href") {
+			if u, err := f.LinkedUrl(attr(*n, "href")); err == nil && browser.Visited(u) {
+				style.SetState(n, style.Visited, true)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			mark(c)
+		}
+	}
+	mark(doc)
+}
+
+// cssSrcs returns the user agent stylesheet and those of doc in
+// document order. Loaded is called after every downloaded stylesheet.
+func cssSrcs(f opossum.Fetcher, doc *html.Node, loaded func(srcs []string)) (srcs []string) {
 	srcs = make([]string, 0, 20)
 	srcs = append(srcs, style.AddOnCSS)
 	ntAll := nodes.NewNodeTree(doc, style.Map{}, make(map[*html.Node]style.Map), nil)
@@ -196,6 +345,7 @@ func cssSrcs(f opossum.Fetcher, doc *html.Node) (srcs []string) {
 				}
 				if contentType.IsCSS() {
 					srcs = append(srcs, string(buf))
+					loaded(srcs)
 				} else {
 					log.Printf("css: unexpected %v", contentType)
 				}
@@ -211,7 +361,18 @@ func formData(n, submitBtn *html.Node) (data url.Values) {
 
 	switch n.Data {
 	case "input", "select":
-		if attr(*n, "type") == "submit" && n != submitBtn {
+		t := attr(*n, "type")
+		if t == "submit" && n != submitBtn {
+			return
+		}
+		if t == "checkbox" || t == "radio" {
+			if nm != "" && hasAttr(*n, "checked") {
+				v := attr(*n, "value")
+				if v == "" {
+					v = "on"
+				}
+				data.Add(nm, v)
+			}
 			return
 		}
 		if nm != "" {
@@ -283,7 +444,7 @@ func (b *Browser) submit(form *html.Node, submitBtn *html.Node) {
 			q.Set(k, vs[0]) // TODO: what is with the rest?
 		}
 		uri.RawQuery = escapeValues(b.Website.ContentType, q).Encode()
-		buf, contentType, err = b.get(uri, true)
+		buf, contentType, err = b.get(uri, true, "")
 	} else {
 		buf, contentType, err = b.PostForm(uri, formData(form, submitBtn))
 	}
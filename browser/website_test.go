@@ -69,3 +69,38 @@ func TestPercentEncoding(t *testing.T) {
 		t.Errorf("%v", res)
 	}
 }
+
+type cssFetcher struct {
+	TestFetcher
+}
+
+func (cf *cssFetcher) LinkedUrl(href string) (*url.URL, error) {
+	return url.Parse("https://example.com/" + href)
+}
+
+func (cf *cssFetcher) Get(u *url.URL) ([]byte, opossum.ContentType, error) {
+	ct, err := opossum.NewContentType("text/css", u)
+	return []byte("/* " + u.Path + " */"), ct, err
+}
+
+func TestCssSrcsLoaded(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`
+		<link rel="stylesheet" href="a.css">
+		<style>p { color: red; }</style>
+		<link rel="stylesheet" href="b.css">
+		<link rel="icon" href="c.ico">
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded []int
+	srcs := cssSrcs(&cssFetcher{}, doc, func(srcs []string) {
+		loaded = append(loaded, len(srcs))
+	})
+	if len(srcs) != 4 || srcs[3] != "/* /b.css */" {
+		t.Fatalf("%+v", srcs)
+	}
+	if len(loaded) != 2 || loaded[0] != 2 || loaded[1] != 4 {
+		t.Errorf("%+v", loaded)
+	}
+}
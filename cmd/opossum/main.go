@@ -259,7 +259,7 @@ func Main() (err error) {
 }
 
 func usage() {
-	fmt.Printf("usage: opossum [-v|-vv] [-h] [-jsinsecure] [-cpu|-mem fn] [startPage]\n")
+	fmt.Printf("usage: opossum [-v|-vv] [-h] [-jsinsecure] [-noanim] [-family generic=font,...] [-cpu|-mem fn] [startPage]\n")
 	os.Exit(1)
 }
 
@@ -281,6 +281,17 @@ func main() {
 		case "-jsinsecure":
 			browser.ExperimentalJsInsecure = true
 			args = args[1:]
+		case "-noanim":
+			browser.DisableAnimations = true
+			args = args[1:]
+		case "-family":
+			if len(args) < 2 {
+				usage()
+			}
+			if err := style.SetGenericFamily(args[1]); err != nil {
+				log.Fatalf("%v", err)
+			}
+			args = args[2:]
 		case "-cpu":
 			cpuprofile, args = args[1], args[2:]
 		case "-mem":
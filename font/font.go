@@ -0,0 +1,103 @@
+// Package font loads TrueType, OpenType, WOFF and WOFF2 fonts and
+// rasterizes them into Plan 9 fonts which can be opened with draw.
+package font
+
+import (
+	"fmt"
+	"golang.org/x/image/font/sfnt"
+	"strconv"
+	"strings"
+)
+
+// Range of runes from Lo to Hi inclusive.
+type Range struct {
+	Lo, Hi rune
+}
+
+// ParseUnicodeRange parses the value of a CSS unicode-range descriptor
+// like "U+0025-00FF, U+4??".
+func ParseUnicodeRange(v string) (rs []Range, err error) {
+	for _, s := range strings.Split(v, ",") {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if !strings.HasPrefix(s, "U+") {
+			return nil, fmt.Errorf("invalid unicode range %q", s)
+		}
+		s = s[2:]
+		var lo, hi string
+		if i := strings.Index(s, "-"); i >= 0 {
+			lo, hi = s[:i], s[i+1:]
+		} else if strings.HasSuffix(s, "?") {
+			lo = strings.ReplaceAll(s, "?", "0")
+			hi = strings.ReplaceAll(s, "?", "F")
+		} else {
+			lo, hi = s, s
+		}
+		l, err := strconv.ParseUint(lo, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unicode range %q: %w", s, err)
+		}
+		h, err := strconv.ParseUint(hi, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unicode range %q: %w", s, err)
+		}
+		if h > MaxRune {
+			h = MaxRune
+		}
+		if l > h {
+			continue
+		}
+		rs = append(rs, Range{Lo: rune(l), Hi: rune(h)})
+	}
+	return
+}
+
+// MaxRune is the highest rune considered for rasterization.
+const MaxRune = 0x10ffff
+
+// Face is a font limited to runes of Ranges. A Face without Ranges
+// covers all runes of the font.
+type Face struct {
+	Font   *sfnt.Font
+	Ranges []Range
+}
+
+// Parse a TrueType, OpenType, WOFF or WOFF2 font.
+func Parse(buf []byte) (*sfnt.Font, error) {
+	data, err := Decode(buf)
+	if err != nil {
+		return nil, err
+	}
+	return sfnt.Parse(data)
+}
+
+// ranges returns the ranges of f clipped to the ranges scanned for
+// glyphs.
+func (f Face) ranges() (rs []Range) {
+	all := f.Ranges
+	if len(all) == 0 {
+		all = []Range{{0, MaxRune}}
+	}
+	for _, r := range all {
+		for _, s := range scanned {
+			lo, hi := r.Lo, r.Hi
+			if lo < s.Lo {
+				lo = s.Lo
+			}
+			if hi > s.Hi {
+				hi = s.Hi
+			}
+			if lo <= hi {
+				rs = append(rs, Range{lo, hi})
+			}
+		}
+	}
+	return
+}
+
+// scanned are the ranges of runes looked up in a font: the basic and
+// supplementary multilingual planes and the supplementary private use
+// area which is used by icon fonts.
+var scanned = []Range{
+	{0, 0x1ffff},
+	{0xf0000, 0xfffff},
+}
@@ -0,0 +1,325 @@
+package font
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+func TestParseUnicodeRange(t *testing.T) {
+	tests := map[string][]Range{
+		"U+26":               {{0x26, 0x26}},
+		"U+0000-00FF, U+4??": {{0, 0xff}, {0x400, 0x4ff}},
+		"u+f000-f2e0":        {{0xf000, 0xf2e0}},
+		"U+10????":           {{0x100000, 0x10ffff}},
+	}
+	for v, exp := range tests {
+		rs, err := ParseUnicodeRange(v)
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		if len(rs) != len(exp) {
+			t.Fatalf("%v: %+v", v, rs)
+		}
+		for i, r := range rs {
+			if r != exp[i] {
+				t.Errorf("%v: %+v", v, rs)
+			}
+		}
+	}
+	if _, err := ParseUnicodeRange("0-ff"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// tables returns the tables of an sfnt font.
+func tables(t *testing.T, buf []byte) (ts []table) {
+	n := int(binary.BigEndian.Uint16(buf[4:]))
+	for i := 0; i < n; i++ {
+		e := buf[12+16*i:]
+		off := binary.BigEndian.Uint32(e[8:])
+		l := binary.BigEndian.Uint32(e[12:])
+		ts = append(ts, table{tag: string(e[:4]), data: buf[off : off+l]})
+	}
+	return
+}
+
+func woff(t *testing.T, buf []byte) []byte {
+	ts := tables(t, buf)
+	var dir, data []byte
+	off := 44 + 20*len(ts)
+	for _, tb := range ts {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write(tb.data)
+		w.Close()
+		comp := z.Bytes()
+		if len(comp) >= len(tb.data) {
+			comp = tb.data
+		}
+		dir = append(dir, tb.tag...)
+		dir = appendU32(dir, uint32(off+len(data)))
+		dir = appendU32(dir, uint32(len(comp)))
+		dir = appendU32(dir, uint32(len(tb.data)))
+		dir = appendU32(dir, checksum(tb.data))
+		data = append(data, comp...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	hdr := []byte("wOFF")
+	hdr = append(hdr, buf[:4]...)
+	hdr = appendU32(hdr, uint32(off+len(data)))
+	hdr = appendU16(hdr, uint16(len(ts)))
+	hdr = appendU16(hdr, 0)
+	hdr = appendU32(hdr, uint32(len(buf)))
+	hdr = append(hdr, make([]byte, 44-len(hdr))...)
+	return append(append(hdr, dir...), data...)
+}
+
+// woff2 returns buf as WOFF2 without transformations.
+func woff2(t *testing.T, buf []byte) []byte {
+	ts := tables(t, buf)
+	var dir []byte
+	var z bytes.Buffer
+	w := brotli.NewWriter(&z)
+	for _, tb := range ts {
+		flags := byte(0x3f)
+		for i, tag := range woff2Tags {
+			if tag == tb.tag {
+				flags = byte(i)
+			}
+		}
+		if tb.tag == "glyf" || tb.tag == "loca" {
+			flags |= 3 << 6
+		}
+		dir = append(dir, flags)
+		if flags&0x3f == 0x3f {
+			dir = append(dir, tb.tag...)
+		}
+		dir = append(dir, base128(len(tb.data))...)
+		w.Write(tb.data)
+	}
+	w.Close()
+	hdr := []byte("wOF2")
+	hdr = append(hdr, buf[:4]...)
+	hdr = appendU32(hdr, 0)
+	hdr = appendU16(hdr, uint16(len(ts)))
+	hdr = appendU16(hdr, 0)
+	hdr = appendU32(hdr, uint32(len(buf)))
+	hdr = appendU32(hdr, uint32(z.Len()))
+	hdr = append(hdr, make([]byte, 48-len(hdr))...)
+	return append(append(hdr, dir...), z.Bytes()...)
+}
+
+func base128(v int) (b []byte) {
+	b = append(b, byte(v&0x7f))
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return
+}
+
+func TestDecode(t *testing.T) {
+	exp, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, buf := range map[string][]byte{
+		"ttf":   goregular.TTF,
+		"woff":  woff(t, goregular.TTF),
+		"woff2": woff2(t, goregular.TTF),
+	} {
+		f, err := Parse(buf)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if f.NumGlyphs() != exp.NumGlyphs() {
+			t.Errorf("%v: %v glyphs", name, f.NumGlyphs())
+		}
+		var b sfnt.Buffer
+		for _, r := range "aZ€" {
+			gi, _ := f.GlyphIndex(&b, r)
+			egi, _ := exp.GlyphIndex(&b, r)
+			if gi == 0 || gi != egi {
+				t.Errorf("%v: %c: %v", name, r, gi)
+			}
+		}
+	}
+	if _, err := Decode([]byte("<html>")); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestDecodeSizes(t *testing.T) {
+	for name, buf := range map[string][]byte{
+		"woff":  woff(t, goregular.TTF),
+		"woff2": woff2(t, goregular.TTF),
+	} {
+		for _, size := range []uint32{maxSfntSize + 1, 1000} {
+			b := append([]byte{}, buf...)
+			binary.BigEndian.PutUint32(b[16:], size)
+			if _, err := Decode(b); err == nil {
+				t.Errorf("%v: totalSfntSize %v: expected error", name, size)
+			}
+		}
+	}
+	b := woff(t, goregular.TTF)
+	binary.BigEndian.PutUint32(b[44+12:], 0xfffffff0)
+	if _, err := Decode(b); err == nil {
+		t.Errorf("woff: huge table: expected error")
+	}
+}
+
+func TestTriplet(t *testing.T) {
+	tests := []struct {
+		flag   byte
+		in     []byte
+		dx, dy int
+	}{
+		{0, []byte{5}, 0, -5},
+		{1, []byte{5}, 0, 5},
+		{11, []byte{1}, 1, 0},
+		{20, []byte{0x12}, -2, -3},
+		{87, []byte{3, 4}, 4, 5},
+		{121, []byte{1, 0x23, 4}, 0x12, -0x304},
+		{127, []byte{1, 0, 2, 0}, 0x100, 0x200},
+	}
+	for _, tt := range tests {
+		dx, dy, err := triplet(tt.flag, &reader{b: tt.in})
+		if err != nil || dx != tt.dx || dy != tt.dy {
+			t.Errorf("%v: %v %v %v", tt.flag, dx, dy, err)
+		}
+	}
+}
+
+func TestU255(t *testing.T) {
+	for in, exp := range map[string]int{
+		"\x00":         0,
+		"\xfc":         252,
+		"\xff\x00":     253,
+		"\xfe\x00":     506,
+		"\xfd\x12\x34": 0x1234,
+	} {
+		v, err := (&reader{b: []byte(in)}).u255()
+		if err != nil || v != exp {
+			t.Errorf("%q: %v %v", in, v, err)
+		}
+	}
+}
+
+func TestWriteFont(t *testing.T) {
+	f, err := Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latin := Face{Font: f, Ranges: []Range{{0x20, 0x7e}}}
+	dir := t.TempDir()
+	fn, err := WriteFont(dir, []Face{latin}, 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	hdr := strings.Fields(lines[0])
+	height, _ := strconv.Atoi(hdr[0])
+	ascent, _ := strconv.Atoi(hdr[1])
+	if height < 22 || ascent >= height || ascent <= 0 {
+		t.Fatalf("%v", lines[0])
+	}
+	if len(lines) != 2 || lines[1] != "0x0020 0x007e 0.0020" {
+		t.Fatalf("%v", lines)
+	}
+
+	sub, err := os.ReadFile(filepath.Join(dir, "0.0020"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sub[:12]) != "         k8 " {
+		t.Fatalf("%q", sub[:12])
+	}
+	w, _ := strconv.Atoi(strings.TrimSpace(string(sub[36:48])))
+	h, _ := strconv.Atoi(strings.TrimSpace(string(sub[48:60])))
+	if h != height {
+		t.Fatalf("%v", h)
+	}
+	pix := sub[60 : 60+w*h]
+	info := sub[60+w*h:]
+	if n, _ := strconv.Atoi(strings.TrimSpace(string(info[:12]))); n != 0x7e-0x20+1 {
+		t.Fatalf("%v", n)
+	}
+	fc := info[36:]
+	if len(fc) != 6*(0x7e-0x20+2) {
+		t.Fatalf("%v", len(fc))
+	}
+	glyph := func(r rune) (x, x1, top, bottom, width int) {
+		e := fc[6*(r-0x20):]
+		x = int(binary.LittleEndian.Uint16(e))
+		x1 = int(binary.LittleEndian.Uint16(e[6:]))
+		return x, x1, int(e[2]), int(e[3]), int(e[5])
+	}
+	if x, x1, _, _, width := glyph(' '); x1 != x || width == 0 {
+		t.Errorf("space: %v %v %v", x, x1, width)
+	}
+	x, x1, top, bottom, width := glyph('M')
+	if x1 <= x || top >= bottom || bottom > ascent || width < x1-x {
+		t.Fatalf("M: %v %v %v %v %v", x, x1, top, bottom, width)
+	}
+	ink := 0
+	for y := top; y < bottom; y++ {
+		for _, p := range pix[y*w+x : y*w+x1] {
+			if p > 128 {
+				ink++
+			}
+		}
+	}
+	if ink < (x1-x)*(bottom-top)/4 {
+		t.Errorf("M: %v", ink)
+	}
+
+	// first face wins, the rest comes from the second one
+	euro := Face{Font: f, Ranges: []Range{{0x20ac, 0x20ac}}}
+	fn, err = WriteFont(t.TempDir(), []Face{euro, {Font: f}}, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(fn)
+	if !strings.Contains(string(data), "0x20ac 0x20ac 0.20ac\n") || !strings.Contains(string(data), "0x0020 0x007e 1.0020\n") {
+		t.Errorf("%s", data)
+	}
+}
+
+func TestGo(t *testing.T) {
+	tests := map[[3]bool]string{
+		{false, false, false}: "regular",
+		{false, true, true}:   "bolditalic",
+		{true, false, false}:  "mono",
+		{true, true, false}:   "monobold",
+	}
+	for in, exp := range tests {
+		v := GoVariant(in[0], in[1], in[2])
+		if v != exp {
+			t.Errorf("%v: %v", in, v)
+		}
+		f, err := Go(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, err := f.Name(nil, sfnt.NameIDFull)
+		if err != nil || !strings.HasPrefix(strings.ReplaceAll(strings.ToLower(name), " ", ""), "go"+strings.TrimSuffix(v, "regular")) {
+			t.Errorf("%v: %v %v", v, name, err)
+		}
+	}
+}
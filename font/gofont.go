@@ -0,0 +1,67 @@
+package font
+
+import (
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"sync"
+)
+
+// goTTFs are the bundled Go fonts by variant.
+var goTTFs = map[string][]byte{
+	"regular":        goregular.TTF,
+	"bold":           gobold.TTF,
+	"italic":         goitalic.TTF,
+	"bolditalic":     gobolditalic.TTF,
+	"mono":           gomono.TTF,
+	"monobold":       gomonobold.TTF,
+	"monoitalic":     gomonoitalic.TTF,
+	"monobolditalic": gomonobolditalic.TTF,
+}
+
+var (
+	goMu    sync.Mutex
+	goFonts = make(map[string]*sfnt.Font)
+)
+
+// GoVariant returns the name of the Go font variant, e.g. monobold.
+func GoVariant(mono, bold, italic bool) (v string) {
+	if mono {
+		v = "mono"
+	}
+	if bold {
+		v += "bold"
+	}
+	if italic {
+		v += "italic"
+	}
+	if v == "" {
+		v = "regular"
+	}
+	return
+}
+
+// Go returns the bundled Go font of the variant.
+func Go(variant string) (*sfnt.Font, error) {
+	goMu.Lock()
+	defer goMu.Unlock()
+	if f, ok := goFonts[variant]; ok {
+		return f, nil
+	}
+	ttf, ok := goTTFs[variant]
+	if !ok {
+		ttf = goregular.TTF
+	}
+	f, err := sfnt.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+	goFonts[variant] = f
+	return f, nil
+}
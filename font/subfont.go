@@ -0,0 +1,194 @@
+package font
+
+import (
+	"bufio"
+	"fmt"
+	xfont "golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blockSize is the maximum number of runes per subfont.
+const blockSize = 256
+
+// run of runes rendered by the same face.
+type run struct {
+	face int
+	lo   rune
+	hi   rune
+}
+
+// WriteFont rasterizes faces at px pixels into a font file with one
+// subfont per run of runes in dir and returns the name of the font
+// file. Runes are taken from the first face that covers them.
+func WriteFont(dir string, faces []Face, px int) (fn string, err error) {
+	if len(faces) == 0 {
+		return "", fmt.Errorf("no faces")
+	}
+	if px <= 0 {
+		return "", fmt.Errorf("invalid size %v", px)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	xfs := make([]xfont.Face, len(faces))
+	ascent, descent := 0, 0
+	for i, f := range faces {
+		xf, err := opentype.NewFace(f.Font, &opentype.FaceOptions{
+			Size:    float64(px),
+			DPI:     72,
+			Hinting: xfont.HintingFull,
+		})
+		if err != nil {
+			return "", err
+		}
+		defer xf.Close()
+		xfs[i] = xf
+		m := xf.Metrics()
+		if a := m.Ascent.Ceil(); a > ascent {
+			ascent = a
+		}
+		if d := m.Descent.Ceil(); d > descent {
+			descent = d
+		}
+	}
+	height := ascent + descent
+	if height <= 0 || ascent <= 0 {
+		return "", fmt.Errorf("invalid font metrics")
+	}
+	if height > math.MaxUint8 {
+		return "", fmt.Errorf("font too large")
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%d %d\n", height, ascent)
+	for _, r := range runs(faces) {
+		name := fmt.Sprintf("%d.%04x", r.face, r.lo)
+		if err := writeSubfont(filepath.Join(dir, name), xfs[r.face], r, height, ascent); err != nil {
+			return "", fmt.Errorf("subfont %v: %w", name, err)
+		}
+		fmt.Fprintf(&buf, "0x%04x 0x%04x %s\n", r.lo, r.hi, name)
+	}
+	fn = filepath.Join(dir, "font")
+	if err := os.WriteFile(fn, []byte(buf.String()), 0600); err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
+// runs returns the runes of the faces grouped into runs of
+// consecutive runes of the same face and block.
+func runs(faces []Face) (rs []run) {
+	covered := make(map[rune]int)
+	var b sfnt.Buffer
+	for i, f := range faces {
+		for _, r := range f.ranges() {
+			for c := r.Lo; c <= r.Hi; c++ {
+				if _, ok := covered[c]; ok {
+					continue
+				}
+				if gi, err := f.Font.GlyphIndex(&b, c); err == nil && gi != 0 {
+					covered[c] = i
+				}
+			}
+		}
+	}
+	var cur *run
+	for _, s := range scanned {
+		for c := s.Lo; c <= s.Hi; c++ {
+			i, ok := covered[c]
+			switch {
+			case !ok:
+				cur = nil
+			case cur != nil && cur.face == i && cur.hi == c-1 && c%blockSize != 0:
+				cur.hi = c
+			default:
+				rs = append(rs, run{face: i, lo: c, hi: c})
+				cur = &rs[len(rs)-1]
+			}
+		}
+		cur = nil
+	}
+	return
+}
+
+// writeSubfont writes the glyphs of r as uncompressed 8 bit grey
+// subfont into the file fn.
+func writeSubfont(fn string, f xfont.Face, r run, height, ascent int) error {
+	type glyph struct {
+		dr      image.Rectangle
+		mask    image.Image
+		advance int
+	}
+	n := int(r.hi-r.lo) + 1
+	gs := make([]glyph, n)
+	width := 0
+	for i := range gs {
+		dr, mask, mp, adv, ok := f.Glyph(fixed.P(0, ascent), r.lo+rune(i))
+		if !ok {
+			continue
+		}
+		clipped := dr.Intersect(image.Rect(dr.Min.X, 0, dr.Max.X, height))
+		mp = mp.Add(clipped.Min.Sub(dr.Min))
+		// the mask is reused by the next call
+		m := image.NewAlpha(clipped)
+		draw.Draw(m, clipped, mask, mp, draw.Src)
+		gs[i] = glyph{dr: clipped, mask: m, advance: adv.Round()}
+		width += clipped.Dx()
+	}
+	if width == 0 {
+		width = 1
+	}
+
+	strip := image.NewGray(image.Rect(0, 0, width, height))
+	info := make([]byte, 0, 6*(n+1))
+	x := 0
+	for _, g := range gs {
+		left := clamp(g.dr.Min.X, math.MinInt8, math.MaxInt8)
+		adv := clamp(g.advance, 0, math.MaxUint8)
+		top, bottom := 0, 0
+		if !g.dr.Empty() {
+			top, bottom = g.dr.Min.Y, g.dr.Max.Y
+			dst := image.Rect(x, top, x+g.dr.Dx(), bottom)
+			draw.DrawMask(strip, dst, image.White, image.Point{}, g.mask, g.dr.Min, draw.Over)
+		}
+		info = append(info, byte(x), byte(x>>8), byte(top), byte(bottom), byte(int8(left)), byte(adv))
+		x += g.dr.Dx()
+	}
+	info = append(info, byte(x), byte(x>>8), 0, 0, 0, 0)
+	if x > math.MaxUint16 {
+		return fmt.Errorf("subfont too wide")
+	}
+
+	fd, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fd)
+	fmt.Fprintf(w, "%11s %11d %11d %11d %11d ", "k8", 0, 0, width, height)
+	w.Write(strip.Pix)
+	fmt.Fprintf(w, "%11d %11d %11d ", n, height, ascent)
+	w.Write(info)
+	if err := w.Flush(); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,688 @@
+package font
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"io"
+	"sort"
+)
+
+// table of an sfnt font.
+type table struct {
+	tag  string
+	data []byte
+}
+
+// maxSfntSize is the largest decoded font accepted.
+const maxSfntSize = 64 << 20
+
+// sfntSize returns totalSfntSize of the WOFF or WOFF2 header buf.
+func sfntSize(buf []byte) (int, error) {
+	size := int(binary.BigEndian.Uint32(buf[16:]))
+	if size > maxSfntSize {
+		return 0, fmt.Errorf("font of %v bytes too large", size)
+	}
+	return size, nil
+}
+
+// Decode returns the sfnt data (TrueType or OpenType) of a font that
+// is possibly wrapped in WOFF or WOFF2.
+func Decode(buf []byte) ([]byte, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("font too short")
+	}
+	switch string(buf[:4]) {
+	case "wOFF":
+		return decodeWOFF(buf)
+	case "wOF2":
+		return decodeWOFF2(buf)
+	case "\x00\x01\x00\x00", "OTTO", "true":
+		return buf, nil
+	}
+	return nil, fmt.Errorf("unknown font format %q", buf[:4])
+}
+
+// decodeWOFF decodes WOFF 1.0 with zlib compressed tables.
+func decodeWOFF(buf []byte) ([]byte, error) {
+	if len(buf) < 44 {
+		return nil, fmt.Errorf("woff header too short")
+	}
+	flavor := binary.BigEndian.Uint32(buf[4:])
+	n := int(binary.BigEndian.Uint16(buf[12:]))
+	if len(buf) < 44+20*n {
+		return nil, fmt.Errorf("woff table directory too short")
+	}
+	size, err := sfntSize(buf)
+	if err != nil {
+		return nil, err
+	}
+	ts := make([]table, 0, n)
+	for i := 0; i < n; i++ {
+		e := buf[44+20*i:]
+		off := int(binary.BigEndian.Uint32(e[4:]))
+		compLen := int(binary.BigEndian.Uint32(e[8:]))
+		origLen := int(binary.BigEndian.Uint32(e[12:]))
+		if off < 0 || compLen < 0 || off+compLen > len(buf) || compLen > origLen {
+			return nil, fmt.Errorf("woff table %q out of bounds", e[:4])
+		}
+		if size -= origLen; size < 0 {
+			return nil, fmt.Errorf("woff table %q exceeds totalSfntSize", e[:4])
+		}
+		data := buf[off : off+compLen]
+		if compLen < origLen {
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("woff table %q: %w", e[:4], err)
+			}
+			data = make([]byte, origLen)
+			if _, err := io.ReadFull(zr, data); err != nil {
+				return nil, fmt.Errorf("woff table %q: %w", e[:4], err)
+			}
+		}
+		ts = append(ts, table{tag: string(e[:4]), data: data})
+	}
+	return writeSfnt(flavor, ts), nil
+}
+
+// woff2Tags are the known table tags of the WOFF2 table directory.
+var woff2Tags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post",
+	"cvt ", "fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT",
+	"EBLC", "gasp", "hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea",
+	"vmtx", "BASE", "GDEF", "GPOS", "GSUB", "EBSC", "JSTF", "MATH",
+	"CBDT", "CBLC", "COLR", "CPAL", "SVG ", "sbix", "acnt", "avar",
+	"bdat", "bloc", "bsln", "cvar", "fdsc", "feat", "fmtx", "fvar",
+	"gvar", "hsty", "just", "lcar", "mort", "morx", "opbd", "prop",
+	"trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+type woff2Entry struct {
+	tag         string
+	transformed bool
+	length      int // in the decompressed stream
+}
+
+// decodeWOFF2 decodes WOFF 2.0 including the glyf, loca and hmtx
+// transforms. Font collections are not supported.
+func decodeWOFF2(buf []byte) ([]byte, error) {
+	if len(buf) < 48 {
+		return nil, fmt.Errorf("woff2 header too short")
+	}
+	flavor := binary.BigEndian.Uint32(buf[4:])
+	if flavor == 0x74746366 {
+		return nil, fmt.Errorf("woff2 font collections not supported")
+	}
+	n := int(binary.BigEndian.Uint16(buf[12:]))
+	size, err := sfntSize(buf)
+	if err != nil {
+		return nil, err
+	}
+	compLen := int(binary.BigEndian.Uint32(buf[20:]))
+	r := &reader{b: buf, off: 48}
+	es := make([]woff2Entry, 0, n)
+	streamLen := 0
+	for i := 0; i < n; i++ {
+		flags, err := r.u8()
+		if err != nil {
+			return nil, err
+		}
+		var e woff2Entry
+		if idx := flags & 0x3f; idx == 0x3f {
+			tag, err := r.bytes(4)
+			if err != nil {
+				return nil, err
+			}
+			e.tag = string(tag)
+		} else {
+			e.tag = woff2Tags[idx]
+		}
+		version := flags >> 6
+		if e.tag == "glyf" || e.tag == "loca" {
+			e.transformed = version != 3
+		} else {
+			e.transformed = version != 0
+		}
+		origLen, err := r.base128()
+		if err != nil {
+			return nil, err
+		}
+		e.length = origLen
+		if e.transformed {
+			if e.length, err = r.base128(); err != nil {
+				return nil, err
+			}
+		}
+		if streamLen += e.length; streamLen > size {
+			return nil, fmt.Errorf("woff2 table %q exceeds totalSfntSize", e.tag)
+		}
+		es = append(es, e)
+	}
+	if r.off+compLen > len(buf) {
+		return nil, fmt.Errorf("woff2 compressed data out of bounds")
+	}
+	stream := make([]byte, streamLen)
+	br := brotli.NewReader(bytes.NewReader(buf[r.off : r.off+compLen]))
+	if _, err := io.ReadFull(br, stream); err != nil {
+		return nil, fmt.Errorf("woff2 decompress: %w", err)
+	}
+
+	raw := make(map[string][]byte)
+	ts := make([]table, 0, n)
+	off := 0
+	for _, e := range es {
+		if off+e.length > len(stream) {
+			return nil, fmt.Errorf("woff2 table %q out of bounds", e.tag)
+		}
+		raw[e.tag] = stream[off : off+e.length]
+		off += e.length
+	}
+	var xMins []int16
+	for _, e := range es {
+		data := raw[e.tag]
+		switch {
+		case e.tag == "glyf" && e.transformed:
+			var loca []byte
+			if data, loca, xMins, err = reconstructGlyf(data); err != nil {
+				return nil, fmt.Errorf("woff2 glyf: %w", err)
+			}
+			raw["loca"] = loca
+		case e.tag == "hmtx" && e.transformed:
+			if data, err = reconstructHmtx(data, raw["hhea"], xMins); err != nil {
+				return nil, fmt.Errorf("woff2 hmtx: %w", err)
+			}
+		case e.transformed && e.tag != "loca":
+			return nil, fmt.Errorf("woff2 transform of %q not supported", e.tag)
+		}
+		raw[e.tag] = data
+	}
+	for _, e := range es {
+		ts = append(ts, table{tag: e.tag, data: raw[e.tag]})
+	}
+	return writeSfnt(flavor, ts), nil
+}
+
+// reconstructGlyf returns glyf and loca tables from the transformed
+// glyf table and the xMin of each glyph.
+func reconstructGlyf(b []byte) (glyf, loca []byte, xMins []int16, err error) {
+	if len(b) < 36 {
+		return nil, nil, nil, fmt.Errorf("header too short")
+	}
+	optFlags := binary.BigEndian.Uint16(b[2:])
+	numGlyphs := int(binary.BigEndian.Uint16(b[4:]))
+	indexFormat := binary.BigEndian.Uint16(b[6:])
+	var streams [7]*reader
+	off := 36
+	for i := range streams {
+		l := int(binary.BigEndian.Uint32(b[8+4*i:]))
+		if off+l > len(b) || l < 0 {
+			return nil, nil, nil, fmt.Errorf("stream %v out of bounds", i)
+		}
+		streams[i] = &reader{b: b[off : off+l]}
+		off += l
+	}
+	nContour, nPoints, flagS, glyphS, compS, bboxS, instrS := streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+	var overlap []byte
+	if optFlags&1 != 0 {
+		l := (numGlyphs + 7) / 8
+		if off+l > len(b) {
+			return nil, nil, nil, fmt.Errorf("overlap bitmap out of bounds")
+		}
+		overlap = b[off : off+l]
+	}
+	bboxBitmap, err := bboxS.bytes(((numGlyphs + 31) >> 5) << 2)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var out bytes.Buffer
+	offsets := make([]int, 0, numGlyphs+1)
+	xMins = make([]int16, numGlyphs)
+	for i := 0; i < numGlyphs; i++ {
+		offsets = append(offsets, out.Len())
+		nc, err := nContour.i16()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		hasBbox := bboxBitmap[i>>3]&(0x80>>(i&7)) != 0
+		var g []byte
+		switch {
+		case nc == 0:
+			if hasBbox {
+				return nil, nil, nil, fmt.Errorf("glyph %v: empty glyph with bbox", i)
+			}
+		case nc > 0:
+			ov := overlap != nil && overlap[i>>3]&(0x80>>(i&7)) != 0
+			g, err = simpleGlyph(int(nc), hasBbox, ov, nPoints, flagS, glyphS, bboxS, instrS)
+		default:
+			if !hasBbox {
+				return nil, nil, nil, fmt.Errorf("glyph %v: composite glyph without bbox", i)
+			}
+			g, err = compositeGlyph(glyphS, compS, bboxS, instrS)
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("glyph %v: %w", i, err)
+		}
+		if len(g) >= 4 {
+			xMins[i] = int16(binary.BigEndian.Uint16(g[2:]))
+		}
+		out.Write(g)
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	offsets = append(offsets, out.Len())
+
+	for _, o := range offsets {
+		if indexFormat == 0 {
+			loca = appendU16(loca, uint16(o/2))
+		} else {
+			loca = appendU32(loca, uint32(o))
+		}
+	}
+	return out.Bytes(), loca, xMins, nil
+}
+
+// simpleGlyph decodes a simple glyph with nc contours.
+func simpleGlyph(nc int, hasBbox, overlap bool, nPoints, flagS, glyphS, bboxS, instrS *reader) ([]byte, error) {
+	endPts := make([]int, nc)
+	total := 0
+	for i := range endPts {
+		n, err := nPoints.u255()
+		if err != nil {
+			return nil, err
+		}
+		total += n
+		endPts[i] = total - 1
+	}
+	type point struct {
+		x, y    int
+		onCurve bool
+	}
+	pts := make([]point, total)
+	x, y := 0, 0
+	for i := range pts {
+		flag, err := flagS.u8()
+		if err != nil {
+			return nil, err
+		}
+		dx, dy, err := triplet(flag&0x7f, glyphS)
+		if err != nil {
+			return nil, err
+		}
+		x += dx
+		y += dy
+		pts[i] = point{x, y, flag&0x80 == 0}
+	}
+	instrLen, err := glyphS.u255()
+	if err != nil {
+		return nil, err
+	}
+	instrs, err := instrS.bytes(instrLen)
+	if err != nil {
+		return nil, err
+	}
+
+	var bbox [4]int16
+	if hasBbox {
+		for i := range bbox {
+			if bbox[i], err = bboxS.i16(); err != nil {
+				return nil, err
+			}
+		}
+	} else if len(pts) > 0 {
+		bbox = [4]int16{int16(pts[0].x), int16(pts[0].y), int16(pts[0].x), int16(pts[0].y)}
+		for _, p := range pts {
+			bbox[0] = min16(bbox[0], int16(p.x))
+			bbox[1] = min16(bbox[1], int16(p.y))
+			bbox[2] = max16(bbox[2], int16(p.x))
+			bbox[3] = max16(bbox[3], int16(p.y))
+		}
+	}
+
+	g := make([]byte, 0, 10+2*nc+2+len(instrs)+5*total)
+	g = appendU16(g, uint16(nc))
+	for _, v := range bbox {
+		g = appendU16(g, uint16(v))
+	}
+	for _, e := range endPts {
+		g = appendU16(g, uint16(e))
+	}
+	g = appendU16(g, uint16(instrLen))
+	g = append(g, instrs...)
+
+	// flags, then x and y coordinates
+	var xs, ys []byte
+	lx, ly := 0, 0
+	for i, p := range pts {
+		var f byte
+		if p.onCurve {
+			f |= 0x01
+		}
+		if i == 0 && overlap {
+			f |= 0x40
+		}
+		dx, dy := p.x-lx, p.y-ly
+		lx, ly = p.x, p.y
+		switch {
+		case dx == 0:
+			f |= 0x10
+		case -255 <= dx && dx <= 255:
+			f |= 0x02
+			if dx > 0 {
+				f |= 0x10
+			} else {
+				dx = -dx
+			}
+			xs = append(xs, byte(dx))
+		default:
+			xs = appendU16(xs, uint16(int16(dx)))
+		}
+		switch {
+		case dy == 0:
+			f |= 0x20
+		case -255 <= dy && dy <= 255:
+			f |= 0x04
+			if dy > 0 {
+				f |= 0x20
+			} else {
+				dy = -dy
+			}
+			ys = append(ys, byte(dy))
+		default:
+			ys = appendU16(ys, uint16(int16(dy)))
+		}
+		g = append(g, f)
+	}
+	g = append(g, xs...)
+	return append(g, ys...), nil
+}
+
+// compositeGlyph copies a composite glyph.
+func compositeGlyph(glyphS, compS, bboxS, instrS *reader) ([]byte, error) {
+	g := appendU16(nil, 0xffff)
+	for i := 0; i < 4; i++ {
+		v, err := bboxS.i16()
+		if err != nil {
+			return nil, err
+		}
+		g = appendU16(g, uint16(v))
+	}
+	haveInstrs := false
+	for {
+		flags, err := compS.u16()
+		if err != nil {
+			return nil, err
+		}
+		n := 2 // glyph index
+		if flags&0x0001 != 0 {
+			n += 4
+		} else {
+			n += 2
+		}
+		switch {
+		case flags&0x0008 != 0:
+			n += 2
+		case flags&0x0040 != 0:
+			n += 4
+		case flags&0x0080 != 0:
+			n += 8
+		}
+		rest, err := compS.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		g = appendU16(g, flags)
+		g = append(g, rest...)
+		haveInstrs = haveInstrs || flags&0x0100 != 0
+		if flags&0x0020 == 0 {
+			break
+		}
+	}
+	if haveInstrs {
+		l, err := glyphS.u255()
+		if err != nil {
+			return nil, err
+		}
+		instrs, err := instrS.bytes(l)
+		if err != nil {
+			return nil, err
+		}
+		g = appendU16(g, uint16(l))
+		g = append(g, instrs...)
+	}
+	return g, nil
+}
+
+// triplet decodes the point coordinate deltas of the triplet encoding
+// with flag.
+func triplet(flag byte, r *reader) (dx, dy int, err error) {
+	withSign := func(f byte, v int) int {
+		if f&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	var n int
+	switch {
+	case flag < 84:
+		n = 1
+	case flag < 120:
+		n = 2
+	case flag < 124:
+		n = 3
+	default:
+		n = 4
+	}
+	in, err := r.bytes(n)
+	if err != nil {
+		return 0, 0, err
+	}
+	f := int(flag)
+	switch {
+	case flag < 10:
+		dy = withSign(flag, (f&14)<<7+int(in[0]))
+	case flag < 20:
+		dx = withSign(flag, ((f-10)&14)<<7+int(in[0]))
+	case flag < 84:
+		b0 := f - 20
+		b1 := int(in[0])
+		dx = withSign(flag, 1+(b0&0x30)+(b1>>4))
+		dy = withSign(flag>>1, 1+(b0&0x0c)<<2+(b1&0x0f))
+	case flag < 120:
+		b0 := f - 84
+		dx = withSign(flag, 1+(b0/12)<<8+int(in[0]))
+		dy = withSign(flag>>1, 1+((b0%12)>>2)<<8+int(in[1]))
+	case flag < 124:
+		b2 := int(in[1])
+		dx = withSign(flag, int(in[0])<<4+b2>>4)
+		dy = withSign(flag>>1, (b2&0x0f)<<8+int(in[2]))
+	default:
+		dx = withSign(flag, int(in[0])<<8+int(in[1]))
+		dy = withSign(flag>>1, int(in[2])<<8+int(in[3]))
+	}
+	return
+}
+
+// reconstructHmtx returns the hmtx table from the transformed one.
+// Left side bearings left out are the xMins of the glyphs.
+func reconstructHmtx(b, hhea []byte, xMins []int16) ([]byte, error) {
+	if len(hhea) < 36 {
+		return nil, fmt.Errorf("hhea too short")
+	}
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	numGlyphs := len(xMins)
+	if numHMetrics > numGlyphs {
+		return nil, fmt.Errorf("more metrics than glyphs")
+	}
+	r := &reader{b: b}
+	flags, err := r.u8()
+	if err != nil {
+		return nil, err
+	}
+	adv := make([]uint16, numHMetrics)
+	for i := range adv {
+		if adv[i], err = r.u16(); err != nil {
+			return nil, err
+		}
+	}
+	lsbs := make([]int16, numGlyphs)
+	for i := range lsbs {
+		if (i < numHMetrics && flags&1 != 0) || (i >= numHMetrics && flags&2 != 0) {
+			lsbs[i] = xMins[i]
+		} else if lsbs[i], err = r.i16(); err != nil {
+			return nil, err
+		}
+	}
+	var out []byte
+	for i, lsb := range lsbs {
+		if i < numHMetrics {
+			out = appendU16(out, adv[i])
+		}
+		out = appendU16(out, uint16(lsb))
+	}
+	return out, nil
+}
+
+// writeSfnt serializes the tables into an sfnt font.
+func writeSfnt(flavor uint32, ts []table) []byte {
+	sort.Slice(ts, func(i, j int) bool { return ts[i].tag < ts[j].tag })
+	n := len(ts)
+	es, sel := 1, 0
+	for es*2 <= n {
+		es *= 2
+		sel++
+	}
+	out := make([]byte, 0, 12+16*n)
+	out = appendU32(out, flavor)
+	out = appendU16(out, uint16(n))
+	out = appendU16(out, uint16(es*16))
+	out = appendU16(out, uint16(sel))
+	out = appendU16(out, uint16(n*16-es*16))
+	off := 12 + 16*n
+	for _, t := range ts {
+		out = append(out, t.tag...)
+		out = appendU32(out, checksum(t.data))
+		out = appendU32(out, uint32(off))
+		out = appendU32(out, uint32(len(t.data)))
+		off += (len(t.data) + 3) &^ 3
+	}
+	for _, t := range ts {
+		out = append(out, t.data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+func checksum(b []byte) (s uint32) {
+	for i := 0; i < len(b); i += 4 {
+		var w [4]byte
+		copy(w[:], b[i:])
+		s += binary.BigEndian.Uint32(w[:])
+	}
+	return
+}
+
+// reader of big endian font data.
+type reader struct {
+	b   []byte
+	off int
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.off+n > len(r.b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+func (r *reader) u8() (byte, error) {
+	b, err := r.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *reader) u16() (uint16, error) {
+	b, err := r.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (r *reader) i16() (int16, error) {
+	v, err := r.u16()
+	return int16(v), err
+}
+
+// u255 reads a 255UInt16.
+func (r *reader) u255() (int, error) {
+	c, err := r.u8()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case 253:
+		v, err := r.u16()
+		return int(v), err
+	case 254:
+		v, err := r.u8()
+		return int(v) + 253*2, err
+	case 255:
+		v, err := r.u8()
+		return int(v) + 253, err
+	}
+	return int(c), nil
+}
+
+// base128 reads a UIntBase128.
+func (r *reader) base128() (int, error) {
+	v := 0
+	for i := 0; i < 5; i++ {
+		c, err := r.u8()
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 && c == 0x80 {
+			return 0, fmt.Errorf("base128 with leading zeros")
+		}
+		if v&0xfe000000 != 0 {
+			return 0, fmt.Errorf("base128 overflow")
+		}
+		v = v<<7 | int(c&0x7f)
+		if c&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("base128 too long")
+}
+
+func min16(a, b int16) int16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max16(a, b int16) int16 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func appendU16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
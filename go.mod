@@ -16,6 +16,7 @@ exclude github.com/hanwen/go-fuse/v2 v2.1.0
 
 require (
 	9fans.net/go v0.0.2
+	github.com/andybalholm/brotli v1.0.4
 	github.com/andybalholm/cascadia v1.3.1
 	github.com/knusbaum/go9p v1.18.0
 	github.com/mjl-/duit v0.0.0-20200330125617-580cb0b2843f
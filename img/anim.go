@@ -0,0 +1,365 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/image/webp"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"time"
+)
+
+// ErrNotAnimated is returned for images with a single frame.
+var ErrNotAnimated = errors.New("not animated")
+
+// Animation holds the frames of an animated image composited onto its
+// canvas and how long each of them is shown.
+type Animation struct {
+	Frames []*image.RGBA
+	Delays []time.Duration
+}
+
+// DecodeAnimation decodes all frames of an animated GIF, PNG (APNG) or
+// WebP. ErrNotAnimated is also returned if the frames would take more
+// than maxPixels altogether, the first frame can be decoded as a still
+// image then.
+func DecodeAnimation(data []byte) (a *Animation, err error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		a, err = decodeGif(data)
+	case bytes.HasPrefix(data, pngSig):
+		a, err = decodeApng(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		a, err = decodeWebp(data)
+	default:
+		return nil, ErrNotAnimated
+	}
+	if err == nil && len(a.Frames) < 2 {
+		return nil, ErrNotAnimated
+	}
+	return
+}
+
+// tooLarge is true if n frames of w x h take more than maxPixels.
+func tooLarge(w, h, n int) bool {
+	return w <= 0 || h <= 0 || w > maxPixels/h || n > maxPixels/(w*h)
+}
+
+// frameDelay clamps very short delays like browsers do.
+func frameDelay(d time.Duration) time.Duration {
+	if d <= 10*time.Millisecond {
+		return 100 * time.Millisecond
+	}
+	return d
+}
+
+// compositor draws frames onto a canvas and takes snapshots.
+type compositor struct {
+	canvas *image.RGBA
+	a      Animation
+}
+
+func newCompositor(w, h int) *compositor {
+	return &compositor{canvas: image.NewRGBA(image.Rect(0, 0, w, h))}
+}
+
+// frame draws src at r onto the canvas, blending it over the canvas or
+// replacing the pixels, and takes a snapshot. Afterwards the area is
+// cleared if background is set or restored if previous is set.
+func (c *compositor) frame(src image.Image, r image.Rectangle, blend bool, delay time.Duration, background, previous bool) {
+	var prev *image.RGBA
+	if previous {
+		prev = clone(c.canvas)
+	}
+	op := draw.Src
+	if blend {
+		op = draw.Over
+	}
+	draw.Draw(c.canvas, r, src, src.Bounds().Min, op)
+	c.a.Frames = append(c.a.Frames, clone(c.canvas))
+	c.a.Delays = append(c.a.Delays, frameDelay(delay))
+	switch {
+	case previous:
+		c.canvas = prev
+	case background:
+		draw.Draw(c.canvas, r, image.Transparent, image.Point{}, draw.Src)
+	}
+}
+
+func clone(i *image.RGBA) *image.RGBA {
+	c := image.NewRGBA(i.Rect)
+	copy(c.Pix, i.Pix)
+	return c
+}
+
+func decodeGif(data []byte) (*Animation, error) {
+	cfg, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gif: %w", err)
+	}
+	if tooLarge(cfg.Width, cfg.Height, 1) {
+		return nil, ErrNotAnimated
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gif: %w", err)
+	}
+	if tooLarge(g.Config.Width, g.Config.Height, len(g.Image)) {
+		return nil, ErrNotAnimated
+	}
+	c := newCompositor(g.Config.Width, g.Config.Height)
+	for i, p := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		delay := time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		c.frame(p, p.Bounds(), true, delay, disposal == gif.DisposalBackground, disposal == gif.DisposalPrevious)
+	}
+	return &c.a, nil
+}
+
+var pngSig = []byte("\x89PNG\r\n\x1a\n")
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+func pngChunks(data []byte) (cs []pngChunk, err error) {
+	data = data[len(pngSig):]
+	for len(data) >= 12 {
+		n := binary.BigEndian.Uint32(data)
+		if uint64(n)+12 > uint64(len(data)) {
+			return nil, fmt.Errorf("chunk too long")
+		}
+		cs = append(cs, pngChunk{typ: string(data[4:8]), data: data[8 : 8+n]})
+		data = data[12+n:]
+	}
+	return
+}
+
+func writePngChunk(b *bytes.Buffer, typ string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	b.Write(n[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	b.WriteString(typ)
+	b.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	b.Write(n[:])
+}
+
+// apngFrame is the fcTL chunk of an APNG frame and its image data.
+type apngFrame struct {
+	r              image.Rectangle
+	delay          time.Duration
+	dispose, blend byte
+	idat           [][]byte
+}
+
+// decodeApng composites the frames of an APNG. Each frame is decoded
+// as a PNG of its own with the header and palette chunks of the file.
+func decodeApng(data []byte) (*Animation, error) {
+	cs, err := pngChunks(data)
+	if err != nil {
+		return nil, fmt.Errorf("apng: %w", err)
+	}
+	var ihdr []byte
+	var shared []pngChunk
+	var frames []*apngFrame
+	animated := false
+	seenIdat := false
+	for _, c := range cs {
+		switch c.typ {
+		case "IHDR":
+			ihdr = c.data
+		case "acTL":
+			animated = true
+		case "fcTL":
+			if len(c.data) != 26 {
+				return nil, fmt.Errorf("apng: fcTL length %v", len(c.data))
+			}
+			d := c.data
+			w, h := binary.BigEndian.Uint32(d[4:]), binary.BigEndian.Uint32(d[8:])
+			x, y := binary.BigEndian.Uint32(d[12:]), binary.BigEndian.Uint32(d[16:])
+			num, den := binary.BigEndian.Uint16(d[20:]), binary.BigEndian.Uint16(d[22:])
+			if den == 0 {
+				den = 100
+			}
+			frames = append(frames, &apngFrame{
+				r:       image.Rect(int(x), int(y), int(x+w), int(y+h)),
+				delay:   time.Duration(num) * time.Second / time.Duration(den),
+				dispose: d[24],
+				blend:   d[25],
+			})
+		case "IDAT":
+			// the default image is only a frame if fcTL precedes it
+			if len(frames) == 1 {
+				frames[0].idat = append(frames[0].idat, c.data)
+			}
+			seenIdat = true
+		case "fdAT":
+			if len(frames) == 0 || len(c.data) < 4 {
+				return nil, fmt.Errorf("apng: unexpected fdAT")
+			}
+			f := frames[len(frames)-1]
+			f.idat = append(f.idat, c.data[4:])
+		case "IEND":
+		default:
+			if !seenIdat {
+				shared = append(shared, c)
+			}
+		}
+	}
+	if !animated || len(ihdr) != 13 {
+		return nil, ErrNotAnimated
+	}
+	w, h := binary.BigEndian.Uint32(ihdr), binary.BigEndian.Uint32(ihdr[4:])
+	if w > maxPixels || h > maxPixels || tooLarge(int(w), int(h), len(frames)) {
+		return nil, ErrNotAnimated
+	}
+	c := newCompositor(int(w), int(h))
+	for i, f := range frames {
+		if len(f.idat) == 0 {
+			continue
+		}
+		if !f.r.In(c.canvas.Rect) {
+			return nil, fmt.Errorf("apng: frame %v out of bounds", i)
+		}
+		var b bytes.Buffer
+		b.Write(pngSig)
+		hdr := make([]byte, len(ihdr))
+		copy(hdr, ihdr)
+		binary.BigEndian.PutUint32(hdr, uint32(f.r.Dx()))
+		binary.BigEndian.PutUint32(hdr[4:], uint32(f.r.Dy()))
+		writePngChunk(&b, "IHDR", hdr)
+		for _, s := range shared {
+			writePngChunk(&b, s.typ, s.data)
+		}
+		writePngChunk(&b, "IDAT", bytes.Join(f.idat, nil))
+		writePngChunk(&b, "IEND", nil)
+		img, err := png.Decode(&b)
+		if err != nil {
+			return nil, fmt.Errorf("apng: frame %v: %w", i, err)
+		}
+		// dispose to previous on the first frame means to background
+		previous := f.dispose == 2 && len(c.a.Frames) > 0
+		c.frame(img, f.r, f.blend == 1, f.delay, f.dispose == 1 || f.dispose == 2 && !previous, previous)
+	}
+	return &c.a, nil
+}
+
+type riffChunk struct {
+	id   string
+	data []byte
+}
+
+func riffChunks(data []byte) (cs []riffChunk, err error) {
+	for len(data) >= 8 {
+		n := binary.LittleEndian.Uint32(data[4:])
+		if uint64(n)+8 > uint64(len(data)) {
+			return nil, fmt.Errorf("chunk too long")
+		}
+		cs = append(cs, riffChunk{id: string(data[:4]), data: data[8 : 8+n]})
+		data = data[8+n:]
+		if n%2 == 1 && len(data) > 0 {
+			data = data[1:]
+		}
+	}
+	return
+}
+
+func writeRiffChunk(b *bytes.Buffer, id string, data []byte) {
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(data)))
+	b.WriteString(id)
+	b.Write(n[:])
+	b.Write(data)
+	if len(data)%2 == 1 {
+		b.WriteByte(0)
+	}
+}
+
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// decodeWebp composites the ANMF frames of an animated WebP. Each frame
+// is decoded as a WebP file of its own.
+func decodeWebp(data []byte) (*Animation, error) {
+	cs, err := riffChunks(data[12:])
+	if err != nil {
+		return nil, fmt.Errorf("webp: %w", err)
+	}
+	if len(cs) == 0 || cs[0].id != "VP8X" || len(cs[0].data) < 10 || cs[0].data[0]&0x02 == 0 {
+		return nil, ErrNotAnimated
+	}
+	x := cs[0].data
+	n := 0
+	for _, ch := range cs[1:] {
+		if ch.id == "ANMF" {
+			n++
+		}
+	}
+	w, h := uint24(x[4:])+1, uint24(x[7:])+1
+	if tooLarge(w, h, n) {
+		return nil, ErrNotAnimated
+	}
+	c := newCompositor(w, h)
+	for _, ch := range cs[1:] {
+		if ch.id != "ANMF" {
+			continue
+		}
+		d := ch.data
+		if len(d) < 16 {
+			return nil, fmt.Errorf("webp: short ANMF")
+		}
+		fx, fy := 2*uint24(d), 2*uint24(d[3:])
+		fw, fh := uint24(d[6:])+1, uint24(d[9:])+1
+		r := image.Rect(fx, fy, fx+fw, fy+fh)
+		if !r.In(c.canvas.Rect) {
+			return nil, fmt.Errorf("webp: frame out of bounds")
+		}
+		delay := time.Duration(uint24(d[12:])) * time.Millisecond
+		flags := d[15]
+		sub, err := riffChunks(d[16:])
+		if err != nil {
+			return nil, fmt.Errorf("webp: frame: %w", err)
+		}
+		var body bytes.Buffer
+		for _, s := range sub {
+			if s.id == "ALPH" {
+				vp8x := make([]byte, 10)
+				vp8x[0] = 0x10
+				vp8x[4], vp8x[5], vp8x[6] = byte(fw-1), byte((fw-1)>>8), byte((fw-1)>>16)
+				vp8x[7], vp8x[8], vp8x[9] = byte(fh-1), byte((fh-1)>>8), byte((fh-1)>>16)
+				writeRiffChunk(&body, "VP8X", vp8x)
+				break
+			}
+		}
+		for _, s := range sub {
+			writeRiffChunk(&body, s.id, s.data)
+		}
+		var b bytes.Buffer
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(4+body.Len()))
+		b.WriteString("RIFF")
+		b.Write(n[:])
+		b.WriteString("WEBP")
+		b.Write(body.Bytes())
+		img, err := webp.Decode(&b)
+		if err != nil {
+			return nil, fmt.Errorf("webp: frame: %w", err)
+		}
+		c.frame(img, r, flags&0x02 == 0, delay, flags&0x01 == 1, false)
+	}
+	return &c.a, nil
+}
@@ -0,0 +1,285 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+var (
+	red  = color.RGBA{R: 0xff, A: 0xff}
+	blue = color.RGBA{B: 0xff, A: 0xff}
+)
+
+func TestDecodeGif(t *testing.T) {
+	pal := color.Palette{color.Transparent, red, blue}
+	g := &gif.GIF{
+		Config: image.Config{Width: 4, Height: 4, ColorModel: pal},
+	}
+	f0 := image.NewPaletted(image.Rect(0, 0, 4, 4), pal)
+	for i := range f0.Pix {
+		f0.Pix[i] = 1
+	}
+	f1 := image.NewPaletted(image.Rect(2, 2, 4, 4), pal)
+	for i := range f1.Pix {
+		f1.Pix[i] = 2
+	}
+	f2 := image.NewPaletted(image.Rect(0, 0, 1, 1), pal)
+	f2.Pix[0] = 2
+	g.Image = []*image.Paletted{f0, f1, f2}
+	g.Delay = []int{50, 0, 20}
+	g.Disposal = []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone}
+	var b bytes.Buffer
+	if err := gif.EncodeAll(&b, g); err != nil {
+		t.Fatal(err)
+	}
+	a, err := DecodeAnimation(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 3 || a.Delays[0] != 500*time.Millisecond || a.Delays[1] != 100*time.Millisecond {
+		t.Fatalf("%v %v", len(a.Frames), a.Delays)
+	}
+	if c := a.Frames[1].RGBAAt(3, 3); c != blue {
+		t.Errorf("%v", c)
+	}
+	if c := a.Frames[1].RGBAAt(0, 0); c != red {
+		t.Errorf("%v", c)
+	}
+	if c := a.Frames[2].RGBAAt(3, 3); c.A != 0 {
+		t.Errorf("not disposed: %v", c)
+	}
+	if c := a.Frames[2].RGBAAt(0, 0); c != blue {
+		t.Errorf("%v", c)
+	}
+}
+
+func TestDecodeStill(t *testing.T) {
+	var b bytes.Buffer
+	png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	if _, err := DecodeAnimation(b.Bytes()); err != ErrNotAnimated {
+		t.Errorf("%v", err)
+	}
+	if _, err := DecodeAnimation([]byte("<svg></svg>")); err != ErrNotAnimated {
+		t.Errorf("%v", err)
+	}
+}
+
+// apng encodes frames with their offsets, dispose and blend ops.
+func apng(t *testing.T, w, h int, frames []image.Image, ops [][2]byte) []byte {
+	var b bytes.Buffer
+	b.Write(pngSig)
+	seq := uint32(0)
+	for i, f := range frames {
+		var fb bytes.Buffer
+		if err := png.Encode(&fb, f); err != nil {
+			t.Fatal(err)
+		}
+		cs, err := pngChunks(fb.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			ihdr := make([]byte, 13)
+			copy(ihdr, cs[0].data)
+			binary.BigEndian.PutUint32(ihdr, uint32(w))
+			binary.BigEndian.PutUint32(ihdr[4:], uint32(h))
+			writePngChunk(&b, "IHDR", ihdr)
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl, uint32(len(frames)))
+			writePngChunk(&b, "acTL", actl)
+		}
+		r := f.Bounds()
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl, seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(r.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(r.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:], uint32(r.Min.X))
+		binary.BigEndian.PutUint32(fctl[16:], uint32(r.Min.Y))
+		binary.BigEndian.PutUint16(fctl[20:], 1)
+		binary.BigEndian.PutUint16(fctl[22:], 4)
+		fctl[24], fctl[25] = ops[i][0], ops[i][1]
+		seq++
+		writePngChunk(&b, "fcTL", fctl)
+		for _, c := range cs {
+			if c.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePngChunk(&b, "IDAT", c.data)
+			} else {
+				d := make([]byte, 4, 4+len(c.data))
+				binary.BigEndian.PutUint32(d, seq)
+				seq++
+				writePngChunk(&b, "fdAT", append(d, c.data...))
+			}
+		}
+	}
+	writePngChunk(&b, "IEND", nil)
+	return b.Bytes()
+}
+
+func fill(r image.Rectangle, c color.Color) *image.RGBA {
+	i := image.NewRGBA(r)
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i.Set(x, y, c)
+		}
+	}
+	return i
+}
+
+func TestDecodeApng(t *testing.T) {
+	// frames share the color type of the header only if all have
+	// transparent pixels
+	f0 := fill(image.Rect(0, 0, 4, 4), red)
+	f0.Set(1, 0, color.RGBA{})
+	f1 := fill(image.Rect(2, 2, 4, 4), blue)
+	f1.Set(2, 2, color.RGBA{})
+	data := apng(t, 4, 4, []image.Image{
+		f0,
+		f1,
+		fill(image.Rect(0, 0, 1, 1), color.RGBA{}),
+	}, [][2]byte{{0, 0}, {2, 0}, {0, 1}})
+	a, err := DecodeAnimation(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 3 || a.Delays[0] != 250*time.Millisecond {
+		t.Fatalf("%v %v", len(a.Frames), a.Delays)
+	}
+	if c := a.Frames[1].RGBAAt(3, 3); c != blue {
+		t.Errorf("%v", c)
+	}
+	if c := a.Frames[2].RGBAAt(3, 3); c != red {
+		t.Errorf("not restored: %v", c)
+	}
+	if c := a.Frames[2].RGBAAt(0, 0); c != red {
+		t.Errorf("transparent pixel not blended: %v", c)
+	}
+	if i, err := png.Decode(bytes.NewReader(data)); err != nil || i.Bounds().Dx() != 4 {
+		t.Errorf("default image: %v", err)
+	}
+}
+
+// bitWriter writes bits LSB first like VP8L.
+type bitWriter struct {
+	buf  []byte
+	nbit uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.nbit%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[len(w.buf)-1] |= byte((v>>i)&1) << (w.nbit % 8)
+		w.nbit++
+	}
+}
+
+// vp8l encodes a lossless image of a single color with one symbol
+// prefix codes.
+func vp8l(w, h int, c color.RGBA) []byte {
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	bw.write(1, 1) // alpha is used
+	bw.write(0, 3) // version
+	bw.write(0, 1) // no transform
+	bw.write(0, 1) // no color cache
+	bw.write(0, 1) // no meta prefix codes
+	for _, v := range []uint8{c.G, c.R, c.B, c.A, 0} {
+		bw.write(1, 1) // simple code
+		bw.write(0, 1) // one symbol
+		bw.write(1, 1) // 8 bit symbol
+		bw.write(uint32(v), 8)
+	}
+	return bw.buf
+}
+
+func TestDecodeWebp(t *testing.T) {
+	var body bytes.Buffer
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 | 0x10
+	vp8x[4], vp8x[7] = 3, 3
+	writeRiffChunk(&body, "VP8X", vp8x)
+	writeRiffChunk(&body, "ANIM", make([]byte, 6))
+	anmf := func(x, y, w, h, ms int, flags byte, c color.RGBA) {
+		var f bytes.Buffer
+		f.Write([]byte{byte(x / 2), 0, 0, byte(y / 2), 0, 0, byte(w - 1), 0, 0, byte(h - 1), 0, 0, byte(ms), byte(ms >> 8), 0, flags})
+		writeRiffChunk(&f, "VP8L", vp8l(w, h, c))
+		writeRiffChunk(&body, "ANMF", f.Bytes())
+	}
+	anmf(0, 0, 4, 4, 300, 0, red)
+	anmf(2, 2, 2, 2, 40, 0x01, blue)
+	anmf(0, 0, 1, 1, 5, 0x02, color.RGBA{})
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(4+body.Len()))
+	b.WriteString("WEBP")
+	b.Write(body.Bytes())
+
+	a, err := DecodeAnimation(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 3 || a.Delays[0] != 300*time.Millisecond || a.Delays[2] != 100*time.Millisecond {
+		t.Fatalf("%v %v", len(a.Frames), a.Delays)
+	}
+	if c := a.Frames[1].RGBAAt(3, 3); c != blue {
+		t.Errorf("%v", c)
+	}
+	if c := a.Frames[2].RGBAAt(3, 3); c.A != 0 {
+		t.Errorf("not disposed: %v", c)
+	}
+	if c := a.Frames[2].RGBAAt(0, 0); c.A != 0 {
+		t.Errorf("blended: %v", c)
+	}
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	// 500x500 with 100 frames exceeds maxPixels
+	pal := color.Palette{color.Transparent, red}
+	g := &gif.GIF{
+		Config: image.Config{Width: 500, Height: 500, ColorModel: pal},
+	}
+	for i := 0; i < 100; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), pal))
+		g.Delay = append(g.Delay, 10)
+	}
+	var b bytes.Buffer
+	if err := gif.EncodeAll(&b, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeAnimation(b.Bytes()); err != ErrNotAnimated {
+		t.Errorf("%v", err)
+	}
+	g.Image, g.Delay = g.Image[:2], g.Delay[:2]
+	b.Reset()
+	if err := gif.EncodeAll(&b, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeAnimation(b.Bytes()); err != nil {
+		t.Errorf("%v", err)
+	}
+
+	var body bytes.Buffer
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02
+	vp8x[4], vp8x[5], vp8x[6] = 0xff, 0xff, 0xff
+	vp8x[7], vp8x[8], vp8x[9] = 0xff, 0xff, 0xff
+	writeRiffChunk(&body, "VP8X", vp8x)
+	writeRiffChunk(&body, "ANMF", make([]byte, 16))
+	writeRiffChunk(&body, "ANMF", make([]byte, 16))
+	data := append([]byte("RIFF\x00\x00\x00\x00WEBP"), body.Bytes()...)
+	if _, err := DecodeAnimation(data); err != ErrNotAnimated {
+		t.Errorf("webp: %v", err)
+	}
+}
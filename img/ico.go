@@ -0,0 +1,178 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+)
+
+func init() {
+	image.RegisterFormat("ico", "\x00\x00\x01\x00", decodeIco, decodeIcoConfig)
+}
+
+// icoEntry is an image of an ICO file.
+type icoEntry struct {
+	w, h int
+	bpp  int
+	data []byte
+}
+
+// icoLargest returns the largest image of the ICO file data.
+func icoLargest(data []byte) (e icoEntry, err error) {
+	if len(data) < 6 {
+		return e, fmt.Errorf("ico: short header")
+	}
+	n := int(binary.LittleEndian.Uint16(data[4:]))
+	if n == 0 || len(data) < 6+16*n {
+		return e, fmt.Errorf("ico: %v entries", n)
+	}
+	for i := 0; i < n; i++ {
+		d := data[6+16*i:]
+		c := icoEntry{
+			w:   int(d[0]),
+			h:   int(d[1]),
+			bpp: int(binary.LittleEndian.Uint16(d[6:])),
+		}
+		if c.w == 0 {
+			c.w = 256
+		}
+		if c.h == 0 {
+			c.h = 256
+		}
+		size, off := binary.LittleEndian.Uint32(d[8:]), binary.LittleEndian.Uint32(d[12:])
+		if uint64(off)+uint64(size) > uint64(len(data)) {
+			return e, fmt.Errorf("ico: entry %v out of bounds", i)
+		}
+		c.data = data[off : off+size]
+		if e.data == nil || c.w*c.h > e.w*e.h || c.w*c.h == e.w*e.h && c.bpp > e.bpp {
+			e = c
+		}
+	}
+	return
+}
+
+func decodeIcoConfig(r io.Reader) (image.Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	e, err := icoLargest(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if bytes.HasPrefix(e.data, pngSig) {
+		return png.DecodeConfig(bytes.NewReader(e.data))
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: e.w, Height: e.h}, nil
+}
+
+// decodeIco decodes the largest image of an ICO file. Images are
+// either PNGs or device-independent bitmaps with an AND mask.
+func decodeIco(r io.Reader) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	e, err := icoLargest(data)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(e.data, pngSig) {
+		return png.Decode(bytes.NewReader(e.data))
+	}
+	return decodeDib(e.data)
+}
+
+// decodeDib decodes an uncompressed bitmap without file header whose
+// height includes the AND mask below the pixels.
+func decodeDib(d []byte) (image.Image, error) {
+	if len(d) < 40 {
+		return nil, fmt.Errorf("dib: short header")
+	}
+	hdrLen := int(binary.LittleEndian.Uint32(d))
+	w := int(int32(binary.LittleEndian.Uint32(d[4:])))
+	h := int(int32(binary.LittleEndian.Uint32(d[8:]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(d[14:]))
+	compression := binary.LittleEndian.Uint32(d[16:])
+	nColors := int(binary.LittleEndian.Uint32(d[32:]))
+	if hdrLen < 40 || hdrLen > len(d) {
+		return nil, fmt.Errorf("dib: header length %v", hdrLen)
+	}
+	if w <= 0 || h <= 0 || w > 1024 || h > 1024 || compression != 0 {
+		return nil, fmt.Errorf("dib: unsupported %vx%v compression %v", w, h, compression)
+	}
+	switch bpp {
+	case 1, 4, 8, 24, 32:
+	default:
+		return nil, fmt.Errorf("dib: %v bits per pixel", bpp)
+	}
+	var pal []color.NRGBA
+	if bpp <= 8 {
+		if nColors == 0 {
+			nColors = 1 << bpp
+		}
+		p := d[hdrLen:]
+		if nColors > 1<<bpp {
+			return nil, fmt.Errorf("dib: %v colors", nColors)
+		}
+		if len(p) < 4*nColors {
+			return nil, fmt.Errorf("dib: short palette")
+		}
+		for i := 0; i < nColors; i++ {
+			pal = append(pal, color.NRGBA{R: p[4*i+2], G: p[4*i+1], B: p[4*i], A: 0xff})
+		}
+	}
+	stride := (w*bpp + 31) / 32 * 4
+	maskStride := (w + 31) / 32 * 4
+	pix := d[hdrLen+4*len(pal):]
+	if len(pix) < stride*h {
+		return nil, fmt.Errorf("dib: short pixel data")
+	}
+	mask := pix[stride*h:]
+	hasMask := len(mask) >= maskStride*h
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	hasAlpha := false
+	for y := 0; y < h; y++ {
+		row := pix[(h-1-y)*stride:]
+		for x := 0; x < w; x++ {
+			var c color.NRGBA
+			switch bpp {
+			case 32:
+				c = color.NRGBA{R: row[4*x+2], G: row[4*x+1], B: row[4*x], A: row[4*x+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{R: row[3*x+2], G: row[3*x+1], B: row[3*x], A: 0xff}
+			default:
+				bit := x * bpp
+				i := int(row[bit/8]>>(8-bpp-bit%8)) & (1<<bpp - 1)
+				if i < len(pal) {
+					c = pal[i]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	// 32 bit images use the mask only if they have no alpha channel
+	if bpp == 32 && hasAlpha || !hasMask {
+		return img, nil
+	}
+	for y := 0; y < h; y++ {
+		row := mask[(h-1-y)*maskStride:]
+		for x := 0; x < w; x++ {
+			c := img.NRGBAAt(x, y)
+			if row[x/8]>>(7-x%8)&1 == 1 {
+				c.A = 0
+			} else {
+				c.A = 0xff
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
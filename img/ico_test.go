@@ -0,0 +1,104 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// ico encodes entries of the given sizes and data.
+func ico(sizes []int, bpps []int, entries [][]byte) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0, 0, 1, 0})
+	binary.Write(&b, binary.LittleEndian, uint16(len(entries)))
+	off := 6 + 16*len(entries)
+	for i, e := range entries {
+		b.Write([]byte{byte(sizes[i]), byte(sizes[i]), 0, 0, 1, 0})
+		binary.Write(&b, binary.LittleEndian, uint16(bpps[i]))
+		binary.Write(&b, binary.LittleEndian, uint32(len(e)))
+		binary.Write(&b, binary.LittleEndian, uint32(off))
+		off += len(e)
+	}
+	for _, e := range entries {
+		b.Write(e)
+	}
+	return b.Bytes()
+}
+
+// dib encodes a bitmap header, palette, pixel rows and AND mask.
+func dib(w, h, bpp int, pal []byte, pix, mask []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(40))
+	binary.Write(&b, binary.LittleEndian, int32(w))
+	binary.Write(&b, binary.LittleEndian, int32(2*h))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint16(bpp))
+	b.Write(make([]byte, 24))
+	b.Write(pal)
+	b.Write(pix)
+	b.Write(mask)
+	return b.Bytes()
+}
+
+func TestDecodeIco(t *testing.T) {
+	// 2x2 with 1 bpp: bottom row first, rows padded to 4 bytes
+	pal := []byte{0, 0, 0xff, 0, 0xff, 0, 0, 0}
+	pix := []byte{0x40, 0, 0, 0, 0x80, 0, 0, 0}
+	mask := []byte{0x40, 0, 0, 0, 0, 0, 0, 0}
+	small := dib(2, 2, 1, pal, pix, mask)
+
+	// 4x4 with 32 bpp and alpha
+	pix32 := make([]byte, 4*4*4)
+	for i := 0; i < len(pix32); i += 4 {
+		pix32[i], pix32[i+3] = 0xff, 0xff
+	}
+	large := dib(4, 4, 32, nil, pix32, make([]byte, 4*4))
+
+	i, f, err := image.Decode(bytes.NewReader(ico([]int{2, 4}, []int{1, 32}, [][]byte{small, large})))
+	if err != nil || f != "ico" {
+		t.Fatalf("%v %v", f, err)
+	}
+	if i.Bounds().Dx() != 4 {
+		t.Fatalf("%v", i.Bounds())
+	}
+	if c := color.RGBAModel.Convert(i.At(1, 1)); c != blue {
+		t.Errorf("%v", c)
+	}
+
+	i, _, err = image.Decode(bytes.NewReader(ico([]int{2}, []int{1}, [][]byte{small})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.RGBAModel.Convert(i.At(0, 0)); c != blue {
+		t.Errorf("%v", c)
+	}
+	if c := color.RGBAModel.Convert(i.At(1, 0)); c != red {
+		t.Errorf("%v", c)
+	}
+	if c := color.RGBAModel.Convert(i.At(1, 1)); c.(color.RGBA).A != 0 {
+		t.Errorf("not masked: %v", c)
+	}
+
+	var p bytes.Buffer
+	png.Encode(&p, fill(image.Rect(0, 0, 3, 3), red))
+	cfg, f, err := image.DecodeConfig(bytes.NewReader(ico([]int{3}, []int{32}, [][]byte{p.Bytes()})))
+	if err != nil || f != "ico" || cfg.Width != 3 {
+		t.Errorf("%+v %v %v", cfg, f, err)
+	}
+}
+
+func TestDecodeIcoInvalid(t *testing.T) {
+	hdr := dib(2, 2, 1, nil, nil, nil)
+	long := append([]byte{}, hdr...)
+	binary.LittleEndian.PutUint32(long, 0xffff)
+	colors := append([]byte{}, hdr...)
+	binary.LittleEndian.PutUint32(colors[32:], 0xffffffff)
+	for _, d := range [][]byte{long, colors, hdr[:20]} {
+		if _, _, err := image.Decode(bytes.NewReader(ico([]int{2}, []int{1}, [][]byte{d}))); err == nil {
+			t.Errorf("no error for %x", d)
+		}
+	}
+}
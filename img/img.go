@@ -13,9 +13,13 @@ import (
 	xdraw "golang.org/x/image/draw"
 	"image"
 	imagedraw "image/draw"
+	"math"
 	"net/url"
 	"strings"
+	"time"
 
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 	_ "image/gif"
 	_ "image/jpeg"
@@ -24,7 +28,11 @@ import (
 
 const SrcZero = "//:0"
 
-func parseDataUri(addr string) (data []byte, ct opossum.ContentType, err error) {
+// Accept lists the image formats that can be decoded for the Accept
+// header of image requests.
+const Accept = "image/webp,image/apng,image/png,image/jpeg,image/gif,image/bmp,image/tiff,image/x-icon,image/svg+xml,image/*;q=0.8,*/*;q=0.5"
+
+func ParseDataUri(addr string) (data []byte, ct opossum.ContentType, err error) {
 	addr = strings.TrimPrefix(addr, "data:")
 	if strings.Contains(addr, "charset=UTF-8") {
 		return nil, ct, fmt.Errorf("cannot handle charset")
@@ -152,9 +160,9 @@ func quoteAttrs(s string) string {
 }
 
 // Svg returns the svg+xml with the sizing defined in
-// viewbox unless w and h != 0
+// viewbox in device pixels unless w or h != 0
 func Svg(dui *duit.DUI, data string, w, h int) (ni *draw.Image, err error) {
-	rgba, err := svg(data, w, h)
+	rgba, err := svg(data, w, h, float64(dui.Scale(1)))
 	if err != nil {
 		return nil, err
 	}
@@ -169,7 +177,10 @@ func Svg(dui *duit.DUI, data string, w, h int) (ni *draw.Image, err error) {
 	return
 }
 
-func svg(data string, w, h int) (img *image.RGBA, err error) {
+// svg renders data to w x h. If only one of them is set, the aspect
+// ratio of the viewbox is preserved. Without both the viewbox size is
+// multiplied by sc.
+func svg(data string, w, h int, sc float64) (img *image.RGBA, err error) {
 	data = strings.ReplaceAll(data, "currentColor", "black")
 	data = strings.ReplaceAll(data, "inherit", "black")
 	data = quoteAttrs(data)
@@ -180,9 +191,18 @@ func svg(data string, w, h int) (img *image.RGBA, err error) {
 		return nil, fmt.Errorf("read icon stream: %w", err)
 	}
 
-	if w == 0 || h == 0 {
-		w = int(icon.ViewBox.W)
-		h = int(icon.ViewBox.H)
+	vw, vh := int(icon.ViewBox.W), int(icon.ViewBox.H)
+	if w == 0 && h == 0 {
+		if sc <= 0 {
+			sc = 1
+		}
+		w = int(math.Round(icon.ViewBox.W * sc))
+		h = int(math.Round(icon.ViewBox.H * sc))
+	} else if w == 0 || h == 0 {
+		w, h, _ = newSizes(vw, vh, w, h)
+	}
+	if w <= 0 || h <= 0 || w*h > maxPixels {
+		return nil, fmt.Errorf("size %vx%v", w, h)
 	}
 
 	icon.SetTarget(0, 0, float64(w), float64(h))
@@ -192,16 +212,46 @@ func svg(data string, w, h int) (img *image.RGBA, err error) {
 	return rgba, nil
 }
 
-// Load and resize to w and h if != 0
-func Load(dui *duit.DUI, f opossum.Fetcher, src string, maxW, w, h int, forceSync bool) (ni *draw.Image, err error) {
-	log.Printf("Load(..., %v, maxW=%v, w=%v, h=%v, ...)", src, maxW, w, h)
+// Options to size images. All sizes are in device pixels.
+type Options struct {
+	// MaxW limits the width if W and H are 0
+	MaxW int
+
+	// W and H are the size of the box the image is shown in. If
+	// only one of them is set the aspect ratio is preserved.
+	W, H int
+
+	// Scale converts intrinsic image pixels to device pixels,
+	// e.g. 2 on HiDPI displays for images without srcset density.
+	// 0 is the same as 1.
+	Scale float64
+
+	Fit Fit
+}
+
+// Fit is how the image is placed inside a box of W x H (CSS object-fit
+// and object-position).
+type Fit struct {
+	// Mode is fill, contain, cover, none or scale-down. Empty keeps
+	// the aspect ratio of the image based on the width.
+	Mode string
+
+	// X, Y position the image relative to the remaining space in
+	// percent plus OffX, OffY in device pixels.
+	X, Y       float64
+	OffX, OffY int
+}
+
+// Load and resize according to o
+func Load(dui *duit.DUI, f opossum.Fetcher, src string, o Options, forceSync bool) (ni *draw.Image, err error) {
+	log.Printf("Load(..., %v, %+v, ...)", src, o)
 	ch := make(chan image.Image, 1)
 	var bounds draw.Rectangle
-	if w != 0 && h != 0 && !forceSync {
-		bounds = draw.Rect(0, 0, w, h)
+	if o.W != 0 && o.H != 0 && !forceSync {
+		bounds = draw.Rect(0, 0, o.W, o.H)
 		go func() {
 			log.Printf("load async %v...", src)
-			drawImg, err := load(f, src, maxW, w, h)
+			drawImg, err := load(f, src, o)
 			if err != nil {
 				log.Errorf("load %v: %v", src, err)
 				close(ch)
@@ -211,7 +261,7 @@ func Load(dui *duit.DUI, f opossum.Fetcher, src string, maxW, w, h int, forceSyn
 			log.Printf("loaded async %v", src)
 		}()
 	} else {
-		drawImg, err := load(f, src, maxW, w, h)
+		drawImg, err := load(f, src, o)
 		if err != nil {
 			return nil, err
 		}
@@ -231,18 +281,7 @@ func Load(dui *duit.DUI, f opossum.Fetcher, src string, maxW, w, h int, forceSyn
 			return
 		}
 		dui.Call <- func() {
-			// Stolen from duit.ReadImage
-			var rgba *image.RGBA
-			switch i := drawImg.(type) {
-			case *image.RGBA:
-				rgba = i
-			default:
-				b := drawImg.Bounds()
-				rgba = image.NewRGBA(image.Rectangle{image.ZP, b.Size()})
-				imagedraw.Draw(rgba, rgba.Bounds(), drawImg, b.Min, imagedraw.Src)
-			}
-			_, err = ni.Load(rgba.Bounds(), rgba.Pix)
-			if err != nil {
+			if err := loadPix(ni, drawImg); err != nil {
 				log.Errorf("load image: %s", err)
 			}
 			log.Printf("copied image %v", src)
@@ -251,77 +290,224 @@ func Load(dui *duit.DUI, f opossum.Fetcher, src string, maxW, w, h int, forceSyn
 	return
 }
 
-func load(f opossum.Fetcher, src string, maxW, w, h int) (img image.Image, err error) {
-	var imgUrl *url.URL
-	var data []byte
-	var contentType opossum.ContentType
+// loadPix copies the pixels of img to ni.
+func loadPix(ni *draw.Image, img image.Image) (err error) {
+	// Stolen from duit.ReadImage
+	var rgba *image.RGBA
+	switch i := img.(type) {
+	case *image.RGBA:
+		rgba = i
+	default:
+		b := img.Bounds()
+		rgba = image.NewRGBA(image.Rectangle{image.ZP, b.Size()})
+		imagedraw.Draw(rgba, rgba.Bounds(), img, b.Min, imagedraw.Src)
+	}
+	_, err = ni.Load(rgba.Bounds(), rgba.Pix)
+	return
+}
+
+// Frames are the images of an animation and how long each of them is
+// shown. Still images have a single frame.
+type Frames struct {
+	Images []*draw.Image
+	Delays []time.Duration
+}
 
-	if strings.HasPrefix(src, "data:") {
-		if data, contentType, err = parseDataUri(src); err != nil {
-			return nil, fmt.Errorf("parse data uri %v: %w", src, err)
+// LoadFrames is like Load with forceSync but loads all frames of
+// animated GIF, PNG and WebP images.
+func LoadFrames(dui *duit.DUI, f opossum.Fetcher, src string, o Options) (fr *Frames, err error) {
+	data, contentType, err := fetch(f, src)
+	if err != nil {
+		return nil, err
+	}
+	if contentType.IsSvg() {
+		return loadFrame(dui, f, src, o)
+	}
+	a, err := DecodeAnimation(data)
+	if err != nil {
+		if err != ErrNotAnimated {
+			log.Errorf("decode animation %v: %v", src, err)
 		}
-	} else {
-		if imgUrl, err = f.LinkedUrl(src); err != nil {
-			return nil, err
+		return loadFrame(dui, f, src, o)
+	}
+	fr = &Frames{Delays: a.Delays}
+	imgs := make([]image.Image, len(a.Frames))
+	for i, frame := range a.Frames {
+		imgs[i] = resize(frame, o)
+		ni, err := dui.Display.AllocImage(imgs[i].Bounds(), draw.ABGR32, false, draw.White)
+		if err != nil {
+			return nil, fmt.Errorf("allocimage: %s", err)
+		}
+		fr.Images = append(fr.Images, ni)
+	}
+	dui.Call <- func() {
+		for i, ni := range fr.Images {
+			if err := loadPix(ni, imgs[i]); err != nil {
+				log.Errorf("load frame: %s", err)
+			}
 		}
-		if data, contentType, err = f.Get(imgUrl); err != nil {
-			return nil, fmt.Errorf("get %v: %w", imgUrl, err)
+	}
+	return
+}
+
+func loadFrame(dui *duit.DUI, f opossum.Fetcher, src string, o Options) (*Frames, error) {
+	ni, err := Load(dui, f, src, o, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Frames{Images: []*draw.Image{ni}}, nil
+}
+
+// Decode fetches src and decodes it in its intrinsic size.
+func Decode(f opossum.Fetcher, src string) (image.Image, error) {
+	return load(f, src, Options{})
+}
+
+func fetch(f opossum.Fetcher, src string) (data []byte, contentType opossum.ContentType, err error) {
+	if strings.HasPrefix(src, "data:") {
+		if data, contentType, err = ParseDataUri(src); err != nil {
+			return nil, contentType, fmt.Errorf("parse data uri %v: %w", src, err)
 		}
+		return
+	}
+	imgUrl, err := f.LinkedUrl(src)
+	if err != nil {
+		return nil, contentType, err
+	}
+	if data, contentType, err = f.Get(imgUrl); err != nil {
+		return nil, contentType, fmt.Errorf("get %v: %w", imgUrl, err)
+	}
+	return
+}
+
+func load(f opossum.Fetcher, src string, o Options) (img image.Image, err error) {
+	data, contentType, err := fetch(f, src)
+	if err != nil {
+		return nil, err
 	}
 
 	if contentType.IsSvg() {
-		img, err := svg(string(data), w, h)
+		img, err := svg(string(data), o.W, o.H, o.Scale)
 		if err != nil {
 			return nil, fmt.Errorf("svg: %v", err)
 		}
 		return img, nil
-	} else {
-		img, _, err = image.Decode(bytes.NewReader(data))
-		if err != nil {
-			return nil, fmt.Errorf("decode %v: %w", imgUrl, err)
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && tooLarge(cfg.Width, cfg.Height, 1) {
+		return nil, fmt.Errorf("decode %v: %vx%v too large", src, cfg.Width, cfg.Height)
+	}
+	img, _, err = image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode %v: %w", src, err)
+	}
+	return resize(img, o), nil
+}
+
+// maxPixels limits the size of resized images.
+const maxPixels = 4096 * 4096
+
+// resize img to the size of o. Images wider than MaxW are shrunk.
+func resize(img image.Image, o Options) image.Image {
+	dx := img.Bounds().Dx()
+	dy := img.Bounds().Dy()
+	w, h := o.W, o.H
+	log.Printf("dx,dy=%v,%v", dx, dy)
+	if w == 0 && h == 0 {
+		w = dx
+		if o.Scale > 0 {
+			w = int(math.Round(float64(dx) * o.Scale))
 		}
-		if maxW != 0 || w != 0 || h != 0 {
-			dx := img.Bounds().Max.X
-			dy := img.Bounds().Max.Y
-			log.Printf("dx,dy=%v,%v", dx, dy)
-			if w == 0 && h == 0 && 0 < maxW && maxW < dx {
-				w = maxW
-			}
+		if 0 < o.MaxW && o.MaxW < w {
+			w = o.MaxW
+		}
+	}
+	if o.Fit.Mode != "" && w > 0 && h > 0 {
+		return fit(img, w, h, o.Scale, o.Fit)
+	}
 
-			newX, newY, skip := newSizes(dx, dy, w, h)
+	newX, newY, skip := newSizes(dx, dy, w, h)
 
-			if !skip {
-				log.Printf("resize image to %v x %v", newX, newY)
-				dst := image.NewRGBA(image.Rect(0, 0, newX, newY))
-				xdraw.NearestNeighbor.Scale(dst, dst.Rect, img, img.Bounds(), xdraw.Over, nil)
-				img = dst
-			} else {
-				log.Printf("skip resizing")
-			}
-		}
+	if skip {
+		log.Printf("skip resizing")
+		return img
+	}
+	log.Printf("resize image to %v x %v", newX, newY)
+	return scale(img, newX, newY)
+}
+
+// scale img to w x h. Catmull-Rom is used for shrinking to avoid
+// aliasing and the faster bilinear approximation for enlarging where
+// both look about the same.
+func scale(img image.Image, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	var s xdraw.Scaler = xdraw.ApproxBiLinear
+	if w < img.Bounds().Dx() || h < img.Bounds().Dy() {
+		s = xdraw.CatmullRom
 	}
+	s.Scale(dst, dst.Rect, img, img.Bounds(), xdraw.Src, nil)
+	return dst
+}
 
-	return img, nil
+// fit img into a box of w x h according to the object-fit mode and
+// position of f. sc converts intrinsic pixels into device pixels.
+func fit(img image.Image, w, h int, sc float64, f Fit) image.Image {
+	if sc <= 0 {
+		sc = 1
+	}
+	dx := float64(img.Bounds().Dx())
+	dy := float64(img.Bounds().Dy())
+	if dx == 0 || dy == 0 {
+		return img
+	}
+	contain := math.Min(float64(w)/dx, float64(h)/dy)
+	var r float64
+	switch f.Mode {
+	case "contain":
+		r = contain
+	case "cover":
+		r = math.Max(float64(w)/dx, float64(h)/dy)
+	case "none":
+		r = sc
+	case "scale-down":
+		r = math.Min(sc, contain)
+	default:
+		if w*h > maxPixels {
+			return img
+		}
+		return scale(img, w, h)
+	}
+	sw := int(math.Round(dx * r))
+	sh := int(math.Round(dy * r))
+	if sw == 0 || sh == 0 || sw*sh > maxPixels {
+		return img
+	}
+	var scaled image.Image = img
+	if sw != int(dx) || sh != int(dy) {
+		scaled = scale(img, sw, sh)
+	}
+	x := int(math.Round(float64(w-sw)*f.X/100)) + f.OffX
+	y := int(math.Round(float64(h-sh)*f.Y/100)) + f.OffY
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	imagedraw.Draw(dst, image.Rect(x, y, x+sw, y+sh), scaled, scaled.Bounds().Min, imagedraw.Src)
+	return dst
 }
 
+// newSizes returns the size to scale oldX x oldY to. If only one of
+// wantedX and wantedY is set, the aspect ratio is preserved. Otherwise
+// it is based on wantedX.
 func newSizes(oldX, oldY, wantedX, wantedY int) (newX, newY int, skip bool) {
 	if oldX == 0 || oldY == 0 || (wantedX == 0 && wantedY == 0) {
 		return oldX, oldY, true
 	}
 	if wantedX == 0 {
-		newX = int(float64(oldX) * float64(wantedY) / float64(oldY))
+		newX = int(math.Round(float64(oldX) * float64(wantedY) / float64(oldY)))
 		newY = wantedY
 	} else {
 		newX = wantedX
-		newY = int(float64(oldY) * float64(wantedX) / float64(oldX))
-	}
-
-	if newX > 2000 || newY > 2000 {
-		return oldX, oldY, true
+		newY = int(math.Round(float64(oldY) * float64(wantedX) / float64(oldX)))
 	}
 
-	r := float64(newX) / float64(oldX)
-	if 0.8 <= r && r <= 1.2 {
+	if newX <= 0 || newY <= 0 || newX*newY > maxPixels || newX == oldX && newY == oldY {
 		return oldX, oldY, true
 	}
 
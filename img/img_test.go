@@ -6,6 +6,7 @@ import (
 	"github.com/psilva261/opossum"
 	"github.com/psilva261/opossum/logger"
 	"image"
+	"image/color"
 	"image/png"
 	"net/url"
 	"testing"
@@ -27,7 +28,7 @@ func TestParseDataUri(t *testing.T) {
 	}
 
 	for _, src := range srcs {
-		data, _, err := parseDataUri(src)
+		data, _, err := ParseDataUri(src)
 		if err != nil {
 			t.Fatalf(err.Error())
 		}
@@ -37,7 +38,7 @@ func TestParseDataUri(t *testing.T) {
 
 func TestEmpty(t *testing.T) {
 	src := "data:image/svg+xml"
-	_, _, err := parseDataUri(src)
+	_, _, err := ParseDataUri(src)
 	if err == nil {
 		t.Fatalf(err.Error())
 	}
@@ -56,7 +57,7 @@ func TestSvg(t *testing.T) {
 	}
 
 	for _, xml := range xmls {
-		_, err := svg(xml, 0, 0)
+		_, err := svg(xml, 0, 0, 1)
 		if err != nil {
 			t.Fatalf(err.Error())
 		}
@@ -72,7 +73,7 @@ func TestSvgUnquoted(t *testing.T) {
        `
 	xml = `<svg xmlns=http://www.w3.org/2000/svg viewBox=0 0 37 37 fill=#000000><path class=border fill=blue stroke=green/></svg>`
 
-	_, err := svg(xml, 0, 0)
+	_, err := svg(xml, 0, 0, 1)
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -125,7 +126,7 @@ func TestLoad(t *testing.T) {
 			t.Fail()
 		}
 		b := &MockBrowser{buf.Bytes()}
-		img, err := load(b, "", mw, w, h)
+		img, err := load(b, "", Options{MaxW: mw, W: w, H: h})
 		if err != nil {
 			t.Errorf("load: %v", err)
 		}
@@ -157,3 +158,73 @@ func TestNewSizes(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestResizeScale(t *testing.T) {
+	i := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	tests := []struct {
+		o    Options
+		x, y int
+	}{
+		{Options{Scale: 2}, 200, 100},
+		{Options{Scale: 2, MaxW: 150}, 150, 75},
+		{Options{Scale: 2, H: 25}, 50, 25},
+		{Options{W: 90}, 90, 45},
+		{Options{}, 100, 50},
+	}
+	for _, tt := range tests {
+		r := resize(i, tt.o).Bounds()
+		if r.Dx() != tt.x || r.Dy() != tt.y {
+			t.Errorf("%+v: %v", tt.o, r)
+		}
+	}
+}
+
+func TestScaleSmooth(t *testing.T) {
+	// stripes of 1px black and white are averaged when shrinking
+	i := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			if x%2 == 0 {
+				i.Set(x, y, color.White)
+			} else {
+				i.Set(x, y, color.Black)
+			}
+		}
+	}
+	c := scale(i, 16, 16).RGBAAt(8, 8)
+	if c.R < 0x40 || c.R > 0xc0 {
+		t.Errorf("%v", c)
+	}
+}
+
+func TestFit(t *testing.T) {
+	i := fill(image.Rect(0, 0, 40, 20), red)
+	tests := []struct {
+		f      Fit
+		opaque []image.Point
+		clear  []image.Point
+	}{
+		{Fit{Mode: "fill"}, []image.Point{{0, 0}, {19, 19}}, nil},
+		{Fit{Mode: "contain", X: 50, Y: 50}, []image.Point{{0, 5}, {19, 14}}, []image.Point{{0, 0}, {19, 19}}},
+		{Fit{Mode: "contain", X: 0, Y: 100}, []image.Point{{0, 19}}, []image.Point{{0, 0}}},
+		{Fit{Mode: "cover", X: 50, Y: 50}, []image.Point{{0, 0}, {19, 19}}, nil},
+		{Fit{Mode: "none", X: 50, Y: 50}, []image.Point{{0, 0}, {19, 19}}, nil},
+		{Fit{Mode: "scale-down", X: 0, Y: 0, OffY: 2}, []image.Point{{0, 2}}, []image.Point{{0, 0}, {0, 19}}},
+	}
+	for _, tt := range tests {
+		res := resize(i, Options{W: 20, H: 20, Fit: tt.f})
+		if r := res.Bounds(); r.Dx() != 20 || r.Dy() != 20 {
+			t.Fatalf("%+v: %v", tt.f, r)
+		}
+		for _, p := range tt.opaque {
+			if _, _, _, a := res.At(p.X, p.Y).RGBA(); a == 0 {
+				t.Errorf("%+v: %v transparent", tt.f, p)
+			}
+		}
+		for _, p := range tt.clear {
+			if _, _, _, a := res.At(p.X, p.Y).RGBA(); a != 0 {
+				t.Errorf("%+v: %v opaque", tt.f, p)
+			}
+		}
+	}
+}
@@ -18,6 +18,12 @@ type Node struct {
 	Rectangular
 	Children []*Node
 	parent   *Node `json:"-"`
+
+	// PseudoElement is before, after or marker for generated content.
+	PseudoElement string
+
+	// counters before the element, nil if there were none
+	counters *style.Counters
 }
 
 type Rectangular interface {
@@ -28,6 +34,12 @@ type Rectangular interface {
 //
 // First applies the parent style and at the end the local style attribute's style is attached.
 func NewNodeTree(doc *html.Node, ps style.Map, nodeMap map[*html.Node]style.Map, parent *Node) (n *Node) {
+	n = newNodeTree(doc, ps, nodeMap, parent, style.NewCounters())
+	CollapseWhiteSpace(n)
+	return
+}
+
+func newNodeTree(doc *html.Node, ps style.Map, nodeMap map[*html.Node]style.Map, parent *Node, cnt *style.Counters) (n *Node) {
 	ncs := style.Map{
 		Declarations: make(map[string]style.Declaration),
 	}
@@ -62,21 +74,97 @@ func NewNodeTree(doc *html.Node, ps style.Map, nodeMap map[*html.Node]style.Map,
 			Val:  "inline",
 		}
 	}
+	var pes map[string]style.Map
+	if doc.Type == html.ElementNode {
+		if !cnt.Empty() {
+			n.counters = cnt.Clone()
+		}
+		cnt.Update(ncs)
+		cnt.UpdateList(doc, ncs)
+		pes = nodeMap[doc].PseudoElements
+	}
+	cnt.Enter()
+	if data == "li" || ncs.Css("display") == "list-item" {
+		if pm, ok := pes["marker"]; ok && pm.Css("content") != "" {
+			n.addPseudoElement("marker", pes, cnt)
+		} else {
+			n.addListMarker(pm, cnt)
+		}
+	}
+	n.addPseudoElement("before", pes, cnt)
 	i := 0
 	for c := doc.FirstChild; c != nil; c = c.NextSibling {
-		if c.Type == html.CommentNode || (c.Type == html.TextNode && strings.TrimSpace(c.Data) == "") {
+		if c.Type == html.CommentNode || (c.Type == html.TextNode && c.Data == "") {
 			continue
 		}
-		cnt := NewNodeTree(c, ncs, nodeMap, n)
-		n.Children = append(n.Children, cnt)
+		cn := newNodeTree(c, ncs, nodeMap, n, cnt)
+		n.Children = append(n.Children, cn)
 		i++
 	}
+	n.addPseudoElement("after", pes, cnt)
+	cnt.Leave()
 	n.Map.DomTree = n
 
 	return
 }
 
-// filterText removes line break runes (TODO: add this later but handle properly)
+// addPseudoElement appends the generated content of pseudo-element pe
+// as text node.
+func (n *Node) addPseudoElement(pe string, pes map[string]style.Map, cnt *style.Counters) {
+	pm, ok := pes[pe]
+	if !ok {
+		return
+	}
+	ncs := n.Map.ApplyChildStyle(pm, false)
+	if ncs.IsDisplayNone() {
+		return
+	}
+	cnt.Update(ncs)
+	t, ok := ncs.Content(n.DomSubtree, cnt)
+	if !ok || t == "" {
+		return
+	}
+	if _, ok := ncs.Declarations["display"]; !ok || pe == "marker" {
+		ncs.SetCss("display", "inline")
+	}
+	n.addGenerated(pe, t, ncs)
+}
+
+// addListMarker appends the marker generated from list-style-type with
+// the style pm of ::marker.
+func (n *Node) addListMarker(pm style.Map, cnt *style.Counters) {
+	ncs := n.Map.ApplyChildStyle(pm, false)
+	if ncs.IsDisplayNone() {
+		return
+	}
+	t, ok := ncs.ListMarker(cnt.Value("list-item"))
+	if !ok || t == "" {
+		return
+	}
+	ncs.SetCss("display", "inline")
+	n.addGenerated("marker", t, ncs)
+}
+
+// addGenerated appends the text t of pseudo-element pe as text node.
+func (n *Node) addGenerated(pe, t string, ncs style.Map) {
+	c := &Node{
+		DomSubtree: &html.Node{
+			Type:   html.TextNode,
+			Data:   t,
+			Parent: n.DomSubtree,
+		},
+		Text:          filterText(t),
+		Wrappable:     true,
+		Map:           ncs,
+		parent:        n,
+		PseudoElement: pe,
+	}
+	c.Map.DomTree = c
+	n.Children = append(n.Children, c)
+}
+
+// filterText removes soft hyphens and expands tabs. Other white space
+// is processed by CollapseWhiteSpace.
 func filterText(t string) string {
 	t = strings.ReplaceAll(t, "­", "")
 	t = strings.ReplaceAll(t, "\t", "    ")
@@ -178,9 +266,14 @@ func (n *Node) HasAttr(k string) bool {
 
 // https://developer.mozilla.org/en-US/docs/Web/CSS/Containing_block#identifying_the_containing_block
 func (n *Node) IsContainingBlock(position string) bool {
-	if position == "absolute" {
+	switch position {
+	case "absolute":
 		return n.Css("position") == "fixed" || n.Css("position") == "absolute" ||
 			n.Css("position") == "relative" || n.Css("position") == "sticky" || n.Data() == "body"
+	case "fixed":
+		// fixed elements are placed within the viewport and keep
+		// their fixed descendants
+		return n.Css("position") == "fixed" || n.Data() == "body"
 	}
 	return false
 }
@@ -189,28 +282,34 @@ var ContainingPositions = map[string]string{
 	"absolute": "relative",
 }
 
+// FindNextPositions returns the descendants with position whose
+// containing block is n.
 func (n *Node) FindNextPositions(position string) (ps []*Node) {
 	for _, c := range n.Children {
-		if c.Css("position") == ContainingPositions[position] {
-			continue
-		}
 		if c.Css("position") == position {
 			ps = append(ps, c)
-		} else {
+		} else if !c.IsContainingBlock(position) {
 			ps = append(ps, c.FindNextPositions(position)...)
 		}
 	}
 	return
 }
 
+// IsOutOfFlow is true for absolutely positioned elements.
+func (n *Node) IsOutOfFlow() bool {
+	p := n.Css("position")
+	return p == "absolute" || p == "fixed"
+}
+
 // CB returns the Containing Block.
 func (n *Node) CB() (blk *Node) {
 	if n.parent == nil || n.Data() == "body" {
 		return n
 	}
-	if n.Css("position") == "absolute" {
+	if n.IsOutOfFlow() {
+		pos := n.Css("position")
 		for p := n.parent; p != nil; p = p.parent {
-			if p.IsContainingBlock("absolute") {
+			if p.IsContainingBlock(pos) {
 				return p
 			}
 		}
@@ -224,14 +323,13 @@ func (n *Node) CB() (blk *Node) {
 func (n *Node) CBItems() (cbis []*Node) {
 	cbis = make([]*Node, 0, len(n.Children))
 
-	if n.IsContainingBlock("absolute") {
-		ps := n.FindNextPositions("absolute")
-		for _, p := range ps {
-			cbis = append(cbis, p)
+	for _, pos := range []string{"absolute", "fixed"} {
+		if n.IsContainingBlock(pos) {
+			cbis = append(cbis, n.FindNextPositions(pos)...)
 		}
 	}
 	for _, c := range n.Children {
-		if c.CB() == n && c.Css("position") != "absolute" {
+		if c.CB() == n && !c.IsOutOfFlow() {
 			cbis = append(cbis, c)
 		}
 	}
@@ -392,6 +490,40 @@ func (n *Node) traverse(rr int, f func(r int, c *Node)) {
 	}
 }
 
+// Restyle rebuilds the subtree of n with the styles from nodeMap and
+// replaces n in its parent. Counters continue with their values before
+// n. The new node is returned.
+func (n *Node) Restyle(nodeMap map[*html.Node]style.Map) (nn *Node) {
+	ps := style.Map{}
+	if n.parent != nil {
+		ps = n.parent.Map
+	}
+	cnt := style.NewCounters()
+	if n.counters != nil {
+		cnt = n.counters.Clone()
+	}
+	nn = newNodeTree(n.DomSubtree, ps, nodeMap, n.parent, cnt)
+	CollapseWhiteSpace(nn)
+	if n.parent != nil {
+		for i, c := range n.parent.Children {
+			if c == n {
+				n.parent.Children[i] = nn
+			}
+		}
+	}
+	return
+}
+
+// Lookup the node with DOM node dn in the subtree of n.
+func (n *Node) Lookup(dn *html.Node) (found *Node) {
+	n.Traverse(func(r int, c *Node) {
+		if found == nil && c.DomSubtree == dn {
+			found = c
+		}
+	})
+	return
+}
+
 func (n *Node) PrintTree() {
 	n.Traverse(func(r int, n *Node) {
 		for i := 0; i < r; i++ {
@@ -236,6 +236,19 @@ func TestCBItems(t *testing.T) {
 			"article": {},
 			"a":       {"link"},
 		},
+		`
+			<html>
+				<body>
+					<main style="position: relative;">
+						<nav style="position: fixed;"><a style="position: absolute;">link</a></nav>
+					</main>
+				</body>
+			</html>
+		`: {
+			"body": {"nav", "main"},
+			"main": {},
+			"nav":  {"a"},
+		},
 	}
 	for htm, m := range tests {
 		doc, err := html.Parse(strings.NewReader(htm))
@@ -261,3 +274,147 @@ func TestCBItems(t *testing.T) {
 		}
 	}
 }
+
+func TestRestyle(t *testing.T) {
+	buf := strings.NewReader(`
+	<html>
+		<body>
+			<p><a>link</a></p>
+		</body>
+	</html>`)
+	doc, err := html.Parse(buf)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	nt := NewNodeTree(doc, style.Map{}, make(map[*html.Node]style.Map), nil)
+	p := nt.Find("html").Find("body").Find("p")
+	a := p.Find("a")
+	nm := map[*html.Node]style.Map{
+		a.DomSubtree: style.Map{
+			Declarations: map[string]style.Declaration{
+				"color": style.Declaration{Prop: "color", Val: "red"},
+			},
+		},
+	}
+	na := a.Restyle(nm)
+	if na == a || p.Children[0] != na {
+		t.Fatalf("not replaced")
+	}
+	if c := na.Css("color"); c != "red" {
+		t.Fatalf("%v", c)
+	}
+	if nt.Lookup(a.DomSubtree) != na {
+		t.Fatalf("lookup")
+	}
+}
+
+func TestRestyleCounters(t *testing.T) {
+	buf := strings.NewReader(`
+	<html>
+		<body>
+			<h2>Intro</h2>
+			<ol><li>a</li><li>b</li><li>c</li></ol>
+			<h2>Usage</h2>
+		</body>
+	</html>`)
+	doc, err := html.Parse(buf)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	css := `
+	body { counter-reset: h; }
+	h2 { counter-increment: h; }
+	h2::before { content: counter(h) ". "; }
+	`
+	nm, err := style.FetchNodeMap(doc, style.AddOnCSS+css)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	nt := NewNodeTree(doc, style.Map{}, nm, nil)
+	body := nt.Find("body")
+	li := body.FindAll("li")[2]
+	if nli := li.Restyle(nm); nli.ContentString(false) != "3. c" {
+		t.Errorf("%v", nli.ContentString(false))
+	}
+	h := body.FindAll("h2")[1]
+	if nh := h.Restyle(nm); nh.ContentString(false) != "2. Usage" {
+		t.Errorf("%v", nh.ContentString(false))
+	}
+}
+
+func TestPseudoElements(t *testing.T) {
+	buf := strings.NewReader(`
+	<html>
+		<body>
+			<nav><a>Home</a><a>Docs</a></nav>
+			<h2>Intro</h2>
+			<h2>Usage</h2>
+		</body>
+	</html>`)
+	doc, err := html.Parse(buf)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	css := `
+	body { counter-reset: h; }
+	h2 { counter-increment: h; }
+	h2::before { content: counter(h) ". "; }
+	a + a::before { content: "/"; color: gray; }
+	`
+	nm, err := style.FetchNodeMap(doc, css)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	nt := NewNodeTree(doc, style.Map{}, nm, nil)
+	body := nt.Find("body")
+	as := body.FindAll("a")
+	if len(as[0].Children) != 1 {
+		t.Fatalf("%+v", as[0].Children)
+	}
+	sep := as[1].Children[0]
+	if sep.PseudoElement != "before" || sep.Text != "/" || sep.Css("color") != "gray" {
+		t.Fatalf("%+v", sep)
+	}
+	if c := as[1].ContentString(false); c != "/ Docs" {
+		t.Fatalf("%v", c)
+	}
+	hs := body.FindAll("h2")
+	if c := hs[1].ContentString(false); c != "2. Usage" {
+		t.Fatalf("%v", c)
+	}
+	if q := hs[1].QueryRef(); q != "h2:nth-child(3)" {
+		t.Fatalf("%v", q)
+	}
+}
+
+func TestListMarkers(t *testing.T) {
+	buf := strings.NewReader(`
+	<html>
+		<body>
+			<ol start="3"><li>a</li><li value="10">b</li><li>c</li></ol>
+			<ol reversed><li>x</li><li>y</li></ol>
+			<ul><li>u<ul><li>v</li></ul></li></ul>
+			<ol style="list-style-type: lower-roman"><li>r</li></ol>
+			<ul style="list-style: none"><li>n</li></ul>
+		</body>
+	</html>`)
+	doc, err := html.Parse(buf)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	nm, err := style.FetchNodeMap(doc, style.AddOnCSS)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	nt := NewNodeTree(doc, style.Map{}, nm, nil)
+	var ms []string
+	nt.Traverse(func(r int, n *Node) {
+		if n.PseudoElement == "marker" {
+			ms = append(ms, n.Text)
+		}
+	})
+	exp := []string{"3. ", "10. ", "11. ", "2. ", "1. ", "• ", "◦ ", "i. "}
+	if strings.Join(ms, "|") != strings.Join(exp, "|") {
+		t.Fatalf("%q", ms)
+	}
+}
@@ -0,0 +1,215 @@
+package nodes
+
+import (
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// wsState is the state of white space processing within a formatting
+// context.
+type wsState struct {
+	// space is true at the start of a line and after a collapsible
+	// space, so that following collapsible white space is removed.
+	space bool
+
+	// last is the text node with the last collapsible space, which
+	// is removed when the line ends.
+	last *Node
+
+	// prev is the last text node on the line ending with other than
+	// white space and pending a white space only text node before
+	// that could not be merged into prev yet.
+	prev    *Node
+	pending *Node
+}
+
+// CollapseWhiteSpace processes the white space of the text nodes below
+// n according to their white-space property: sequences of collapsible
+// white space become a single space, also across element boundaries,
+// and are removed at the start and end of lines. Text nodes that end
+// up empty are removed. The space of white space only text between
+// elements is moved into the text of the surrounding elements.
+func CollapseWhiteSpace(n *Node) {
+	ws := &wsState{space: true}
+	ws.walk(n)
+	ws.endLine()
+	prune(n)
+}
+
+// endLine removes the trailing collapsible space of the line.
+func (ws *wsState) endLine() {
+	if ws.last != nil {
+		ws.last.Text = strings.TrimRight(ws.last.Text, " ")
+		ws.last = nil
+	}
+	ws.space = true
+	ws.prev = nil
+	ws.pending = nil
+}
+
+func (ws *wsState) walk(n *Node) {
+	if n.IsDisplayNone() {
+		return
+	}
+	if n.PseudoElement == "marker" && n.ListStylePosition() == "outside" {
+		// not part of the lines
+		return
+	}
+	if n.Type() == html.TextNode {
+		ws.text(n)
+		return
+	}
+	switch n.Data() {
+	case "script", "style", "template":
+		return
+	case "br":
+		ws.endLine()
+		return
+	case "img", "input", "select", "textarea", "button", "svg", "picture", "video", "audio", "canvas", "iframe", "object", "embed":
+		ws.atomic()
+		return
+	}
+
+	d := n.Css("display")
+	switch {
+	case d == "" && isBlockTag(n.Data()):
+		d = "block"
+	case d == "":
+		d = "inline"
+	}
+	if n.IsOutOfFlow() || n.Css("float") == "left" || n.Css("float") == "right" {
+		d = "block"
+	}
+	switch {
+	case d == "inline" || d == "contents":
+		for _, c := range n.Children {
+			ws.walk(c)
+		}
+	case strings.HasPrefix(d, "inline-"):
+		// atomic inline with its own lines inside
+		inner := &wsState{space: true}
+		inner.children(n, d)
+		inner.endLine()
+		ws.atomic()
+	default:
+		ws.endLine()
+		ws.children(n, d)
+		ws.endLine()
+	}
+}
+
+// children processes the children of n with display d. The items of
+// flex, grid and table containers are formatted separately.
+func (ws *wsState) children(n *Node, d string) {
+	items := strings.HasSuffix(d, "flex") || strings.HasSuffix(d, "grid") || strings.HasSuffix(d, "table")
+	for _, c := range n.Children {
+		ws.walk(c)
+		if items {
+			ws.endLine()
+		}
+	}
+}
+
+// atomic continues the line after an atomic inline like an image.
+func (ws *wsState) atomic() {
+	ws.space = false
+	ws.last = nil
+	ws.prev = nil
+	ws.pending = nil
+}
+
+func (ws *wsState) text(n *Node) {
+	collapse, newlines, _ := n.WhiteSpace()
+	if !collapse {
+		if n.Text != "" {
+			ws.atomic()
+		}
+		return
+	}
+	if strings.Trim(n.Text, " \t\n\r\f") == "" && !(newlines && strings.Contains(n.Text, "\n")) {
+		ws.blank(n)
+		return
+	}
+	buf := make([]rune, 0, len(n.Text))
+	for _, r := range n.Text {
+		switch {
+		case r == '\n' && newlines:
+			// spaces around preserved line breaks are removed
+			if len(buf) == 0 {
+				ws.endLine()
+			}
+			buf = []rune(strings.TrimRight(string(buf), " "))
+			buf = append(buf, r)
+			ws.last = nil
+			ws.space = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f':
+			if !ws.space {
+				buf = append(buf, ' ')
+				ws.space = true
+			}
+		default:
+			buf = append(buf, r)
+			ws.space = false
+		}
+	}
+	if ws.pending != nil && len(buf) > 0 && buf[0] != ' ' && buf[0] != '\n' {
+		buf = append([]rune{' '}, buf...)
+		ws.pending.Text = ""
+		ws.pending = nil
+	}
+	n.Text = string(buf)
+	if len(buf) > 0 {
+		ws.last = nil
+		ws.prev = nil
+		switch buf[len(buf)-1] {
+		case ' ':
+			ws.last = n
+		case '\n':
+		default:
+			ws.prev = n
+		}
+	}
+}
+
+// blank processes the white space only text node n. Its space is
+// appended to the preceding or prepended to the following text on the
+// line if possible, so that n can be removed.
+func (ws *wsState) blank(n *Node) {
+	n.Text = ""
+	if ws.space {
+		return
+	}
+	ws.space = true
+	if p := ws.prev; p != nil {
+		p.Text += " "
+		ws.last = p
+		ws.prev = nil
+		return
+	}
+	n.Text = " "
+	ws.last = n
+	ws.pending = n
+}
+
+// prune removes empty text nodes.
+func prune(n *Node) {
+	cs := n.Children[:0]
+	for _, c := range n.Children {
+		if c.Type() == html.TextNode && c.Text == "" {
+			continue
+		}
+		prune(c)
+		cs = append(cs, c)
+	}
+	n.Children = cs
+}
+
+// isBlockTag returns whether the element is block-level by default.
+func isBlockTag(tag string) bool {
+	switch tag {
+	case "html", "body", "head", "tr", "td", "th", "thead", "tbody", "tfoot", "caption", "colgroup", "col", "option", "optgroup", "legend", "summary", "menu", "center",
+		"address", "article", "aside", "blockquote", "details", "dialog", "dd", "div", "dl", "dt", "fieldset", "figcaption", "figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hgroup", "hr", "li", "main", "nav", "ol", "p", "pre", "section", "table", "ul":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package nodes
+
+import (
+	"github.com/psilva261/opossum/style"
+	"golang.org/x/net/html"
+	"strings"
+	"testing"
+)
+
+func TestCollapseWhiteSpace(t *testing.T) {
+	tests := []struct {
+		htm string
+		exp []string
+	}{
+		{"<p>  a \n\t b  </p>", []string{"a b"}},
+		{"<p>a <b> b </b> c</p>", []string{"a ", "b ", "c"}},
+		{"<p><b>a</b> <i>b</i></p>", []string{"a ", "b"}},
+		{"<p><img> <b>a</b></p>", []string{" a"}},
+		{"<div> a </div> <div> b </div>", []string{"a", "b"}},
+		{"<p>a<br> b</p>", []string{"a", "b"}},
+		{`<p style="white-space: pre-line">a  b  
+  c</p>`, []string{"a b\nc"}},
+		{`<p style="white-space: pre"> a  b </p>`, []string{" a  b "}},
+		{`<ul> <li>a</li> <li>b</li> </ul>`, []string{"• ", "a", "• ", "b"}},
+	}
+	for _, tt := range tests {
+		doc, err := html.Parse(strings.NewReader(tt.htm))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nt := NewNodeTree(doc, style.Map{}, make(map[*html.Node]style.Map), nil)
+		var ts []string
+		nt.Traverse(func(r int, n *Node) {
+			if n.Type() == html.TextNode {
+				ts = append(ts, n.Text)
+			}
+		})
+		if strings.Join(ts, "|") != strings.Join(tt.exp, "|") {
+			t.Errorf("%v: %q", tt.htm, ts)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"mime"
 	"net/url"
+	"path"
 	"strings"
 )
 
@@ -28,23 +29,44 @@ type ContentType struct {
 	Params    map[string]string
 }
 
+// extTypes are the mime types of file extensions.
+var extTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".apng": "image/apng",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".ico":  "image/x-icon",
+	".svg":  "image/svg+xml",
+}
+
+// typeAliases are non-standard mime types still in use.
+var typeAliases = map[string]string{
+	"image/jpg":                "image/jpeg",
+	"image/pjpeg":              "image/jpeg",
+	"image/x-png":              "image/png",
+	"image/x-bmp":              "image/bmp",
+	"image/x-ms-bmp":           "image/bmp",
+	"image/x-tiff":             "image/tiff",
+	"image/vnd.microsoft.icon": "image/x-icon",
+}
+
 // NewContentType based on mime type string and url including file extension as fallback
 func NewContentType(s string, u *url.URL) (c ContentType, err error) {
 	if s == "" && u != nil && strings.Contains(u.String(), ".") {
-		l := strings.Split(u.String(), ".")
-		ext := l[len(l)-1]
-		switch ext {
-		case "jpg":
-			return NewContentType("image/jpeg", u)
-		case "png":
-			return NewContentType("image/png", u)
-		case "gif":
-			return NewContentType("image/gif", u)
-		default:
-			return ContentType{}, nil
+		if t, ok := extTypes[strings.ToLower(path.Ext(u.Path))]; ok {
+			return NewContentType(t, u)
 		}
+		return ContentType{}, nil
 	}
 	c.MediaType, c.Params, err = mime.ParseMediaType(s)
+	if t, ok := typeAliases[c.MediaType]; ok {
+		c.MediaType = t
+	}
 	return
 }
 
@@ -78,6 +100,10 @@ func (c ContentType) IsDownload() bool {
 		c.MediaType == "application/zip"
 }
 
+func (c ContentType) IsImage() bool {
+	return strings.HasPrefix(c.MediaType, "image/")
+}
+
 func (c ContentType) IsSvg() bool {
 	return c.MediaType == "image/svg+xml"
 }
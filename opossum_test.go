@@ -0,0 +1,34 @@
+package opossum
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNewContentType(t *testing.T) {
+	tests := []struct {
+		s, u, exp string
+	}{
+		{"image/webp", "https://example.com/a", "image/webp"},
+		{"image/x-ms-bmp", "https://example.com/a", "image/bmp"},
+		{"image/vnd.microsoft.icon", "https://example.com/favicon.ico", "image/x-icon"},
+		{"", "https://example.com/a.WEBP?w=100", "image/webp"},
+		{"", "https://example.com/a.tif", "image/tiff"},
+		{"", "https://example.com/favicon.ico", "image/x-icon"},
+		{"", "https://example.com/a.jpeg#x", "image/jpeg"},
+		{"", "https://example.com/a.html", ""},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewContentType(tt.s, u)
+		if err != nil || c.MediaType != tt.exp {
+			t.Errorf("%v %v: %v %v", tt.s, tt.u, c.MediaType, err)
+		}
+		if c.IsImage() != (tt.exp != "") {
+			t.Errorf("%v %v: IsImage", tt.s, tt.u)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BackgroundLength is Px plus Percent of the background positioning
+// area or auto.
+type BackgroundLength struct {
+	Px, Percent float64
+	Auto        bool
+}
+
+// BackgroundLayer is one of the comma separated layers of the
+// background properties. The first layer is drawn on top.
+type BackgroundLayer struct {
+	Image            string // url(...) or a gradient
+	RepeatX, RepeatY string // repeat, no-repeat, space or round
+	X, Y             BackgroundLength
+	Size             string // cover, contain or empty to use W and H
+	W, H             BackgroundLength
+	Origin, Clip     string // border-box, padding-box or content-box
+}
+
+func defaultBackgroundLayer() BackgroundLayer {
+	return BackgroundLayer{
+		RepeatX: "repeat",
+		RepeatY: "repeat",
+		W:       BackgroundLength{Auto: true},
+		H:       BackgroundLength{Auto: true},
+		Origin:  "padding-box",
+		Clip:    "border-box",
+	}
+}
+
+// BackgroundLayers returns the layers of the background shorthand
+// overridden by the longhand properties. Layers without image are
+// omitted.
+func (cs Map) BackgroundLayers() (ls []BackgroundLayer) {
+	all, _ := cs.backgroundShorthand()
+	if v := cs.Css("background-image"); v != "" {
+		imgs := splitComma(v)
+		for len(all) < len(imgs) {
+			all = append(all, defaultBackgroundLayer())
+		}
+		all = all[:len(imgs)]
+		for i, im := range imgs {
+			all[i].Image = ""
+			if im != "none" {
+				all[i].Image = im
+			}
+		}
+	}
+	longhands := []struct {
+		prop  string
+		apply func(l *BackgroundLayer, v string)
+	}{
+		{"background-repeat", func(l *BackgroundLayer, v string) {
+			l.RepeatX, l.RepeatY, _ = backgroundRepeat(strings.Fields(v))
+		}},
+		{"background-position", func(l *BackgroundLayer, v string) {
+			l.X, l.Y, _ = cs.backgroundPosition(strings.Fields(v))
+		}},
+		{"background-position-x", func(l *BackgroundLayer, v string) {
+			l.X, _, _ = cs.backgroundPosition(append(strings.Fields(v), "center"))
+		}},
+		{"background-position-y", func(l *BackgroundLayer, v string) {
+			_, l.Y, _ = cs.backgroundPosition(append([]string{"center"}, strings.Fields(v)...))
+		}},
+		{"background-size", func(l *BackgroundLayer, v string) {
+			l.Size, l.W, l.H, _ = cs.backgroundSize(strings.Fields(v))
+		}},
+		{"background-origin", func(l *BackgroundLayer, v string) {
+			l.Origin = v
+		}},
+		{"background-clip", func(l *BackgroundLayer, v string) {
+			l.Clip = v
+		}},
+	}
+	for _, lh := range longhands {
+		v := cs.Css(lh.prop)
+		if v == "" || len(all) == 0 {
+			continue
+		}
+		vs := splitComma(v)
+		for i := range all {
+			lh.apply(&all[i], vs[i%len(vs)])
+		}
+	}
+	for _, l := range all {
+		if l.Image != "" {
+			ls = append(ls, l)
+		}
+	}
+	return
+}
+
+// backgroundShorthand parses the layers of the background shorthand
+// and the color of its final layer.
+func (cs Map) backgroundShorthand() (ls []BackgroundLayer, color string) {
+	v := cs.Css("background")
+	if v == "" {
+		return
+	}
+	for _, lv := range splitComma(v) {
+		l := defaultBackgroundLayer()
+		toks := backgroundTokens(lv)
+		boxes := 0
+		for i := 0; i < len(toks); i++ {
+			t := toks[i]
+			switch {
+			case t == "none":
+			case strings.HasPrefix(t, "url(") || strings.Contains(t, "gradient("):
+				l.Image = t
+			case t == "scroll" || t == "fixed" || t == "local" || t == "text":
+			case t == "border-box" || t == "padding-box" || t == "content-box":
+				if boxes == 0 {
+					l.Origin = t
+				}
+				l.Clip = t
+				boxes++
+			default:
+				if x, y, ok := backgroundRepeat(toks[i:]); ok {
+					l.RepeatX, l.RepeatY = x, y
+					if i+1 < len(toks) && isRepeat(toks[i+1]) && t != "repeat-x" && t != "repeat-y" {
+						i++
+					}
+					continue
+				}
+				n := 0
+				for i+n < len(toks) && n < 4 && cs.isPosition(toks[i+n]) {
+					n++
+				}
+				if n > 0 {
+					l.X, l.Y, _ = cs.backgroundPosition(toks[i : i+n])
+					i += n - 1
+					if i+2 < len(toks) && toks[i+1] == "/" {
+						m := 1
+						if i+3 < len(toks) && cs.isSize(toks[i+2]) && cs.isSize(toks[i+3]) {
+							m = 2
+						}
+						l.Size, l.W, l.H, _ = cs.backgroundSize(toks[i+2 : i+2+m])
+						i += 1 + m
+					}
+					continue
+				}
+				color = t
+			}
+		}
+		ls = append(ls, l)
+	}
+	return
+}
+
+// backgroundTokens splits v at spaces and slashes outside of
+// parentheses. Slashes are tokens of their own.
+func backgroundTokens(v string) (toks []string) {
+	for _, t := range contentTokens(v) {
+		if strings.Contains(t, "(") {
+			toks = append(toks, t)
+			continue
+		}
+		for {
+			i := strings.IndexByte(t, '/')
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				toks = append(toks, t[:i])
+			}
+			toks = append(toks, "/")
+			t = t[i+1:]
+		}
+		if t != "" {
+			toks = append(toks, t)
+		}
+	}
+	return
+}
+
+func isRepeat(t string) bool {
+	switch t {
+	case "repeat", "no-repeat", "space", "round":
+		return true
+	}
+	return false
+}
+
+// backgroundRepeat parses the repeat style at the start of toks.
+func backgroundRepeat(toks []string) (x, y string, ok bool) {
+	if len(toks) == 0 {
+		return
+	}
+	switch t := toks[0]; {
+	case t == "repeat-x":
+		return "repeat", "no-repeat", true
+	case t == "repeat-y":
+		return "no-repeat", "repeat", true
+	case isRepeat(t):
+		if len(toks) > 1 && isRepeat(toks[1]) {
+			return t, toks[1], true
+		}
+		return t, t, true
+	}
+	return
+}
+
+func (cs Map) isPosition(t string) bool {
+	switch t {
+	case "left", "right", "top", "bottom", "center":
+		return true
+	}
+	_, ok := cs.backgroundLength(t)
+	return ok
+}
+
+// isSize is true for width and height values of background-size.
+func (cs Map) isSize(t string) bool {
+	_, ok := cs.backgroundLength(t)
+	return ok || t == "auto"
+}
+
+// backgroundLength parses a length or percentage.
+func (cs Map) backgroundLength(t string) (l BackgroundLength, ok bool) {
+	if strings.HasSuffix(t, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(t, "%"), 64)
+		if err != nil {
+			return l, false
+		}
+		return BackgroundLength{Percent: p}, true
+	}
+	if !startsNumeric(t) && !strings.HasPrefix(t, "calc(") {
+		return l, false
+	}
+	f, _, err := length(&cs, t)
+	if err != nil {
+		return l, false
+	}
+	return BackgroundLength{Px: f}, true
+}
+
+// backgroundPosition parses 1 to 4 values of background-position.
+func (cs Map) backgroundPosition(toks []string) (x, y BackgroundLength, ok bool) {
+	edges := map[string]float64{"left": 0, "top": 0, "center": 50, "right": 100, "bottom": 100}
+	x = BackgroundLength{Percent: 50}
+	y = BackgroundLength{Percent: 50}
+	toks = append([]string{}, toks...)
+	switch len(toks) {
+	case 1, 2:
+		if len(toks) == 1 {
+			toks = append(toks, "center")
+		}
+		if toks[0] == "top" || toks[0] == "bottom" || toks[1] == "left" || toks[1] == "right" {
+			toks[0], toks[1] = toks[1], toks[0]
+		}
+		for i, t := range toks {
+			l, ok := cs.backgroundLength(t)
+			if p, isEdge := edges[t]; isEdge {
+				l, ok = BackgroundLength{Percent: p}, true
+			}
+			if !ok {
+				return x, y, false
+			}
+			if i == 0 {
+				x = l
+			} else {
+				y = l
+			}
+		}
+		return x, y, true
+	case 3, 4:
+		for i := 0; i < len(toks); i++ {
+			kw := toks[i]
+			p, isEdge := edges[kw]
+			if !isEdge {
+				return x, y, false
+			}
+			l := BackgroundLength{Percent: p}
+			if i+1 < len(toks) {
+				if off, ok := cs.backgroundLength(toks[i+1]); ok {
+					if p == 100 {
+						off.Px, off.Percent = -off.Px, -off.Percent
+					}
+					l.Px += off.Px
+					l.Percent += off.Percent
+					i++
+				}
+			}
+			switch kw {
+			case "left", "right":
+				x = l
+			case "top", "bottom":
+				y = l
+			}
+		}
+		return x, y, true
+	}
+	return x, y, false
+}
+
+// backgroundSize parses cover, contain or 1 to 2 values of width and
+// height.
+func (cs Map) backgroundSize(toks []string) (size string, w, h BackgroundLength, ok bool) {
+	w = BackgroundLength{Auto: true}
+	h = BackgroundLength{Auto: true}
+	if len(toks) == 1 && (toks[0] == "cover" || toks[0] == "contain") {
+		return toks[0], w, h, true
+	}
+	if len(toks) == 0 || len(toks) > 2 {
+		return "", w, h, false
+	}
+	for i, t := range toks {
+		l := BackgroundLength{Auto: true}
+		if t != "auto" {
+			if l, ok = cs.backgroundLength(t); !ok {
+				return "", w, h, false
+			}
+		}
+		if i == 0 {
+			w = l
+		} else {
+			h = l
+		}
+	}
+	return "", w, h, true
+}
+
+// Url of the image or false if it is not loaded from an url like
+// gradients.
+func (l BackgroundLayer) Url() (string, bool) {
+	if !strings.HasPrefix(l.Image, "url(") {
+		return "", false
+	}
+	return backgroundImageUrl(Declaration{Val: l.Image})
+}
@@ -0,0 +1,85 @@
+package style
+
+import (
+	"testing"
+)
+
+func TestBackgroundLayers(t *testing.T) {
+	pct := func(p float64) BackgroundLength { return BackgroundLength{Percent: p} }
+	px := func(f float64) BackgroundLength { return BackgroundLength{Px: f} }
+	auto := BackgroundLength{Auto: true}
+	tests := []struct {
+		cs  Map
+		exp []BackgroundLayer
+	}{
+		{
+			decls("background", "#fff"),
+			nil,
+		},
+		{
+			decls("background", "url(sprite.png) no-repeat -20px -40px"),
+			[]BackgroundLayer{{Image: "url(sprite.png)", RepeatX: "no-repeat", RepeatY: "no-repeat", X: px(-20), Y: px(-40), W: auto, H: auto, Origin: "padding-box", Clip: "border-box"}},
+		},
+		{
+			decls("background", `center/cover url("hero.jpg") #333`),
+			[]BackgroundLayer{{Image: `url("hero.jpg")`, RepeatX: "repeat", RepeatY: "repeat", X: pct(50), Y: pct(50), Size: "cover", W: auto, H: auto, Origin: "padding-box", Clip: "border-box"}},
+		},
+		{
+			decls("background", "url(a.png) right 10px bottom / 50% auto repeat-x content-box, url(b.png) top left / 10px 20px space round border-box padding-box"),
+			[]BackgroundLayer{
+				{Image: "url(a.png)", RepeatX: "repeat", RepeatY: "no-repeat", X: BackgroundLength{Percent: 100, Px: -10}, Y: pct(100), W: pct(50), H: auto, Origin: "content-box", Clip: "content-box"},
+				{Image: "url(b.png)", RepeatX: "space", RepeatY: "round", X: pct(0), Y: pct(0), W: px(10), H: px(20), Origin: "border-box", Clip: "padding-box"},
+			},
+		},
+		{
+			decls("background", "url(a.png) no-repeat", "background-position", "bottom 5px right", "background-size", "contain", "background-repeat", "repeat-y"),
+			[]BackgroundLayer{{Image: "url(a.png)", RepeatX: "no-repeat", RepeatY: "repeat", X: pct(100), Y: BackgroundLength{Percent: 100, Px: -5}, Size: "contain", W: auto, H: auto, Origin: "padding-box", Clip: "border-box"}},
+		},
+		{
+			decls("background-image", "url(a.png), none, url(c.png)", "background-position-x", "3px", "background-position-y", "top", "background-clip", "content-box, padding-box"),
+			[]BackgroundLayer{
+				{Image: "url(a.png)", RepeatX: "repeat", RepeatY: "repeat", X: px(3), Y: pct(0), W: auto, H: auto, Origin: "padding-box", Clip: "content-box"},
+				{Image: "url(c.png)", RepeatX: "repeat", RepeatY: "repeat", X: px(3), Y: pct(0), W: auto, H: auto, Origin: "padding-box", Clip: "content-box"},
+			},
+		},
+		{
+			decls("background", "url(a.png)", "background-image", "none"),
+			nil,
+		},
+	}
+	for i, tt := range tests {
+		ls := tt.cs.BackgroundLayers()
+		if len(ls) != len(tt.exp) {
+			t.Errorf("%d: %+v", i, ls)
+			continue
+		}
+		for j := range ls {
+			if ls[j] != tt.exp[j] {
+				t.Errorf("%d/%d: %+v != %+v", i, j, ls[j], tt.exp[j])
+			}
+		}
+	}
+}
+
+func TestBackgroundShorthandColor(t *testing.T) {
+	tests := map[string]string{
+		"url(a.png) no-repeat, red url(b.png) 0 0": "red",
+		"rgb(0, 0, 0) url(x.png)":                  "rgb(0, 0, 0)",
+		"url(a.png)":                               "",
+		"none":                                     "",
+	}
+	for v, exp := range tests {
+		if _, c := decls("background", v).backgroundShorthand(); c != exp {
+			t.Errorf("%v: %v", v, c)
+		}
+	}
+}
+
+func TestBackgroundLayerUrl(t *testing.T) {
+	if u, ok := (BackgroundLayer{Image: `url('a b.png')`}).Url(); !ok || u != "a b.png" {
+		t.Errorf("%v %v", u, ok)
+	}
+	if _, ok := (BackgroundLayer{Image: "linear-gradient(red, blue)"}).Url(); ok {
+		t.Errorf("gradient")
+	}
+}
@@ -0,0 +1,294 @@
+package style
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"9fans.net/go/draw"
+)
+
+// Border of one side of a box with Style none, hidden, solid, dashed,
+// dotted, double, groove, ridge, inset or outset. Width is 0 for none
+// and hidden.
+type Border struct {
+	Width int
+	Style string
+	Color draw.Color
+}
+
+// Borders of a box in the order of duit.Space.
+type Borders struct {
+	Top, Right, Bottom, Left Border
+}
+
+// Shadow of box-shadow.
+type Shadow struct {
+	X, Y, Blur, Spread int
+	Color              draw.Color
+}
+
+var borderSides = []string{"top", "right", "bottom", "left"}
+
+// Borders returns the computed borders including the border,
+// border-width, border-style, border-color and border-<side>
+// shorthands.
+func (cs Map) Borders() (bs Borders) {
+	sides := []*Border{&bs.Top, &bs.Right, &bs.Bottom, &bs.Left}
+	for _, b := range sides {
+		*b = Border{Width: 3, Style: "none", Color: cs.Color()}
+	}
+	if v := cs.Css("border"); v != "" {
+		for _, b := range sides {
+			cs.border(b, v)
+		}
+	}
+	for i, v := range tlbrFields(cs.Css("border-width")) {
+		if w, ok := cs.borderWidth(v); ok {
+			sides[i].Width = w
+		}
+	}
+	for i, v := range tlbrFields(cs.Css("border-style")) {
+		if isBorderStyle(v) {
+			sides[i].Style = v
+		}
+	}
+	for i, v := range tlbrFields(cs.Css("border-color")) {
+		if c, ok := cs.borderColor(v); ok {
+			sides[i].Color = c
+		}
+	}
+	for i, side := range borderSides {
+		b := sides[i]
+		if v := cs.Css("border-" + side); v != "" {
+			cs.border(b, v)
+		}
+		if w, ok := cs.borderWidth(cs.Css("border-" + side + "-width")); ok {
+			b.Width = w
+		}
+		if v := cs.Css("border-" + side + "-style"); isBorderStyle(v) {
+			b.Style = v
+		}
+		if c, ok := cs.borderColor(cs.Css("border-" + side + "-color")); ok {
+			b.Color = c
+		}
+	}
+	for _, b := range sides {
+		if b.Style == "none" || b.Style == "hidden" {
+			b.Width = 0
+		}
+	}
+	return
+}
+
+// Outline returns the outline and outline-offset.
+func (cs Map) Outline() (b Border, offset int) {
+	b = Border{Width: 3, Style: "none", Color: cs.Color()}
+	if v := cs.Css("outline"); v != "" {
+		cs.border(&b, v)
+	}
+	if w, ok := cs.borderWidth(cs.Css("outline-width")); ok {
+		b.Width = w
+	}
+	if v := cs.Css("outline-style"); isBorderStyle(v) || v == "auto" {
+		b.Style = v
+	}
+	if c, ok := cs.borderColor(cs.Css("outline-color")); ok {
+		b.Color = c
+	}
+	if b.Style == "auto" {
+		b.Style = "solid"
+	}
+	if b.Style == "none" || b.Style == "hidden" {
+		b.Width = 0
+	}
+	if o, err := cs.CssPx("outline-offset"); err == nil {
+		offset = o
+	}
+	return
+}
+
+// BorderRadius returns the radii of the top-left, top-right,
+// bottom-right and bottom-left corners. Elliptical corners get the
+// smaller of their horizontal and vertical radius. Percentages are
+// resolved against the width and height, radii that are too large are
+// reduced when drawn.
+func (cs Map) BorderRadius() (r [4]int) {
+	hs := cs.Css("border-radius")
+	vs := hs
+	if i := strings.Index(hs, "/"); i >= 0 {
+		hs, vs = hs[:i], hs[i+1:]
+	}
+	var h, v [4]string
+	copy(h[:], tlbrFields(hs))
+	copy(v[:], tlbrFields(vs))
+	for i, c := range []string{"top-left", "top-right", "bottom-right", "bottom-left"} {
+		if fs := strings.Fields(cs.Css("border-" + c + "-radius")); len(fs) > 0 {
+			h[i], v[i] = fs[0], fs[len(fs)-1]
+		}
+	}
+	for i := range r {
+		r[i], _ = cs.radius(h[i], "width")
+		// keep the horizontal radius if the height is unknown
+		if rv, ok := cs.radius(v[i], "height"); ok && rv < r[i] {
+			r[i] = rv
+		}
+	}
+	return
+}
+
+// BoxShadow returns the first outer shadow of box-shadow.
+func (cs Map) BoxShadow() (s Shadow, ok bool) {
+	v := cs.Css("box-shadow")
+	if v == "" || v == "none" {
+		return
+	}
+	for _, sh := range splitComma(v) {
+		s = Shadow{Color: cs.Color()}
+		var ls []int
+		inset := false
+		for _, t := range contentTokens(sh) {
+			if t == "inset" {
+				inset = true
+			} else if f, _, err := length(&cs, t); err == nil && startsNumeric(t) {
+				ls = append(ls, int(f))
+			} else if c, ok := cs.color(t); ok {
+				s.Color = c
+			}
+		}
+		if inset || len(ls) < 2 {
+			continue
+		}
+		s.X, s.Y = ls[0], ls[1]
+		if len(ls) > 2 {
+			s.Blur = ls[2]
+		}
+		if len(ls) > 3 {
+			s.Spread = ls[3]
+		}
+		return s, true
+	}
+	return
+}
+
+// border applies the values of a border shorthand to b.
+func (cs Map) border(b *Border, v string) {
+	if v == "none" || v == "0" {
+		b.Style = "none"
+		return
+	}
+	*b = Border{Width: 3, Style: "none", Color: cs.Color()}
+	for _, t := range contentTokens(v) {
+		if isBorderStyle(t) || t == "auto" {
+			b.Style = t
+		} else if w, ok := cs.borderWidth(t); ok {
+			b.Width = w
+		} else if c, ok := cs.borderColor(t); ok {
+			b.Color = c
+		}
+	}
+}
+
+func (cs Map) borderWidth(v string) (w int, ok bool) {
+	switch v {
+	case "":
+		return 0, false
+	case "thin":
+		return 1, true
+	case "medium":
+		return 3, true
+	case "thick":
+		return 5, true
+	}
+	if !startsNumeric(v) && !strings.HasPrefix(v, "calc(") {
+		return 0, false
+	}
+	f, _, err := length(&cs, v)
+	if err != nil || f < 0 {
+		return 0, false
+	}
+	w = int(math.Round(f))
+	if w == 0 && f > 0 {
+		w = 1
+	}
+	return w, true
+}
+
+func (cs Map) borderColor(v string) (c draw.Color, ok bool) {
+	if v == "" {
+		return 0, false
+	}
+	return cs.color(v)
+}
+
+// radius of a corner in px, percentages are of the dimension dim, i.e.
+// width or height. ok is false if that is unknown.
+func (cs Map) radius(v, dim string) (r int, ok bool) {
+	if strings.HasSuffix(v, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil {
+			return 0, true
+		}
+		if l, err := cs.CssPx(dim); err == nil && l > 0 {
+			return int(p / 100 * float64(l)), true
+		}
+		if p >= 50 {
+			return math.MaxInt16, false
+		}
+		return 0, false
+	}
+	f, _, err := length(&cs, v)
+	if err != nil || f < 0 {
+		return 0, true
+	}
+	return int(f), true
+}
+
+func isBorderStyle(v string) bool {
+	switch v {
+	case "none", "hidden", "solid", "dashed", "dotted", "double", "groove", "ridge", "inset", "outset":
+		return true
+	}
+	return false
+}
+
+func startsNumeric(v string) bool {
+	return v != "" && (v[0] >= '0' && v[0] <= '9' || v[0] == '.' || v[0] == '-' || v[0] == '+')
+}
+
+// tlbrFields returns the values of top, right, bottom and left of a
+// property with one to four values.
+func tlbrFields(v string) (fs []string) {
+	ts := contentTokens(v)
+	switch len(ts) {
+	case 0:
+		return nil
+	case 1:
+		return []string{ts[0], ts[0], ts[0], ts[0]}
+	case 2:
+		return []string{ts[0], ts[1], ts[0], ts[1]}
+	case 3:
+		return []string{ts[0], ts[1], ts[2], ts[1]}
+	}
+	return ts[:4]
+}
+
+// splitComma splits v at commas outside of parentheses.
+func splitComma(v string) (l []string) {
+	depth := 0
+	from := 0
+	for i, c := range v {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				l = append(l, strings.TrimSpace(v[from:i]))
+				from = i + 1
+			}
+		}
+	}
+	return append(l, strings.TrimSpace(v[from:]))
+}
@@ -0,0 +1,126 @@
+package style
+
+import (
+	"math"
+	"testing"
+
+	"9fans.net/go/draw"
+)
+
+func decls(kv ...string) Map {
+	cs := Map{Declarations: make(map[string]Declaration)}
+	for i := 0; i+1 < len(kv); i += 2 {
+		cs.Declarations[kv[i]] = Declaration{Prop: kv[i], Val: kv[i+1]}
+	}
+	return cs
+}
+
+func TestBorders(t *testing.T) {
+	red := draw.Color(0xff0000ff)
+	blue := draw.Color(0x0000ffff)
+	tests := []struct {
+		cs  Map
+		exp Borders
+	}{
+		{
+			decls(),
+			Borders{
+				Border{0, "none", draw.Black},
+				Border{0, "none", draw.Black},
+				Border{0, "none", draw.Black},
+				Border{0, "none", draw.Black},
+			},
+		},
+		{
+			decls("border", "2px solid red"),
+			Borders{
+				Border{2, "solid", red},
+				Border{2, "solid", red},
+				Border{2, "solid", red},
+				Border{2, "solid", red},
+			},
+		},
+		{
+			decls("border", "1px dashed", "border-color", "red blue", "border-bottom", "thick double blue", "border-left-style", "none"),
+			Borders{
+				Border{1, "dashed", red},
+				Border{1, "dashed", blue},
+				Border{5, "double", blue},
+				Border{0, "none", blue},
+			},
+		},
+		{
+			decls("border-style", "solid dotted", "border-width", "1px 2px 3px", "color", "blue"),
+			Borders{
+				Border{1, "solid", blue},
+				Border{2, "dotted", blue},
+				Border{3, "solid", blue},
+				Border{2, "dotted", blue},
+			},
+		},
+		{
+			decls("border-top", "solid", "border", "none"),
+			Borders{
+				Border{3, "solid", draw.Black},
+				Border{0, "none", draw.Black},
+				Border{0, "none", draw.Black},
+				Border{0, "none", draw.Black},
+			},
+		},
+	}
+	for i, tt := range tests {
+		if bs := tt.cs.Borders(); bs != tt.exp {
+			t.Errorf("%v: %+v", i, bs)
+		}
+	}
+}
+
+func TestOutline(t *testing.T) {
+	cs := decls("outline", "auto 2px", "outline-offset", "3px")
+	if b, o := cs.Outline(); b != (Border{2, "solid", draw.Black}) || o != 3 {
+		t.Fatalf("%+v %v", b, o)
+	}
+	if b, _ := decls().Outline(); b.Width != 0 {
+		t.Fatalf("%+v", b)
+	}
+}
+
+func TestBorderRadius(t *testing.T) {
+	tests := []struct {
+		cs  Map
+		exp [4]int
+	}{
+		{decls(), [4]int{}},
+		{decls("border-radius", "4px"), [4]int{4, 4, 4, 4}},
+		{decls("border-radius", "1px 2px 3px / 5px"), [4]int{1, 2, 3, 2}},
+		{decls("border-radius", "50%", "border-bottom-left-radius", "0"), [4]int{math.MaxInt16, math.MaxInt16, math.MaxInt16, 0}},
+		{decls("border-radius", "10%", "width", "200px"), [4]int{20, 20, 20, 20}},
+		{decls("border-radius", "10px / 4px"), [4]int{4, 4, 4, 4}},
+		{decls("border-radius", "8px / 0"), [4]int{}},
+		{decls("border-radius", "50% / 20%", "width", "200px", "height", "40px"), [4]int{8, 8, 8, 8}},
+		{decls("border-radius", "6px", "border-top-left-radius", "8px 2px"), [4]int{2, 6, 6, 6}},
+	}
+	for i, tt := range tests {
+		if r := tt.cs.BorderRadius(); r != tt.exp {
+			t.Errorf("%v: %v", i, r)
+		}
+	}
+}
+
+func TestBoxShadow(t *testing.T) {
+	tests := []struct {
+		v   string
+		ok  bool
+		exp Shadow
+	}{
+		{"none", false, Shadow{}},
+		{"2px 3px", true, Shadow{2, 3, 0, 0, draw.Black}},
+		{"inset 1px 1px red, 1px 2px 4px -1px rgb(0,0,255)", true, Shadow{1, 2, 4, -1, 0x0000ffff}},
+	}
+	for _, tt := range tests {
+		s, ok := decls("box-shadow", tt.v).BoxShadow()
+		if ok != tt.ok || ok && s != tt.exp {
+			t.Errorf("%v: %+v %v", tt.v, s, ok)
+		}
+	}
+}
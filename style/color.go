@@ -0,0 +1,436 @@
+package style
+
+import (
+	"9fans.net/go/draw"
+	"github.com/psilva261/opossum/logger"
+	"golang.org/x/image/colornames"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// colorHex parses a CSS color like #0a0, green, rgb(0 160 0 / 50%),
+// hsl(120deg 100% 31%) or oklch(60% 0.2 140) and returns it
+// premultiplied by its alpha. currentColor depends on the element and
+// is resolved by Map.color.
+func colorHex(propVal string) (c draw.Color, ok bool) {
+	v := strings.ToLower(strings.TrimSpace(propVal))
+	var rgba [4]float64
+	if strings.HasPrefix(v, "#") {
+		rgba, ok = hexColor(v[1:])
+	} else if i := strings.IndexByte(v, '('); i > 0 && strings.HasSuffix(v, ")") {
+		rgba, ok = colorFunc(strings.TrimSpace(v[:i]), v[i+1:len(v)-1])
+	} else if v == "transparent" {
+		return draw.Transparent, true
+	} else if cc, found := colornames.Map[v]; found {
+		rgba = [4]float64{float64(cc.R) / 0xff, float64(cc.G) / 0xff, float64(cc.B) / 0xff, 1}
+		ok = true
+	} else if sc, found := systemColors[v]; found {
+		return sc, true
+	}
+	if !ok {
+		log.Printf("could not interpret %v", propVal)
+		return 0, false
+	}
+	return rgbaColor(rgba), true
+}
+
+// systemColors are the values of CSS system colors.
+var systemColors = map[string]draw.Color{
+	"canvas":           draw.White,
+	"canvastext":       draw.Black,
+	"linktext":         0x0000eeff,
+	"visitedtext":      0x551a8bff,
+	"activetext":       draw.Red,
+	"buttonface":       0xefefefff,
+	"buttontext":       draw.Black,
+	"buttonborder":     0x767676ff,
+	"field":            draw.White,
+	"fieldtext":        draw.Black,
+	"highlight":        0xb5d5ffff,
+	"highlighttext":    draw.Black,
+	"selecteditem":     0x0078d7ff,
+	"selecteditemtext": draw.White,
+	"mark":             draw.Yellow,
+	"marktext":         draw.Black,
+	"graytext":         0x6d6d6dff,
+	"accentcolor":      0x0078d7ff,
+	"accentcolortext":  draw.White,
+}
+
+// rgbaColor returns the premultiplied draw.Color of sRGB components
+// and alpha from 0 to 1. Out of gamut components are clipped.
+func rgbaColor(rgba [4]float64) draw.Color {
+	a := clamp01(rgba[3])
+	x := uint32(0)
+	for _, v := range rgba[:3] {
+		x = x<<8 | uint32(math.Round(clamp01(v)*a*0xff))
+	}
+	return draw.Color(x<<8 | uint32(math.Round(a*0xff)))
+}
+
+func clamp01(v float64) float64 {
+	if math.IsNaN(v) || v < 0 {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// hexColor parses the digits of #rgb, #rgba, #rrggbb and #rrggbbaa.
+func hexColor(h string) (rgba [4]float64, ok bool) {
+	n := 1
+	switch len(h) {
+	case 3, 4:
+	case 6, 8:
+		n = 2
+	default:
+		return
+	}
+	rgba[3] = 1
+	for i := 0; i*n < len(h); i++ {
+		x, err := strconv.ParseUint(h[i*n:(i+1)*n], 16, 8)
+		if err != nil {
+			return rgba, false
+		}
+		if n == 1 {
+			x *= 0x11
+		}
+		rgba[i] = float64(x) / 0xff
+	}
+	return rgba, true
+}
+
+// colorFunc evaluates the color function fn with the arguments args.
+func colorFunc(fn, args string) (rgba [4]float64, ok bool) {
+	var cs []string
+	alpha := "1"
+	if strings.Contains(args, ",") {
+		// legacy syntax
+		cs = strings.Split(args, ",")
+		for i := range cs {
+			cs[i] = strings.TrimSpace(cs[i])
+		}
+		if len(cs) == 4 {
+			alpha = cs[3]
+			cs = cs[:3]
+		}
+	} else {
+		if i := strings.IndexByte(args, '/'); i >= 0 {
+			alpha = strings.TrimSpace(args[i+1:])
+			args = args[:i]
+		}
+		cs = strings.Fields(args)
+	}
+	if fn == "color" && len(cs) > 0 {
+		fn, cs = cs[0], cs[1:]
+	}
+	if len(cs) != 3 {
+		return
+	}
+	if rgba[3], ok = component(alpha, 1); !ok {
+		return
+	}
+	var x [3]float64
+	switch fn {
+	case "rgb", "rgba":
+		for i, c := range cs {
+			if x[i], ok = component(c, 255); !ok {
+				return
+			}
+			x[i] /= 255
+		}
+		copy(rgba[:3], x[:])
+	case "hsl", "hsla", "hwb":
+		h, ok := hue(cs[0])
+		if !ok {
+			return rgba, false
+		}
+		var s, l float64
+		if s, ok = component(cs[1], 100); !ok {
+			return rgba, false
+		}
+		if l, ok = component(cs[2], 100); !ok {
+			return rgba, false
+		}
+		if fn == "hwb" {
+			copy(rgba[:3], hwbToRgb(h, s/100, l/100))
+		} else {
+			copy(rgba[:3], hslToRgb(h, s/100, l/100))
+		}
+	case "lab", "lch", "oklab", "oklch":
+		// reference ranges of 100%
+		refs := map[string][3]float64{
+			"lab":   {100, 125, 125},
+			"lch":   {100, 150, 0},
+			"oklab": {1, 0.4, 0.4},
+			"oklch": {1, 0.4, 0},
+		}[fn]
+		for i := 0; i < 2; i++ {
+			if x[i], ok = component(cs[i], refs[i]); !ok {
+				return
+			}
+		}
+		if strings.HasSuffix(fn, "lch") {
+			h, ok := hue(cs[2])
+			if !ok {
+				return rgba, false
+			}
+			h *= math.Pi / 180
+			x[1], x[2] = x[1]*math.Cos(h), x[1]*math.Sin(h)
+		} else if x[2], ok = component(cs[2], refs[2]); !ok {
+			return
+		}
+		var lin [3]float64
+		if strings.HasPrefix(fn, "ok") {
+			lin = oklabToLinear(x)
+		} else {
+			lin = mulMatrix(xyzD65ToLinear, mulMatrix(d50ToD65, labToXyz(x)))
+		}
+		for i, v := range lin {
+			rgba[i] = gamma(v)
+		}
+	case "srgb", "srgb-linear", "display-p3", "xyz", "xyz-d65", "xyz-d50":
+		for i, c := range cs {
+			if x[i], ok = component(c, 1); !ok {
+				return
+			}
+		}
+		switch fn {
+		case "srgb":
+			copy(rgba[:3], x[:])
+			return rgba, true
+		case "display-p3":
+			for i, v := range x {
+				x[i] = linear(v)
+			}
+			x = mulMatrix(xyzD65ToLinear, mulMatrix(p3ToXyz, x))
+		case "xyz", "xyz-d65":
+			x = mulMatrix(xyzD65ToLinear, x)
+		case "xyz-d50":
+			x = mulMatrix(xyzD65ToLinear, mulMatrix(d50ToD65, x))
+		}
+		for i, v := range x {
+			rgba[i] = gamma(v)
+		}
+	default:
+		return rgba, false
+	}
+	return rgba, true
+}
+
+// component parses a number or a percentage of ref. none is 0.
+func component(v string, ref float64) (f float64, ok bool) {
+	if v == "none" {
+		return 0, true
+	}
+	p := strings.HasSuffix(v, "%")
+	f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+	if err != nil {
+		return 0, false
+	}
+	if p {
+		f = f * ref / 100
+	}
+	return f, true
+}
+
+// hue parses an angle in degrees.
+func hue(v string) (h float64, ok bool) {
+	units := []struct {
+		suffix string
+		deg    float64
+	}{
+		{"grad", 0.9},
+		{"turn", 360},
+		{"rad", 180 / math.Pi},
+		{"deg", 1},
+	}
+	for _, u := range units {
+		if strings.HasSuffix(v, u.suffix) {
+			h, ok = component(strings.TrimSuffix(v, u.suffix), 1)
+			return h * u.deg, ok
+		}
+	}
+	return component(v, 1)
+}
+
+func hslToRgb(h, s, l float64) []float64 {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	s = clamp01(s)
+	l = clamp01(l)
+	f := func(n float64) float64 {
+		k := math.Mod(n+h/30, 12)
+		a := s * math.Min(l, 1-l)
+		return l - a*math.Max(-1, math.Min(math.Min(k-3, 9-k), 1))
+	}
+	return []float64{f(0), f(8), f(4)}
+}
+
+func hwbToRgb(h, w, b float64) []float64 {
+	w = clamp01(w)
+	b = clamp01(b)
+	if w+b >= 1 {
+		g := w / (w + b)
+		return []float64{g, g, g}
+	}
+	rgb := hslToRgb(h, 1, 0.5)
+	for i := range rgb {
+		rgb[i] = rgb[i]*(1-w-b) + w
+	}
+	return rgb
+}
+
+var (
+	// d50ToD65 is the Bradford chromatic adaptation of D50 XYZ to D65.
+	d50ToD65 = [3][3]float64{
+		{0.955473421488075, -0.02309845494876471, 0.06325924320057072},
+		{-0.0283697093338637, 1.0099953980813041, 0.021041441191917323},
+		{0.012314014864481998, -0.020507649298898964, 1.330365926242124},
+	}
+	xyzD65ToLinear = [3][3]float64{
+		{3.2409699419045226, -1.537383177570094, -0.4986107602930034},
+		{-0.9692436362808796, 1.8759675015077202, 0.04155505740717559},
+		{0.05563007969699366, -0.20397695888897652, 1.0569715142428786},
+	}
+	p3ToXyz = [3][3]float64{
+		{0.4865709486482162, 0.26566769316909306, 0.1982172852343625},
+		{0.2289745640697488, 0.6917385218365064, 0.079286914093745},
+		{0, 0.04511338185890264, 1.043944368900976},
+	}
+)
+
+func mulMatrix(m [3][3]float64, v [3]float64) (r [3]float64) {
+	for i := range m {
+		r[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+	}
+	return
+}
+
+// labToXyz converts CIE Lab to D50 XYZ.
+func labToXyz(lab [3]float64) [3]float64 {
+	const (
+		k = 24389.0 / 27
+		e = 216.0 / 24389
+	)
+	fy := (lab[0] + 16) / 116
+	fx := lab[1]/500 + fy
+	fz := fy - lab[2]/200
+	f := func(t float64) float64 {
+		if t*t*t > e {
+			return t * t * t
+		}
+		return (116*t - 16) / k
+	}
+	y := lab[0] / k
+	if lab[0] > k*e {
+		y = fy * fy * fy
+	}
+	return [3]float64{
+		f(fx) * 0.3457 / 0.3585,
+		y,
+		f(fz) * (1 - 0.3457 - 0.3585) / 0.3585,
+	}
+}
+
+// oklabToLinear converts OKLab to linear sRGB.
+func oklabToLinear(lab [3]float64) [3]float64 {
+	l := lab[0] + 0.3963377774*lab[1] + 0.2158037573*lab[2]
+	m := lab[0] - 0.1055613458*lab[1] - 0.0638541728*lab[2]
+	s := lab[0] - 0.0894841775*lab[1] - 1.2914855480*lab[2]
+	l, m, s = l*l*l, m*m*m, s*s*s
+	return [3]float64{
+		4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s,
+	}
+}
+
+// gamma applies the sRGB transfer function to a linear component.
+func gamma(v float64) float64 {
+	if math.Abs(v) <= 0.0031308 {
+		return 12.92 * v
+	}
+	return math.Copysign(1.055*math.Pow(math.Abs(v), 1/2.4)-0.055, v)
+}
+
+// linear inverts gamma.
+func linear(v float64) float64 {
+	if math.Abs(v) <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Copysign(math.Pow((math.Abs(v)+0.055)/1.055, 2.4), v)
+}
+
+// over composites the premultiplied color c over d.
+func over(c, d draw.Color) draw.Color {
+	a := uint32(c & 0xff)
+	x := uint32(0)
+	for sh := 24; sh >= 0; sh -= 8 {
+		v := uint32(c)>>sh&0xff + (uint32(d)>>sh&0xff)*(0xff-a)/0xff
+		x |= v << sh
+	}
+	return draw.Color(x)
+}
+
+// color resolves currentColor and parses other colors with colorHex.
+func (cs Map) color(v string) (c draw.Color, ok bool) {
+	if strings.EqualFold(v, "currentColor") {
+		return cs.Color(), true
+	}
+	return colorHex(v)
+}
+
+// Color is the text color composited over the background if it is
+// translucent.
+func (cs Map) Color() draw.Color {
+	if d, ok := cs.Declarations["color"]; ok && !strings.EqualFold(d.Val, "currentColor") {
+		if c, ok := colorHex(d.Val); ok {
+			if c&0xff == 0xff {
+				return c
+			}
+			if bg, ok := cs.backgroundColor(); ok {
+				return over(c, bg)
+			}
+			return over(c, cs.backdrop())
+		}
+	}
+	return draw.Black
+}
+
+// backdrop returns the opaque color an element is drawn on: the
+// background color of the nearest ancestor that has one or white.
+// Translucent backgrounds are composited over their backdrop by
+// backgroundColor.
+func (cs Map) backdrop() draw.Color {
+	if cs.DomTree == nil {
+		return draw.White
+	}
+	p, ok := cs.DomTree.Parent()
+	if !ok {
+		return draw.White
+	}
+	pcs := p.Style()
+	if c, ok := pcs.backgroundColor(); ok {
+		return c
+	}
+	return pcs.backdrop()
+}
+
+// Opacity of the element and its descendants from 0 to 1.
+func (cs Map) Opacity() float64 {
+	v := cs.Css("opacity")
+	if v == "" {
+		return 1
+	}
+	f, ok := component(v, 1)
+	if !ok {
+		log.Printf("opacity: %v", v)
+		return 1
+	}
+	return clamp01(f)
+}
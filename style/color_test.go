@@ -0,0 +1,137 @@
+package style
+
+import (
+	"9fans.net/go/draw"
+	"image"
+	"testing"
+)
+
+func TestColorSyntax(t *testing.T) {
+	tests := map[string]draw.Color{
+		"#0a0":                       0x00aa00ff,
+		"#0a08":                      0x005b0088,
+		"#00AA00":                    0x00aa00ff,
+		"#00aa0080":                  0x00550080,
+		"Green":                      0x008000ff,
+		"transparent":                draw.Transparent,
+		"Canvas":                     draw.White,
+		"rgb(255, 0, 0)":             0xff0000ff,
+		"rgba(255,0,0,0.5)":          0x80000080,
+		"rgb(100%, 50%, 0%)":         0xff8000ff,
+		"rgb(255 0 0 / 50%)":         0x80000080,
+		"rgba(255 0 0)":              0xff0000ff,
+		"rgb(300 -5 none)":           0xff0000ff,
+		"hsl(120, 100%, 50%)":        0x00ff00ff,
+		"hsl(120deg 100% 25%)":       0x008000ff,
+		"hsla(0.5turn 100% 50% / 1)": 0x00ffffff,
+		"hsl(3.14159rad 100% 50%)":   0x00ffffff,
+		"hsl(-120 100% 50%)":         0x0000ffff,
+		"hsl(0 0% 100% / 0)":         draw.Transparent,
+		"hwb(0 0% 0%)":               0xff0000ff,
+		"hwb(120 0% 50%)":            0x008000ff,
+		"hwb(0 60% 60%)":             0x808080ff,
+		"lab(100 0 0)":               0xffffffff,
+		"lab(0% 0 0)":                0x000000ff,
+		"lch(54.29 106.84 40.85)":    0xff0000ff,
+		"oklab(0.628 0.2249 0.1258)": 0xff0000ff,
+		"oklch(100% 0 0)":            0xffffffff,
+		"color(srgb 1 0.5 0)":        0xff8000ff,
+		"color(srgb-linear 1 0 0)":   0xff0000ff,
+		"color(display-p3 0 0 1)":    0x0000ffff,
+		"color(xyz 0.9505 1 1.089)":  0xffffffff,
+	}
+	for v, exp := range tests {
+		c, ok := colorHex(v)
+		if !ok || c != exp {
+			t.Errorf("%v: %x %v", v, uint32(c), ok)
+		}
+	}
+	for _, v := range []string{"", "#12", "#ggg", "rgb(1 2)", "rgb(a, b, c)", "hsl(1foo 0% 0%)", "foo(1 2 3)", "notacolor"} {
+		if _, ok := colorHex(v); ok {
+			t.Errorf("%v", v)
+		}
+	}
+}
+
+func TestOver(t *testing.T) {
+	if c := over(0x80000080, draw.White); c != 0xff7f7fff {
+		t.Errorf("%x", uint32(c))
+	}
+	if c := over(draw.Transparent, draw.Red); c != draw.Red {
+		t.Errorf("%x", uint32(c))
+	}
+}
+
+// tree is a DomTree with a parent.
+type tree struct {
+	m      Map
+	parent *tree
+}
+
+func (tr *tree) Rect() image.Rectangle { return image.Rectangle{} }
+func (tr *tree) Style() Map            { return tr.m }
+func (tr *tree) Parent() (p DomTree, ok bool) {
+	if tr.parent == nil {
+		return nil, false
+	}
+	return tr.parent, true
+}
+
+func child(parent *tree, m Map) *tree {
+	tr := &tree{m: m, parent: parent}
+	tr.m.DomTree = tr
+	return tr
+}
+
+func TestComposite(t *testing.T) {
+	root := child(nil, decls("background-color", "rgb(0 0 255 / 50%)"))
+	mid := child(root, decls("background", "rgba(255, 0, 0, 0.5)"))
+	leaf := child(mid, decls("color", "#00000080"))
+
+	if c, ok := root.m.backgroundColor(); !ok || c != 0x7f7fffff {
+		t.Errorf("%x", uint32(c))
+	}
+	if c, ok := mid.m.backgroundColor(); !ok || c != 0xbf3f7fff {
+		t.Errorf("%x", uint32(c))
+	}
+	if c := leaf.m.Color(); c != 0x5f1f3fff {
+		t.Errorf("%x", uint32(c))
+	}
+	if _, ok := child(mid, decls("background-color", "transparent")).m.backgroundColor(); ok {
+		t.Errorf("transparent")
+	}
+	if c := child(mid, decls("color", "red", "border-color", "currentColor")).m.Color(); c != draw.Red {
+		t.Errorf("%x", uint32(c))
+	}
+}
+
+func TestCurrentColor(t *testing.T) {
+	p := decls("color", "blue")
+	cs := p.ApplyChildStyle(decls("color", "currentColor", "border-color", "currentcolor"), false)
+	if cs.Color() != draw.Blue {
+		t.Errorf("%x", uint32(cs.Color()))
+	}
+	if c, ok := cs.borderColor("currentColor"); !ok || c != draw.Blue {
+		t.Errorf("%x", uint32(c))
+	}
+}
+
+func TestOpacity(t *testing.T) {
+	tests := map[string]float64{
+		"":    1,
+		"0.5": 0.5,
+		"25%": 0.25,
+		"2":   1,
+		"-1":  0,
+		"x":   1,
+	}
+	for v, exp := range tests {
+		if op := decls("opacity", v).Opacity(); op != exp {
+			t.Errorf("%v: %v", v, op)
+		}
+	}
+	p := decls("opacity", "0.5", "color", "red")
+	if _, ok := p.ApplyChildStyle(decls(), false).Declarations["opacity"]; ok {
+		t.Errorf("opacity inherited")
+	}
+}
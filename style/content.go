@@ -0,0 +1,383 @@
+package style
+
+import (
+	"golang.org/x/net/html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Counters holds the CSS counters and the quote nesting level while
+// the document is traversed in tree order.
+type Counters struct {
+	vals   map[string][]int
+	scopes [][]string
+	quotes int
+}
+
+func NewCounters() *Counters {
+	return &Counters{
+		vals:   make(map[string][]int),
+		scopes: [][]string{nil},
+	}
+}
+
+// Clone returns a copy of c, e.g. to continue the traversal at an
+// element again when it is restyled.
+func (c *Counters) Clone() *Counters {
+	cc := &Counters{
+		vals:   make(map[string][]int, len(c.vals)),
+		scopes: make([][]string, len(c.scopes)),
+		quotes: c.quotes,
+	}
+	for name, vs := range c.vals {
+		cc.vals[name] = append([]int(nil), vs...)
+	}
+	for i, s := range c.scopes {
+		cc.scopes[i] = append([]string(nil), s...)
+	}
+	return cc
+}
+
+// Empty reports whether c is like new, i.e. holds no counters and no
+// open quotes.
+func (c *Counters) Empty() bool {
+	return len(c.vals) == 0 && c.quotes == 0
+}
+
+// Enter the children of an element. Counters reset by the children
+// are in scope until Leave.
+func (c *Counters) Enter() {
+	c.scopes = append(c.scopes, nil)
+}
+
+// Leave the children of an element.
+func (c *Counters) Leave() {
+	l := len(c.scopes) - 1
+	for _, name := range c.scopes[l] {
+		vs := c.vals[name]
+		if len(vs) <= 1 {
+			delete(c.vals, name)
+		} else {
+			c.vals[name] = vs[:len(vs)-1]
+		}
+	}
+	c.scopes = c.scopes[:l]
+}
+
+// Reset instantiates a new counter name with value v.
+func (c *Counters) Reset(name string, v int) {
+	c.vals[name] = append(c.vals[name], v)
+	l := len(c.scopes) - 1
+	c.scopes[l] = append(c.scopes[l], name)
+}
+
+// Set the innermost counter name to v.
+func (c *Counters) Set(name string, v int) {
+	vs := c.vals[name]
+	if len(vs) == 0 {
+		c.Reset(name, v)
+		return
+	}
+	vs[len(vs)-1] = v
+}
+
+// Increment the innermost counter name by v.
+func (c *Counters) Increment(name string, v int) {
+	vs := c.vals[name]
+	if len(vs) == 0 {
+		c.Reset(name, v)
+		return
+	}
+	vs[len(vs)-1] += v
+}
+
+// Value of the innermost counter name.
+func (c *Counters) Value(name string) int {
+	vs := c.vals[name]
+	if len(vs) == 0 {
+		return 0
+	}
+	return vs[len(vs)-1]
+}
+
+// Update the counters with counter-reset, counter-set and
+// counter-increment of cs, in that order.
+func (c *Counters) Update(cs Map) {
+	for _, p := range []string{"counter-reset", "counter-set", "counter-increment"} {
+		v := cs.Css(p)
+		if v == "" || v == "none" {
+			continue
+		}
+		fs := strings.Fields(v)
+		for i := 0; i < len(fs); i++ {
+			name := fs[i]
+			n := 0
+			if p == "counter-increment" {
+				n = 1
+			}
+			if i+1 < len(fs) {
+				if nn, err := strconv.Atoi(fs[i+1]); err == nil {
+					n = nn
+					i++
+				}
+			}
+			switch p {
+			case "counter-reset":
+				c.Reset(name, n)
+			case "counter-set":
+				c.Set(name, n)
+			default:
+				c.Increment(name, n)
+			}
+		}
+	}
+}
+
+// Content evaluates the content property of a pseudo-element of n. ok
+// is false if no box is generated.
+func (cs Map) Content(n *html.Node, c *Counters) (t string, ok bool) {
+	v := strings.TrimSpace(cs.Css("content"))
+	if v == "" || v == "none" || v == "normal" {
+		return "", false
+	}
+	var b strings.Builder
+	for _, tok := range contentTokens(v) {
+		switch {
+		case strings.HasPrefix(tok, `"`) || strings.HasPrefix(tok, `'`):
+			b.WriteString(unquote(tok))
+		case strings.HasPrefix(tok, "attr(") && strings.HasSuffix(tok, ")"):
+			arg := strings.TrimSpace(tok[5 : len(tok)-1])
+			for _, a := range n.Attr {
+				if a.Key == arg {
+					b.WriteString(a.Val)
+				}
+			}
+		case strings.HasPrefix(tok, "counter(") && strings.HasSuffix(tok, ")"):
+			args := contentArgs(tok[8 : len(tok)-1])
+			st := "decimal"
+			if len(args) > 1 {
+				st = args[1]
+			}
+			b.WriteString(CounterStyle(c.Value(args[0]), st))
+		case strings.HasPrefix(tok, "counters(") && strings.HasSuffix(tok, ")"):
+			args := contentArgs(tok[9 : len(tok)-1])
+			if len(args) < 2 {
+				continue
+			}
+			st := "decimal"
+			if len(args) > 2 {
+				st = args[2]
+			}
+			l := make([]string, 0, len(c.vals[args[0]]))
+			for _, v := range c.vals[args[0]] {
+				l = append(l, CounterStyle(v, st))
+			}
+			b.WriteString(strings.Join(l, unquote(args[1])))
+		case tok == "open-quote":
+			b.WriteString(cs.quote(c.quotes, true))
+			c.quotes++
+		case tok == "close-quote":
+			if c.quotes > 0 {
+				c.quotes--
+			}
+			b.WriteString(cs.quote(c.quotes, false))
+		case tok == "no-open-quote":
+			c.quotes++
+		case tok == "no-close-quote":
+			if c.quotes > 0 {
+				c.quotes--
+			}
+		}
+	}
+	return b.String(), true
+}
+
+// quote returns the open or close quote of nesting level depth.
+func (cs Map) quote(depth int, open bool) string {
+	qs := []string{"“", "”", "‘", "’"}
+	if v := cs.Css("quotes"); v == "none" {
+		return ""
+	} else if v != "" && v != "auto" {
+		qs = qs[:0]
+		for _, tok := range contentTokens(v) {
+			qs = append(qs, unquote(tok))
+		}
+	}
+	if len(qs) < 2 {
+		return ""
+	}
+	i := 2 * depth
+	if i+1 >= len(qs) {
+		i = len(qs) - 2
+	}
+	if !open {
+		i++
+	}
+	return qs[i]
+}
+
+// contentTokens splits v into strings, functions and keywords. Tokens
+// need not be separated by spaces, e.g. counter(h)". ".
+func contentTokens(v string) (toks []string) {
+	for i := 0; i < len(v); {
+		switch c := v[i]; {
+		case c == ' ':
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(v) && v[j] != c {
+				if v[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(v) {
+				j = len(v) - 1
+			}
+			toks = append(toks, v[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			depth := 0
+			for j < len(v) && (depth > 0 || v[j] != ' ' && v[j] != '"' && v[j] != '\'') {
+				if v[j] == '(' {
+					depth++
+				} else if v[j] == ')' {
+					depth--
+				} else if v[j] == '"' || v[j] == '\'' {
+					q := v[j]
+					for j++; j < len(v) && v[j] != q; j++ {
+					}
+				}
+				j++
+				if depth == 0 && v[j-1] == ')' {
+					break
+				}
+			}
+			if j > len(v) {
+				j = len(v)
+			}
+			toks = append(toks, v[i:j])
+			i = j
+		}
+	}
+	return
+}
+
+func contentArgs(v string) (args []string) {
+	for _, a := range strings.Split(v, ",") {
+		args = append(args, strings.TrimSpace(a))
+	}
+	return
+}
+
+// unquote removes the quotes of a CSS string and resolves escapes.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		j := i + 1
+		for j < len(s) && j < i+7 && isHex(s[j]) {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(s[j])
+			i = j
+			continue
+		}
+		r, _ := strconv.ParseInt(s[i+1:j], 16, 32)
+		if r == 0 || !utf8.ValidRune(rune(r)) {
+			r = utf8.RuneError
+		}
+		b.WriteRune(rune(r))
+		if j < len(s) && s[j] == ' ' {
+			j++
+		}
+		i = j - 1
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// CounterStyle formats v with the list-style-type st.
+func CounterStyle(v int, st string) string {
+	switch st {
+	case "none":
+		return ""
+	case "disc":
+		return "•"
+	case "circle":
+		return "◦"
+	case "square":
+		return "▪"
+	case "decimal-leading-zero":
+		if 0 <= v && v < 10 {
+			return "0" + strconv.Itoa(v)
+		}
+	case "lower-roman", "upper-roman":
+		if r := roman(v); r != "" {
+			if st == "lower-roman" {
+				return strings.ToLower(r)
+			}
+			return r
+		}
+	case "lower-alpha", "lower-latin", "upper-alpha", "upper-latin":
+		if a := alphabetic(v, 'a', 26); a != "" {
+			if strings.HasPrefix(st, "upper") {
+				return strings.ToUpper(a)
+			}
+			return a
+		}
+	case "lower-greek":
+		if a := alphabetic(v, 'α', 24); a != "" {
+			return a
+		}
+	default:
+		if strings.HasPrefix(st, `"`) || strings.HasPrefix(st, `'`) {
+			return unquote(st)
+		}
+	}
+	return strconv.Itoa(v)
+}
+
+func roman(v int) (r string) {
+	if v <= 0 || v >= 4000 {
+		return ""
+	}
+	vals := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	syms := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	for i, n := range vals {
+		for ; v >= n; v -= n {
+			r += syms[i]
+		}
+	}
+	return
+}
+
+func alphabetic(v int, first rune, n int) (a string) {
+	if v <= 0 {
+		return ""
+	}
+	for ; v > 0; v = (v - 1) / n {
+		r := first + rune((v-1)%n)
+		if first == 'α' && r >= 'ς' {
+			// skip final sigma
+			r++
+		}
+		a = string(r) + a
+	}
+	return
+}
@@ -0,0 +1,119 @@
+package style
+
+import (
+	"golang.org/x/net/html"
+	"strings"
+	"testing"
+)
+
+func TestCounterStyle(t *testing.T) {
+	tests := []struct {
+		v   int
+		st  string
+		exp string
+	}{
+		{3, "decimal", "3"},
+		{7, "decimal-leading-zero", "07"},
+		{1994, "upper-roman", "MCMXCIV"},
+		{4, "lower-roman", "iv"},
+		{28, "lower-alpha", "ab"},
+		{26, "upper-latin", "Z"},
+		{18, "lower-greek", "σ"},
+		{1, "disc", "•"},
+		{1, "none", ""},
+		{1, `"- "`, "- "},
+	}
+	for _, tt := range tests {
+		if res := CounterStyle(tt.v, tt.st); res != tt.exp {
+			t.Errorf("%v %v: %v != %v", tt.v, tt.st, res, tt.exp)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	if s := unquote(`"\201C x\"y"`); s != `“x"y` {
+		t.Fatalf("%v", s)
+	}
+}
+
+func TestContent(t *testing.T) {
+	n := &html.Node{
+		Type: html.ElementNode,
+		Data: "a",
+		Attr: []html.Attribute{{Key: "href", Val: "/x"}},
+	}
+	c := NewCounters()
+	c.Reset("h", 0)
+	c.Increment("h", 2)
+	c.Enter()
+	c.Reset("h", 0)
+	c.Increment("h", 1)
+
+	cs := Map{Declarations: make(map[string]Declaration)}
+	cs.SetCss("content", `"(" attr(href) ") " counters(h,".") open-quote close-quote`)
+	if s, ok := cs.Content(n, c); !ok || s != "(/x) 2.1“”" {
+		t.Fatalf("%v", s)
+	}
+	c.Leave()
+	cs.SetCss("content", `counter(h, upper-roman)`)
+	if s, _ := cs.Content(n, c); s != "II" {
+		t.Fatalf("%v", s)
+	}
+	cs.SetCss("content", `counter(h)". "attr(href)`)
+	if s, _ := cs.Content(n, c); s != "2. /x" {
+		t.Fatalf("%v", s)
+	}
+	for v, exp := range map[string]string{
+		"attr(":         "",
+		"counter(":      "",
+		"counters(":     "",
+		`"a" counter(h`: "a",
+	} {
+		cs.SetCss("content", v)
+		if s, ok := cs.Content(n, c); !ok || s != exp {
+			t.Fatalf("%v: %v", v, s)
+		}
+	}
+	cs.SetCss("content", "none")
+	if _, ok := cs.Content(n, c); ok {
+		t.Fatalf("none generates no box")
+	}
+}
+
+func TestContentTokens(t *testing.T) {
+	tests := map[string]string{
+		`"(" attr(href) ") "`:      `"(";attr(href);") "`,
+		`counter(h)". "`:           `counter(h);". "`,
+		`"§"counters(h, ".")"x"`:   `"§";counters(h, ".");"x"`,
+		`counter(a)counter(b)`:     `counter(a);counter(b)`,
+		`rgb(1, 2, 3) 50%`:         `rgb(1, 2, 3);50%`,
+		`url("a b.png") no-repeat`: `url("a b.png");no-repeat`,
+		`attr(`:                    `attr(`,
+	}
+	for v, exp := range tests {
+		if s := strings.Join(contentTokens(v), ";"); s != exp {
+			t.Errorf("%v: %v", v, s)
+		}
+	}
+}
+
+func TestFetchNodeMapPseudoElement(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<p>text</p>`))
+	if err != nil {
+		t.Fatal()
+	}
+	p := grep(doc, "p")
+	m, err := FetchNodeMap(doc, `p { color: red; } p::before { content: "»"; } p:after { content: "«"; }`)
+	if err != nil {
+		t.Fatal()
+	}
+	if m[p].Css("color") != "red" || m[p].Css("content") != "" {
+		t.Fatalf("%+v", m[p])
+	}
+	if c := m[p].PseudoElements["before"].Css("content"); c != `"»"` {
+		t.Fatalf("%v", c)
+	}
+	if c := m[p].PseudoElements["after"].Css("content"); c != `"«"` {
+		t.Fatalf("%v", c)
+	}
+}
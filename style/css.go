@@ -29,15 +29,48 @@ type Rule struct {
 
 type Selector struct {
 	Val string
+
+	// PseudoElement is set for matched selectors like a::before
+	PseudoElement string
 }
 
 type Declaration struct {
 	Important   bool
 	Specificity cascadia.Specificity
+	Origin      Origin
 	Prop        string
 	Val         string
 }
 
+// Origin of a declaration, ordered by precedence.
+type Origin int
+
+const (
+	// Inherited values lose against all values of the element itself.
+	Inherited Origin = iota - 2
+	// Hint declarations are mapped from presentational attributes
+	// like width or cellpadding.
+	Hint
+	// Author declarations are matched by style sheet selectors.
+	Author
+	// StyleAttr declarations come from the style attribute.
+	StyleAttr
+)
+
+// Less reports whether d is overridden by dd in the cascade.
+func (d Declaration) Less(dd Declaration) bool {
+	if (d.Origin == Inherited) != (dd.Origin == Inherited) {
+		return d.Origin == Inherited
+	}
+	if d.Important != dd.Important {
+		return dd.Important
+	}
+	if d.Origin != dd.Origin {
+		return d.Origin < dd.Origin
+	}
+	return d.Specificity.Less(dd.Specificity)
+}
+
 func Preprocess(s string) (bs []byte, ct opossum.ContentType, imports []string, err error) {
 	buf := bytes.NewBufferString("")
 	l := css.NewLexer(parse.NewInputString(s))
@@ -3,6 +3,7 @@ package style
 import (
 	"9fans.net/go/draw"
 	"github.com/psilva261/opossum/logger"
+	"image"
 	"testing"
 )
 
@@ -56,31 +57,19 @@ func TestBackgroundGradient(t *testing.T) {
 		"linear-gradient(to right,rgb(0,60,60,1),rgba(0,180,180,1))":            0x007878ff,
 		"linear-gradient(to bottom, rgba(40,40,40,1) 0%,rgba(40,40,40,1) 100%)": 0x282828ff,
 	}
+	m := Map{}
 	for v, cc := range values {
-		m := Map{
-			Declarations: make(map[string]Declaration),
-		}
-		m.Declarations["background"] = Declaration{
-			Prop: "background",
-			Val:  v,
-		}
-		c, ok := m.backgroundGradient()
+		g, ok := m.ParseGradient(v)
 		if !ok {
-			t.Fail()
+			t.Fatalf("%v", v)
 		}
-		if uint32(c) != cc {
-			t.Fail()
+		rgba := g.Render(image.Pt(101, 1))
+		c := rgba.RGBAAt(50, 0)
+		if x := uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A); x != cc {
+			t.Errorf("%v: %x", v, x)
 		}
 	}
-	m := Map{
-		Declarations: make(map[string]Declaration),
-	}
-	m.Declarations["background"] = Declaration{
-		Prop: "background",
-		Val:  "#123456",
-	}
-	_, ok := m.backgroundGradient()
-	if ok {
+	if _, ok := m.ParseGradient("#123456"); ok {
 		t.Fail()
 	}
 }
@@ -0,0 +1,100 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FlexItem returns flex-grow, flex-shrink and flex-basis in px where
+// basis -1 means auto or content. Longhands override the flex shorthand.
+func (cs Map) FlexItem() (grow, shrink float64, basis int) {
+	grow, shrink, basis = 0, 1, -1
+	bs := "auto"
+
+	if v := cs.Css("flex"); v != "" {
+		switch v {
+		case "none":
+			shrink = 0
+		case "auto":
+			grow = 1
+		case "initial":
+		default:
+			var nums []float64
+			for _, f := range strings.Fields(v) {
+				if x, err := strconv.ParseFloat(f, 64); err == nil && len(nums) < 2 {
+					nums = append(nums, x)
+				} else {
+					bs = f
+				}
+			}
+			if len(nums) > 0 {
+				grow = nums[0]
+				if bs == "auto" {
+					// flex: <number> means flex-basis: 0
+					bs = "0"
+				}
+			}
+			if len(nums) > 1 {
+				shrink = nums[1]
+			}
+		}
+	}
+	if d, ok := cs.Declarations["flex-grow"]; ok {
+		if x, err := strconv.ParseFloat(d.Val, 64); err == nil {
+			grow = x
+		}
+	}
+	if d, ok := cs.Declarations["flex-shrink"]; ok {
+		if x, err := strconv.ParseFloat(d.Val, 64); err == nil {
+			shrink = x
+		}
+	}
+	if d, ok := cs.Declarations["flex-basis"]; ok {
+		bs = d.Val
+	}
+	switch bs {
+	case "auto", "content", "":
+		if w := cs.Css("width"); w != "" && w != "auto" && !strings.HasSuffix(w, "%") {
+			basis = cs.Width()
+		}
+	default:
+		if f, _, err := length(&cs, bs); err == nil {
+			basis = int(f)
+		}
+	}
+	if basis < -1 {
+		basis = -1
+	}
+	return
+}
+
+// Gap returns row-gap and column-gap in px.
+func (cs Map) Gap() (row, column int) {
+	if v := cs.Css("gap"); v != "" {
+		fs := strings.Fields(v)
+		if f, _, err := length(&cs, fs[0]); err == nil {
+			row = int(f)
+			column = row
+		}
+		if len(fs) > 1 {
+			if f, _, err := length(&cs, fs[1]); err == nil {
+				column = int(f)
+			}
+		}
+	}
+	for _, p := range []string{"row-gap", "grid-row-gap"} {
+		if v := cs.Css(p); v != "" && v != "normal" {
+			if f, _, err := length(&cs, v); err == nil {
+				row = int(f)
+			}
+		}
+	}
+	for _, p := range []string{"column-gap", "grid-column-gap"} {
+		if v := cs.Css(p); v != "" && v != "normal" {
+			if f, _, err := length(&cs, v); err == nil {
+				column = int(f)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,342 @@
+package style
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/psilva261/opossum/font"
+	"github.com/psilva261/opossum/img"
+	"github.com/psilva261/opossum/logger"
+	"golang.org/x/image/font/sfnt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fontFace is a font declared with @font-face.
+type fontFace struct {
+	family string
+	style  string
+	weight [2]int
+	srcs   []string
+	ranges []font.Range
+
+	once sync.Once
+	font *sfnt.Font
+	hash string
+}
+
+var (
+	// fontMu guards fontFaces and webFonts, fonts are looked up while
+	// the page is laid out and restyled.
+	fontMu    sync.Mutex
+	fontFaces []*fontFace
+
+	// webFonts maps family, weight, style and size to the rasterized
+	// font file. Empty names are fonts that are being loaded or could
+	// not be loaded.
+	webFonts = make(map[string]string)
+
+	// fontGen is increased when the font faces are reset, fonts loaded
+	// for a previous page are dropped then.
+	fontGen int
+)
+
+// FontLoaded is called when a web font was loaded in the background.
+// Until then the fonts of font-family without web fonts are used.
+var FontLoaded func()
+
+// ResetFontFaces removes the @font-face rules, e.g. when a new page is
+// loaded.
+func ResetFontFaces() {
+	fontMu.Lock()
+	defer fontMu.Unlock()
+	fontFaces = nil
+	webFonts = make(map[string]string)
+	fontGen++
+}
+
+var reFontSrc = regexp.MustCompile(`url\(\s*['"]?([^'")]+)['"]?\s*\)\s*(format\(\s*['"]?([^'")]+)['"]?\s*\))?`)
+
+// addFontFace registers the font of the declarations of an @font-face
+// rule. Sources that are no TrueType, OpenType or WOFF fonts are
+// ignored.
+func addFontFace(ds []Declaration) {
+	ff := &fontFace{
+		style:  "normal",
+		weight: [2]int{400, 400},
+	}
+	for _, d := range ds {
+		switch d.Prop {
+		case "font-family":
+			ff.family = strings.ToLower(unquote(strings.TrimSpace(d.Val)))
+		case "font-style":
+			ff.style = strings.Fields(d.Val + " normal")[0]
+		case "font-weight":
+			var cs Map
+			fs := strings.Fields(d.Val)
+			for i := 0; i < 2 && i < len(fs); i++ {
+				if w, ok := cs.fontWeight(fs[i]); ok {
+					ff.weight[i] = w
+				}
+			}
+			if len(fs) < 2 {
+				ff.weight[1] = ff.weight[0]
+			}
+		case "unicode-range":
+			rs, err := font.ParseUnicodeRange(d.Val)
+			if err != nil {
+				log.Errorf("@font-face: %v", err)
+				continue
+			}
+			ff.ranges = rs
+		case "src":
+			for _, s := range splitComma(d.Val) {
+				m := reFontSrc.FindStringSubmatch(s)
+				if m == nil {
+					continue
+				}
+				u, format := m[1], strings.ToLower(m[3])
+				ext := strings.ToLower(strings.SplitN(strings.SplitN(u, "?", 2)[0], "#", 2)[0])
+				if format == "embedded-opentype" || format == "svg" || strings.HasSuffix(ext, ".eot") || strings.HasSuffix(ext, ".svg") {
+					continue
+				}
+				ff.srcs = append(ff.srcs, u)
+			}
+		}
+	}
+	if ff.family == "" || len(ff.srcs) == 0 {
+		return
+	}
+	fontMu.Lock()
+	defer fontMu.Unlock()
+	for _, f := range fontFaces {
+		if f.family == ff.family && f.style == ff.style && f.weight == ff.weight && fmt.Sprint(f.srcs, f.ranges) == fmt.Sprint(ff.srcs, ff.ranges) {
+			return
+		}
+	}
+	fontFaces = append(fontFaces, ff)
+}
+
+// load the first source of the font face that can be parsed.
+func (ff *fontFace) load() *sfnt.Font {
+	ff.once.Do(ff.fetch)
+	return ff.font
+}
+
+func (ff *fontFace) fetch() {
+	for _, src := range ff.srcs {
+		var buf []byte
+		var err error
+		if strings.HasPrefix(src, "data:") {
+			buf, _, err = img.ParseDataUri(src)
+		} else if fetcher == nil {
+			err = fmt.Errorf("no fetcher")
+		} else {
+			u, err1 := fetcher.LinkedUrl(src)
+			if err1 != nil {
+				err = err1
+			} else {
+				buf, _, err = fetcher.Get(u)
+			}
+		}
+		if err == nil {
+			ff.font, err = font.Parse(buf)
+		}
+		if err != nil {
+			log.Errorf("@font-face %v: %v: %v", ff.family, src, err)
+			continue
+		}
+		ff.hash = fmt.Sprintf("%x", sha256.Sum256(buf))
+		return
+	}
+}
+
+// matchFontFaces returns the font faces of the first family that has
+// any, with the best matching style and weight. All faces of the
+// chosen style and weight are returned because they might cover
+// different unicode ranges.
+func matchFontFaces(families []string, style string, weight int) (ffs []*fontFace) {
+	for _, fam := range families {
+		var best *fontFace
+		bestStyle, bestWeight := 0, 0
+		for _, ff := range fontFaces {
+			if ff.family != fam {
+				continue
+			}
+			s, w := styleRank(style, ff.style), weightRank(weight, ff.weight)
+			if best == nil || s < bestStyle || s == bestStyle && w < bestWeight {
+				best, bestStyle, bestWeight = ff, s, w
+			}
+		}
+		if best == nil {
+			continue
+		}
+		for _, ff := range fontFaces {
+			if ff.family == fam && ff.style == best.style && ff.weight == best.weight {
+				ffs = append(ffs, ff)
+			}
+		}
+		return
+	}
+	return
+}
+
+// styleRank of the font style available when style is desired. Lower
+// is better.
+func styleRank(style, available string) int {
+	var order []string
+	switch style {
+	case "italic":
+		order = []string{"italic", "oblique", "normal"}
+	case "oblique":
+		order = []string{"oblique", "italic", "normal"}
+	default:
+		order = []string{"normal", "oblique", "italic"}
+	}
+	for i, s := range order {
+		if s == available {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// weightRank of the weight range available when weight is desired.
+// Lower is better. Between 400 and 500 the weights up to 500 are
+// preferred, then lighter and then heavier ones. Below 400 lighter
+// and above 500 heavier weights are preferred.
+func weightRank(weight int, available [2]int) int {
+	w := weight
+	if w < available[0] {
+		w = available[0]
+	} else if w > available[1] {
+		w = available[1]
+	}
+	d := int(math.Abs(float64(w - weight)))
+	switch {
+	case w == weight:
+		return 0
+	case 400 <= weight && weight <= 500:
+		if weight < w && w <= 500 {
+			return d
+		} else if w < weight {
+			return 1000 + d
+		}
+		return 2000 + d
+	case weight < 400:
+		if w < weight {
+			return d
+		}
+		return 1000 + d
+	default:
+		if w > weight {
+			return d
+		}
+		return 1000 + d
+	}
+}
+
+// fontFamilies returns the lower case names of font-family or the font
+// shorthand.
+func (cs Map) fontFamilies() (fams []string) {
+	v := cs.Css("font-family")
+	if v == "" {
+		ts := strings.Fields(cs.Css("font"))
+		i := len(cs.fontShorthand()) + 1
+		if i < len(ts) && strings.HasPrefix(ts[i], "/") {
+			// line height
+			i++
+			if ts[i-1] == "/" {
+				i++
+			}
+		}
+		if i >= len(ts) {
+			return
+		}
+		v = strings.Join(ts[i:], " ")
+	}
+	for _, f := range splitComma(v) {
+		if f = strings.ToLower(unquote(strings.TrimSpace(f))); f != "" {
+			fams = append(fams, f)
+		}
+	}
+	return
+}
+
+// webFontFilename returns the font file of the @font-face fonts
+// matching cs at the font size of cs. Fonts are fetched and rasterized
+// in the background, FontLoaded is called when they are available.
+func (cs Map) webFontFilename() (fn string, ok bool) {
+	fontMu.Lock()
+	defer fontMu.Unlock()
+	if len(fontFaces) == 0 {
+		return "", false
+	}
+	fams := cs.fontFamilies()
+	style, weight := cs.FontStyle(), cs.FontWeight()
+	px := int(math.Round(2 * cs.FontSize()))
+	key := fmt.Sprintf("%v/%v/%v/%v", fams, weight, style, px)
+	if fn, ok := webFonts[key]; ok {
+		return fn, fn != ""
+	}
+	webFonts[key] = ""
+	if ffs := matchFontFaces(fams, style, weight); len(ffs) > 0 {
+		go loadWebFont(key, ffs, px, fontGen)
+	}
+	return "", false
+}
+
+// loadWebFont fetches and rasterizes the font faces ffs at px pixels
+// as web font key.
+func loadWebFont(key string, ffs []*fontFace, px, gen int) {
+	fn, err := writeWebFont(ffs, px)
+	if err != nil {
+		log.Errorf("web font %v: %v", key, err)
+		return
+	}
+	fontMu.Lock()
+	if gen != fontGen {
+		fontMu.Unlock()
+		return
+	}
+	webFonts[key] = fn
+	fontMu.Unlock()
+	if FontLoaded != nil {
+		FontLoaded()
+	}
+}
+
+// writeWebFont returns the font file of ffs at px pixels, rasterized
+// into the font cache unless it is there already.
+func writeWebFont(ffs []*fontFace, px int) (fn string, err error) {
+	var faces []font.Face
+	h := sha256.New()
+	for _, ff := range ffs {
+		if f := ff.load(); f != nil {
+			faces = append(faces, font.Face{Font: f, Ranges: ff.ranges})
+			fmt.Fprintf(h, "%v %v\n", ff.hash, ff.ranges)
+		}
+	}
+	if len(faces) == 0 {
+		return "", fmt.Errorf("no source could be loaded")
+	}
+	dir := filepath.Join(fontCacheDir(), fmt.Sprintf("%x", h.Sum(nil))[:32], strconv.Itoa(px))
+	fn = filepath.Join(dir, "font")
+	if _, err := os.Stat(fn); err == nil {
+		return fn, nil
+	}
+	return font.WriteFont(dir, faces, px)
+}
+
+// fontCacheDir is the cache directory of rasterized fonts.
+func fontCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "opossum", "fonts")
+}
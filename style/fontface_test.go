@@ -0,0 +1,182 @@
+package style
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/net/html"
+)
+
+func TestFontFace(t *testing.T) {
+	defer ResetFontFaces()
+	doc, err := html.Parse(strings.NewReader(`<html><body><i>x</i></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	css := `
+@font-face {
+	font-family: "Icons";
+	src: url(icons.eot?#iefix) format("embedded-opentype"), url('icons.woff2') format("woff2"), url(icons.svg#icons) format("svg");
+	unicode-range: U+F000-F2FF;
+}
+@font-face {
+	font-family: Sans;
+	src: local(Sans), url(sans-bold.woff);
+	font-weight: bold;
+}
+@font-face {
+	font-family: Sans;
+	src: url(sans-light.ttf);
+	font-weight: 200 300;
+	font-style: italic;
+}
+@font-face {
+	font-family: Sans;
+	src: url(sans.ttf);
+}
+@font-face {
+	font-family: Sans;
+	src: url(sans-cyrillic.ttf);
+	unicode-range: U+0400-04FF;
+}
+i { color: red; }
+`
+	for i := 0; i < 2; i++ {
+		if _, _, err := FetchNodeRules(doc, css); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(fontFaces) != 5 {
+		t.Fatalf("%+v", fontFaces)
+	}
+	icons := fontFaces[0]
+	if icons.family != "icons" || len(icons.srcs) != 1 || icons.srcs[0] != "icons.woff2" || len(icons.ranges) != 1 || icons.ranges[0].Lo != 0xf000 {
+		t.Errorf("%+v", icons)
+	}
+	if fontFaces[1].weight != [2]int{700, 700} || fontFaces[2].weight != [2]int{200, 300} || fontFaces[2].style != "italic" {
+		t.Errorf("%+v %+v", fontFaces[1], fontFaces[2])
+	}
+
+	srcs := func(ffs []*fontFace) (l []string) {
+		for _, ff := range ffs {
+			l = append(l, ff.srcs...)
+		}
+		return
+	}
+	tests := []struct {
+		families []string
+		style    string
+		weight   int
+		srcs     string
+	}{
+		{[]string{"icons"}, "normal", 400, "[icons.woff2]"},
+		{[]string{"helvetica", "sans"}, "normal", 400, "[sans.ttf sans-cyrillic.ttf]"},
+		{[]string{"sans"}, "normal", 600, "[sans-bold.woff]"},
+		{[]string{"sans"}, "normal", 100, "[sans.ttf sans-cyrillic.ttf]"},
+		{[]string{"sans"}, "italic", 400, "[sans-light.ttf]"},
+		{[]string{"serif"}, "normal", 400, "[]"},
+	}
+	for _, tt := range tests {
+		if s := srcs(matchFontFaces(tt.families, tt.style, tt.weight)); strings.Join(s, " ") != strings.Trim(tt.srcs, "[]") {
+			t.Errorf("%+v: %v", tt, s)
+		}
+	}
+}
+
+func TestWeightRank(t *testing.T) {
+	// preference order of the available weights
+	tests := map[int][]int{
+		400: {400, 500, 300, 100, 600, 900},
+		500: {500, 400, 300, 600, 900},
+		300: {300, 200, 100, 400, 900},
+		700: {700, 800, 900, 600, 100},
+	}
+	for w, order := range tests {
+		for i := 1; i < len(order); i++ {
+			a := weightRank(w, [2]int{order[i-1], order[i-1]})
+			b := weightRank(w, [2]int{order[i], order[i]})
+			if a >= b {
+				t.Errorf("%v: %v before %v", w, order[i-1], order[i])
+			}
+		}
+	}
+	if weightRank(450, [2]int{100, 900}) != 0 {
+		t.Errorf("range")
+	}
+}
+
+func TestFontFamilies(t *testing.T) {
+	tests := map[string]Map{
+		"[icons sans-serif]":   decls("font-family", `"Icons", sans-serif`),
+		"[georgia serif]":      decls("font", "italic 600 12px/1.5 Georgia, serif"),
+		"[open sans]":          decls("font", "12px / 1.5 'Open Sans'"),
+		"[monospace]":          decls("font", "bold 1em monospace"),
+		"[]":                   decls(),
+		"[fontawesome symbol]": decls("font", "normal normal normal 14px/1 FontAwesome", "font-family", "FontAwesome,Symbol"),
+	}
+	for exp, cs := range tests {
+		if fs := cs.fontFamilies(); strings.Join(fs, " ") != strings.Trim(exp, "[]") {
+			t.Errorf("%v: %v", exp, fs)
+		}
+	}
+}
+
+func TestWebFontFilename(t *testing.T) {
+	defer ResetFontFaces()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	src := "data:font/ttf;base64," + base64.StdEncoding.EncodeToString(goregular.TTF)
+	addFontFace([]Declaration{
+		{Prop: "font-family", Val: "Go"},
+		{Prop: "src", Val: `url("` + src + `") format("truetype")`},
+		{Prop: "unicode-range", Val: "U+0020-007E"},
+	})
+	addFontFace([]Declaration{
+		{Prop: "font-family", Val: "Broken"},
+		{Prop: "src", Val: `url("data:font/ttf;base64,AAAA")`},
+	})
+	loaded := make(chan bool, 10)
+	FontLoaded = func() { loaded <- true }
+	defer func() { FontLoaded = nil }()
+	if _, ok := decls("font-family", "Broken").webFontFilename(); ok {
+		t.Errorf("broken font")
+	}
+	if _, ok := decls("font-family", "serif").webFontFilename(); ok {
+		t.Errorf("no font face")
+	}
+	cs := decls("font-family", "Go, serif", "font-size", "12px")
+	if _, ok := cs.webFontFilename(); ok {
+		t.Fatalf("loaded during layout")
+	}
+	select {
+	case <-loaded:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("not loaded")
+	}
+	if _, ok := decls("font-family", "Broken").webFontFilename(); ok {
+		t.Errorf("broken font")
+	}
+	fn, ok := cs.webFontFilename()
+	if !ok || !strings.HasPrefix(fn, fontCacheDir()) || !strings.HasSuffix(fn, "/24/font") {
+		t.Fatalf("%v %v", fn, ok)
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "0x0020 0x007e 0.0020\n") {
+		t.Errorf("%s", data)
+	}
+	if fn2, _ := cs.webFontFilename(); fn2 != fn {
+		t.Errorf("%v", fn2)
+	}
+	decls("font-family", "Go").webFontFilename()
+	<-loaded
+	if fn3, _ := decls("font-family", "Go").webFontFilename(); fn3 == fn || fn3 == "" {
+		t.Errorf("%v", fn3)
+	}
+}
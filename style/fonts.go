@@ -0,0 +1,304 @@
+package style
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/psilva261/opossum/font"
+	"github.com/psilva261/opossum/logger"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GenericFamilies are the preferred fonts of the generic font families
+// in order of preference. Go and Go Mono are the bundled Go fonts
+// which are also used when none of the fonts is available.
+var GenericFamilies = map[string][]string{
+	"sans-serif": {"HelveticaNeue", "Helvetica", "Arial", "DejaVuSans", "LiberationSans"},
+	"serif":      {"Times", "TimesNewRoman", "Georgia", "DejaVuSerif", "LiberationSerif"},
+	"monospace":  {"DejaVuSansMono", "LiberationMono", "Menlo-Regular", "Courier", "Go Mono"},
+	"system-ui":  {"HelveticaNeue", "Helvetica", "DejaVuSans"},
+}
+
+// SetGenericFamily sets the preferred fonts of a generic family from
+// a specification like "monospace=DejaVuSansMono,Go Mono".
+func SetGenericFamily(spec string) error {
+	i := strings.Index(spec, "=")
+	if i < 0 {
+		return fmt.Errorf("missing =: %v", spec)
+	}
+	g := strings.ToLower(strings.TrimSpace(spec[:i]))
+	if _, ok := GenericFamilies[g]; !ok {
+		return fmt.Errorf("unknown generic family %v", g)
+	}
+	var fs []string
+	for _, f := range strings.Split(spec[i+1:], ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fs = append(fs, f)
+		}
+	}
+	GenericFamilies[g] = fs
+	return nil
+}
+
+// genericFamily returns the generic family f stands for or "".
+func genericFamily(f string) string {
+	switch f {
+	case "sans-serif", "serif", "monospace", "system-ui":
+		return f
+	case "ui-sans-serif", "cursive", "fantasy", "emoji", "math", "fangsong":
+		return "sans-serif"
+	case "ui-serif":
+		return "serif"
+	case "ui-monospace":
+		return "monospace"
+	}
+	return ""
+}
+
+// normFontName returns the lower case font name without separators,
+// e.g. dejavusansmono for DejaVu Sans Mono.
+func normFontName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "/"))
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name)
+}
+
+// goFontMono returns whether the font name is one of the bundled Go
+// fonts and whether it is monospaced.
+func goFontMono(name string) (mono, ok bool) {
+	switch normFontName(name) {
+	case "go", "goregular":
+		return false, true
+	case "gomono":
+		return true, true
+	}
+	return false, false
+}
+
+// fontFilenames returns the fonts of the font-family list in order.
+// fontFile returns the font of a font name. A bundled Go font stands in
+// for a generic family only if no font was found before, so that it is
+// not rasterized unless needed. Go fonts all cover the same glyphs, so
+// only the first one is used.
+func (cs Map) fontFilenames(fontFile func(cs Map, name string) (string, bool)) (fns []string) {
+	hasGo := false
+	add := func(fn string, isGo bool) {
+		if isGo && hasGo {
+			return
+		}
+		hasGo = hasGo || isGo
+		for _, f := range fns {
+			if f == fn {
+				return
+			}
+		}
+		fns = append(fns, fn)
+	}
+	for _, fam := range append(cs.fontFamilies(), "sans-serif") {
+		names := []string{fam}
+		g := genericFamily(fam)
+		if g != "" {
+			names = GenericFamilies[g]
+		}
+		found := false
+		for _, name := range names {
+			var fn string
+			var ok bool
+			mono, isGo := goFontMono(name)
+			if isGo {
+				fn, ok = cs.goFontFilename(mono)
+			} else {
+				fn, ok = fontFile(cs, name)
+			}
+			if ok {
+				add(fn, isGo)
+				found = true
+				break
+			}
+		}
+		if g != "" && !found && len(fns) == 0 {
+			if fn, ok := cs.goFontFilename(g == "monospace"); ok {
+				add(fn, true)
+			}
+		}
+	}
+	return
+}
+
+// availableFontSizes maps fontsrv font names to their sizes.
+var availableFontSizes = make(map[string][]int)
+
+// fontsrvFilename returns the fontsrv font with the name.
+func (cs Map) fontsrvFilename(name string) (string, bool) {
+	f, ok := cs.preferedFontName([]string{name})
+	if !ok {
+		return "", false
+	}
+	f = fontVariant(f, cs.IsBold(), cs.IsItalic())
+	if _, ok := availableFontSizes[f]; !ok {
+		fss, err := fontSizes(f)
+		if err != nil {
+			log.Errorf("font sizes %v: %v", f, err)
+		}
+		availableFontSizes[f] = fss
+	}
+	if len(availableFontSizes[f]) == 0 {
+		return "", false
+	}
+	s := matchClosestFontSize(2*cs.FontSize(), availableFontSizes[f])
+
+	return fmt.Sprintf("/mnt/font/"+f+"%va/font", s), true
+}
+
+var (
+	fallbackMu sync.Mutex
+
+	// fallbackFonts maps lists of font files to the combined font file.
+	fallbackFonts = make(map[string]string)
+)
+
+// fallbackFont returns a font file that takes each glyph from the
+// first of the font files fns that covers it. Metrics are those of the
+// first font.
+func fallbackFont(fns []string) (string, bool) {
+	if len(fns) == 0 {
+		return "", false
+	}
+	if len(fns) == 1 {
+		return fns[0], true
+	}
+	key := strings.Join(fns, "\n")
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	if fn, ok := fallbackFonts[key]; ok {
+		return fn, true
+	}
+	fallbackFonts[key] = fns[0]
+	var buf bytes.Buffer
+	for i, fn := range fns {
+		data, err := readFontFile(fn)
+		if err != nil {
+			log.Errorf("read font %v: %v", fn, err)
+			if i == 0 {
+				return fns[0], true
+			}
+			continue
+		}
+		lines := strings.Split(string(data), "\n")
+		if i == 0 {
+			buf.WriteString(strings.TrimSpace(lines[0]) + "\n")
+		}
+		for _, l := range lines[1:] {
+			fs := strings.Fields(l)
+			if len(fs) < 3 {
+				continue
+			}
+			name := fs[len(fs)-1]
+			if !strings.HasPrefix(name, "/") {
+				name = path.Join(path.Dir(fn), name)
+			}
+			fs[len(fs)-1] = name
+			buf.WriteString(strings.Join(fs, " ") + "\n")
+		}
+	}
+	dir := filepath.Join(fontCacheDir(), "fallback")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Errorf("fallback font: %v", err)
+		return fns[0], true
+	}
+	fn := filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256(buf.Bytes()))[:32]+".font")
+	if err := os.WriteFile(fn, buf.Bytes(), 0600); err != nil {
+		log.Errorf("fallback font: %v", err)
+		return fns[0], true
+	}
+	fallbackFonts[key] = fn
+	return fn, true
+}
+
+// readFontFile returns the font file fn, served by fontsrv if in
+// /mnt/font and not mounted.
+func readFontFile(fn string) ([]byte, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil && strings.HasPrefix(fn, "/mnt/font/") {
+		out, err1 := exec.Command("fontsrv", "-pp", strings.TrimPrefix(fn, "/mnt/font/")).Output()
+		if err1 == nil && len(out) > 0 && out[0] == '\001' {
+			return out[1:], nil
+		}
+	}
+	return data, err
+}
+
+var (
+	goFontMu sync.Mutex
+
+	// goFontFiles maps variant and size of the bundled Go fonts to the
+	// rasterized font file.
+	goFontFiles = make(map[string]string)
+)
+
+// monospaceFamilies are the lower case names of common monospaced
+// fonts.
+var monospaceFamilies = map[string]bool{
+	"monospace":        true,
+	"ui-monospace":     true,
+	"courier":          true,
+	"courier new":      true,
+	"consolas":         true,
+	"monaco":           true,
+	"menlo":            true,
+	"lucida console":   true,
+	"sfmono-regular":   true,
+	"source code pro":  true,
+	"dejavu sans mono": true,
+	"liberation mono":  true,
+	"go mono":          true,
+}
+
+// IsMonospace is true if the first known family of font-family is
+// monospaced.
+func (cs Map) IsMonospace() bool {
+	for _, f := range cs.fontFamilies() {
+		if monospaceFamilies[f] {
+			return true
+		} else if genericFamily(f) != "" {
+			return genericFamily(f) == "monospace"
+		}
+	}
+	return false
+}
+
+// goFontFilename returns the font file of the bundled Go font matching
+// the style, weight and size of cs, monospaced if mono is set. The font
+// is rasterized if necessary.
+func (cs Map) goFontFilename(mono bool) (fn string, ok bool) {
+	v := font.GoVariant(mono, cs.IsBold(), cs.IsItalic())
+	px := int(math.Round(2 * cs.FontSize()))
+	key := fmt.Sprintf("%v/%v", v, px)
+	goFontMu.Lock()
+	defer goFontMu.Unlock()
+	if fn, ok := goFontFiles[key]; ok {
+		return fn, fn != ""
+	}
+	goFontFiles[key] = ""
+	f, err := font.Go(v)
+	if err != nil {
+		log.Errorf("go font %v: %v", v, err)
+		return "", false
+	}
+	dir := filepath.Join(fontCacheDir(), "go", v, strconv.Itoa(px))
+	fn = filepath.Join(dir, "font")
+	if _, err := os.Stat(fn); err != nil {
+		if fn, err = font.WriteFont(dir, []font.Face{{Font: f}}, px); err != nil {
+			log.Errorf("write go font %v: %v", key, err)
+			return "", false
+		}
+	}
+	goFontFiles[key] = fn
+	return fn, true
+}
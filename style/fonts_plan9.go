@@ -9,12 +9,16 @@ import (
 	"io/fs"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 var (
 	fonts  map[int]*draw.Font
 	fontHs []int
+
+	// bold and italic fonts by height
+	variants map[string]map[int]*draw.Font
 )
 
 func initFontserver() {
@@ -25,6 +29,28 @@ func initFontserver() {
 	if df := dui.Font(nil); df.Height >= 40 {
 		dui.Display.DPI = 200
 	}
+	es, err := os.ReadDir("/mnt/font")
+	if err != nil {
+		log.Printf("read /mnt/font: %v", err)
+		return
+	}
+	for _, e := range es {
+		availableFontNames = append(availableFontNames, e.Name()+"/")
+	}
+}
+
+// fontSizes returns the sizes fontsrv provides of the font.
+func fontSizes(fontName string) (fss []int, err error) {
+	es, err := os.ReadDir("/mnt/font/" + fontName)
+	if err != nil {
+		return
+	}
+	for _, e := range es {
+		if fs, err := strconv.Atoi(e.Name()); err == nil {
+			fss = append(fss, fs)
+		}
+	}
+	return
 }
 
 func initFonts() {
@@ -51,6 +77,45 @@ func initFonts() {
 		fontHs = append(fontHs, f.Height)
 	}
 	log.Infof("font heights: %+v", fontHs)
+
+	variants = make(map[string]map[int]*draw.Font)
+	for _, v := range []string{"bold", "italic"} {
+		variants[v] = make(map[int]*draw.Font)
+		ms, err := fontVariantsLike(def, v)
+		if err != nil {
+			log.Infof("find %v fonts: %v", v, err)
+			continue
+		}
+		for _, m := range ms {
+			f, err := dui.Display.OpenFont(m)
+			if err != nil {
+				log.Errorf("open font: %v", err)
+				continue
+			}
+			variants[v][f.Height] = f
+		}
+	}
+}
+
+// fontVariantsLike returns the fonts in the directory of path with
+// the prefix v, e.g. boldlatin1.13.font in lucidasans.
+func fontVariantsLike(path, v string) (fts []string, err error) {
+	if path == "*default*" {
+		path = "/lib/font/bit/lucidasans/unicode.13.font"
+	}
+	l := strings.Split(path, "/")
+	dn := strings.Join(l[:len(l)-1], "/")
+	ms, err := fs.Glob(os.DirFS(dn), v+"*.font")
+	if err != nil {
+		return
+	}
+	for _, m := range ms {
+		fts = append(fts, dn+"/"+m)
+	}
+	if len(fts) == 0 {
+		return nil, fmt.Errorf("unable to find %v fonts in %v", v, dn)
+	}
+	return
 }
 
 var reNum = regexp.MustCompile(`(\d+(x\d+)?)`)
@@ -92,14 +157,64 @@ func fontsLike(path string) (fts []string, err error) {
 	return
 }
 
-func (cs Map) FontFilename() (fn string, ok bool) {
+// FontFilename returns the font for the font-family list of cs. Families
+// are taken from fontsrv in /mnt/font, generic families with the fonts
+// of GenericFamilies. Glyphs missing in a font are taken from the next
+// one and finally from the default font.
+func (cs Map) FontFilename() (string, bool) {
+	var fns []string
+	add := func(fn string) {
+		for _, f := range fns {
+			if f == fn {
+				return
+			}
+		}
+		fns = append(fns, fn)
+	}
+	for _, fam := range cs.fontFamilies() {
+		names := []string{fam}
+		if g := genericFamily(fam); g != "" {
+			names = GenericFamilies[g]
+		}
+		for _, name := range names {
+			if fn, ok := cs.fontsrvFilename(name); ok {
+				add(fn)
+				break
+			}
+		}
+	}
+	if fn, ok := cs.defaultFontFilename(); ok {
+		add(fn)
+	}
+	return fallbackFont(fns)
+}
+
+// defaultFontFilename returns the font like the default font with the
+// size and style of cs, or the bundled Go font if there is none.
+func (cs Map) defaultFontFilename() (fn string, ok bool) {
 	if fonts == nil {
 		initFonts()
 	}
+	if len(fontHs) == 0 {
+		return cs.goFontFilename(cs.IsMonospace())
+	}
 	h := matchClosestFontSize(2*cs.FontSize(), fontHs)
 	f, ok := fonts[h]
 	if !ok {
 		return
 	}
+	v := ""
+	if cs.IsBold() {
+		v = "bold"
+	} else if cs.IsItalic() {
+		v = "italic"
+	}
+	if vfs := variants[v]; len(vfs) > 0 {
+		hs := make([]int, 0, len(vfs))
+		for vh := range vfs {
+			hs = append(hs, vh)
+		}
+		return vfs[matchClosestFontSize(float64(h), hs)].Name, true
+	}
 	return f.Name, true
 }
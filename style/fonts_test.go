@@ -0,0 +1,162 @@
+package style
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsMonospace(t *testing.T) {
+	tests := map[string]bool{
+		"monospace":                 true,
+		`"Courier New", monospace`:  true,
+		"SomeWebFont, Menlo, serif": true,
+		"Arial, sans-serif":         false,
+		"serif, monospace":          false,
+		"":                          false,
+	}
+	for v, exp := range tests {
+		if m := decls("font-family", v).IsMonospace(); m != exp {
+			t.Errorf("%v: %v", v, m)
+		}
+	}
+}
+
+func TestGoFontFilename(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func() { goFontFiles = make(map[string]string) }()
+	tests := map[string]Map{
+		"/go/regular/22/font":        decls(),
+		"/go/bold/44/font":           decls("font-weight", "bold", "font-size", "22px"),
+		"/go/monoitalic/22/font":     decls("font-family", "monospace", "font-style", "italic"),
+		"/go/monobolditalic/33/font": decls("font", "italic bold 12px Consolas, monospace", "font-size", "1.5em"),
+	}
+	for exp, cs := range tests {
+		fn, ok := cs.goFontFilename(cs.IsMonospace())
+		if !ok || !strings.HasPrefix(fn, fontCacheDir()) || !strings.HasSuffix(fn, exp) {
+			t.Errorf("%v: %v %v", exp, fn, ok)
+			continue
+		}
+		if _, err := os.Stat(fn); err != nil {
+			t.Errorf("%v: %v", exp, err)
+		}
+		if fn2, _ := cs.goFontFilename(cs.IsMonospace()); fn2 != fn {
+			t.Errorf("%v: %v", exp, fn2)
+		}
+	}
+}
+
+func TestGoFontFilenameConcurrent(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func() { goFontFiles = make(map[string]string) }()
+	cs := decls("font-size", "7px")
+	fns := make(chan string)
+	for i := 0; i < 4; i++ {
+		go func() {
+			fn, _ := cs.goFontFilename(false)
+			fns <- fn
+		}()
+	}
+	fn := <-fns
+	for i := 1; i < 4; i++ {
+		if fn2 := <-fns; fn2 != fn || fn == "" {
+			t.Errorf("%v %v", fn, fn2)
+		}
+	}
+}
+
+func TestFontFilenames(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func() { goFontFiles = make(map[string]string) }()
+	defer func(g map[string][]string) { GenericFamilies = g }(GenericFamilies)
+	GenericFamilies = map[string][]string{
+		"sans-serif": {"Helvetica"},
+		"serif":      {"Times"},
+		"monospace":  {"Go Mono"},
+		"system-ui":  {"Missing"},
+	}
+	avail := map[string]bool{"helvetica": true, "times": true, "dejavusans": true}
+	fontFile := func(cs Map, name string) (string, bool) {
+		if n := normFontName(name); avail[n] {
+			return n, true
+		}
+		return "", false
+	}
+	goFont := func(fn string) string {
+		if i := strings.Index(fn, "/go/"); i >= 0 {
+			return fn[i+1:]
+		}
+		return fn
+	}
+	tests := []struct {
+		cs  Map
+		exp string
+	}{
+		{decls(), "helvetica"},
+		{decls("font-family", `"DejaVu Sans", Arial`), "dejavusans helvetica"},
+		{decls("font", "bold 12px Georgia, serif"), "times helvetica"},
+		{decls("font-family", "Courier, monospace"), "go/mono/22/font helvetica"},
+		{decls("font-family", "system-ui"), "go/regular/22/font helvetica"},
+		{decls("font-family", "Go, sans-serif"), "go/regular/22/font helvetica"},
+		{decls("font-family", "Arial, serif"), "times helvetica"},
+		{decls("font-family", "Arial, cursive"), "helvetica"},
+	}
+	for _, tt := range tests {
+		goFontFiles = make(map[string]string)
+		var l []string
+		for _, fn := range tt.cs.fontFilenames(fontFile) {
+			l = append(l, goFont(fn))
+		}
+		if s := strings.Join(l, " "); s != tt.exp {
+			t.Errorf("%v: %v", tt.exp, s)
+		}
+		if !strings.Contains(tt.exp, "go/") && len(goFontFiles) > 0 {
+			t.Errorf("%v: go font rasterized: %v", tt.exp, goFontFiles)
+		}
+	}
+
+	if err := SetGenericFamily("monospace = Consolas, Go Mono"); err != nil || strings.Join(GenericFamilies["monospace"], ",") != "Consolas,Go Mono" {
+		t.Errorf("%v %v", GenericFamilies["monospace"], err)
+	}
+	if err := SetGenericFamily("cursive=Comic Sans"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestFallbackFont(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a", "font")
+	b := filepath.Join(dir, "b", "font")
+	os.Mkdir(filepath.Dir(a), 0700)
+	os.Mkdir(filepath.Dir(b), 0700)
+	os.WriteFile(a, []byte("20 16\n0x0000 0x00ff x0000.bit\n"), 0600)
+	os.WriteFile(b, []byte("24 18\n0x0020 0x007e 0.0020\n0x0400 0x04ff 1 /lib/font/cyr\n"), 0600)
+
+	if fn, ok := fallbackFont([]string{a}); !ok || fn != a {
+		t.Errorf("%v", fn)
+	}
+	fn, ok := fallbackFont([]string{a, b, filepath.Join(dir, "missing")})
+	if !ok || !strings.HasPrefix(fn, fontCacheDir()) {
+		t.Fatalf("%v %v", fn, ok)
+	}
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "20 16\n" +
+		"0x0000 0x00ff " + dir + "/a/x0000.bit\n" +
+		"0x0020 0x007e " + dir + "/b/0.0020\n" +
+		"0x0400 0x04ff 1 /lib/font/cyr\n"
+	if string(data) != exp {
+		t.Errorf("%s", data)
+	}
+	if fn2, _ := fallbackFont([]string{a, b, filepath.Join(dir, "missing")}); fn2 != fn {
+		t.Errorf("%v", fn2)
+	}
+}
@@ -3,7 +3,6 @@
 package style
 
 import (
-	"fmt"
 	"github.com/psilva261/opossum/logger"
 	"os/exec"
 	"regexp"
@@ -11,8 +10,6 @@ import (
 	"strings"
 )
 
-var availableFontSizes = make(map[string][]int)
-
 func initFontserver() {
 	buf, err := exec.Command("fontsrv", "-p", ".").Output()
 	if err == nil {
@@ -22,6 +19,7 @@ func initFontserver() {
 	}
 }
 
+// fontSizes returns the sizes fontsrv provides of the font.
 func fontSizes(fontName string) (fss []int, err error) {
 	re := regexp.MustCompile(`^(\d+)$`)
 	fss = make([]int, 0, 20)
@@ -46,16 +44,10 @@ func fontSizes(fontName string) (fss []int, err error) {
 	return
 }
 
+// FontFilename returns the font for the font-family list of cs. Fonts
+// are taken from fontsrv or are the bundled Go fonts if fontsrv is
+// missing or lacks the family. Glyphs missing in a font are taken from
+// the next one.
 func (cs Map) FontFilename() (string, bool) {
-	f := cs.preferedFontName([]string{"HelveticaNeue", "Helvetica"})
-	if _, ok := availableFontSizes[f]; !ok {
-		fss, err := fontSizes(f)
-		if err != nil {
-			log.Errorf("font sizes %v: %v", f, err)
-		}
-		availableFontSizes[f] = fss
-	}
-	s := matchClosestFontSize(2*cs.FontSize(), availableFontSizes[f])
-
-	return fmt.Sprintf("/mnt/font/"+f+"%va/font", s), true
+	return fallbackFont(cs.fontFilenames(Map.fontsrvFilename))
 }
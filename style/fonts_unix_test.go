@@ -0,0 +1,32 @@
+//go:build !plan9
+
+package style
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFontFilenameFallback(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func(names []string) { availableFontNames = names }(availableFontNames)
+	defer func() { goFontFiles = make(map[string]string) }()
+	availableFontSizes["HelveticaNeue/"] = []int{16, 22, 28}
+	defer delete(availableFontSizes, "HelveticaNeue/")
+
+	// fontsrv missing
+	availableFontNames = nil
+	if fn, ok := decls().FontFilename(); !ok || !strings.HasSuffix(fn, "/go/regular/22/font") {
+		t.Errorf("%v %v", fn, ok)
+	}
+	if fn, ok := decls("font-family", "monospace").FontFilename(); !ok || !strings.HasSuffix(fn, "/go/mono/22/font") {
+		t.Errorf("%v %v", fn, ok)
+	}
+
+	availableFontNames = []string{"HelveticaNeue/"}
+	fns := decls("font-family", "Helvetica Neue, sans-serif", "font-size", "14px").fontFilenames(Map.fontsrvFilename)
+	if len(fns) != 1 || fns[0] != "/mnt/font/HelveticaNeue/28a/font" {
+		t.Errorf("%v", fns)
+	}
+}
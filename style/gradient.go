@@ -0,0 +1,421 @@
+package style
+
+import (
+	"9fans.net/go/draw"
+	"image"
+	"math"
+	"strings"
+)
+
+// Gradient is a linear, radial or conic gradient image.
+type Gradient struct {
+	Kind      string // linear, radial or conic
+	Repeating bool
+
+	// Angle is the direction of linear gradients and the start of
+	// conic gradients in degrees clockwise from up.
+	Angle float64
+
+	// Corner is the direction of linear gradients to a corner like
+	// {1, -1} for "to top right", zero otherwise.
+	Corner image.Point
+
+	// Circle, Extent and the radii RX and RY set the ending shape of
+	// radial gradients. Extent is closest-side, closest-corner,
+	// farthest-side or farthest-corner and only used if RX is auto.
+	Circle bool
+	Extent string
+	RX, RY BackgroundLength
+
+	// X and Y are the center of radial and conic gradients.
+	X, Y BackgroundLength
+
+	Stops []ColorStop
+}
+
+// ColorStop of a gradient. Pos is relative to the gradient line and
+// Auto if unset. Hints only have a position.
+type ColorStop struct {
+	Color draw.Color
+	Pos   BackgroundLength
+	Hint  bool
+}
+
+// ParseGradient parses the gradient functions linear-gradient,
+// radial-gradient, conic-gradient and their repeating variants.
+func (cs Map) ParseGradient(v string) (g Gradient, ok bool) {
+	v = strings.TrimSpace(v)
+	i := strings.IndexByte(v, '(')
+	if i < 0 || !strings.HasSuffix(v, ")") {
+		return
+	}
+	fn := strings.ToLower(v[:i])
+	g.Repeating = strings.HasPrefix(fn, "repeating-")
+	g.Kind = strings.TrimSuffix(strings.TrimPrefix(fn, "repeating-"), "-gradient")
+	switch g.Kind {
+	case "linear":
+		g.Angle = 180
+	case "radial":
+		g.Extent = "farthest-corner"
+		g.RX = BackgroundLength{Auto: true}
+		g.RY = BackgroundLength{Auto: true}
+		fallthrough
+	case "conic":
+		g.X = BackgroundLength{Percent: 50}
+		g.Y = BackgroundLength{Percent: 50}
+	default:
+		return
+	}
+	args := splitComma(v[i+1 : len(v)-1])
+	if len(args) == 0 {
+		return
+	}
+	if cs.gradientPrelude(&g, contentTokens(args[0])) {
+		args = args[1:]
+	}
+	for _, a := range args {
+		if !cs.colorStop(&g, contentTokens(a)) {
+			return g, false
+		}
+	}
+	if n := len(g.Stops); n == 0 || g.Stops[n-1].Hint {
+		// hints must be between two color stops
+		return g, false
+	}
+	return g, true
+}
+
+// gradientPrelude parses the arguments before the color stops and
+// returns false if toks are not a prelude.
+func (cs Map) gradientPrelude(g *Gradient, toks []string) bool {
+	var shape []string
+	for i := 0; i < len(toks); i++ {
+		switch t := toks[i]; {
+		case t == "in":
+			// interpolation color space
+			i++
+			if i+1 < len(toks) && strings.HasSuffix(toks[i+1], "hue") {
+				i++
+			}
+		case t == "to" && g.Kind == "linear":
+			for _, s := range toks[i+1:] {
+				switch s {
+				case "left":
+					g.Corner.X = -1
+				case "right":
+					g.Corner.X = 1
+				case "top":
+					g.Corner.Y = -1
+				case "bottom":
+					g.Corner.Y = 1
+				default:
+					return false
+				}
+			}
+			if g.Corner.X == 0 || g.Corner.Y == 0 {
+				g.Angle = math.Atan2(float64(g.Corner.X), float64(-g.Corner.Y)) * 180 / math.Pi
+				g.Corner = image.Point{}
+			}
+			return true
+		case t == "from" && g.Kind == "conic" && i+1 < len(toks):
+			a, ok := angle(toks[i+1])
+			if !ok {
+				return false
+			}
+			g.Angle = a
+			i++
+		case t == "at" && g.Kind != "linear":
+			x, y, ok := cs.backgroundPosition(toks[i+1:])
+			if !ok {
+				return false
+			}
+			g.X, g.Y = x, y
+			i = len(toks)
+		case g.Kind == "linear":
+			a, ok := angle(t)
+			if !ok {
+				return false
+			}
+			g.Angle = a
+		case g.Kind == "radial":
+			shape = append(shape, t)
+		default:
+			return false
+		}
+	}
+	return g.Kind != "radial" || cs.radialShape(g, shape)
+}
+
+// radialShape parses the shape and size of a radial gradient.
+func (cs Map) radialShape(g *Gradient, toks []string) bool {
+	var ls []BackgroundLength
+	ellipse := false
+	for _, t := range toks {
+		switch t {
+		case "circle":
+			g.Circle = true
+		case "ellipse":
+			ellipse = true
+		case "closest-side", "closest-corner", "farthest-side", "farthest-corner":
+			g.Extent = t
+		default:
+			l, ok := cs.backgroundLength(t)
+			if !ok {
+				return false
+			}
+			ls = append(ls, l)
+		}
+	}
+	switch len(ls) {
+	case 1:
+		if ellipse || ls[0].Percent != 0 {
+			return false
+		}
+		g.Circle = true
+		g.RX, g.RY = ls[0], ls[0]
+	case 2:
+		if g.Circle {
+			return false
+		}
+		g.RX, g.RY = ls[0], ls[1]
+	}
+	return true
+}
+
+// colorStop appends the color stops or the hint of toks.
+func (cs Map) colorStop(g *Gradient, toks []string) bool {
+	var ps []BackgroundLength
+	var c draw.Color
+	hasColor := false
+	for _, t := range toks {
+		if l, ok := cs.stopPosition(g, t); ok {
+			ps = append(ps, l)
+		} else if cc, ok := cs.color(t); ok && !hasColor {
+			c = cc
+			hasColor = true
+		} else {
+			return false
+		}
+	}
+	switch {
+	case !hasColor && len(ps) == 1:
+		if n := len(g.Stops); n == 0 || g.Stops[n-1].Hint {
+			return false
+		}
+		g.Stops = append(g.Stops, ColorStop{Pos: ps[0], Hint: true})
+	case !hasColor || len(ps) > 2:
+		return false
+	case len(ps) == 0:
+		g.Stops = append(g.Stops, ColorStop{Color: c, Pos: BackgroundLength{Auto: true}})
+	default:
+		for _, p := range ps {
+			g.Stops = append(g.Stops, ColorStop{Color: c, Pos: p})
+		}
+	}
+	return true
+}
+
+// stopPosition parses lengths and percentages or angles of conic
+// gradients as percentages of a full turn.
+func (cs Map) stopPosition(g *Gradient, t string) (l BackgroundLength, ok bool) {
+	if g.Kind == "conic" && !strings.HasSuffix(t, "%") {
+		a, ok := angle(t)
+		return BackgroundLength{Percent: a / 3.6}, ok
+	}
+	return cs.backgroundLength(t)
+}
+
+// angle parses an angle in degrees which requires a unit unless it is
+// 0.
+func angle(t string) (float64, bool) {
+	if !startsNumeric(t) {
+		return 0, false
+	}
+	if strings.TrimLeft(t, "+-0.") != "" && strings.IndexAny(t[len(t)-1:], "0123456789") >= 0 {
+		return 0, false
+	}
+	return hue(t)
+}
+
+// Render the gradient with size in pixels.
+func (g Gradient) Render(size image.Point) *image.RGBA {
+	rgba := image.NewRGBA(image.Rectangle{Max: size})
+	w, h := float64(size.X), float64(size.Y)
+	var t func(x, y float64) float64
+	var length float64
+	switch g.Kind {
+	case "linear":
+		a := g.Angle * math.Pi / 180
+		dx, dy := math.Sin(a), -math.Cos(a)
+		if g.Corner != (image.Point{}) {
+			n := math.Hypot(w, h)
+			dx, dy = float64(g.Corner.X)*h/n, float64(g.Corner.Y)*w/n
+		}
+		length = math.Abs(w*dx) + math.Abs(h*dy)
+		t = func(x, y float64) float64 {
+			return ((x-w/2)*dx+(y-h/2)*dy)/length + 0.5
+		}
+	case "radial":
+		cx, cy := g.center(size)
+		rx, ry := g.radii(size, cx, cy)
+		length = rx
+		t = func(x, y float64) float64 {
+			return math.Hypot((x-cx)/rx, (y-cy)/ry)
+		}
+	case "conic":
+		cx, cy := g.center(size)
+		length = 1
+		t = func(x, y float64) float64 {
+			a := math.Atan2(x-cx, cy-y)*180/math.Pi - g.Angle
+			return math.Mod(math.Mod(a, 360)+360, 360) / 360
+		}
+	default:
+		return rgba
+	}
+	stops := g.resolveStops(length)
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			c := gradientColor(stops, t(float64(x)+0.5, float64(y)+0.5), g.Repeating)
+			i := rgba.PixOffset(x, y)
+			rgba.Pix[i+0] = uint8(c >> 24)
+			rgba.Pix[i+1] = uint8(c >> 16)
+			rgba.Pix[i+2] = uint8(c >> 8)
+			rgba.Pix[i+3] = uint8(c)
+		}
+	}
+	return rgba
+}
+
+func (g Gradient) center(size image.Point) (cx, cy float64) {
+	return resolvePx(g.X, float64(size.X)), resolvePx(g.Y, float64(size.Y))
+}
+
+// radii of the ending shape of a radial gradient.
+func (g Gradient) radii(size image.Point, cx, cy float64) (rx, ry float64) {
+	w, h := float64(size.X), float64(size.Y)
+	if !g.RX.Auto {
+		rx, ry = resolvePx(g.RX, w), resolvePx(g.RY, h)
+	} else {
+		sx := []float64{math.Abs(cx), math.Abs(w - cx)}
+		sy := []float64{math.Abs(cy), math.Abs(h - cy)}
+		pick := math.Min
+		if strings.HasPrefix(g.Extent, "farthest") {
+			pick = math.Max
+		}
+		rx, ry = pick(sx[0], sx[1]), pick(sy[0], sy[1])
+		if g.Circle {
+			rx = pick(rx, ry)
+			ry = rx
+		}
+		if strings.HasSuffix(g.Extent, "corner") {
+			if g.Circle {
+				rx = math.Hypot(rx, ry)
+				ry = rx
+			} else {
+				rx, ry = rx*math.Sqrt2, ry*math.Sqrt2
+			}
+		}
+	}
+	return math.Max(rx, 1e-3), math.Max(ry, 1e-3)
+}
+
+// resolvePx resolves l against ref in pixels.
+func resolvePx(l BackgroundLength, ref float64) float64 {
+	px := l.Px
+	if dui != nil && dui.Display != nil {
+		px = px * float64(dui.Scale(1000)) / 1000
+	}
+	return px + l.Percent*ref/100
+}
+
+// resolvedStop is a color stop or a hint at T along the gradient line.
+type resolvedStop struct {
+	c    draw.Color
+	t    float64
+	hint bool
+}
+
+// resolveStops positions the stops on a gradient line of length.
+func (g Gradient) resolveStops(length float64) (rs []resolvedStop) {
+	rs = make([]resolvedStop, len(g.Stops))
+	known := make([]bool, len(rs))
+	max := math.Inf(-1)
+	for i, s := range g.Stops {
+		rs[i] = resolvedStop{c: s.Color, hint: s.Hint}
+		switch {
+		case !s.Pos.Auto:
+			rs[i].t = resolvePx(s.Pos, length) / length
+			known[i] = true
+		case i == 0:
+			known[i] = true
+		case i == len(rs)-1:
+			rs[i].t = 1
+			known[i] = true
+		}
+		if known[i] {
+			rs[i].t = math.Max(rs[i].t, max)
+			max = rs[i].t
+		}
+	}
+	for i := 0; i < len(rs); i++ {
+		if known[i] {
+			continue
+		}
+		j := i
+		for !known[j] {
+			j++
+		}
+		for k := i; k < j; k++ {
+			rs[k].t = rs[i-1].t + (rs[j].t-rs[i-1].t)*float64(k-i+1)/float64(j-i+1)
+		}
+		i = j
+	}
+	return
+}
+
+// gradientColor returns the color at t of the resolved stops.
+func gradientColor(rs []resolvedStop, t float64, repeating bool) draw.Color {
+	first, last := rs[0].t, rs[len(rs)-1].t
+	if repeating && last > first {
+		t = first + math.Mod(math.Mod(t-first, last-first)+last-first, last-first)
+	}
+	if t <= first || len(rs) == 1 {
+		return rs[0].c
+	}
+	for i := 1; i < len(rs); i++ {
+		if rs[i].hint || t > rs[i].t {
+			continue
+		}
+		a := rs[i-1]
+		if a.hint {
+			a = rs[i-2]
+		}
+		if rs[i].t <= a.t {
+			return rs[i].c
+		}
+		f := (t - a.t) / (rs[i].t - a.t)
+		if h := rs[i-1]; h.hint {
+			if t < h.t {
+				f = 0.5 * (t - a.t) / (h.t - a.t)
+			} else {
+				f = 0.5 + 0.5*(t-h.t)/(rs[i].t-h.t)
+			}
+			if ht := (h.t - a.t) / (rs[i].t - a.t); ht > 0 && ht < 1 {
+				f = math.Pow((t-a.t)/(rs[i].t-a.t), math.Log(0.5)/math.Log(ht))
+			}
+		}
+		return lerpColor(a.c, rs[i].c, f)
+	}
+	return rs[len(rs)-1].c
+}
+
+// lerpColor interpolates the premultiplied colors a and b.
+func lerpColor(a, b draw.Color, f float64) draw.Color {
+	var x uint32
+	for sh := 24; sh >= 0; sh -= 8 {
+		ca := float64(uint32(a) >> sh & 0xff)
+		cb := float64(uint32(b) >> sh & 0xff)
+		x |= uint32(math.Round(ca+(cb-ca)*f)) << sh
+	}
+	return draw.Color(x)
+}
@@ -0,0 +1,103 @@
+package style
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseGradient(t *testing.T) {
+	pct := func(p float64) BackgroundLength { return BackgroundLength{Percent: p} }
+	auto := BackgroundLength{Auto: true}
+	tests := []struct {
+		v     string
+		check func(g Gradient) bool
+	}{
+		{"linear-gradient(red, blue)", func(g Gradient) bool {
+			return g.Kind == "linear" && g.Angle == 180 && len(g.Stops) == 2 && g.Stops[0].Color == 0xff0000ff && g.Stops[1].Pos == auto
+		}},
+		{"linear-gradient(45deg, red 10%, blue)", func(g Gradient) bool {
+			return g.Angle == 45 && g.Stops[0].Pos == pct(10)
+		}},
+		{"linear-gradient(to left, red, blue)", func(g Gradient) bool {
+			return g.Angle == -90 && g.Corner == image.Point{}
+		}},
+		{"linear-gradient(to top right, red, blue)", func(g Gradient) bool {
+			return g.Corner == image.Pt(1, -1)
+		}},
+		{"linear-gradient(in oklab, red 0 50%, 70%, blue 50% 100%)", func(g Gradient) bool {
+			return len(g.Stops) == 5 && g.Stops[2].Hint && g.Stops[1].Pos == pct(50)
+		}},
+		{"repeating-radial-gradient(circle closest-side at 10px 20%, red, blue 10px)", func(g Gradient) bool {
+			return g.Kind == "radial" && g.Repeating && g.Circle && g.Extent == "closest-side" && g.X.Px == 10 && g.Y == pct(20)
+		}},
+		{"radial-gradient(20px 30%, red, blue)", func(g Gradient) bool {
+			return !g.Circle && g.RX.Px == 20 && g.RY == pct(30)
+		}},
+		{"conic-gradient(from 0.25turn at 0 0, red, blue 180deg)", func(g Gradient) bool {
+			return g.Kind == "conic" && g.Angle == 90 && g.Stops[1].Pos == pct(50)
+		}},
+	}
+	for _, tt := range tests {
+		g, ok := Map{}.ParseGradient(tt.v)
+		if !ok || !tt.check(g) {
+			t.Errorf("%v: %v %+v", tt.v, ok, g)
+		}
+	}
+	for _, v := range []string{
+		"url(a.png)",
+		"linear-gradient()",
+		"linear-gradient(45, red, blue)",
+		"linear-gradient(red, 10%, 20%, blue)",
+		"linear-gradient(red, 50%)",
+		"radial-gradient(circle 10% at center, red, blue)",
+		"foo-gradient(red, blue)",
+	} {
+		if _, ok := (Map{}).ParseGradient(v); ok {
+			t.Errorf("%v", v)
+		}
+	}
+}
+
+func TestRenderGradient(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	blue := color.RGBA{B: 0xff, A: 0xff}
+	tests := []struct {
+		v   string
+		pt  image.Point
+		exp color.RGBA
+	}{
+		{"linear-gradient(red 10%, blue 90%)", image.Pt(5, 5), red},
+		{"linear-gradient(red 10%, blue 90%)", image.Pt(5, 95), blue},
+		{"linear-gradient(to right, red 50%, blue 50%)", image.Pt(49, 5), red},
+		{"linear-gradient(to right, red 50%, blue 50%)", image.Pt(50, 5), blue},
+		{"linear-gradient(90deg, red 20px, blue 20px)", image.Pt(19, 50), red},
+		{"linear-gradient(to bottom right, red 50%, blue 50%)", image.Pt(60, 60), blue},
+		{"linear-gradient(to bottom right, red 50%, blue 50%)", image.Pt(90, 5), red},
+		{"repeating-linear-gradient(to right, red 0 10px, blue 10px 20px)", image.Pt(35, 0), blue},
+		{"repeating-linear-gradient(to right, red 0 10px, blue 10px 20px)", image.Pt(45, 0), red},
+		{"radial-gradient(circle closest-side, red 50%, blue 50%)", image.Pt(50, 30), red},
+		{"radial-gradient(circle closest-side, red 50%, blue 50%)", image.Pt(50, 10), blue},
+		{"conic-gradient(red 25%, blue 25%)", image.Pt(60, 40), red},
+		{"conic-gradient(red 25%, blue 25%)", image.Pt(40, 60), blue},
+		{"conic-gradient(from 180deg, red 25%, blue 25%)", image.Pt(40, 60), red},
+	}
+	for _, tt := range tests {
+		g, ok := Map{}.ParseGradient(tt.v)
+		if !ok {
+			t.Fatalf("%v", tt.v)
+		}
+		rgba := g.Render(image.Pt(100, 100))
+		if c := rgba.RGBAAt(tt.pt.X, tt.pt.Y); c != tt.exp {
+			t.Errorf("%v at %v: %v", tt.v, tt.pt, c)
+		}
+	}
+}
+
+func TestGradientHint(t *testing.T) {
+	g, _ := Map{}.ParseGradient("linear-gradient(to right, black, 25%, white)")
+	c := g.Render(image.Pt(100, 1)).RGBAAt(25, 0)
+	if c.R < 0x78 || c.R > 0x88 {
+		t.Errorf("%v", c)
+	}
+}
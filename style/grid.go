@@ -0,0 +1,307 @@
+package style
+
+import (
+	"image"
+	"strconv"
+	"strings"
+)
+
+// TrackSize of a grid track with Unit px, %, fr, auto, min-content or
+// max-content.
+type TrackSize struct {
+	Value float64
+	Unit  string
+}
+
+// Track is sized between Min and Max like minmax(Min, Max).
+type Track struct {
+	Min TrackSize
+	Max TrackSize
+}
+
+// TrackList of grid-template-columns or grid-template-rows. Repeat is
+// inserted at RepeatAt as often as it fits for repeat(auto-fill, ...)
+// and repeat(auto-fit, ...).
+type TrackList struct {
+	Tracks   []Track
+	Repeat   []Track
+	RepeatAt int
+	AutoFit  bool
+}
+
+// GridPlacement in 1-based lines of grid-row and grid-column. 0 means
+// auto, negative lines count from the end of the explicit grid.
+type GridPlacement struct {
+	RowStart, RowEnd, RowSpan int
+	ColStart, ColEnd, ColSpan int
+}
+
+// GridTemplate returns the explicit tracks of the grid axis prop which
+// is grid-template-columns or grid-template-rows.
+func (cs Map) GridTemplate(prop string) (tl TrackList) {
+	v := cs.Css(prop)
+	if v == "" {
+		rows, cols, ok := cs.gridTemplateShorthand()
+		if !ok {
+			return
+		}
+		v = cols
+		if prop == "grid-template-rows" {
+			v = rows
+		}
+	}
+	return cs.trackList(v)
+}
+
+// GridAutoTracks returns the implicit tracks of grid-auto-columns or
+// grid-auto-rows.
+func (cs Map) GridAutoTracks(prop string) []Track {
+	return cs.trackList(cs.Css(prop)).Tracks
+}
+
+// gridTemplateShorthand splits grid-template: <rows> / <columns>.
+func (cs Map) gridTemplateShorthand() (rows, cols string, ok bool) {
+	v := cs.Css("grid-template")
+	if strings.ContainsAny(v, `"'`) {
+		// rows are given together with the areas
+		if i := strings.LastIndex(v, "/"); i >= 0 {
+			return "", strings.TrimSpace(v[i+1:]), true
+		}
+		return "", "", false
+	}
+	l := strings.Split(v, "/")
+	if len(l) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(l[0]), strings.TrimSpace(l[1]), true
+}
+
+func (cs Map) trackList(v string) (tl TrackList) {
+	if v == "" || v == "none" {
+		return
+	}
+	for _, tok := range contentTokens(v) {
+		if strings.HasPrefix(tok, "[") {
+			// line names
+			continue
+		}
+		if strings.HasPrefix(tok, "repeat(") && strings.HasSuffix(tok, ")") {
+			args := strings.SplitN(tok[7:len(tok)-1], ",", 2)
+			if len(args) != 2 {
+				continue
+			}
+			var ts []Track
+			for _, t := range contentTokens(strings.TrimSpace(args[1])) {
+				if !strings.HasPrefix(t, "[") {
+					ts = append(ts, cs.track(t))
+				}
+			}
+			switch n := strings.TrimSpace(args[0]); n {
+			case "auto-fill", "auto-fit":
+				tl.Repeat = ts
+				tl.RepeatAt = len(tl.Tracks)
+				tl.AutoFit = n == "auto-fit"
+			default:
+				c, err := strconv.Atoi(n)
+				if err != nil || c > 1000 {
+					continue
+				}
+				for i := 0; i < c; i++ {
+					tl.Tracks = append(tl.Tracks, ts...)
+				}
+			}
+			continue
+		}
+		tl.Tracks = append(tl.Tracks, cs.track(tok))
+	}
+	return
+}
+
+// track parses a track size, minmax() or fit-content().
+func (cs Map) track(v string) Track {
+	switch {
+	case strings.HasPrefix(v, "minmax(") && strings.HasSuffix(v, ")"):
+		args := contentArgs(v[7 : len(v)-1])
+		if len(args) == 2 {
+			min := cs.trackSize(args[0])
+			if min.Unit == "fr" {
+				min = TrackSize{Unit: "auto"}
+			}
+			return Track{Min: min, Max: cs.trackSize(args[1])}
+		}
+	case strings.HasPrefix(v, "fit-content("):
+		return Track{Min: TrackSize{Unit: "auto"}, Max: TrackSize{Unit: "max-content"}}
+	}
+	s := cs.trackSize(v)
+	if s.Unit == "fr" {
+		return Track{Min: TrackSize{Unit: "auto"}, Max: s}
+	}
+	return Track{Min: s, Max: s}
+}
+
+func (cs Map) trackSize(v string) TrackSize {
+	switch {
+	case v == "auto" || v == "min-content" || v == "max-content":
+		return TrackSize{Unit: v}
+	case strings.HasSuffix(v, "fr"):
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(v, "fr"), 64); err == nil {
+			return TrackSize{Value: f, Unit: "fr"}
+		}
+	case strings.HasSuffix(v, "%"):
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64); err == nil {
+			return TrackSize{Value: f, Unit: "%"}
+		}
+	default:
+		if f, _, err := length(&cs, v); err == nil {
+			return TrackSize{Value: f, Unit: "px"}
+		}
+	}
+	return TrackSize{Unit: "auto"}
+}
+
+// GridAreas returns the named areas of grid-template-areas in 0-based
+// tracks where X are columns.
+func (cs Map) GridAreas() (areas map[string]image.Rectangle) {
+	v := cs.Css("grid-template-areas")
+	if v == "" || v == "none" {
+		v = cs.Css("grid-template")
+	}
+	areas = make(map[string]image.Rectangle)
+	y := 0
+	for _, tok := range contentTokens(v) {
+		if !strings.HasPrefix(tok, `"`) && !strings.HasPrefix(tok, `'`) {
+			continue
+		}
+		for x, name := range strings.Fields(unquote(tok)) {
+			if strings.Trim(name, ".") == "" {
+				continue
+			}
+			cell := image.Rect(x, y, x+1, y+1)
+			if r, ok := areas[name]; ok {
+				cell = r.Union(cell)
+			}
+			areas[name] = cell
+		}
+		y++
+	}
+	return
+}
+
+// GridPlacement returns the placement of a grid item from grid-area,
+// grid-row and grid-column and their longhands. Names refer to the
+// areas of the container.
+func (cs Map) GridPlacement(areas map[string]image.Rectangle) (p GridPlacement) {
+	var rowStart, colStart, rowEnd, colEnd string
+	if v := cs.Css("grid-area"); v != "" {
+		l := slashed(v)
+		rowStart = l[0]
+		if len(l) > 1 {
+			colStart = l[1]
+		} else if !isLineNum(rowStart) {
+			colStart = rowStart
+		}
+		if len(l) > 2 {
+			rowEnd = l[2]
+		} else if !isLineNum(rowStart) {
+			rowEnd = rowStart
+		}
+		if len(l) > 3 {
+			colEnd = l[3]
+		} else if !isLineNum(colStart) {
+			colEnd = colStart
+		}
+	}
+	if v := cs.Css("grid-row"); v != "" {
+		rowStart, rowEnd = startEnd(v)
+	}
+	if v := cs.Css("grid-column"); v != "" {
+		colStart, colEnd = startEnd(v)
+	}
+	for prop, val := range map[string]*string{
+		"grid-row-start":    &rowStart,
+		"grid-row-end":      &rowEnd,
+		"grid-column-start": &colStart,
+		"grid-column-end":   &colEnd,
+	} {
+		if v := cs.Css(prop); v != "" {
+			*val = v
+		}
+	}
+	row := func(r image.Rectangle, start bool) int {
+		if start {
+			return r.Min.Y + 1
+		}
+		return r.Max.Y + 1
+	}
+	col := func(r image.Rectangle, start bool) int {
+		if start {
+			return r.Min.X + 1
+		}
+		return r.Max.X + 1
+	}
+	p.RowStart, p.RowSpan = gridLine(rowStart, areas, true, row)
+	p.RowEnd, p.RowSpan = gridLineEnd(rowEnd, areas, p.RowSpan, row)
+	p.ColStart, p.ColSpan = gridLine(colStart, areas, true, col)
+	p.ColEnd, p.ColSpan = gridLineEnd(colEnd, areas, p.ColSpan, col)
+	return
+}
+
+func slashed(v string) (l []string) {
+	for _, s := range strings.Split(v, "/") {
+		l = append(l, strings.TrimSpace(s))
+	}
+	return
+}
+
+func startEnd(v string) (start, end string) {
+	l := slashed(v)
+	start = l[0]
+	if len(l) > 1 {
+		end = l[1]
+	} else if !isLineNum(start) {
+		end = start
+	}
+	return
+}
+
+func isLineNum(v string) bool {
+	if v == "" || v == "auto" || strings.HasPrefix(v, "span") {
+		return true
+	}
+	_, err := strconv.Atoi(v)
+	return err == nil
+}
+
+// gridLine returns the line or span of a grid-*-start value.
+func gridLine(v string, areas map[string]image.Rectangle, start bool, line func(image.Rectangle, bool) int) (l, span int) {
+	span = 1
+	switch {
+	case v == "" || v == "auto":
+	case strings.HasPrefix(v, "span"):
+		if n, err := strconv.Atoi(strings.TrimSpace(v[4:])); err == nil && n > 0 {
+			span = n
+		}
+	default:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n, span
+		}
+		name := v
+		if strings.HasSuffix(v, "-start") {
+			name, start = strings.TrimSuffix(v, "-start"), true
+		} else if strings.HasSuffix(v, "-end") {
+			name, start = strings.TrimSuffix(v, "-end"), false
+		}
+		if r, ok := areas[name]; ok {
+			return line(r, start), span
+		}
+	}
+	return
+}
+
+func gridLineEnd(v string, areas map[string]image.Rectangle, span int, line func(image.Rectangle, bool) int) (l, sp int) {
+	l, sp = gridLine(v, areas, false, line)
+	if strings.HasPrefix(v, "span") {
+		return 0, sp
+	}
+	return l, span
+}
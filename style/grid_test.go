@@ -0,0 +1,64 @@
+package style
+
+import (
+	"image"
+	"testing"
+)
+
+func TestGridTemplate(t *testing.T) {
+	cs := Map{Declarations: map[string]Declaration{
+		"grid-template-columns": {Prop: "grid-template-columns", Val: "[a] 100px repeat(2, 1fr) minmax(50px, auto)"},
+		"grid-template-rows":    {Prop: "grid-template-rows", Val: "repeat(auto-fill, minmax(20px, 1fr))"},
+	}}
+	tl := cs.GridTemplate("grid-template-columns")
+	exp := []Track{
+		{TrackSize{100, "px"}, TrackSize{100, "px"}},
+		{TrackSize{0, "auto"}, TrackSize{1, "fr"}},
+		{TrackSize{0, "auto"}, TrackSize{1, "fr"}},
+		{TrackSize{50, "px"}, TrackSize{0, "auto"}},
+	}
+	if len(tl.Tracks) != len(exp) || tl.Repeat != nil {
+		t.Fatalf("%+v", tl)
+	}
+	for i, tr := range tl.Tracks {
+		if tr != exp[i] {
+			t.Errorf("%v: %+v", i, tr)
+		}
+	}
+	tl = cs.GridTemplate("grid-template-rows")
+	if len(tl.Tracks) != 0 || len(tl.Repeat) != 1 || tl.AutoFit || tl.Repeat[0] != (Track{TrackSize{20, "px"}, TrackSize{1, "fr"}}) {
+		t.Errorf("%+v", tl)
+	}
+	for _, v := range []string{"repeat(", "minmax(", "repeat(2, minmax(", "100px repeat(2"} {
+		cs.Declarations["grid-template-columns"] = Declaration{Prop: "grid-template-columns", Val: v}
+		cs.GridTemplate("grid-template-columns")
+	}
+}
+
+func TestGridPlacement(t *testing.T) {
+	areas := Map{Declarations: map[string]Declaration{
+		"grid-template-areas": {Prop: "grid-template-areas", Val: `"head head" "nav main" ". foot"`},
+	}}.GridAreas()
+	if len(areas) != 4 || areas["head"] != image.Rect(0, 0, 2, 1) || areas["foot"] != image.Rect(1, 2, 2, 3) {
+		t.Fatalf("%+v", areas)
+	}
+	tests := []struct {
+		prop, val string
+		exp       GridPlacement
+	}{
+		{"grid-area", "head", GridPlacement{1, 2, 1, 1, 3, 1}},
+		{"grid-area", "2 / 1 / 4 / 3", GridPlacement{2, 4, 1, 1, 3, 1}},
+		{"grid-column", "1 / span 2", GridPlacement{0, 0, 1, 1, 0, 2}},
+		{"grid-column", "span 3", GridPlacement{0, 0, 1, 0, 0, 3}},
+		{"grid-row", "-1", GridPlacement{-1, 0, 1, 0, 0, 1}},
+		{"grid-row-start", "main-start", GridPlacement{2, 0, 1, 0, 0, 1}},
+	}
+	for _, tt := range tests {
+		cs := Map{Declarations: map[string]Declaration{
+			tt.prop: {Prop: tt.prop, Val: tt.val},
+		}}
+		if p := cs.GridPlacement(areas); p != tt.exp {
+			t.Errorf("%v: %v: %+v", tt.prop, tt.val, p)
+		}
+	}
+}
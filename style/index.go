@@ -0,0 +1,348 @@
+package style
+
+import (
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/psilva261/opossum/logger"
+	"golang.org/x/net/html"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RuleIndex holds the rules of stylesheets with their selectors
+// bucketed by the id, class or tag of the rightmost compound selector.
+// Only the selectors of the buckets of an element are matched against
+// it, right to left.
+type RuleIndex struct {
+	ids       map[string][]*indexedSel
+	classes   map[string][]*indexedSel
+	tags      map[string][]*indexedSel
+	universal []*indexedSel
+
+	n       int // selectors added so far
+	rVars   map[string]string
+	anchors []cascadia.Sel // compound selectors of dynamic selectors that depend on state
+}
+
+// indexedSel is a compiled selector with its rule. Order is the
+// position in the stylesheets.
+type indexedSel struct {
+	sel     cascadia.Sel
+	rule    Rule
+	order   int
+	dynamic bool
+}
+
+func NewRuleIndex() *RuleIndex {
+	return &RuleIndex{
+		ids:     make(map[string][]*indexedSel),
+		classes: make(map[string][]*indexedSel),
+		tags:    make(map[string][]*indexedSel),
+		rVars:   make(map[string]string),
+	}
+}
+
+// Add the rules of the stylesheet cssText after those already added.
+// Rules of non-matching media queries are omitted.
+func (ri *RuleIndex) Add(cssText string) error {
+	s, err := Parse(cssText, false)
+	if err != nil {
+		return fmt.Errorf("parse: %w", err)
+	}
+	for _, r := range s.Rules {
+		if strings.TrimSpace(r.Prelude) == "@font-face" {
+			addFontFace(r.Declarations)
+			continue
+		}
+		ri.add(r)
+
+		// for media queries
+		if strings.HasPrefix(r.Prelude, "@media") {
+			p := strings.TrimPrefix(r.Prelude, "@media")
+			p = strings.TrimSpace(p)
+			yes, err := MatchQuery(p, MediaValues)
+			if err != nil {
+				log.Errorf("match query %v: %v", r.Prelude, err)
+			} else if !yes {
+				continue
+			}
+		}
+		for _, rr := range r.Rules {
+			ri.add(rr)
+		}
+	}
+	return nil
+}
+
+// add indexes every selector of r with a copy of r restricted to it.
+func (ri *RuleIndex) add(r Rule) {
+	for _, sel := range r.Selectors {
+		if sel.Val == ":root" {
+			for _, d := range r.Declarations {
+				ri.rVars[d.Prop] = d.Val
+			}
+		}
+		cs, dyn, err := compileSel(sel.Val)
+		if err != nil {
+			log.Printf("cssSel compile %v: %v", sel.Val, err)
+			continue
+		}
+		if ss, ok := cs.(*stateSel); ok {
+			for _, p := range ss.parts {
+				if p.anchor != nil {
+					ri.anchors = append(ri.anchors, p.anchor)
+				}
+			}
+		}
+		sr := r
+		sr.Selectors = []Selector{{Val: sel.Val, PseudoElement: cs.PseudoElement()}}
+		sr.Declarations = make([]Declaration, len(r.Declarations))
+		copy(sr.Declarations, r.Declarations)
+		for j := range sr.Declarations {
+			sr.Declarations[j].Specificity = cs.Specificity()
+		}
+		is := &indexedSel{sel: cs, rule: sr, order: ri.n, dynamic: dyn}
+		ri.n++
+		switch kind, key := selectorKey(sel.Val); kind {
+		case '#':
+			ri.ids[key] = append(ri.ids[key], is)
+		case '.':
+			ri.classes[key] = append(ri.classes[key], is)
+		case 't':
+			ri.tags[key] = append(ri.tags[key], is)
+		default:
+			ri.universal = append(ri.universal, is)
+		}
+	}
+}
+
+// Match returns the rules with a selector matching n in stylesheet
+// order.
+func (ri *RuleIndex) Match(n *html.Node) (rs []Rule) {
+	return ri.match(n, 0)
+}
+
+// match returns the matching rules of the selectors added after the
+// first from ones.
+func (ri *RuleIndex) match(n *html.Node, from int) (rs []Rule) {
+	for _, c := range ri.candidates(n) {
+		if c.order >= from && c.sel.Match(n) {
+			rs = append(rs, c.rule)
+		}
+	}
+	return
+}
+
+// dynamicKey identifies the dynamic selectors matching n.
+func (ri *RuleIndex) dynamicKey(n *html.Node) string {
+	var b strings.Builder
+	for _, c := range ri.candidates(n) {
+		if c.dynamic && c.sel.Match(n) {
+			b.WriteString(strconv.Itoa(c.order))
+			b.WriteByte(',')
+		}
+	}
+	return b.String()
+}
+
+// candidates returns the selectors in the buckets of n in stylesheet
+// order.
+func (ri *RuleIndex) candidates(n *html.Node) (cands []*indexedSel) {
+	if n.Type != html.ElementNode {
+		return
+	}
+	cands = make([]*indexedSel, 0, 8)
+	cands = append(cands, ri.tags[strings.ToLower(n.Data)]...)
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "id":
+			cands = append(cands, ri.ids[a.Val]...)
+		case "class":
+			cs := strings.Fields(a.Val)
+			for i, c := range cs {
+				if !containsStr(cs[:i], c) {
+					cands = append(cands, ri.classes[c]...)
+				}
+			}
+		}
+	}
+	cands = append(cands, ri.universal...)
+	sort.Slice(cands, func(i, j int) bool { return cands[i].order < cands[j].order })
+	return
+}
+
+func containsStr(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeRules returns the matching rules of all elements of doc and the
+// custom properties of :root.
+func (ri *RuleIndex) NodeRules(doc *html.Node) (m map[*html.Node][]Rule, rVars map[string]string) {
+	m = make(map[*html.Node][]Rule)
+	ri.matchTree(doc, 0, m)
+	return m, ri.rVars
+}
+
+// matchTree appends the matching rules of the selectors added after
+// the first from ones to those of the elements of doc in m.
+func (ri *RuleIndex) matchTree(doc *html.Node, from int, m map[*html.Node][]Rule) {
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if rs := ri.match(n, from); len(rs) > 0 {
+			m[n] = append(m[n], rs...)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
+
+// NodeMap returns the cascaded declarations of all elements of doc.
+func (ri *RuleIndex) NodeMap(doc *html.Node) map[*html.Node]Map {
+	return nodeMap(ri.NodeRules(doc))
+}
+
+// Matches caches the rules matching the elements of a document while
+// stylesheets are added to a RuleIndex.
+type Matches struct {
+	rules map[*html.Node][]Rule
+	n     int // selectors matched so far
+}
+
+// Update matches only the selectors added to ri since the last update
+// against doc and returns the cascaded declarations of all elements.
+// The document must not change between updates.
+func (ms *Matches) Update(ri *RuleIndex, doc *html.Node) map[*html.Node]Map {
+	if ms.rules == nil {
+		ms.rules = make(map[*html.Node][]Rule)
+	}
+	ri.matchTree(doc, ms.n, ms.rules)
+	ms.n = ri.n
+	return nodeMap(ms.rules, ri.rVars)
+}
+
+// Dynamic returns the selectors added so far whose matches depend on
+// element state.
+func (ri *RuleIndex) Dynamic() Dynamic {
+	return Dynamic{ri: ri}
+}
+
+// selectorKey returns the bucket of the selector sel: '#' with the id,
+// '.' with a class or 't' with the tag of the rightmost compound
+// selector. Otherwise kind is 0 for the universal bucket.
+func selectorKey(sel string) (kind byte, key string) {
+	// start of the rightmost compound selector
+	start := 0
+	depth := 0
+	var quote byte
+	for i := 0; i < len(sel); i++ {
+		c := sel[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			if i+1 < len(sel) && isHex(sel[i+1]) {
+				// the space after hex escapes is no combinator
+				return 0, ""
+			}
+			i++
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case depth == 0 && (c == ' ' || c == '\t' || c == '\n' || c == '>' || c == '+' || c == '~'):
+			start = i + 1
+		}
+	}
+	comp := sel[start:]
+
+	var class, tag string
+	for i := 0; i < len(comp); {
+		c := comp[i]
+		switch {
+		case c == '#' || c == '.':
+			id, n, ok := selectorIdent(comp[i+1:])
+			if !ok {
+				return 0, ""
+			}
+			if c == '#' && id != "" {
+				return '#', id
+			}
+			if class == "" {
+				class = id
+			}
+			i += 1 + n
+		case i == 0 && (isIdentChar(c) || c >= 0x80):
+			t, n, ok := selectorIdent(comp)
+			if !ok {
+				return 0, ""
+			}
+			tag = strings.ToLower(t)
+			i += n
+		case c == '|':
+			// namespaces
+			return 0, ""
+		case c == '[' || c == '(':
+			depth := 0
+			for ; i < len(comp); i++ {
+				if comp[i] == '[' || comp[i] == '(' {
+					depth++
+				} else if comp[i] == ']' || comp[i] == ')' {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			i++
+		default:
+			// *, pseudo-classes and pseudo-elements
+			i++
+			for i < len(comp) && (isIdentChar(comp[i]) || comp[i] == '-') {
+				i++
+			}
+		}
+	}
+	switch {
+	case class != "":
+		return '.', class
+	case tag != "":
+		return 't', tag
+	}
+	return 0, ""
+}
+
+// selectorIdent returns the unescaped identifier at the start of s and
+// its length in s. Hex escapes are not supported.
+func selectorIdent(s string) (id string, n int, ok bool) {
+	var b strings.Builder
+	for n < len(s) {
+		c := s[n]
+		switch {
+		case c == '\\':
+			if n+1 >= len(s) || isHex(s[n+1]) || s[n+1] == '\n' {
+				return "", 0, false
+			}
+			b.WriteByte(s[n+1])
+			n += 2
+		case isIdentChar(c) || c >= 0x80:
+			b.WriteByte(c)
+			n++
+		default:
+			return b.String(), n, true
+		}
+	}
+	return b.String(), n, true
+}
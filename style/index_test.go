@@ -0,0 +1,204 @@
+package style
+
+import (
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"strings"
+	"testing"
+)
+
+func TestSelectorKey(t *testing.T) {
+	tests := map[string]string{
+		"div":                    "t div",
+		"DIV.foo":                ". foo",
+		"ul li > a#nav:hover":    "# nav",
+		".a .b":                  ". b",
+		"p.x.y":                  ". x",
+		`.md\:flex`:              ". md:flex",
+		`.\31 0`:                 "",
+		"*":                      "",
+		"[href]":                 "",
+		"a[title='x y'] span":    "t span",
+		"li:nth-child(2n+1)":     "t li",
+		"div:not(.a)":            "t div",
+		":not(.a)":               "",
+		"h1 + p::before":         "t p",
+		"ns|a":                   "",
+		"section ~ .card.active": ". card",
+	}
+	for sel, exp := range tests {
+		kind, key := selectorKey(sel)
+		res := ""
+		if kind == 't' || kind == '.' || kind == '#' {
+			res = string(kind) + " " + key
+		}
+		if res != exp {
+			t.Errorf("%v: %q", sel, res)
+		}
+	}
+}
+
+func TestRuleIndexMatch(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`
+		<div id="main" class="card  card active">
+			<p class="x">a <a href="/" class="md:flex">link</a></p>
+			<ul><li>1</li><li class="x">2</li></ul>
+		</div>
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	css := `
+		div { color: red; }
+		.card { margin: 1px; }
+		#main .x { padding: 2px; }
+		.card.active > p a { color: blue; }
+		[href] { color: green; }
+		li:nth-child(2) { color: gray; }
+		.md\:flex { display: flex; }
+		* { box-sizing: border-box; }
+		@media (min-width: 1px) { ul li { margin: 0; } }
+	`
+	ri := NewRuleIndex()
+	if err := ri.Add(css); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := ri.NodeRules(doc)
+	exp := queryAllRules(doc, css)
+	if len(m) != len(exp) {
+		t.Fatalf("%v != %v", len(m), len(exp))
+	}
+	for n, rs := range exp {
+		var a, b []string
+		for _, r := range rs {
+			a = append(a, r.Selectors[0].Val)
+		}
+		for _, r := range m[n] {
+			b = append(b, r.Selectors[0].Val)
+		}
+		if strings.Join(a, ",") != strings.Join(b, ",") {
+			t.Errorf("%v: %v != %v", n.Data, b, a)
+		}
+	}
+	if rs := m[grep(doc, "div")]; len(rs) != 3 {
+		t.Errorf("duplicate class: %+v", rs)
+	}
+}
+
+func TestRuleIndexOrder(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<p id="a" class="b">x</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ri := NewRuleIndex()
+	ri.Add(`#a { color: red; } p { color: blue; }`)
+	ri.Add(`.b { color: green; } p.b { color: black; }`)
+	m := ri.NodeMap(doc)
+	if c := m[grep(doc, "p")].Css("color"); c != "red" {
+		t.Errorf("%v", c)
+	}
+	ri.Add(`p#a.b { color: white; }`)
+	m = ri.NodeMap(doc)
+	if c := m[grep(doc, "p")].Css("color"); c != "white" {
+		t.Errorf("%v", c)
+	}
+}
+
+func TestMatchesUpdate(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<p id="a" class="b">x</p><div>y</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ri := NewRuleIndex()
+	var ms Matches
+	ri.Add(`#a { color: red; } p { color: blue; } div { color: blue; }`)
+	m := ms.Update(ri, doc)
+	if c := m[grep(doc, "p")].Css("color"); c != "red" {
+		t.Errorf("%v", c)
+	}
+	ri.Add(`.b { color: green; } div { color: black; }`)
+	m = ms.Update(ri, doc)
+	if c := m[grep(doc, "p")].Css("color"); c != "red" {
+		t.Errorf("%v", c)
+	}
+	if c := m[grep(doc, "div")].Css("color"); c != "black" {
+		t.Errorf("%v", c)
+	}
+	if rs := ms.rules[grep(doc, "p")]; len(rs) != 3 {
+		t.Errorf("rules matched again: %+v", rs)
+	}
+	full := ri.NodeMap(doc)
+	for _, tag := range []string{"p", "div"} {
+		n := grep(doc, tag)
+		if full[n].Css("color") != m[n].Css("color") {
+			t.Errorf("%v: %v != %v", tag, full[n].Css("color"), m[n].Css("color"))
+		}
+	}
+}
+
+// queryAllRules matches every selector against the whole document.
+func queryAllRules(doc *html.Node, cssText string) map[*html.Node][]Rule {
+	m := make(map[*html.Node][]Rule)
+	s, _ := Parse(cssText, false)
+	var rules []Rule
+	for _, r := range s.Rules {
+		rules = append(rules, r)
+		rules = append(rules, r.Rules...)
+	}
+	for _, r := range rules {
+		for _, sel := range r.Selectors {
+			csg, err := compile(sel.Val)
+			if err != nil || len(csg) != 1 {
+				continue
+			}
+			for _, el := range cascadia.QueryAll(doc, csg[0]) {
+				sr := r
+				sr.Selectors = []Selector{sel}
+				m[el] = append(m[el], sr)
+			}
+		}
+	}
+	return m
+}
+
+// benchDoc returns a page with about 5000 elements and a stylesheet
+// with about 3000 selectors like those of large css frameworks.
+func benchDoc(b *testing.B) (doc *html.Node, css string) {
+	var hb, cb strings.Builder
+	hb.WriteString("<html><body>")
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&hb, `<div class="row r%d"><div class="col-md-%d card"><h3 class="title">x</h3><p class="text-muted">y <a href="#" class="btn btn-%d">z</a></p>`, i, i%12, i%7)
+		fmt.Fprintf(&hb, `<ul class="list"><li>a</li><li class="active">b</li></ul><span id="s%d">c</span></div></div>`, i)
+	}
+	hb.WriteString("</body></html>")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&cb, ".c%d { color: red; }\n", i)
+		fmt.Fprintf(&cb, ".row .col-md-%d > .card-%d { margin: 1px; }\n", i%12, i)
+		fmt.Fprintf(&cb, "#s%d, ul li.item-%d a:hover { padding: 2px; }\n", i, i)
+	}
+	cb.WriteString("div { display: block; } .btn { color: blue; } li.active { color: green; } * { box-sizing: border-box; }\n")
+	doc, err := html.Parse(strings.NewReader(hb.String()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return doc, cb.String()
+}
+
+func BenchmarkNodeRulesIndexed(b *testing.B) {
+	doc, css := benchDoc(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := FetchNodeRules(doc, css); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNodeRulesQueryAll(b *testing.B) {
+	doc, css := benchDoc(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		queryAllRules(doc, css)
+	}
+}
@@ -0,0 +1,128 @@
+package style
+
+import (
+	"golang.org/x/net/html"
+	"strconv"
+	"strings"
+)
+
+// ListStyleType returns list-style-type, also from the list-style
+// shorthand. The longhand wins, the default is disc.
+func (cs Map) ListStyleType() string {
+	if v := cs.Css("list-style-type"); v != "" {
+		return v
+	}
+	st, _ := cs.listStyle()
+	if st == "" {
+		return "disc"
+	}
+	return st
+}
+
+// ListStylePosition returns inside or outside.
+func (cs Map) ListStylePosition() string {
+	v := cs.Css("list-style-position")
+	if v == "" {
+		_, v = cs.listStyle()
+	}
+	if v == "inside" {
+		return v
+	}
+	return "outside"
+}
+
+// listStyle returns type and position of the list-style shorthand.
+func (cs Map) listStyle() (st, pos string) {
+	for _, tok := range contentTokens(cs.Css("list-style")) {
+		switch {
+		case tok == "inside" || tok == "outside":
+			pos = tok
+		case strings.HasPrefix(tok, "url("):
+		case tok == "none":
+			if st == "" {
+				st = tok
+			}
+		default:
+			st = tok
+		}
+	}
+	return
+}
+
+// ListMarker returns the text of the marker of a list item with the
+// list-item counter v. ok is false if there is no marker.
+func (cs Map) ListMarker(v int) (t string, ok bool) {
+	switch st := cs.ListStyleType(); {
+	case st == "none":
+		return "", false
+	case st == "disc" || st == "circle" || st == "square":
+		return CounterStyle(v, st) + " ", true
+	case strings.HasPrefix(st, `"`) || strings.HasPrefix(st, `'`):
+		return unquote(st), true
+	default:
+		return CounterStyle(v, st) + ". ", true
+	}
+}
+
+// UpdateList updates the list-item counter for the element n with
+// style cs: lists reset it and list items increment it, backwards in
+// reversed lists. The value attribute sets it.
+func (c *Counters) UpdateList(n *html.Node, cs Map) {
+	switch n.Data {
+	case "ol", "ul", "menu", "dir":
+		start, err := strconv.Atoi(attr(n, "start"))
+		if _, ok := hasAttr(n, "reversed"); ok {
+			if err != nil {
+				start = listItems(n)
+			}
+			c.Reset("list-item", start+1)
+		} else {
+			if err != nil {
+				start = 1
+			}
+			c.Reset("list-item", start-1)
+		}
+		return
+	}
+	if n.Data != "li" && cs.Css("display") != "list-item" {
+		return
+	}
+	if v, err := strconv.Atoi(attr(n, "value")); err == nil {
+		c.Set("list-item", v)
+		return
+	}
+	if strings.Contains(cs.Css("counter-increment"), "list-item") {
+		return
+	}
+	inc := 1
+	if p := n.Parent; p != nil && p.Data == "ol" {
+		if _, ok := hasAttr(p, "reversed"); ok {
+			inc = -1
+		}
+	}
+	c.Increment("list-item", inc)
+}
+
+// listItems counts the li children of n.
+func listItems(n *html.Node) (i int) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "li" {
+			i++
+		}
+	}
+	return
+}
+
+func hasAttr(n *html.Node, k string) (v string, ok bool) {
+	for _, a := range n.Attr {
+		if a.Key == k {
+			return a.Val, true
+		}
+	}
+	return
+}
+
+func attr(n *html.Node, k string) string {
+	v, _ := hasAttr(n, k)
+	return v
+}
@@ -0,0 +1,32 @@
+package style
+
+import (
+	"testing"
+)
+
+func TestListStyle(t *testing.T) {
+	tests := []struct {
+		cs      Map
+		st, pos string
+		marker  string
+		ok      bool
+	}{
+		{decls(), "disc", "outside", "• ", true},
+		{decls("list-style", "square inside"), "square", "inside", "▪ ", true},
+		{decls("list-style", "none"), "none", "outside", "", false},
+		{decls("list-style", "inside", "list-style-type", "upper-roman"), "upper-roman", "inside", "IV. ", true},
+		{decls("list-style-type", `"- "`), `"- "`, "outside", "- ", true},
+		{decls("list-style", `url(x.png) lower-alpha`), "lower-alpha", "outside", "d. ", true},
+	}
+	for _, tt := range tests {
+		if st := tt.cs.ListStyleType(); st != tt.st {
+			t.Errorf("%+v: type %v", tt, st)
+		}
+		if pos := tt.cs.ListStylePosition(); pos != tt.pos {
+			t.Errorf("%+v: position %v", tt, pos)
+		}
+		if m, ok := tt.cs.ListMarker(4); m != tt.marker || ok != tt.ok {
+			t.Errorf("%+v: marker %v %v", tt, m, ok)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ObjectFit returns object-fit and object-position of replaced
+// elements like img. The fit is empty if not set or invalid, the
+// position is centered by default.
+func (cs Map) ObjectFit() (fit string, x, y BackgroundLength) {
+	switch v := cs.Css("object-fit"); v {
+	case "fill", "contain", "cover", "none", "scale-down":
+		fit = v
+	}
+	x, y, _ = cs.backgroundPosition(strings.Fields(cs.Css("object-position")))
+	return
+}
+
+// AspectRatio returns the preferred ratio of width to height of the
+// aspect-ratio property, e.g. 16 / 9 or 1.5.
+func (cs Map) AspectRatio() (r float64, ok bool) {
+	v := strings.TrimSpace(strings.Replace(cs.Css("aspect-ratio"), "auto", "", 1))
+	if v == "" {
+		return 0, false
+	}
+	parts := strings.SplitN(v, "/", 2)
+	w, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || w <= 0 {
+		return 0, false
+	}
+	h := 1.0
+	if len(parts) == 2 {
+		if h, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err != nil || h <= 0 {
+			return 0, false
+		}
+	}
+	return w / h, true
+}
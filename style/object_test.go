@@ -0,0 +1,49 @@
+package style
+
+import (
+	"testing"
+)
+
+func TestObjectFit(t *testing.T) {
+	tests := []struct {
+		cs   Map
+		fit  string
+		x, y BackgroundLength
+	}{
+		{decls(), "", BackgroundLength{Percent: 50}, BackgroundLength{Percent: 50}},
+		{decls("object-fit", "cover"), "cover", BackgroundLength{Percent: 50}, BackgroundLength{Percent: 50}},
+		{decls("object-fit", "stretch"), "", BackgroundLength{Percent: 50}, BackgroundLength{Percent: 50}},
+		{decls("object-fit", "contain", "object-position", "left top"), "contain", BackgroundLength{}, BackgroundLength{}},
+		{decls("object-position", "10px 100%"), "", BackgroundLength{Px: 10}, BackgroundLength{Percent: 100}},
+		{decls("object-position", "bottom"), "", BackgroundLength{Percent: 50}, BackgroundLength{Percent: 100}},
+	}
+	for _, tt := range tests {
+		fit, x, y := tt.cs.ObjectFit()
+		if fit != tt.fit || x != tt.x || y != tt.y {
+			t.Errorf("%+v: %v %+v %+v", tt.cs.Declarations, fit, x, y)
+		}
+	}
+}
+
+func TestAspectRatio(t *testing.T) {
+	tests := []struct {
+		v  string
+		r  float64
+		ok bool
+	}{
+		{"", 0, false},
+		{"auto", 0, false},
+		{"16 / 9", 16.0 / 9, true},
+		{"4/3", 4.0 / 3, true},
+		{"1.5", 1.5, true},
+		{"auto 2 / 1", 2, true},
+		{"1 / 0", 0, false},
+		{"wide", 0, false},
+	}
+	for _, tt := range tests {
+		r, ok := decls("aspect-ratio", tt.v).AspectRatio()
+		if r != tt.r || ok != tt.ok {
+			t.Errorf("%v: %v %v", tt.v, r, ok)
+		}
+	}
+}
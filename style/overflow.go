@@ -0,0 +1,55 @@
+package style
+
+import (
+	"strings"
+)
+
+// Overflow returns the computed overflow-x and overflow-y: visible,
+// hidden, clip, scroll or auto. If only one axis is visible or clip it
+// computes to auto or hidden respectively.
+func (cs Map) Overflow() (x, y string) {
+	x, y = "visible", "visible"
+	if v := strings.Fields(cs.Css("overflow")); len(v) > 0 {
+		x, y = v[0], v[0]
+		if len(v) > 1 {
+			y = v[1]
+		}
+	}
+	if v := cs.Css("overflow-x"); v != "" {
+		x = v
+	}
+	if v := cs.Css("overflow-y"); v != "" {
+		y = v
+	}
+	visible := func(o string) bool { return o == "visible" || o == "clip" }
+	fix := func(o, other string) string {
+		if visible(other) {
+			return o
+		}
+		switch o {
+		case "visible":
+			return "auto"
+		case "clip":
+			return "hidden"
+		}
+		return o
+	}
+	return fix(x, y), fix(y, x)
+}
+
+// Scrolls returns whether overflowing content along an axis with
+// overflow o can be scrolled.
+func Scrolls(o string) bool {
+	return o == "auto" || o == "scroll"
+}
+
+// TextOverflow returns whether overflowing inline content of the block
+// container is truncated with an ellipsis.
+func (cs Map) TextOverflow() bool {
+	if x, _ := cs.Overflow(); x == "visible" {
+		return false
+	}
+	// with two values the second one applies to the end of the line
+	v := strings.Fields(cs.Css("text-overflow"))
+	return len(v) > 0 && v[len(v)-1] == "ellipsis"
+}
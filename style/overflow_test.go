@@ -0,0 +1,37 @@
+package style
+
+import (
+	"testing"
+)
+
+func TestOverflow(t *testing.T) {
+	tests := []struct {
+		cs   Map
+		x, y string
+	}{
+		{decls(), "visible", "visible"},
+		{decls("overflow", "hidden"), "hidden", "hidden"},
+		{decls("overflow", "clip"), "clip", "clip"},
+		{decls("overflow", "hidden scroll"), "hidden", "scroll"},
+		{decls("overflow-x", "auto"), "auto", "auto"},
+		{decls("overflow-y", "scroll", "overflow-x", "clip"), "hidden", "scroll"},
+		{decls("overflow", "visible", "overflow-x", "clip"), "clip", "visible"},
+	}
+	for _, tt := range tests {
+		if x, y := tt.cs.Overflow(); x != tt.x || y != tt.y {
+			t.Errorf("%+v: %v %v", tt.cs.Declarations, x, y)
+		}
+	}
+}
+
+func TestTextOverflow(t *testing.T) {
+	if decls("text-overflow", "ellipsis").TextOverflow() {
+		t.Errorf("visible overflow")
+	}
+	if !decls("text-overflow", "ellipsis", "overflow", "hidden").TextOverflow() {
+		t.Errorf("hidden overflow")
+	}
+	if decls("text-overflow", "clip", "overflow", "hidden").TextOverflow() {
+		t.Errorf("clip")
+	}
+}
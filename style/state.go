@@ -0,0 +1,443 @@
+package style
+
+import (
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"strings"
+	"sync"
+)
+
+// State of an element that is matched by dynamic pseudo-classes.
+type State int
+
+const (
+	Hover State = 1 << iota
+	Active
+	Focus
+	FocusWithin
+	Visited
+)
+
+var stateNames = map[string]State{
+	"hover":         Hover,
+	"active":        Active,
+	"focus":         Focus,
+	"focus-visible": Focus,
+	"focus-within":  FocusWithin,
+	"visited":       Visited,
+}
+
+var (
+	stateMu sync.RWMutex
+	states  = make(map[*html.Node]State)
+)
+
+func (s State) String() string {
+	l := make([]string, 0, 2)
+	for _, name := range []string{"hover", "active", "focus", "focus-within", "visited"} {
+		if s&stateNames[name] != 0 {
+			l = append(l, name)
+		}
+	}
+	return strings.Join(l, " ")
+}
+
+// SetState turns s on or off for n and reports whether that changed
+// anything.
+func SetState(n *html.Node, s State, on bool) (changed bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	old := states[n]
+	nw := old &^ s
+	if on {
+		nw |= s
+	}
+	if nw == 0 {
+		delete(states, n)
+	} else {
+		states[n] = nw
+	}
+	return old != nw
+}
+
+// GetState of n.
+func GetState(n *html.Node) State {
+	stateMu.RLock()
+	defer stateMu.RUnlock()
+
+	return states[n]
+}
+
+// ResetStates of all elements, e.g. when a new page is loaded.
+func ResetStates() {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	states = make(map[*html.Node]State)
+}
+
+// stateSel matches a selector with dynamic pseudo-classes right to
+// left. The compound selectors are matched by cascadia, the states of
+// elements are looked up in the side table.
+type stateSel struct {
+	val   string
+	parts []statePart
+}
+
+// statePart is a compound selector without its dynamic pseudo-classes
+// and the combinator before it.
+type statePart struct {
+	comb  byte // ' ', '>', '+' or '~', 0 for the first part
+	sel   cascadia.Sel
+	state State
+
+	// anchor is sel without :checked if the part depends on state.
+	anchor cascadia.Sel
+}
+
+var _ cascadia.Sel = &stateSel{}
+
+// compileSel compiles the selector sel. It is dynamic if it contains
+// dynamic pseudo-classes or :checked.
+func compileSel(sel string) (cs cascadia.Sel, dynamic bool, err error) {
+	ss, err := newStateSel(sel)
+	if err != nil {
+		return nil, false, err
+	}
+	if ss != nil {
+		return ss, true, nil
+	}
+	csg, err := compile(sel)
+	if err != nil {
+		return nil, false, err
+	}
+	if n := len(csg); n != 1 {
+		return nil, false, fmt.Errorf("csg len %v", n)
+	}
+	return csg[0], false, nil
+}
+
+// newStateSel returns nil if sel is not dynamic. Dynamic pseudo-classes
+// within functional pseudo-classes like :not() are not supported.
+func newStateSel(sel string) (ss *stateSel, err error) {
+	comps, combs := splitCompounds(sel)
+	ss = &stateSel{val: sel}
+	dynamic := false
+	for i, comp := range comps {
+		rest, base, st, checked, err := stripDynamic(comp)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", sel, err)
+		}
+		p := statePart{comb: combs[i], state: st}
+		if p.sel, err = compileCompound(rest); err != nil {
+			return nil, err
+		}
+		if st != 0 || checked {
+			dynamic = true
+			p.anchor = p.sel
+			if checked {
+				if p.anchor, err = compileCompound(base); err != nil {
+					return nil, err
+				}
+			}
+		}
+		ss.parts = append(ss.parts, p)
+	}
+	if !dynamic {
+		return nil, nil
+	}
+	return ss, nil
+}
+
+func compileCompound(comp string) (cascadia.Sel, error) {
+	if comp == "" || strings.HasPrefix(comp, "::") {
+		comp = "*" + comp
+	}
+	return cascadia.ParseWithPseudoElement(comp)
+}
+
+// splitCompounds splits sel into compound selectors and the
+// combinators before them.
+func splitCompounds(sel string) (comps []string, combs []byte) {
+	var b strings.Builder
+	var comb, prev byte
+	var quote byte
+	depth := 0
+	for i := 0; i < len(sel); i++ {
+		c := sel[i]
+		if quote == 0 && depth == 0 {
+			switch c {
+			case ' ', '\t', '\n', '\r', '\f':
+				if comb == 0 {
+					comb = ' '
+				}
+				continue
+			case '>', '+', '~':
+				comb = c
+				continue
+			}
+		}
+		if comb != 0 {
+			if b.Len() > 0 {
+				comps = append(comps, b.String())
+				combs = append(combs, prev)
+				b.Reset()
+				prev = comb
+			}
+			comb = 0
+		}
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(sel) {
+				b.WriteByte(c)
+				i++
+				c = sel[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(sel):
+			b.WriteByte(c)
+			i++
+			c = sel[i]
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		}
+		b.WriteByte(c)
+	}
+	comps = append(comps, b.String())
+	combs = append(combs, prev)
+	return
+}
+
+// stripDynamic removes the dynamic pseudo-classes from the compound
+// selector comp and returns their states. :checked is kept in rest and
+// only removed in base.
+func stripDynamic(comp string) (rest, base string, st State, checked bool, err error) {
+	var r, b strings.Builder
+	var quote byte
+	depth := 0
+	for i := 0; i < len(comp); i++ {
+		c := comp[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(comp) {
+				r.WriteByte(c)
+				b.WriteByte(c)
+				i++
+				c = comp[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(comp):
+			r.WriteByte(c)
+			b.WriteByte(c)
+			i++
+			c = comp[i]
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == ':':
+			if i+1 < len(comp) && comp[i+1] == ':' {
+				r.WriteString("::")
+				b.WriteString("::")
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(comp) && isIdentChar(comp[j]) {
+				j++
+			}
+			name := strings.ToLower(comp[i+1 : j])
+			if s, ok := stateNames[name]; ok {
+				if depth > 0 {
+					return "", "", 0, false, fmt.Errorf(":%v within functional pseudo-class", name)
+				}
+				st |= s
+				i = j - 1
+				continue
+			} else if name == "checked" && depth == 0 {
+				checked = true
+				r.WriteString(comp[i:j])
+				i = j - 1
+				continue
+			}
+		}
+		r.WriteByte(c)
+		b.WriteByte(c)
+	}
+	return r.String(), b.String(), st, checked, nil
+}
+
+func (s *stateSel) Match(n *html.Node) bool {
+	return s.matchPart(n, len(s.parts)-1)
+}
+
+func (s *stateSel) matchPart(n *html.Node, i int) bool {
+	p := s.parts[i]
+	if n.Type != html.ElementNode || !p.sel.Match(n) {
+		return false
+	}
+	if p.state != 0 && GetState(n)&p.state != p.state {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch p.comb {
+	case '>':
+		return n.Parent != nil && s.matchPart(n.Parent, i-1)
+	case '+':
+		prev := prevElement(n)
+		return prev != nil && s.matchPart(prev, i-1)
+	case '~':
+		for prev := prevElement(n); prev != nil; prev = prevElement(prev) {
+			if s.matchPart(prev, i-1) {
+				return true
+			}
+		}
+	default:
+		for a := n.Parent; a != nil; a = a.Parent {
+			if s.matchPart(a, i-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func prevElement(n *html.Node) *html.Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *stateSel) Specificity() (sp cascadia.Specificity) {
+	for _, p := range s.parts {
+		sp = sp.Add(p.sel.Specificity())
+		for st := p.state; st != 0; st &= st - 1 {
+			// like a class
+			sp[1]++
+		}
+	}
+	return
+}
+
+func (s *stateSel) String() string {
+	return s.val
+}
+
+func (s *stateSel) PseudoElement() string {
+	return s.parts[len(s.parts)-1].sel.PseudoElement()
+}
+
+func isIdentChar(c byte) bool {
+	return c == '-' || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// Dynamic matches the selectors of a RuleIndex whose matches depend on
+// element state, i.e. user interaction.
+type Dynamic struct {
+	ri *RuleIndex
+}
+
+// Empty is true if no stylesheet depends on element state.
+func (d Dynamic) Empty() bool {
+	return d.ri == nil || len(d.ri.anchors) == 0
+}
+
+// Changes calls update, which changes the state or attributes of the
+// elements ns, and returns the elements whose matching dynamic
+// selectors differ before and after. Only ns and, if a dynamic compound
+// selector could match them, their subtrees and following siblings are
+// matched.
+func (d Dynamic) Changes(ns []*html.Node, update func()) (changed []*html.Node) {
+	if d.Empty() {
+		update()
+		return
+	}
+	scope := d.scope(ns)
+	before := make([]string, len(scope))
+	for i, n := range scope {
+		before[i] = d.ri.dynamicKey(n)
+	}
+	update()
+	for i, n := range scope {
+		if d.ri.dynamicKey(n) != before[i] {
+			changed = append(changed, n)
+		}
+	}
+	return
+}
+
+func (d Dynamic) scope(ns []*html.Node) (scope []*html.Node) {
+	seen := make(map[*html.Node]bool)
+	deep := make(map[*html.Node]bool)
+	var add func(n *html.Node, subtree bool)
+	add = func(n *html.Node, subtree bool) {
+		if n.Type == html.ElementNode && !seen[n] {
+			seen[n] = true
+			scope = append(scope, n)
+		}
+		if !subtree || deep[n] {
+			return
+		}
+		deep[n] = true
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			add(c, true)
+		}
+	}
+	for _, n := range ns {
+		if n == nil {
+			continue
+		}
+		if !d.anchors(n) {
+			add(n, false)
+			continue
+		}
+		for s := n; s != nil; s = s.NextSibling {
+			add(s, true)
+		}
+	}
+	return
+}
+
+// Siblings returns the following siblings of the elements of ns that
+// could be matched by dynamic selectors like input:checked + label.
+func (d Dynamic) Siblings(ns []*html.Node) (sibs []*html.Node) {
+	if d.Empty() {
+		return
+	}
+	for _, n := range ns {
+		if n.Type != html.ElementNode || !d.anchors(n) {
+			continue
+		}
+		for s := n.NextSibling; s != nil; s = s.NextSibling {
+			if s.Type == html.ElementNode {
+				sibs = append(sibs, s)
+			}
+		}
+	}
+	return
+}
+
+// anchors reports whether the state of n can affect a dynamic selector.
+func (d Dynamic) anchors(n *html.Node) bool {
+	for _, a := range d.ri.anchors {
+		if a.Match(n) {
+			return true
+		}
+	}
+	return false
+}
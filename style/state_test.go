@@ -0,0 +1,120 @@
+package style
+
+import (
+	"golang.org/x/net/html"
+	"strings"
+	"testing"
+)
+
+func TestCompileSel(t *testing.T) {
+	tests := map[string]string{
+		"a:hover":                "a|hover",
+		"li:focus-within > span": "li|focus-within >span|",
+		"a:visited::before":      "a::before|visited",
+		"div :HOVER~p":           "div| _*|hover ~p|",
+		"input:checked + label":  "input:checked| +label|",
+	}
+	for in, exp := range tests {
+		cs, dyn, err := compileSel(in)
+		if err != nil || !dyn {
+			t.Fatalf("%v: %v %v", in, dyn, err)
+		}
+		var l []string
+		for _, p := range cs.(*stateSel).parts {
+			comb := string(p.comb)
+			switch p.comb {
+			case 0:
+				comb = ""
+			case ' ':
+				comb = "_"
+			}
+			l = append(l, comb+p.sel.String()+"|"+p.state.String())
+		}
+		if res := strings.Join(l, " "); res != exp {
+			t.Errorf("%v: %v != %v", in, res, exp)
+		}
+	}
+	for _, sel := range []string{`a[title=":hover"]`, "p:first-child", `a[title="x > y"] b`} {
+		if _, dyn, err := compileSel(sel); err != nil || dyn {
+			t.Errorf("%v: %v %v", sel, dyn, err)
+		}
+	}
+	if _, _, err := compileSel("a:not(:hover)"); err == nil {
+		t.Errorf("expected error")
+	}
+	cs, _, _ := compileSel("#x a.b:hover")
+	if sp := cs.Specificity(); sp != [3]int{1, 2, 1} {
+		t.Errorf("%v", sp)
+	}
+}
+
+func TestSetState(t *testing.T) {
+	defer ResetStates()
+	n := &html.Node{Type: html.ElementNode, Data: "a"}
+	if !SetState(n, Hover, true) || SetState(n, Hover, true) {
+		t.Fatalf("changed")
+	}
+	SetState(n, Active, true)
+	if s := GetState(n); s != Hover|Active || s.String() != "hover active" {
+		t.Fatalf("%v", s)
+	}
+	SetState(n, Hover|Active, false)
+	if GetState(n) != 0 {
+		t.Fatalf("%v", GetState(n))
+	}
+}
+
+func TestHover(t *testing.T) {
+	defer ResetStates()
+	data := `<p><a href="x">link</a><b>b</b></p><p>c</p>`
+	css := `a { color: blue; } a:hover { color: red; } p:hover + p { color: green; } a:hover ~ b { color: red; }`
+	doc, err := html.Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal()
+	}
+	a := grep(doc, "a")
+	b := grep(doc, "b")
+	p := grep(doc, "p")
+
+	ri := NewRuleIndex()
+	ri.Add(css)
+	d := ri.Dynamic()
+	if d.Empty() {
+		t.Fatalf("no dynamic selectors")
+	}
+	ns := d.Changes([]*html.Node{a}, func() {
+		SetState(a, Hover, true)
+	})
+	if len(ns) != 2 || ns[0] != a || ns[1] != b {
+		t.Fatalf("%+v", ns)
+	}
+	m, err := FetchNodeMap(doc, css)
+	if err != nil {
+		t.Fatal()
+	}
+	if c := m[a].Css("color"); c != "red" {
+		t.Fatalf("%v", c)
+	}
+	for _, at := range a.Attr {
+		if at.Key != "href" {
+			t.Fatalf("state leaked into the DOM: %+v", a.Attr)
+		}
+	}
+	// p:hover affects its subtree and following siblings, body:hover
+	// nothing but body itself
+	if sc := d.scope([]*html.Node{p}); len(sc) != 4 || sc[0] != p {
+		t.Fatalf("%+v", sc)
+	}
+	if sc := d.scope([]*html.Node{grep(doc, "body")}); len(sc) != 1 {
+		t.Fatalf("%+v", sc)
+	}
+	if sibs := d.Siblings([]*html.Node{a}); len(sibs) != 1 || sibs[0] != b {
+		t.Fatalf("%+v", sibs)
+	}
+
+	SetState(a, Hover, false)
+	m, _ = FetchNodeMap(doc, css)
+	if c := m[a].Css("color"); c != "blue" {
+		t.Fatalf("%v", c)
+	}
+}
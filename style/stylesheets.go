@@ -7,7 +7,6 @@ import (
 	"github.com/andybalholm/cascadia"
 	"github.com/mjl-/duit"
 	"github.com/psilva261/opossum/logger"
-	"golang.org/x/image/colornames"
 	"golang.org/x/net/html"
 	"image"
 	"math"
@@ -55,9 +54,95 @@ address, article, aside, blockquote, details, dialog, dd, div, dl, dt, fieldset,
   display: block;
 }
 
+th {
+  font-weight: bold;
+  text-align: center;
+}
+
+h1 {
+  font-size: 2em;
+}
+
+h2 {
+  font-size: 1.5em;
+}
+
+h3 {
+  font-size: 1.17em;
+}
+
+h5 {
+  font-size: 0.83em;
+}
+
+h6 {
+  font-size: 0.67em;
+}
+
+h1, h2, h3, h4, h5, h6, b, strong {
+  font-weight: bold;
+}
+
+i, em, cite, var, dfn, address {
+  font-style: italic;
+}
+
+u, ins {
+  text-decoration: underline;
+}
+
+s, strike, del {
+  text-decoration: line-through;
+}
+
+center {
+  text-align: center;
+}
+
+pre, listing, xmp, plaintext {
+  white-space: pre;
+}
+
+pre, listing, xmp, plaintext, code, kbd, samp, tt {
+  font-family: monospace;
+}
+
+ol, ul, menu, dir {
+  padding-left: 40px;
+}
+
+ol {
+  list-style-type: decimal;
+}
+
+ol ul, ul ul, menu ul, ol menu, ul menu, menu menu {
+  list-style-type: circle;
+}
+
+ol ol ul, ol ul ul, ul ol ul, ul ul ul, ol ol menu, ol ul menu, ul ol menu, ul ul menu {
+  list-style-type: square;
+}
+
+dd {
+  margin-left: 40px;
+}
+
+caption {
+  text-align: center;
+}
+
 *[href] {
   color: blue;
   margin-right: 2px;
+  text-decoration: underline;
+}
+
+q::before {
+  content: open-quote;
+}
+
+q::after {
+  content: close-quote;
 }
 `
 
@@ -72,7 +157,14 @@ func MergeNodeMaps(m, addOn map[*html.Node]Map) {
 		// "zero" valued Map if it doesn't exist yet
 		initial := m[n]
 
-		m[n] = initial.ApplyChildStyle(mp, true)
+		res := initial.ApplyChildStyle(mp, true)
+		for pe, pm := range initial.PseudoElements {
+			res.setPseudoElement(pe, pm)
+		}
+		for pe, pm := range mp.PseudoElements {
+			res.setPseudoElement(pe, initial.PseudoElements[pe].ApplyChildStyle(pm, true))
+		}
+		m[n] = res
 	}
 }
 
@@ -81,10 +173,23 @@ func FetchNodeMap(doc *html.Node, cssText string) (m map[*html.Node]Map, err err
 	if err != nil {
 		return nil, fmt.Errorf("fetch rules: %w", err)
 	}
+	return nodeMap(mr, rv), nil
+}
+
+// nodeMap cascades the matched rules mr of each node.
+func nodeMap(mr map[*html.Node][]Rule, rv map[string]string) (m map[*html.Node]Map) {
 	m = make(map[*html.Node]Map)
 	for n, rs := range mr {
 		ds := make(map[string]Declaration)
+		pds := make(map[string]map[string]Declaration)
 		for _, r := range rs {
+			ds := ds
+			if pe := r.Selectors[0].PseudoElement; pe != "" {
+				if pds[pe] == nil {
+					pds[pe] = make(map[string]Declaration)
+				}
+				ds = pds[pe]
+			}
 			for _, d := range r.Declarations {
 				if exist, ok := ds[d.Prop]; ok && smaller(d, exist) {
 					continue
@@ -99,91 +204,29 @@ func FetchNodeMap(doc *html.Node, cssText string) (m map[*html.Node]Map, err err
 				ds[d.Prop] = d
 			}
 		}
-		m[n] = Map{Declarations: ds}
+		mp := Map{Declarations: ds}
+		for pe, ds := range pds {
+			mp.setPseudoElement(pe, Map{Declarations: ds})
+		}
+		m[n] = mp
 	}
 	return
 }
 
 func smaller(d, dd Declaration) bool {
-	if dd.Important {
-		return true
-	} else if d.Important {
-		return false
-	} else {
-		return d.Specificity.Less(dd.Specificity)
-	}
+	return d.Less(dd)
 }
 
 func compile(v string) (cs cascadia.SelectorGroup, err error) {
-	return cascadia.ParseGroup(v)
+	return cascadia.ParseGroupWithPseudoElements(v)
 }
 
 func FetchNodeRules(doc *html.Node, cssText string) (m map[*html.Node][]Rule, rVars map[string]string, err error) {
-	m = make(map[*html.Node][]Rule)
-	rVars = make(map[string]string)
-	s, err := Parse(cssText, false)
-	if err != nil {
-		return nil, nil, fmt.Errorf("parse: %w", err)
-	}
-	processRule := func(m map[*html.Node][]Rule, r Rule) (err error) {
-		for i, sel := range r.Selectors {
-			if sel.Val == ":root" {
-				for _, d := range r.Declarations {
-					rVars[d.Prop] = d.Val
-				}
-			}
-			csg, err := compile(sel.Val)
-			if err != nil {
-				log.Printf("cssSel compile %v: %v", sel.Val, err)
-				continue
-			}
-			var cs cascadia.Sel
-			if n := len(csg); n == 1 {
-				cs = csg[0]
-			} else {
-				log.Errorf("csg len %v", n)
-			}
-			for _, el := range cascadia.QueryAll(doc, cs) {
-				existing, ok := m[el]
-				if !ok {
-					existing = make([]Rule, 0, 3)
-				}
-				var sr Rule
-				sr = r
-				sr.Selectors = []Selector{r.Selectors[i]}
-				for j := range sr.Declarations {
-					sr.Declarations[j].Specificity[0] = cs.Specificity()[0]
-					sr.Declarations[j].Specificity[1] = cs.Specificity()[1]
-					sr.Declarations[j].Specificity[2] = cs.Specificity()[2]
-				}
-				existing = append(existing, sr)
-				m[el] = existing
-			}
-		}
-		return
-	}
-	for _, r := range s.Rules {
-		if err := processRule(m, r); err != nil {
-			return nil, nil, fmt.Errorf("process rule: %w", err)
-		}
-
-		// for media queries
-		if strings.HasPrefix(r.Prelude, "@media") {
-			p := strings.TrimPrefix(r.Prelude, "@media")
-			p = strings.TrimSpace(p)
-			yes, err := MatchQuery(p, MediaValues)
-			if err != nil {
-				log.Errorf("match query %v: %v", r.Prelude, err)
-			} else if !yes {
-				continue
-			}
-		}
-		for _, rr := range r.Rules {
-			if err := processRule(m, rr); err != nil {
-				return nil, nil, fmt.Errorf("process embedded rule: %w", err)
-			}
-		}
+	ri := NewRuleIndex()
+	if err := ri.Add(cssText); err != nil {
+		return nil, nil, err
 	}
+	m, rVars = ri.NodeRules(doc)
 	return
 }
 
@@ -196,6 +239,17 @@ type DomTree interface {
 type Map struct {
 	Declarations map[string]Declaration
 	DomTree      `json:"-"`
+
+	// PseudoElements holds the matched declarations of ::before,
+	// ::after and ::marker.
+	PseudoElements map[string]Map `json:"-"`
+}
+
+func (cs *Map) setPseudoElement(pe string, m Map) {
+	if cs.PseudoElements == nil {
+		cs.PseudoElements = make(map[string]Map)
+	}
+	cs.PseudoElements[pe] = m
 }
 
 func NewMap(n *html.Node) Map {
@@ -222,6 +276,7 @@ func NewMap(n *html.Node) Map {
 			}
 
 			for _, d := range decls {
+				d.Origin = StyleAttr
 				s.Declarations[d.Prop] = d
 			}
 		} else if a.Key == "height" || a.Key == "width" {
@@ -231,38 +286,95 @@ func NewMap(n *html.Node) Map {
 				v += "px"
 			}
 
-			s.Declarations[a.Key] = Declaration{
-				Prop: a.Key,
-				Val:  v,
-			}
+			s.hint(a.Key, v)
+		} else if a.Key == "nowrap" {
+			s.hint("white-space", "nowrap")
 		} else if a.Key == "bgcolor" {
-			s.Declarations["background-color"] = Declaration{
-				Prop: "background-color",
-				Val:  a.Val,
-			}
+			s.hint("background-color", a.Val)
 		}
 	}
+	if n.Type == html.ElementNode {
+		s.tableHints(n)
+	}
 
 	return s
 }
 
+// hint adds the presentational hint of an HTML attribute unless the
+// style attribute already declares prop.
+func (cs *Map) hint(prop, val string) {
+	if _, ok := cs.Declarations[prop]; ok {
+		return
+	}
+	cs.Declarations[prop] = Declaration{
+		Origin: Hint,
+		Prop:   prop,
+		Val:    val,
+	}
+}
+
+// tableHints maps the border attribute of tables and the cellpadding
+// attribute of the table to its cells.
+func (cs *Map) tableHints(n *html.Node) {
+	switch n.Data {
+	case "table":
+		if v, ok := hasAttr(n, "border"); ok {
+			w, ok := htmlUint(v)
+			if !ok {
+				w = 1
+			}
+			if w > 0 {
+				cs.hint("border-width", fmt.Sprintf("%vpx", w))
+				cs.hint("border-style", "outset")
+			}
+		}
+	case "td", "th":
+		t := n.Parent
+		for t != nil && t.Data != "table" {
+			t = t.Parent
+		}
+		if t == nil {
+			return
+		}
+		if p, ok := htmlUint(attr(t, "cellpadding")); ok {
+			cs.hint("padding", fmt.Sprintf("%vpx", p))
+		}
+	}
+}
+
+// htmlUint parses v with the rules for non-negative integers of HTML,
+// which ignore everything after the leading digits.
+func htmlUint(v string) (i int, ok bool) {
+	v = strings.TrimLeft(v, " \t\n\f\r")
+	v = strings.TrimPrefix(v, "+")
+	end := 0
+	for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+		end++
+	}
+	i, err := strconv.Atoi(v[:end])
+	return i, err == nil
+}
+
 func (cs Map) ApplyChildStyle(ccs Map, copyAll bool) (res Map) {
 	res.Declarations = make(map[string]Declaration)
 
 	for k, v := range cs.Declarations {
 		switch k {
 		// https://www.w3.org/TR/CSS21/propidx.html
-		case "azimuth", "border-collapse", "border-spacing", "caption-side", "color", "cursor", "direction", "elevation", "empty-cells", "font-family", "font-size", "font-style", "font-variant", "font-weight", "font", "letter-spacing", "line-height", "list-style-image", "list-style-position", "list-style-type", "list-style", "orphans", "pitch-range", "pitch", "quotes", "richness", "speak-header", "speak-numeral", "speak-punctuation", "speak", "speech-rate", "stress", "text-align", "text-indent", "text-transform", "visibility", "voice-family", "volume", "white-space", "widows", "word-spacing":
+		case "azimuth", "border-collapse", "border-spacing", "caption-side", "color", "cursor", "direction", "elevation", "empty-cells", "font-family", "font-size", "font-style", "font-variant", "font-weight", "font", "letter-spacing", "line-height", "list-style-image", "list-style-position", "list-style-type", "list-style", "orphans", "pitch-range", "pitch", "quotes", "richness", "speak-header", "speak-numeral", "speak-punctuation", "speak", "speech-rate", "stress", "text-align", "text-indent", "text-transform", "visibility", "voice-family", "volume", "white-space", "widows", "word-spacing", "overflow-wrap", "word-wrap", "word-break":
 		default:
 			if !copyAll {
 				continue
 			}
 		}
+		if !copyAll {
+			v.Origin = Inherited
+		}
 		res.Declarations[k] = v
 	}
 	// overwrite with higher prio child props
 	for k, d := range ccs.Declarations {
-		if d.Val == "inherit" {
+		if d.Val == "inherit" || k == "color" && strings.EqualFold(d.Val, "currentColor") {
 			continue
 		}
 		if exist, ok := res.Declarations[k]; ok && smaller(d, exist) {
@@ -275,11 +387,22 @@ func (cs Map) ApplyChildStyle(ccs Map, copyAll bool) (res Map) {
 }
 
 func (cs Map) Font() *draw.Font {
+	if dui == nil {
+		return nil
+	}
 	fn, ok := cs.FontFilename()
-	if !ok || dui == nil {
+	if wfn, wok := cs.webFontFilename(); wok {
+		if ok {
+			// glyphs missing in the web font
+			fn, ok = fallbackFont([]string{wfn, fn})
+		} else {
+			fn, ok = wfn, true
+		}
+	}
+	if !ok {
 		return nil
 	}
-	if runtime.GOOS == "plan9" && dui.Display.HiDPI() {
+	if runtime.GOOS == "plan9" && dui.Display.HiDPI() && !strings.HasPrefix(fn, fontCacheDir()) {
 		// TODO: proper hidpi handling
 		return dui.Font(nil)
 	}
@@ -298,25 +421,50 @@ func (cs Map) Font() *draw.Font {
 	return font
 }
 
-func (cs Map) preferedFontName(preferences []string) string {
-	avails := availableFontNames
-
-	if len(avails) == 0 {
-		return preferences[0]
+// preferedFontName returns the first of the preferences that fontsrv
+// provides. Case, spaces and hyphens are ignored.
+func (cs Map) preferedFontName(preferences []string) (string, bool) {
+	for _, pref := range preferences {
+		for _, avail := range availableFontNames {
+			if normFontName(pref) == normFontName(avail) {
+				return avail, true
+			}
+		}
 	}
+	return "", false
+}
 
-	for len(preferences) > 0 {
-		var pref string
-		pref, preferences = preferences[0], preferences[1:]
-
-		for _, avail := range avails {
-			if pref == strings.TrimSuffix(avail, "/") {
+// fontVariant returns the bold and/or italic variant of the font name
+// like fontsrv lists them, e.g. HelveticaNeue-BoldItalic, or name
+// itself if there is none.
+func fontVariant(name string, bold, italic bool) string {
+	if !bold && !italic {
+		return name
+	}
+	base := strings.TrimSuffix(name, "/")
+	var suffixes []string
+	switch {
+	case bold && italic:
+		suffixes = []string{"-BoldItalic", "-BoldOblique", "-Bold-Italic", " Bold Italic"}
+	case bold:
+		suffixes = []string{"-Bold", " Bold"}
+	case italic:
+		suffixes = []string{"-Italic", "-Oblique", " Italic"}
+	}
+	for _, suffix := range suffixes {
+		for _, avail := range availableFontNames {
+			if strings.TrimSuffix(avail, "/") == base+suffix {
 				return avail
 			}
 		}
 	}
-
-	return avails[0]
+	if bold && italic {
+		if v := fontVariant(name, true, false); v != name {
+			return v
+		}
+		return fontVariant(name, false, true)
+	}
+	return name
 }
 
 func matchClosestFontSize(desired float64, available []int) (closest int) {
@@ -355,104 +503,6 @@ func (cs Map) FontHeight() float64 {
 	return float64(cs.Font().Height) / float64(dui.Scale(1))
 }
 
-func (cs Map) Color() draw.Color {
-	if d, ok := cs.Declarations["color"]; ok {
-		if h, ok := colorHex(d.Val); ok {
-			c := draw.Color(h)
-			return c
-		}
-	}
-	return draw.Black
-}
-
-func colorHex(propVal string) (c draw.Color, ok bool) {
-	var r, g, b uint32
-	var x uint32
-	if strings.HasPrefix(propVal, "rgb") {
-		val := propVal[3:]
-		val = strings.TrimPrefix(val, "a")
-		val = strings.TrimPrefix(val, "(")
-		val = strings.TrimSuffix(val, ")")
-		vals := strings.Split(val, ",")
-		if len(vals) < 3 {
-			log.Errorf("vals=%+v", vals)
-			return
-		}
-		rr, err := strconv.ParseInt(vals[0], 10, 32)
-		if err != nil {
-			goto default_value
-		}
-		gg, err := strconv.ParseInt(vals[1], 10, 32)
-		if err != nil {
-			goto default_value
-		}
-		bb, err := strconv.ParseInt(vals[2], 10, 32)
-		if err != nil {
-			goto default_value
-		}
-		r = uint32(rr) * 256
-		g = uint32(gg) * 256
-		b = uint32(bb) * 256
-	} else if strings.HasPrefix(propVal, "#") {
-		hexColor := propVal[1:]
-
-		if len(hexColor) == 3 {
-			rr, err := strconv.ParseInt(hexColor[0:1], 16, 32)
-			if err != nil {
-				goto default_value
-			}
-			gg, err := strconv.ParseInt(hexColor[1:2], 16, 32)
-			if err != nil {
-				goto default_value
-			}
-			bb, err := strconv.ParseInt(hexColor[2:3], 16, 32)
-			if err != nil {
-				goto default_value
-			}
-			r = uint32(rr) * 256 * 0x11
-			g = uint32(gg) * 256 * 0x11
-			b = uint32(bb) * 256 * 0x11
-		} else if len(hexColor) == 6 {
-			rr, err := strconv.ParseInt(hexColor[0:2], 16, 32)
-			if err != nil {
-				goto default_value
-			}
-			gg, err := strconv.ParseInt(hexColor[2:4], 16, 32)
-			if err != nil {
-				goto default_value
-			}
-			bb, err := strconv.ParseInt(hexColor[4:6], 16, 32)
-			if err != nil {
-				goto default_value
-			}
-			r = uint32(rr) * 256
-			g = uint32(gg) * 256
-			b = uint32(bb) * 256
-		} else {
-			goto default_value
-		}
-	} else if propVal == "inherit" {
-		// TODO: handle properly
-		goto default_value
-	} else {
-		colorRGBA, ok := colornames.Map[propVal]
-		if !ok {
-			goto default_value
-		}
-		r, g, b, _ = colorRGBA.RGBA()
-	}
-
-	x = (r / 256) << 24
-	x = x | ((g / 256) << 16)
-	x = x | ((b / 256) << 8)
-	x = x | 0x000000ff
-
-	return draw.Color(uint32(x)), true
-default_value:
-	log.Printf("could not interpret %v", propVal)
-	return 0, false
-}
-
 func (cs Map) IsInline() bool {
 	propVal, ok := cs.Declarations["float"]
 	if ok && propVal.Val == "left" {
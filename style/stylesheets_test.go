@@ -237,6 +237,69 @@ func TestNewMapStyle(t *testing.T) {
 	}
 }
 
+func TestNewMapStyleSpecificity(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div id="x" style="display: grid; color: green">a</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm, err := FetchNodeMap(doc, `#x { display: block; color: red !important; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	div := grep(doc, "div")
+	res := nm[div].ApplyChildStyle(NewMap(div), true)
+	if v := res.Css("display"); v != "grid" {
+		t.Errorf("style attribute overridden by id selector: %v", v)
+	}
+	if v := res.Css("color"); v != "red" {
+		t.Errorf("!important overridden by style attribute: %v", v)
+	}
+}
+
+func TestNewMapTableHints(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`
+		<table border="4" cellpadding="10%">
+			<tr><td>a</td><td class="x">b</td><td class="y">c</td></tr>
+		</table>
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := NewMap(grep(doc, "table"))
+	if bs := tbl.Borders(); bs.Top.Width != 4 || bs.Left.Style != "outset" {
+		t.Errorf("%+v", bs)
+	}
+	nm, err := FetchNodeMap(doc, `td:first-child { padding-top: 1px } .x { padding-left: 5px } .y { padding: 2px }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tds []*html.Node
+	var f func(n *html.Node)
+	f = func(n *html.Node) {
+		if n.Data == "td" {
+			tds = append(tds, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	exp := []duit.Space{
+		{Top: 1, Right: 10, Bottom: 10, Left: 10},
+		{Top: 10, Right: 10, Bottom: 10, Left: 5},
+		{Top: 2, Right: 2, Bottom: 2, Left: 2},
+	}
+	for i, td := range tds {
+		res := nm[td].ApplyChildStyle(NewMap(td), true)
+		if s, err := res.Tlbr("padding"); err != nil || s != exp[i] {
+			t.Errorf("%v: %+v %v", i, s, err)
+		}
+	}
+	if _, ok := NewMap(grep(doc, "td")).Declarations["border-width"]; ok {
+		t.Errorf("cells must not inherit the table border")
+	}
+}
+
 func grep(nn *html.Node, tag string) *html.Node {
 	var f func(n *html.Node) *html.Node
 	f = func(n *html.Node) *html.Node {
@@ -265,6 +328,35 @@ func TestSmaller(t *testing.T) {
 	}
 }
 
+func TestDeclarationLess(t *testing.T) {
+	id := Declaration{Specificity: [3]int{1, 0, 0}}
+	tag := Declaration{Specificity: [3]int{0, 0, 1}}
+	attr := Declaration{Origin: StyleAttr}
+	important := Declaration{Specificity: [3]int{0, 0, 1}, Important: true}
+	importantAttr := Declaration{Origin: StyleAttr, Important: true}
+	inherited := Declaration{Origin: Inherited, Specificity: [3]int{1, 0, 0}, Important: true}
+	tests := []struct {
+		d, dd Declaration
+		less  bool
+	}{
+		{tag, id, true},
+		{id, tag, false},
+		{id, attr, true},
+		{attr, id, false},
+		{attr, important, true},
+		{important, attr, false},
+		{important, importantAttr, true},
+		{inherited, tag, true},
+		{tag, inherited, false},
+		{inherited, inherited, false},
+	}
+	for i, tt := range tests {
+		if less := tt.d.Less(tt.dd); less != tt.less {
+			t.Errorf("%v: %+v < %+v: %v", i, tt.d, tt.dd, less)
+		}
+	}
+}
+
 func TestApplyChildStyleInherit(t *testing.T) {
 	parent := Map{
 		Declarations: make(map[string]Declaration),
@@ -351,6 +443,32 @@ func TestApplyChildStyleInherit4(t *testing.T) {
 	}
 }
 
+func TestApplyChildStyleInherited(t *testing.T) {
+	parent := Map{
+		Declarations: map[string]Declaration{
+			"color":      {Prop: "color", Val: "red", Specificity: [3]int{1, 0, 0}},
+			"font-style": {Prop: "font-style", Val: "italic", Important: true},
+		},
+	}
+	child := Map{
+		Declarations: map[string]Declaration{
+			"color":      {Prop: "color", Val: "blue", Specificity: [3]int{0, 0, 1}},
+			"font-style": {Prop: "font-style", Val: "normal"},
+		},
+	}
+	// inherited values are only used without a value of the child
+	res := parent.ApplyChildStyle(child, false)
+	if v := res.Css("color"); v != "blue" {
+		t.Errorf("%v", v)
+	}
+	if v := res.Css("font-style"); v != "normal" {
+		t.Errorf("%v", v)
+	}
+	if res := parent.ApplyChildStyle(Map{}, false); res.Css("color") != "red" {
+		t.Errorf("%v", res.Css("color"))
+	}
+}
+
 func TestCalc(t *testing.T) {
 	tests := map[string]float64{
 		"calc(1px+2px)":         3.0,
@@ -0,0 +1,219 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FontWeight returns the numeric font-weight where 400 is normal and
+// 700 bold.
+func (cs Map) FontWeight() int {
+	v := cs.Css("font-weight")
+	if v == "" {
+		for _, t := range cs.fontShorthand() {
+			if w, ok := cs.fontWeight(t); ok {
+				return w
+			}
+		}
+		return 400
+	}
+	if w, ok := cs.fontWeight(v); ok {
+		return w
+	}
+	return 400
+}
+
+func (cs Map) fontWeight(v string) (w int, ok bool) {
+	switch v {
+	case "normal":
+		return 400, true
+	case "bold":
+		return 700, true
+	case "bolder", "lighter":
+		pw := 400
+		if cs.DomTree != nil {
+			if p, ok := cs.DomTree.Parent(); ok {
+				pw = p.Style().FontWeight()
+			}
+		}
+		if v == "bolder" {
+			if pw < 600 {
+				return 700, true
+			}
+			return 900, true
+		}
+		if pw > 500 {
+			return 400, true
+		}
+		return 100, true
+	}
+	if w, err := strconv.Atoi(v); err == nil && 1 <= w && w <= 1000 {
+		return w, true
+	}
+	return 0, false
+}
+
+// FontStyle returns normal, italic or oblique.
+func (cs Map) FontStyle() string {
+	v := cs.Css("font-style")
+	if v == "" {
+		for _, t := range cs.fontShorthand() {
+			if t == "italic" || t == "oblique" {
+				return t
+			}
+		}
+		return "normal"
+	}
+	if strings.HasPrefix(v, "oblique") {
+		return "oblique"
+	}
+	return v
+}
+
+// IsBold is true for a font-weight of at least 600.
+func (cs Map) IsBold() bool {
+	return cs.FontWeight() >= 600
+}
+
+// IsItalic is true for italic and oblique fonts.
+func (cs Map) IsItalic() bool {
+	return cs.FontStyle() != "normal"
+}
+
+// fontShorthand returns the tokens of font before the font size.
+func (cs Map) fontShorthand() (ts []string) {
+	for _, t := range strings.Fields(cs.Css("font")) {
+		if t != "" && (t[0] >= '0' && t[0] <= '9' || t[0] == '.') && !isFontWeightNum(t) {
+			break
+		}
+		ts = append(ts, t)
+	}
+	return
+}
+
+func isFontWeightNum(t string) bool {
+	w, err := strconv.Atoi(t)
+	return err == nil && w%100 == 0 && 100 <= w && w <= 900
+}
+
+// TextDecoration returns the lines of text-decoration of the element
+// and its ancestors.
+func (cs Map) TextDecoration() (underline, overline, lineThrough bool) {
+	m := cs
+	for {
+		v := m.Css("text-decoration-line")
+		if v == "" {
+			v = m.Css("text-decoration")
+		}
+		for _, t := range strings.Fields(v) {
+			switch t {
+			case "underline":
+				underline = true
+			case "overline":
+				overline = true
+			case "line-through":
+				lineThrough = true
+			}
+		}
+		if m.DomTree == nil {
+			return
+		}
+		p, ok := m.DomTree.Parent()
+		if !ok {
+			return
+		}
+		m = p.Style()
+	}
+}
+
+// TextTransform applies text-transform to s.
+func (cs Map) TextTransform(s string) string {
+	switch cs.Css("text-transform") {
+	case "uppercase":
+		return strings.ToUpper(s)
+	case "lowercase":
+		return strings.ToLower(s)
+	case "capitalize":
+		rs := []rune(s)
+		for i, r := range rs {
+			if i == 0 || unicode.IsSpace(rs[i-1]) || rs[i-1] == '-' {
+				rs[i] = unicode.ToTitle(r)
+			}
+		}
+		return string(rs)
+	}
+	return s
+}
+
+// LetterSpacing in px, 0 for normal.
+func (cs Map) LetterSpacing() int {
+	return cs.spacing("letter-spacing")
+}
+
+// WordSpacing in px, 0 for normal.
+func (cs Map) WordSpacing() int {
+	return cs.spacing("word-spacing")
+}
+
+func (cs Map) spacing(prop string) int {
+	v := cs.Css(prop)
+	if v == "" || v == "normal" {
+		return 0
+	}
+	l, err := cs.CssPx(prop)
+	if err != nil {
+		return 0
+	}
+	return l
+}
+
+// LineHeight returns line-height as factor of the font height or in
+// px. ok is false for normal.
+func (cs Map) LineHeight() (factor float64, px int, ok bool) {
+	v := cs.Css("line-height")
+	if v == "" || v == "normal" {
+		return
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f, 0, f > 0
+	}
+	if strings.HasSuffix(v, "%") {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		return f / 100, 0, err == nil && f > 0
+	}
+	l, err := cs.CssPx("line-height")
+	return 0, l, err == nil && l > 0
+}
+
+// WhiteSpace returns whether white space collapses, whether newlines
+// are preserved and whether lines wrap according to white-space.
+func (cs Map) WhiteSpace() (collapse, newlines, wrap bool) {
+	switch cs.Css("white-space") {
+	case "nowrap":
+		return true, false, false
+	case "pre":
+		return false, true, false
+	case "pre-wrap", "break-spaces":
+		return false, true, true
+	case "pre-line":
+		return true, true, true
+	}
+	return true, false, true
+}
+
+// BreakWord returns whether words that don't fit on a line may be
+// broken at arbitrary points (overflow-wrap or word-break).
+func (cs Map) BreakWord() bool {
+	for _, p := range []string{"overflow-wrap", "word-wrap"} {
+		switch cs.Css(p) {
+		case "break-word", "anywhere":
+			return true
+		}
+	}
+	switch cs.Css("word-break") {
+	case "break-all", "break-word":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package style
+
+import (
+	"testing"
+)
+
+func TestFontWeightStyle(t *testing.T) {
+	tests := []struct {
+		cs     Map
+		weight int
+		style  string
+	}{
+		{decls(), 400, "normal"},
+		{decls("font-weight", "bold", "font-style", "italic"), 700, "italic"},
+		{decls("font-weight", "300", "font-style", "oblique 10deg"), 300, "oblique"},
+		{decls("font", "italic 600 12px/1.5 Georgia, serif"), 600, "italic"},
+		{decls("font", "bold 12px serif", "font-weight", "normal"), 400, "normal"},
+		{decls("font-weight", "bolder"), 700, "normal"},
+	}
+	for i, tt := range tests {
+		if w := tt.cs.FontWeight(); w != tt.weight {
+			t.Errorf("%v: weight %v", i, w)
+		}
+		if s := tt.cs.FontStyle(); s != tt.style {
+			t.Errorf("%v: style %v", i, s)
+		}
+	}
+	if !decls("font-weight", "700").IsBold() || decls("font-weight", "500").IsBold() {
+		t.Errorf("IsBold")
+	}
+}
+
+func TestFontVariant(t *testing.T) {
+	defer func(names []string) { availableFontNames = names }(availableFontNames)
+	availableFontNames = []string{"HelveticaNeue/", "HelveticaNeue-Bold/", "HelveticaNeue-Italic/", "DejaVuSans/", "DejaVuSans-Oblique/"}
+	tests := []struct {
+		name         string
+		bold, italic bool
+		exp          string
+	}{
+		{"HelveticaNeue/", false, false, "HelveticaNeue/"},
+		{"HelveticaNeue/", true, false, "HelveticaNeue-Bold/"},
+		{"HelveticaNeue/", false, true, "HelveticaNeue-Italic/"},
+		{"HelveticaNeue/", true, true, "HelveticaNeue-Bold/"},
+		{"DejaVuSans/", true, true, "DejaVuSans-Oblique/"},
+		{"DejaVuSans/", true, false, "DejaVuSans/"},
+	}
+	for _, tt := range tests {
+		if v := fontVariant(tt.name, tt.bold, tt.italic); v != tt.exp {
+			t.Errorf("%+v: %v", tt, v)
+		}
+	}
+}
+
+func TestTextTransform(t *testing.T) {
+	tests := map[string]string{
+		"uppercase":  "HELLO WORLD-WIDE",
+		"lowercase":  "hello world-wide",
+		"capitalize": "Hello World-Wide",
+		"none":       "hello World-wide",
+	}
+	for tr, exp := range tests {
+		if s := decls("text-transform", tr).TextTransform("hello World-wide"); s != exp {
+			t.Errorf("%v: %v", tr, s)
+		}
+	}
+}
+
+func TestTextDecoration(t *testing.T) {
+	u, o, lt := decls("text-decoration", "underline line-through red").TextDecoration()
+	if !u || o || !lt {
+		t.Errorf("%v %v %v", u, o, lt)
+	}
+	u, o, lt = decls("text-decoration-line", "overline", "text-decoration", "underline").TextDecoration()
+	if u || !o || lt {
+		t.Errorf("%v %v %v", u, o, lt)
+	}
+}
+
+func TestTextSpacing(t *testing.T) {
+	cs := decls("letter-spacing", "2px", "word-spacing", "normal", "line-height", "1.5")
+	if cs.LetterSpacing() != 2 || cs.WordSpacing() != 0 {
+		t.Errorf("%v %v", cs.LetterSpacing(), cs.WordSpacing())
+	}
+	if f, px, ok := cs.LineHeight(); f != 1.5 || px != 0 || !ok {
+		t.Errorf("%v %v %v", f, px, ok)
+	}
+	if f, px, ok := decls("line-height", "20px").LineHeight(); f != 0 || px != 20 || !ok {
+		t.Errorf("%v %v %v", f, px, ok)
+	}
+	if f, _, ok := decls("line-height", "150%").LineHeight(); f != 1.5 || !ok {
+		t.Errorf("%v %v", f, ok)
+	}
+	if _, _, ok := decls("line-height", "normal").LineHeight(); ok {
+		t.Errorf("normal")
+	}
+}
+
+func TestWhiteSpace(t *testing.T) {
+	tests := map[string][3]bool{
+		"":         {true, false, true},
+		"nowrap":   {true, false, false},
+		"pre":      {false, true, false},
+		"pre-wrap": {false, true, true},
+		"pre-line": {true, true, true},
+	}
+	for v, exp := range tests {
+		c, nl, w := decls("white-space", v).WhiteSpace()
+		if [3]bool{c, nl, w} != exp {
+			t.Errorf("%v: %v %v %v", v, c, nl, w)
+		}
+	}
+	if decls().BreakWord() || !decls("overflow-wrap", "anywhere").BreakWord() || !decls("word-break", "break-all").BreakWord() {
+		t.Errorf("BreakWord")
+	}
+}